@@ -26,6 +26,7 @@ const (
 	PAM_SelectAuthenticationMode_FullMethodName = "/authd.PAM/SelectAuthenticationMode"
 	PAM_IsAuthenticated_FullMethodName          = "/authd.PAM/IsAuthenticated"
 	PAM_EndSession_FullMethodName               = "/authd.PAM/EndSession"
+	PAM_ResumeSession_FullMethodName            = "/authd.PAM/ResumeSession"
 	PAM_SetDefaultBrokerForUser_FullMethodName  = "/authd.PAM/SetDefaultBrokerForUser"
 )
 
@@ -40,6 +41,7 @@ type PAMClient interface {
 	SelectAuthenticationMode(ctx context.Context, in *SAMRequest, opts ...grpc.CallOption) (*SAMResponse, error)
 	IsAuthenticated(ctx context.Context, in *IARequest, opts ...grpc.CallOption) (*IAResponse, error)
 	EndSession(ctx context.Context, in *ESRequest, opts ...grpc.CallOption) (*Empty, error)
+	ResumeSession(ctx context.Context, in *RSRequest, opts ...grpc.CallOption) (*RSResponse, error)
 	SetDefaultBrokerForUser(ctx context.Context, in *SDBFURequest, opts ...grpc.CallOption) (*Empty, error)
 }
 
@@ -121,6 +123,16 @@ func (c *pAMClient) EndSession(ctx context.Context, in *ESRequest, opts ...grpc.
 	return out, nil
 }
 
+func (c *pAMClient) ResumeSession(ctx context.Context, in *RSRequest, opts ...grpc.CallOption) (*RSResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(RSResponse)
+	err := c.cc.Invoke(ctx, PAM_ResumeSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *pAMClient) SetDefaultBrokerForUser(ctx context.Context, in *SDBFURequest, opts ...grpc.CallOption) (*Empty, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(Empty)
@@ -142,6 +154,7 @@ type PAMServer interface {
 	SelectAuthenticationMode(context.Context, *SAMRequest) (*SAMResponse, error)
 	IsAuthenticated(context.Context, *IARequest) (*IAResponse, error)
 	EndSession(context.Context, *ESRequest) (*Empty, error)
+	ResumeSession(context.Context, *RSRequest) (*RSResponse, error)
 	SetDefaultBrokerForUser(context.Context, *SDBFURequest) (*Empty, error)
 	mustEmbedUnimplementedPAMServer()
 }
@@ -174,6 +187,9 @@ func (UnimplementedPAMServer) IsAuthenticated(context.Context, *IARequest) (*IAR
 func (UnimplementedPAMServer) EndSession(context.Context, *ESRequest) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method EndSession not implemented")
 }
+func (UnimplementedPAMServer) ResumeSession(context.Context, *RSRequest) (*RSResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResumeSession not implemented")
+}
 func (UnimplementedPAMServer) SetDefaultBrokerForUser(context.Context, *SDBFURequest) (*Empty, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method SetDefaultBrokerForUser not implemented")
 }
@@ -324,6 +340,24 @@ func _PAM_EndSession_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _PAM_ResumeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RSRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(PAMServer).ResumeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: PAM_ResumeSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(PAMServer).ResumeSession(ctx, req.(*RSRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _PAM_SetDefaultBrokerForUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(SDBFURequest)
 	if err := dec(in); err != nil {
@@ -377,6 +411,10 @@ var PAM_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "EndSession",
 			Handler:    _PAM_EndSession_Handler,
 		},
+		{
+			MethodName: "ResumeSession",
+			Handler:    _PAM_ResumeSession_Handler,
+		},
 		{
 			MethodName: "SetDefaultBrokerForUser",
 			Handler:    _PAM_SetDefaultBrokerForUser_Handler,
@@ -387,14 +425,21 @@ var PAM_ServiceDesc = grpc.ServiceDesc{
 }
 
 const (
-	NSS_GetPasswdByName_FullMethodName  = "/authd.NSS/GetPasswdByName"
-	NSS_GetPasswdByUID_FullMethodName   = "/authd.NSS/GetPasswdByUID"
-	NSS_GetPasswdEntries_FullMethodName = "/authd.NSS/GetPasswdEntries"
-	NSS_GetGroupByName_FullMethodName   = "/authd.NSS/GetGroupByName"
-	NSS_GetGroupByGID_FullMethodName    = "/authd.NSS/GetGroupByGID"
-	NSS_GetGroupEntries_FullMethodName  = "/authd.NSS/GetGroupEntries"
-	NSS_GetShadowByName_FullMethodName  = "/authd.NSS/GetShadowByName"
-	NSS_GetShadowEntries_FullMethodName = "/authd.NSS/GetShadowEntries"
+	NSS_GetPasswdByName_FullMethodName        = "/authd.NSS/GetPasswdByName"
+	NSS_GetPasswdByUID_FullMethodName         = "/authd.NSS/GetPasswdByUID"
+	NSS_GetPasswdEntries_FullMethodName       = "/authd.NSS/GetPasswdEntries"
+	NSS_GetPasswdEntriesStream_FullMethodName = "/authd.NSS/GetPasswdEntriesStream"
+	NSS_GetGroupByName_FullMethodName         = "/authd.NSS/GetGroupByName"
+	NSS_GetGroupByGID_FullMethodName          = "/authd.NSS/GetGroupByGID"
+	NSS_GetGroupEntries_FullMethodName        = "/authd.NSS/GetGroupEntries"
+	NSS_GetGroupEntriesStream_FullMethodName  = "/authd.NSS/GetGroupEntriesStream"
+	NSS_StreamGroupMembers_FullMethodName     = "/authd.NSS/StreamGroupMembers"
+	NSS_GetGroupsByUID_FullMethodName         = "/authd.NSS/GetGroupsByUID"
+	NSS_GetShadowByName_FullMethodName        = "/authd.NSS/GetShadowByName"
+	NSS_GetShadowEntries_FullMethodName       = "/authd.NSS/GetShadowEntries"
+	NSS_GetShadowEntriesStream_FullMethodName = "/authd.NSS/GetShadowEntriesStream"
+	NSS_GetGShadowByName_FullMethodName       = "/authd.NSS/GetGShadowByName"
+	NSS_GetGShadowEntries_FullMethodName      = "/authd.NSS/GetGShadowEntries"
 )
 
 // NSSClient is the client API for NSS service.
@@ -404,11 +449,18 @@ type NSSClient interface {
 	GetPasswdByName(ctx context.Context, in *GetPasswdByNameRequest, opts ...grpc.CallOption) (*PasswdEntry, error)
 	GetPasswdByUID(ctx context.Context, in *GetByIDRequest, opts ...grpc.CallOption) (*PasswdEntry, error)
 	GetPasswdEntries(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*PasswdEntries, error)
+	GetPasswdEntriesStream(ctx context.Context, in *PageRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PasswdEntries], error)
 	GetGroupByName(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (*GroupEntry, error)
 	GetGroupByGID(ctx context.Context, in *GetByIDRequest, opts ...grpc.CallOption) (*GroupEntry, error)
 	GetGroupEntries(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GroupEntries, error)
+	GetGroupEntriesStream(ctx context.Context, in *PageRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GroupEntries], error)
+	StreamGroupMembers(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GroupMembers], error)
+	GetGroupsByUID(ctx context.Context, in *GetByIDRequest, opts ...grpc.CallOption) (*GroupEntries, error)
 	GetShadowByName(ctx context.Context, in *GetShadowByNameRequest, opts ...grpc.CallOption) (*ShadowEntry, error)
 	GetShadowEntries(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ShadowEntries, error)
+	GetShadowEntriesStream(ctx context.Context, in *PageRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ShadowEntries], error)
+	GetGShadowByName(ctx context.Context, in *GetGShadowByNameRequest, opts ...grpc.CallOption) (*GShadowEntry, error)
+	GetGShadowEntries(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GShadowEntries, error)
 }
 
 type nSSClient struct {
@@ -449,6 +501,25 @@ func (c *nSSClient) GetPasswdEntries(ctx context.Context, in *Empty, opts ...grp
 	return out, nil
 }
 
+func (c *nSSClient) GetPasswdEntriesStream(ctx context.Context, in *PageRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[PasswdEntries], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NSS_ServiceDesc.Streams[0], NSS_GetPasswdEntriesStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PageRequest, PasswdEntries]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NSS_GetPasswdEntriesStreamClient = grpc.ServerStreamingClient[PasswdEntries]
+
 func (c *nSSClient) GetGroupByName(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (*GroupEntry, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(GroupEntry)
@@ -479,6 +550,54 @@ func (c *nSSClient) GetGroupEntries(ctx context.Context, in *Empty, opts ...grpc
 	return out, nil
 }
 
+func (c *nSSClient) GetGroupEntriesStream(ctx context.Context, in *PageRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GroupEntries], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NSS_ServiceDesc.Streams[1], NSS_GetGroupEntriesStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PageRequest, GroupEntries]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NSS_GetGroupEntriesStreamClient = grpc.ServerStreamingClient[GroupEntries]
+
+func (c *nSSClient) StreamGroupMembers(ctx context.Context, in *GetGroupByNameRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[GroupMembers], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NSS_ServiceDesc.Streams[2], NSS_StreamGroupMembers_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetGroupByNameRequest, GroupMembers]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NSS_StreamGroupMembersClient = grpc.ServerStreamingClient[GroupMembers]
+
+func (c *nSSClient) GetGroupsByUID(ctx context.Context, in *GetByIDRequest, opts ...grpc.CallOption) (*GroupEntries, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GroupEntries)
+	err := c.cc.Invoke(ctx, NSS_GetGroupsByUID_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *nSSClient) GetShadowByName(ctx context.Context, in *GetShadowByNameRequest, opts ...grpc.CallOption) (*ShadowEntry, error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	out := new(ShadowEntry)
@@ -499,6 +618,45 @@ func (c *nSSClient) GetShadowEntries(ctx context.Context, in *Empty, opts ...grp
 	return out, nil
 }
 
+func (c *nSSClient) GetShadowEntriesStream(ctx context.Context, in *PageRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ShadowEntries], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NSS_ServiceDesc.Streams[3], NSS_GetShadowEntriesStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[PageRequest, ShadowEntries]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NSS_GetShadowEntriesStreamClient = grpc.ServerStreamingClient[ShadowEntries]
+
+func (c *nSSClient) GetGShadowByName(ctx context.Context, in *GetGShadowByNameRequest, opts ...grpc.CallOption) (*GShadowEntry, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GShadowEntry)
+	err := c.cc.Invoke(ctx, NSS_GetGShadowByName_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *nSSClient) GetGShadowEntries(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GShadowEntries, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GShadowEntries)
+	err := c.cc.Invoke(ctx, NSS_GetGShadowEntries_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // NSSServer is the server API for NSS service.
 // All implementations must embed UnimplementedNSSServer
 // for forward compatibility.
@@ -506,11 +664,18 @@ type NSSServer interface {
 	GetPasswdByName(context.Context, *GetPasswdByNameRequest) (*PasswdEntry, error)
 	GetPasswdByUID(context.Context, *GetByIDRequest) (*PasswdEntry, error)
 	GetPasswdEntries(context.Context, *Empty) (*PasswdEntries, error)
+	GetPasswdEntriesStream(*PageRequest, grpc.ServerStreamingServer[PasswdEntries]) error
 	GetGroupByName(context.Context, *GetGroupByNameRequest) (*GroupEntry, error)
 	GetGroupByGID(context.Context, *GetByIDRequest) (*GroupEntry, error)
 	GetGroupEntries(context.Context, *Empty) (*GroupEntries, error)
+	GetGroupEntriesStream(*PageRequest, grpc.ServerStreamingServer[GroupEntries]) error
+	StreamGroupMembers(*GetGroupByNameRequest, grpc.ServerStreamingServer[GroupMembers]) error
+	GetGroupsByUID(context.Context, *GetByIDRequest) (*GroupEntries, error)
 	GetShadowByName(context.Context, *GetShadowByNameRequest) (*ShadowEntry, error)
 	GetShadowEntries(context.Context, *Empty) (*ShadowEntries, error)
+	GetShadowEntriesStream(*PageRequest, grpc.ServerStreamingServer[ShadowEntries]) error
+	GetGShadowByName(context.Context, *GetGShadowByNameRequest) (*GShadowEntry, error)
+	GetGShadowEntries(context.Context, *Empty) (*GShadowEntries, error)
 	mustEmbedUnimplementedNSSServer()
 }
 
@@ -530,6 +695,9 @@ func (UnimplementedNSSServer) GetPasswdByUID(context.Context, *GetByIDRequest) (
 func (UnimplementedNSSServer) GetPasswdEntries(context.Context, *Empty) (*PasswdEntries, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetPasswdEntries not implemented")
 }
+func (UnimplementedNSSServer) GetPasswdEntriesStream(*PageRequest, grpc.ServerStreamingServer[PasswdEntries]) error {
+	return status.Errorf(codes.Unimplemented, "method GetPasswdEntriesStream not implemented")
+}
 func (UnimplementedNSSServer) GetGroupByName(context.Context, *GetGroupByNameRequest) (*GroupEntry, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetGroupByName not implemented")
 }
@@ -539,12 +707,30 @@ func (UnimplementedNSSServer) GetGroupByGID(context.Context, *GetByIDRequest) (*
 func (UnimplementedNSSServer) GetGroupEntries(context.Context, *Empty) (*GroupEntries, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetGroupEntries not implemented")
 }
+func (UnimplementedNSSServer) GetGroupEntriesStream(*PageRequest, grpc.ServerStreamingServer[GroupEntries]) error {
+	return status.Errorf(codes.Unimplemented, "method GetGroupEntriesStream not implemented")
+}
+func (UnimplementedNSSServer) StreamGroupMembers(*GetGroupByNameRequest, grpc.ServerStreamingServer[GroupMembers]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamGroupMembers not implemented")
+}
+func (UnimplementedNSSServer) GetGroupsByUID(context.Context, *GetByIDRequest) (*GroupEntries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGroupsByUID not implemented")
+}
 func (UnimplementedNSSServer) GetShadowByName(context.Context, *GetShadowByNameRequest) (*ShadowEntry, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetShadowByName not implemented")
 }
 func (UnimplementedNSSServer) GetShadowEntries(context.Context, *Empty) (*ShadowEntries, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetShadowEntries not implemented")
 }
+func (UnimplementedNSSServer) GetShadowEntriesStream(*PageRequest, grpc.ServerStreamingServer[ShadowEntries]) error {
+	return status.Errorf(codes.Unimplemented, "method GetShadowEntriesStream not implemented")
+}
+func (UnimplementedNSSServer) GetGShadowByName(context.Context, *GetGShadowByNameRequest) (*GShadowEntry, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGShadowByName not implemented")
+}
+func (UnimplementedNSSServer) GetGShadowEntries(context.Context, *Empty) (*GShadowEntries, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetGShadowEntries not implemented")
+}
 func (UnimplementedNSSServer) mustEmbedUnimplementedNSSServer() {}
 func (UnimplementedNSSServer) testEmbeddedByValue()             {}
 
@@ -620,6 +806,17 @@ func _NSS_GetPasswdEntries_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NSS_GetPasswdEntriesStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PageRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NSSServer).GetPasswdEntriesStream(m, &grpc.GenericServerStream[PageRequest, PasswdEntries]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NSS_GetPasswdEntriesStreamServer = grpc.ServerStreamingServer[PasswdEntries]
+
 func _NSS_GetGroupByName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetGroupByNameRequest)
 	if err := dec(in); err != nil {
@@ -674,6 +871,46 @@ func _NSS_GetGroupEntries_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NSS_GetGroupEntriesStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PageRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NSSServer).GetGroupEntriesStream(m, &grpc.GenericServerStream[PageRequest, GroupEntries]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NSS_GetGroupEntriesStreamServer = grpc.ServerStreamingServer[GroupEntries]
+
+func _NSS_StreamGroupMembers_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetGroupByNameRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NSSServer).StreamGroupMembers(m, &grpc.GenericServerStream[GetGroupByNameRequest, GroupMembers]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NSS_StreamGroupMembersServer = grpc.ServerStreamingServer[GroupMembers]
+
+func _NSS_GetGroupsByUID_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetByIDRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NSSServer).GetGroupsByUID(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NSS_GetGroupsByUID_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NSSServer).GetGroupsByUID(ctx, req.(*GetByIDRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _NSS_GetShadowByName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetShadowByNameRequest)
 	if err := dec(in); err != nil {
@@ -710,6 +947,53 @@ func _NSS_GetShadowEntries_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _NSS_GetShadowEntriesStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(PageRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(NSSServer).GetShadowEntriesStream(m, &grpc.GenericServerStream[PageRequest, ShadowEntries]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NSS_GetShadowEntriesStreamServer = grpc.ServerStreamingServer[ShadowEntries]
+
+func _NSS_GetGShadowByName_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetGShadowByNameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NSSServer).GetGShadowByName(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NSS_GetGShadowByName_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NSSServer).GetGShadowByName(ctx, req.(*GetGShadowByNameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _NSS_GetGShadowEntries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(NSSServer).GetGShadowEntries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: NSS_GetGShadowEntries_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(NSSServer).GetGShadowEntries(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // NSS_ServiceDesc is the grpc.ServiceDesc for NSS service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -741,6 +1025,10 @@ var NSS_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetGroupEntries",
 			Handler:    _NSS_GetGroupEntries_Handler,
 		},
+		{
+			MethodName: "GetGroupsByUID",
+			Handler:    _NSS_GetGroupsByUID_Handler,
+		},
 		{
 			MethodName: "GetShadowByName",
 			Handler:    _NSS_GetShadowByName_Handler,
@@ -749,7 +1037,36 @@ var NSS_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetShadowEntries",
 			Handler:    _NSS_GetShadowEntries_Handler,
 		},
+		{
+			MethodName: "GetGShadowByName",
+			Handler:    _NSS_GetGShadowByName_Handler,
+		},
+		{
+			MethodName: "GetGShadowEntries",
+			Handler:    _NSS_GetGShadowEntries_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetPasswdEntriesStream",
+			Handler:       _NSS_GetPasswdEntriesStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetGroupEntriesStream",
+			Handler:       _NSS_GetGroupEntriesStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamGroupMembers",
+			Handler:       _NSS_StreamGroupMembers_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "GetShadowEntriesStream",
+			Handler:       _NSS_GetShadowEntriesStream_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "authd.proto",
 }
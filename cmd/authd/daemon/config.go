@@ -85,6 +85,18 @@ func installConfigFlag(cmd *cobra.Command) *string {
 	return cmd.PersistentFlags().StringP("config", "c", "" /*i18n.G(*/, "use a specific configuration file") /*)*/
 }
 
+// setLogFormat sets the output format of the logs to the one named format, if it's not the current one already.
+func setLogFormat(format string) error {
+	f, err := log.ParseFormat(format)
+	if err != nil {
+		return err
+	}
+	if f != log.GetFormat() {
+		log.SetFormat(f)
+	}
+	return nil
+}
+
 // SetVerboseMode change ErrorFormat and logs between very, middly and non verbose.
 func setVerboseMode(level int) {
 	switch level {
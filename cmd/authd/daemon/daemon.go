@@ -3,16 +3,22 @@ package daemon
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"runtime"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/ubuntu/authd/internal/brokers"
 	"github.com/ubuntu/authd/internal/consts"
 	"github.com/ubuntu/authd/internal/daemon"
 	"github.com/ubuntu/authd/internal/log"
 	"github.com/ubuntu/authd/internal/services"
+	"github.com/ubuntu/authd/internal/services/nss"
 	"github.com/ubuntu/authd/internal/users"
+	"github.com/ubuntu/authd/internal/users/cache"
 	"github.com/ubuntu/decorate"
 )
 
@@ -25,7 +31,8 @@ type App struct {
 	viper   *viper.Viper
 	config  daemonConfig
 
-	daemon *daemon.Daemon
+	daemon  *daemon.Daemon
+	manager *services.Manager
 
 	ready chan struct{}
 }
@@ -37,12 +44,135 @@ type systemPaths struct {
 	Socket      string
 }
 
+// sessionsConfig defines the configuration of the authentication sessions.
+type sessionsConfig struct {
+	// TTL is the maximum lifetime of the sessions, after which they are ended. 0 means that they never expire.
+	TTL time.Duration
+}
+
+// nssConfig defines the configuration of the NSS service.
+type nssConfig struct {
+	// AuthoritativeIDMin and AuthoritativeIDMax restrict the UIDs and GIDs authd answers for, unless they are both 0.
+	AuthoritativeIDMin uint32 `mapstructure:"authoritative_id_min"`
+	AuthoritativeIDMax uint32 `mapstructure:"authoritative_id_max"`
+	// UIDOffset and GIDOffset shift the UIDs and GIDs returned by the NSS service.
+	UIDOffset int64 `mapstructure:"uid_offset"`
+	GIDOffset int64 `mapstructure:"gid_offset"`
+	// SnapshotEnumeration serves the enumerations of all groups from a snapshot taken at the start of each call.
+	SnapshotEnumeration bool `mapstructure:"snapshot_enumeration"`
+	// FlattenedGroupMembers adds the members of the nested groups to the members of the groups.
+	FlattenedGroupMembers bool `mapstructure:"flattened_group_members"`
+	// InlineGroupMembersLimit is the maximum number of members returned in a group entry. 0 keeps the default limit.
+	InlineGroupMembersLimit int `mapstructure:"inline_group_members_limit"`
+}
+
+// options returns the options of the NSS service matching the configuration.
+func (c nssConfig) options() (opts []nss.Option, err error) {
+	if c.AuthoritativeIDMin != 0 || c.AuthoritativeIDMax != 0 {
+		if c.AuthoritativeIDMin > c.AuthoritativeIDMax {
+			return nil, fmt.Errorf("invalid NSS authoritative ID range: %d > %d", c.AuthoritativeIDMin, c.AuthoritativeIDMax)
+		}
+		opts = append(opts, nss.WithAuthoritativeRange(c.AuthoritativeIDMin, c.AuthoritativeIDMax))
+	}
+	if c.UIDOffset != 0 || c.GIDOffset != 0 {
+		opts = append(opts, nss.WithIDOffset(c.UIDOffset, c.GIDOffset))
+	}
+	if c.SnapshotEnumeration {
+		opts = append(opts, nss.WithSnapshotEnumeration())
+	}
+	if c.FlattenedGroupMembers {
+		opts = append(opts, nss.WithFlattenedGroupMembers())
+	}
+	if c.InlineGroupMembersLimit < 0 {
+		return nil, fmt.Errorf("invalid NSS inline group members limit: %d", c.InlineGroupMembersLimit)
+	}
+	if c.InlineGroupMembersLimit != 0 {
+		opts = append(opts, nss.WithInlineGroupMembersLimit(c.InlineGroupMembersLimit))
+	}
+	return opts, nil
+}
+
+// cacheConfig defines the configuration of the cache of users and groups.
+type cacheConfig struct {
+	// CaseInsensitiveNames makes the user names case-insensitive.
+	CaseInsensitiveNames bool `mapstructure:"case_insensitive_names"`
+	// RecordChecksums stores a checksum with each record, so that corrupted records are detected.
+	RecordChecksums bool `mapstructure:"record_checksums"`
+	// UIDReuseDelay is how long the UIDs of deleted users are not allocated to other users. A negative delay reserves
+	// them forever. A deleted user logging in again gets its UID back.
+	UIDReuseDelay time.Duration `mapstructure:"uid_reuse_delay"`
+}
+
+// options returns the options of the cache matching the configuration.
+func (c cacheConfig) options() []cache.Option {
+	return []cache.Option{
+		cache.WithCaseInsensitiveNames(c.CaseInsensitiveNames),
+		cache.WithRecordChecksums(c.RecordChecksums),
+		cache.WithUIDReuseDelay(c.UIDReuseDelay),
+	}
+}
+
+// domainBrokers defines, by domain, the broker users named user@domain are routed to. It's a list rather than a map
+// because the configuration keys can't contain the dots of the domains.
+type domainBrokers []struct {
+	Domain string `mapstructure:"domain"`
+	// Broker is the name of the broker in its configuration file.
+	Broker string `mapstructure:"broker"`
+}
+
+// byDomain returns the names of the brokers indexed by domain.
+func (d domainBrokers) byDomain() map[string]string {
+	r := make(map[string]string, len(d))
+	for _, e := range d {
+		r[e.Domain] = e.Broker
+	}
+	return r
+}
+
 // daemonConfig defines configuration parameters of the daemon.
 type daemonConfig struct {
 	Brokers     []string
 	Verbosity   int
 	Paths       systemPaths
 	UsersConfig users.Config `mapstructure:",squash"`
+	Maintenance services.MaintenanceConfig
+	Sessions    sessionsConfig
+	NSS         nssConfig
+	Cache       cacheConfig
+	// LogFormat is the output format of the logs, either "text" or "json".
+	LogFormat string `mapstructure:"log_format"`
+	// BreakGlassUsers are always authenticated by the local broker, so that they can log in when the other brokers
+	// are unavailable.
+	BreakGlassUsers []string `mapstructure:"break_glass_users"`
+	// BrokerOrder is the names of the brokers in the order they are offered to the users, after the local broker.
+	BrokerOrder []string `mapstructure:"broker_order"`
+	// MaxConcurrentBrokerCalls bounds how many broker calls can be in flight at once. 0 means no limit.
+	MaxConcurrentBrokerCalls int `mapstructure:"max_concurrent_broker_calls"`
+	// BrokerQueryTTL is how long the results of the idempotent broker queries are cached. 0 disables the cache.
+	BrokerQueryTTL time.Duration `mapstructure:"broker_query_ttl"`
+	// BrokerPolicy restricts the brokers users can authenticate with. Its rules refer to the brokers by name.
+	BrokerPolicy brokers.BrokerPolicy `mapstructure:"broker_policy"`
+	// DomainBrokers are the brokers users named user@domain are routed to when they have no previous broker.
+	DomainBrokers domainBrokers `mapstructure:"domain_brokers"`
+}
+
+// defaultConfig returns the configuration of the daemon before applying the configuration file, env variables and
+// flags.
+func defaultConfig() daemonConfig {
+	return daemonConfig{
+		Paths: systemPaths{
+			BrokersConf: consts.DefaultBrokersConfPath,
+			Cache:       consts.DefaultCacheDir,
+			Socket:      "",
+		},
+		UsersConfig: users.DefaultConfig,
+		Maintenance: services.DefaultMaintenanceConfig,
+		Sessions: sessionsConfig{
+			TTL: brokers.DefaultSessionTTL,
+		},
+		BrokerQueryTTL: brokers.DefaultBrokerQueryTTL,
+		LogFormat:      "text",
+	}
 }
 
 // New registers commands and return a new App.
@@ -59,14 +189,7 @@ func New() *App {
 			// TODO: before or after?  cmd.LocalFlags()
 
 			// Set config defaults
-			a.config = daemonConfig{
-				Paths: systemPaths{
-					BrokersConf: consts.DefaultBrokersConfPath,
-					Cache:       consts.DefaultCacheDir,
-					Socket:      "",
-				},
-				UsersConfig: users.DefaultConfig,
-			}
+			a.config = defaultConfig()
 
 			// Install and unmarshall configuration
 			if err := initViperConfig(cmdName, &a.rootCmd, a.viper); err != nil {
@@ -75,9 +198,18 @@ func New() *App {
 			if err := a.viper.Unmarshal(&a.config); err != nil {
 				return fmt.Errorf("unable to decode configuration into struct: %w", err)
 			}
+			if a.config.Maintenance.UserMaxAge < 0 {
+				return fmt.Errorf("invalid configuration: user_max_age can't be negative: %v", a.config.Maintenance.UserMaxAge)
+			}
+			if _, err := a.config.NSS.options(); err != nil {
+				return fmt.Errorf("invalid configuration: %v", err)
+			}
 
 			setVerboseMode(a.config.Verbosity)
 			log.Debugf(context.Background(), "Verbosity: %d", a.config.Verbosity)
+			if err := setLogFormat(a.config.LogFormat); err != nil {
+				return fmt.Errorf("invalid configuration: %v", err)
+			}
 
 			return nil
 		},
@@ -96,6 +228,7 @@ func New() *App {
 
 	// subcommands
 	a.installVersion()
+	a.installDumpUser()
 
 	return &a
 }
@@ -110,7 +243,24 @@ func (a *App) serve(config daemonConfig) error {
 		return fmt.Errorf("error initializing cache directory at %q: %v", cacheDir, err)
 	}
 
-	m, err := services.NewManager(ctx, cacheDir, config.Paths.BrokersConf, config.Brokers, config.UsersConfig)
+	brokerOpts := []brokers.Option{
+		brokers.WithDefaultSessionTTL(config.Sessions.TTL),
+		brokers.WithBreakGlassUsers(config.BreakGlassUsers),
+		brokers.WithBrokerOrder(config.BrokerOrder),
+		brokers.WithBrokerQueryTTL(config.BrokerQueryTTL),
+		brokers.WithMaxConcurrentBrokerCalls(config.MaxConcurrentBrokerCalls),
+	}
+
+	nssOpts, err := config.NSS.options()
+	if err != nil {
+		close(a.ready)
+		return err
+	}
+
+	m, err := services.NewManager(ctx, cacheDir, config.Paths.BrokersConf, config.Brokers, config.UsersConfig,
+		services.WithBrokerOptions(brokerOpts...), services.WithBrokerPolicy(config.BrokerPolicy),
+		services.WithDomainBrokerMap(config.DomainBrokers.byDomain()),
+		services.WithNSSOptions(nssOpts...), services.WithUserOptions(users.WithCacheOptions(config.Cache.options()...)))
 	if err != nil {
 		close(a.ready)
 		return err
@@ -130,7 +280,13 @@ func (a *App) serve(config daemonConfig) error {
 		return err
 	}
 
+	if err := daemon.ScheduleMaintenance(m.MaintenanceSpec(config.Maintenance)); err != nil {
+		close(a.ready)
+		return err
+	}
+
 	a.daemon = daemon
+	a.manager = &m
 	close(a.ready)
 
 	return daemon.Serve(ctx)
@@ -153,14 +309,59 @@ func (a App) UsageError() bool {
 	return !a.rootCmd.SilenceUsage
 }
 
-// Hup prints all goroutine stack traces and return false to signal you shouldn't quit.
-func (a App) Hup() (shouldQuit bool) {
+// Hup prints all goroutine stack traces. Once the daemon is ready, it reloads the brokers and the break-glass users
+// from the configuration file and prints them, with the number of broker calls in flight, whether the system bus is
+// connected and the statistics of the broker query cache. It returns false to signal you shouldn't quit.
+func (a *App) Hup() (shouldQuit bool) {
 	buf := make([]byte, 1<<16)
-	runtime.Stack(buf, true)
-	fmt.Printf("%s", buf)
+	n := runtime.Stack(buf, true)
+	fmt.Printf("%s", buf[:n])
+
+	select {
+	case <-a.ready:
+		if a.manager != nil {
+			if err := a.manager.ReloadBrokers(context.Background()); err != nil {
+				log.Warningf(context.Background(), "Could not reload brokers: %v", err)
+			}
+			if config, err := a.reloadConfig(); err != nil {
+				log.Warningf(context.Background(), "Could not reload configuration: %v", err)
+			} else {
+				a.manager.SetBreakGlassUsers(config.BreakGlassUsers)
+				fmt.Printf("Break-glass users: %s\n", strings.Join(config.BreakGlassUsers, ", "))
+			}
+			var names []string
+			for _, b := range a.manager.LoadedBrokers() {
+				names = append(names, b.Name)
+			}
+			fmt.Printf("Loaded brokers: %s\n", strings.Join(names, ", "))
+			fmt.Printf("Broker calls in flight: %d\n", a.manager.InFlightBrokerCalls())
+			fmt.Printf("System bus connected: %t\n", a.manager.BusConnected())
+			stats := a.manager.BrokerQueryCacheStats()
+			fmt.Printf("Broker query cache: %d hits, %d misses (%.0f%% hit rate)\n",
+				stats.Hits, stats.Misses, stats.HitRate()*100)
+		}
+	default:
+	}
 	return false
 }
 
+// reloadConfig reads the configuration file again and returns the resulting configuration. Only the settings which
+// can be changed at runtime are applied by the caller.
+func (a *App) reloadConfig() (config daemonConfig, err error) {
+	if err := a.viper.ReadInConfig(); err != nil {
+		var e viper.ConfigFileNotFoundError
+		if !errors.As(err, &e) {
+			return daemonConfig{}, fmt.Errorf("invalid configuration file: %w", err)
+		}
+	}
+
+	config = defaultConfig()
+	if err := a.viper.Unmarshal(&config); err != nil {
+		return daemonConfig{}, fmt.Errorf("unable to decode configuration into struct: %w", err)
+	}
+	return config, nil
+}
+
 // Quit gracefully shutdown the service.
 func (a *App) Quit() {
 	a.WaitReady()
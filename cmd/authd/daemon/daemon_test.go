@@ -2,6 +2,7 @@ package daemon_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -13,8 +14,11 @@ import (
 
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/authd/cmd/authd/daemon"
+	"github.com/ubuntu/authd/internal/brokers"
 	"github.com/ubuntu/authd/internal/consts"
+	"github.com/ubuntu/authd/internal/services"
 	"github.com/ubuntu/authd/internal/testutils"
+	"github.com/ubuntu/authd/internal/users/cache"
 	cachetestutils "github.com/ubuntu/authd/internal/users/cache/testutils"
 )
 
@@ -55,6 +59,59 @@ func TestVersion(t *testing.T) {
 	require.Equal(t, consts.Version, fields[1], "Wrong version")
 }
 
+func TestDumpUser(t *testing.T) {
+	tests := map[string]struct {
+		username      string
+		databaseInUse bool
+
+		wantErr bool
+	}{
+		"Dump existing user": {username: "user1"},
+
+		"Error when user does not exist":    {username: "doesnotexist", wantErr: true},
+		"Error when the database is in use": {username: "user1", databaseInUse: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			cacheDir := t.TempDir()
+			c, err := cache.New(cacheDir)
+			require.NoError(t, err, "Setup: could not create cache")
+			err = c.UpdateUserEntry(cache.NewUserDB("user1", 1111, 11111, "", "/home/user1", "/bin/bash"),
+				[]cache.GroupDB{cache.NewGroupDB("group1", 11111, nil), cache.NewGroupDB("group2", 22222, nil)})
+			require.NoError(t, err, "Setup: could not add user to cache")
+			err = c.UpdateBrokerForUser("user1", "broker-id")
+			require.NoError(t, err, "Setup: could not set broker for user")
+			if tc.databaseInUse {
+				// Keep the database opened read-write, like the daemon does.
+				t.Cleanup(func() { _ = c.Close() })
+			} else {
+				require.NoError(t, c.Close(), "Setup: could not close cache")
+			}
+
+			a := daemon.NewForTests(t, &daemon.DaemonConfig{Paths: daemon.SystemPaths{Cache: cacheDir}}, "dump-user", tc.username)
+
+			getStdout := captureStdout(t)
+
+			err = a.Run()
+			if tc.wantErr {
+				require.Error(t, err, "Run should return an error")
+				return
+			}
+			require.NoError(t, err, "Run should not return an error")
+
+			var got cache.UserDump
+			err = json.Unmarshal([]byte(getStdout()), &got)
+			require.NoError(t, err, "Output should be a valid JSON user dump")
+
+			require.Equal(t, "user1", got.User.Name, "Wrong user name")
+			require.Equal(t, "group1", got.PrimaryGroup.Name, "Wrong primary group")
+			require.Len(t, got.Groups, 2, "Wrong number of groups")
+			require.Equal(t, "broker-id", got.BrokerID, "Wrong broker ID")
+			require.False(t, got.LastLogin.IsZero(), "LastLogin should be set")
+		})
+	}
+}
+
 func TestNoUsageError(t *testing.T) {
 	a := daemon.NewForTests(t, nil, "completion", "bash")
 
@@ -210,6 +267,55 @@ func TestAppCanSigHupWhenExecute(t *testing.T) {
 	_, err = io.Copy(&out, r)
 	require.NoError(t, err, "Couldn't copy stdout to buffer")
 	require.NotEmpty(t, out.String(), "Stacktrace is printed")
+	require.Contains(t, out.String(), "Broker calls in flight: 0", "Number of broker calls in flight is printed")
+	require.Contains(t, out.String(), "System bus connected: ", "Connection to the system bus is printed")
+	require.Contains(t, out.String(), "Broker query cache: 0 hits, 0 misses (0% hit rate)", "Broker query cache statistics are printed")
+}
+
+func TestAppReloadsBrokersOnSigHup(t *testing.T) {
+	var config daemon.DaemonConfig
+	config.Paths.BrokersConf = t.TempDir()
+
+	a, wait := startDaemon(t, &config)
+	defer wait()
+	defer a.Quit()
+
+	cfg := "[authd]\nname = Added\nbrand_icon = icon.png\ndbus_name = com.ubuntu.authd.Added\ndbus_object = /com/ubuntu/authd/Added\n"
+	err := os.WriteFile(filepath.Join(config.Paths.BrokersConf, "added.conf"), []byte(cfg), 0600)
+	require.NoError(t, err, "Setup: could not write broker configuration")
+
+	getStdout := captureStdout(t)
+	a.Hup()
+
+	require.Contains(t, getStdout(), "Loaded brokers: local, Added", "Brokers added to the configuration directory are loaded")
+}
+
+func TestAppReloadsBreakGlassUsersOnSigHup(t *testing.T) {
+	confPath := daemon.GenerateTestConfig(t, nil)
+	a := daemon.New()
+	a.SetArgs("--config", confPath)
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		err := a.Run()
+		require.NoError(t, err, "Run should exits without any error")
+	}()
+	defer wg.Wait()
+	defer a.Quit()
+	a.WaitReady()
+
+	f, err := os.OpenFile(confPath, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err, "Setup: could not open configuration file")
+	_, err = f.WriteString("break_glass_users:\n  - rescue\n  - admin\n")
+	require.NoError(t, err, "Setup: could not update configuration file")
+	require.NoError(t, f.Close(), "Setup: could not close configuration file")
+
+	getStdout := captureStdout(t)
+	a.Hup()
+
+	require.Contains(t, getStdout(), "Break-glass users: rescue, admin", "Break-glass users are reloaded from the configuration file")
 }
 
 func TestAppCanSigHupAfterExecute(t *testing.T) {
@@ -321,6 +427,102 @@ func TestNoConfigSetDefaults(t *testing.T) {
 	require.Equal(t, consts.DefaultBrokersConfPath, a.Config().Paths.BrokersConf, "Default brokers configuration path")
 	require.Equal(t, consts.DefaultCacheDir, a.Config().Paths.Cache, "Default cache directory")
 	require.Equal(t, "", a.Config().Paths.Socket, "No socket address as default")
+	require.Equal(t, services.DefaultMaintenanceConfig, a.Config().Maintenance, "Default maintenance configuration")
+	require.Equal(t, brokers.DefaultSessionTTL, a.Config().Sessions.TTL, "Default session lifetime")
+	require.Equal(t, brokers.DefaultBrokerQueryTTL, a.Config().BrokerQueryTTL, "Default lifetime of cached broker queries")
+	require.Equal(t, "text", a.Config().LogFormat, "Default log format")
+}
+
+func TestNegativeUserMaxAgeReturnsError(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "authd.yaml")
+	err := os.WriteFile(configPath, []byte("maintenance:\n  user_max_age: -24h\n"), 0600)
+	require.NoError(t, err, "Setup: could not write configuration file")
+
+	a := daemon.New()
+	// Use version to still run preExec to load the config but without running server
+	a.SetArgs("version", "--config", configPath)
+
+	err = a.Run()
+	require.Error(t, err, "Run should return an error on a negative user max age")
+}
+
+func TestInvalidLogFormatReturnsError(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "authd.yaml")
+	err := os.WriteFile(configPath, []byte("log_format: xml\n"), 0600)
+	require.NoError(t, err, "Setup: could not write configuration file")
+
+	a := daemon.New()
+	// Use version to still run preExec to load the config but without running server
+	a.SetArgs("version", "--config", configPath)
+
+	err = a.Run()
+	require.Error(t, err, "Run should return an error on an unknown log format")
+}
+
+func TestDomainBrokersWithUnknownBrokerReturnsError(t *testing.T) {
+	confPath := daemon.GenerateTestConfig(t, nil)
+	f, err := os.OpenFile(confPath, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err, "Setup: could not open configuration file")
+	_, err = f.WriteString("domain_brokers:\n  - domain: example.com\n    broker: unknown\n")
+	require.NoError(t, err, "Setup: could not update configuration file")
+	require.NoError(t, f.Close(), "Setup: could not close configuration file")
+
+	a := daemon.New()
+	a.SetArgs("--config", confPath)
+
+	err = a.Run()
+	require.ErrorContains(t, err, `domain "example.com" is mapped to broker "unknown"`,
+		"Run should return an error when a domain is mapped to a broker which is not loaded")
+	a.Quit()
+}
+
+func TestNSSConfigLoad(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "authd.yaml")
+	err := os.WriteFile(configPath, []byte("nss:\n  snapshot_enumeration: true\n  flattened_group_members: true\n"+
+		"  inline_group_members_limit: 10\n"), 0600)
+	require.NoError(t, err, "Setup: could not write configuration file")
+
+	a := daemon.New()
+	// Use version to still run preExec to load the config but without running server
+	a.SetArgs("version", "--config", configPath)
+
+	err = a.Run()
+	require.NoError(t, err, "Run should not return an error")
+
+	require.True(t, a.Config().NSS.SnapshotEnumeration, "Snapshot enumeration is set from config")
+	require.True(t, a.Config().NSS.FlattenedGroupMembers, "Flattened group members are set from config")
+	require.Equal(t, 10, a.Config().NSS.InlineGroupMembersLimit, "Inline group members limit is set from config")
+}
+
+func TestInvalidNSSConfigReturnsError(t *testing.T) {
+	tests := map[string]struct {
+		config string
+
+		wantErr string
+	}{
+		"Error on inverted authoritative range": {
+			config:  "nss:\n  authoritative_id_min: 2000\n  authoritative_id_max: 1000\n",
+			wantErr: "invalid NSS authoritative ID range",
+		},
+		"Error on negative inline group members limit": {
+			config:  "nss:\n  inline_group_members_limit: -1\n",
+			wantErr: "invalid NSS inline group members limit",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "authd.yaml")
+			err := os.WriteFile(configPath, []byte(tc.config), 0600)
+			require.NoError(t, err, "Setup: could not write configuration file")
+
+			a := daemon.New()
+			// Use version to still run preExec to load the config but without running server
+			a.SetArgs("version", "--config", configPath)
+
+			err = a.Run()
+			require.ErrorContains(t, err, tc.wantErr, "Run should return an error on an invalid NSS configuration")
+		})
+	}
 }
 
 func TestBadConfigReturnsError(t *testing.T) {
@@ -0,0 +1,48 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/ubuntu/authd/internal/users/cache"
+)
+
+func (a *App) installDumpUser() {
+	cmd := &cobra.Command{
+		Use:                                                                     "dump-user USERNAME",
+		Short:/*i18n.G(*/ "Prints the cached state of a user as JSON and exits", /*)*/
+		Args:                                                                    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dumpUser(a.config.Paths.Cache, args[0], a.config.Cache.options()...)
+		},
+	}
+	a.rootCmd.AddCommand(cmd)
+}
+
+// dumpUser prints all the state of the user cached in cacheDir, opened with opts. The cache is only read, so it's opened
+// read-only, which fails while the daemon holds it open.
+func dumpUser(cacheDir, name string, opts ...cache.Option) (err error) {
+	c, err := cache.New(cacheDir, append(opts, cache.WithReadOnly())...)
+	if errors.Is(err, cache.ErrDatabaseInUse) {
+		return errors.New("the database is in use by the daemon, stop it to dump the user")
+	}
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.Close() }()
+
+	d, err := c.DumpUser(name)
+	if err != nil {
+		return err
+	}
+
+	out, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal user dump: %v", err)
+	}
+	fmt.Println(string(out))
+
+	return nil
+}
@@ -749,6 +749,12 @@ func (b *Broker) EndSession(ctx context.Context, sessionID string) error {
 	return nil
 }
 
+// HasSession returns whether the session is ongoing, that is started and not ended yet.
+func (b *Broker) HasSession(ctx context.Context, sessionID string) bool {
+	_, err := b.sessionInfo(sessionID)
+	return err == nil
+}
+
 // CancelIsAuthenticated cancels the IsAuthenticated request for the specified session.
 // If there is no pending IsAuthenticated call for the session, this is a no-op.
 func (b *Broker) CancelIsAuthenticated(ctx context.Context, sessionID string) {
@@ -120,6 +120,11 @@ func (b *Bus) EndSession(sessionID string) (dbusErr *dbus.Error) {
 	return nil
 }
 
+// HasSession is the method through which the broker and the daemon will communicate once dbusInterface.HasSession is called.
+func (b *Bus) HasSession(sessionID string) (ongoing bool, dbusErr *dbus.Error) {
+	return b.broker.HasSession(context.Background(), sessionID), nil
+}
+
 // CancelIsAuthenticated is the method through which the broker and the daemon will communicate once dbusInterface.CancelIsAuthenticated is called.
 func (b *Bus) CancelIsAuthenticated(sessionID string) (dbusErr *dbus.Error) {
 	b.broker.CancelIsAuthenticated(context.Background(), sessionID)
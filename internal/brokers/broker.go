@@ -7,10 +7,12 @@ import (
 	"fmt"
 	"hash/fnv"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+	"unicode"
 
-	"github.com/godbus/dbus/v5"
 	"github.com/ubuntu/authd/internal/log"
 	"github.com/ubuntu/authd/internal/users"
 	"github.com/ubuntu/decorate"
@@ -20,6 +22,9 @@ import (
 // LocalBrokerName is the name of the local broker.
 const LocalBrokerName = "local"
 
+// localBrokerDisplayName is the human readable name of the local broker.
+const localBrokerDisplayName = "Local Broker"
+
 const (
 	// AuthGranted is the response when the authentication is granted.
 	AuthGranted = "granted"
@@ -37,29 +42,86 @@ const (
 var AuthReplies = []string{AuthGranted, AuthDenied, AuthCancelled, AuthRetry, AuthNext}
 
 type brokerer interface {
-	NewSession(ctx context.Context, username, lang, mode string) (sessionID, encryptionKey string, err error)
+	NewSession(ctx context.Context, username, lang, mode string, sessionContext map[string]string) (sessionID string, key KeyInfo, err error)
 	GetAuthenticationModes(ctx context.Context, sessionID string, supportedUILayouts []map[string]string) (authenticationModes []map[string]string, err error)
 	SelectAuthenticationMode(ctx context.Context, sessionID, authenticationModeName string) (uiLayoutInfo map[string]string, err error)
 	IsAuthenticated(ctx context.Context, sessionID, authenticationData string) (access, data string, err error)
 	EndSession(ctx context.Context, sessionID string) (err error)
 	CancelIsAuthenticated(ctx context.Context, sessionID string)
+	HasSession(ctx context.Context, sessionID string) (ongoing bool, err error)
 
 	UserPreCheck(ctx context.Context, username string) (userinfo string, err error)
+	EnumerateUsers(ctx context.Context) (userinfos string, err error)
+	Ready(ctx context.Context) (ready bool, err error)
+	Ping(ctx context.Context) error
 }
 
 // Broker represents a broker object that can be used for authentication.
 type Broker struct {
-	ID                    string
-	Name                  string
-	BrandIconPath         string
+	ID            string
+	Name          string
+	BrandIconPath string
+	// DisplayName is the human readable name of the broker. It defaults to Name, or to a built-in name for the local
+	// broker.
+	DisplayName string
+	// Icon is the path or URI of the icon of the broker. It defaults to BrandIconPath.
+	Icon string
+
 	layoutValidators      map[string]map[string]layoutValidator
 	layoutValidatorsMu    *sync.Mutex
 	ongoingUserRequests   map[string]string
 	ongoingUserRequestsMu *sync.Mutex
+	// sessionHandles maps the session IDs given to clients to the ones of the broker.
+	sessionHandles        map[string]string
+	sessionHandlesMu      *sync.Mutex
+	maxSessionIDLength    int
+	authoritativeUIDRange *UIDRange
+	strictUIDRange        bool
+	maintenance           *maintenanceState
+	health                *healthState
+	queries               *queryCache
 
 	brokerer brokerer
 }
 
+// UIDRange is an inclusive range of UIDs.
+type UIDRange struct {
+	Min uint32
+	Max uint32
+}
+
+// Contains returns whether uid is in the range.
+func (r UIDRange) Contains(uid uint32) bool {
+	return uid >= r.Min && uid <= r.Max
+}
+
+// overlaps returns whether the two ranges have any UID in common.
+func (r UIDRange) overlaps(other UIDRange) bool {
+	return r.Min <= other.Max && other.Min <= r.Max
+}
+
+// parseUIDRange parses a range of UIDs in the form "min-max".
+func parseUIDRange(s string) (r UIDRange, err error) {
+	minStr, maxStr, found := strings.Cut(s, "-")
+	if !found {
+		return UIDRange{}, fmt.Errorf("%q is not in the form min-max", s)
+	}
+	minUID, err := strconv.ParseUint(strings.TrimSpace(minStr), 10, 32)
+	if err != nil {
+		return UIDRange{}, fmt.Errorf("invalid minimum UID in %q: %v", s, err)
+	}
+	maxUID, err := strconv.ParseUint(strings.TrimSpace(maxStr), 10, 32)
+	if err != nil {
+		return UIDRange{}, fmt.Errorf("invalid maximum UID in %q: %v", s, err)
+	}
+	if minUID > maxUID {
+		return UIDRange{}, fmt.Errorf("minimum UID is greater than maximum UID in %q", s)
+	}
+
+	//nolint:gosec // ParseUint checked that the values fit in an uint32.
+	return UIDRange{Min: uint32(minUID), Max: uint32(maxUID)}, nil
+}
+
 type layoutValidator map[string]fieldValidator
 
 type fieldValidator struct {
@@ -68,54 +130,206 @@ type fieldValidator struct {
 }
 
 // newBroker creates a new broker object based on the provided config file. No config means local broker.
-func newBroker(ctx context.Context, configFile string, bus *dbus.Conn) (b Broker, err error) {
+// The calls made to a dbus broker are bounded by calls.
+func newBroker(ctx context.Context, configFile string, bus *busConn, calls *callLimiter) (b Broker, err error) {
 	defer decorate.OnError(&err, "can't create broker from %q", configFile)
 
 	name := LocalBrokerName
 	id := LocalBrokerName
 	var brandIcon string
 	var broker brokerer
+	var maxSessionIDLength int
+	var authoritativeUIDRange *UIDRange
+	var strictUIDRange bool
+	displayName := localBrokerDisplayName
+	var icon string
 
 	if configFile != "" {
 		log.Debugf(ctx, "Loading broker from %q", configFile)
-		broker, name, brandIcon, err = newDbusBroker(ctx, bus, configFile)
+		var dbusBroker dbusBroker
+		dbusBroker, name, brandIcon, err = newDbusBroker(ctx, bus, configFile, calls)
 		if err != nil {
 			return Broker{}, err
 		}
+		broker = dbusBroker
+		maxSessionIDLength = dbusBroker.maxSessionIDLength
+		authoritativeUIDRange = dbusBroker.authoritativeUIDRange
+		strictUIDRange = dbusBroker.strictUIDRange
+		displayName = dbusBroker.displayName
+		icon = dbusBroker.icon
 		h := fnv.New32a()
 		// This can’t error out in Hash32 implementation.
 		_, _ = h.Write([]byte(name))
 		id = fmt.Sprint(h.Sum32())
 	}
 
+	if displayName == "" {
+		displayName = name
+	}
+	if icon == "" {
+		icon = brandIcon
+	}
+
 	return Broker{
 		ID:                    id,
 		Name:                  name,
 		BrandIconPath:         brandIcon,
+		DisplayName:           displayName,
+		Icon:                  icon,
 		brokerer:              broker,
-		layoutValidators:      make(map[string]map[string]layoutValidator),
-		layoutValidatorsMu:    &sync.Mutex{},
-		ongoingUserRequests:   make(map[string]string),
-		ongoingUserRequestsMu: &sync.Mutex{},
-	}, nil
+		maxSessionIDLength:    maxSessionIDLength,
+		authoritativeUIDRange: authoritativeUIDRange,
+		strictUIDRange:        strictUIDRange,
+	}.withInternalState(), nil
 }
 
-// newSession calls the broker corresponding method, expanding sessionID with the broker ID prefix.
-func (b Broker) newSession(ctx context.Context, username, lang, mode string) (sessionID, encryptionKey string, err error) {
-	sessionID, encryptionKey, err = b.brokerer.NewSession(ctx, username, lang, mode)
-	if err != nil {
-		return "", "", err
+// Info returns the human readable information of the broker.
+func (b Broker) Info() BrokerInfo {
+	info := BrokerInfo{
+		ID:            b.ID,
+		Name:          b.Name,
+		BrandIconPath: b.BrandIconPath,
+		DisplayName:   b.DisplayName,
+		Icon:          b.Icon,
+	}
+	if maintenance, ok := b.Maintenance(); ok {
+		info.Maintenance = &maintenance
+	}
+	return info
+}
+
+// withInternalState returns the broker with its internal state, shared by all its copies, initialized.
+func (b Broker) withInternalState() Broker {
+	b.layoutValidators = make(map[string]map[string]layoutValidator)
+	b.layoutValidatorsMu = &sync.Mutex{}
+	b.ongoingUserRequests = make(map[string]string)
+	b.ongoingUserRequestsMu = &sync.Mutex{}
+	b.sessionHandles = make(map[string]string)
+	b.sessionHandlesMu = &sync.Mutex{}
+	b.maintenance = &maintenanceState{}
+	b.health = &healthState{}
+	return b
+}
+
+// AuthoritativeUIDRange returns the range of UIDs the broker is authoritative for, if it declared one.
+func (b Broker) AuthoritativeUIDRange() (r UIDRange, ok bool) {
+	if b.authoritativeUIDRange == nil {
+		return UIDRange{}, false
 	}
+	return *b.authoritativeUIDRange, true
+}
+
+// StrictUIDRange returns whether the users of the broker are rejected if their UID is outside of its authoritative
+// range, which brokers opt in to with authoritative_uid_range_strict, as users created before the range was declared
+// may be outside of it.
+func (b Broker) StrictUIDRange() bool {
+	return b.strictUIDRange
+}
+
+// abandonedCallTimeout is how long the calls whose caller gave up are waited for, to clean up after them.
+const abandonedCallTimeout = time.Minute
+
+// newSessionResult is the answer of the broker to a NewSession call.
+type newSessionResult struct {
+	sessionID string
+	key       KeyInfo
+	err       error
+}
+
+// newSession calls the broker corresponding method and returns the session ID of the broker.
+// If ctx is done first, it returns without waiting for the broker, and the session the broker creates meanwhile is
+// ended once it answers.
+func (b Broker) newSession(ctx context.Context, username, lang, mode string, sessionContext map[string]string) (sessionID string, key KeyInfo, err error) {
+	results := make(chan newSessionResult, 1)
+	go func() {
+		// The call is not aborted when ctx is done, so that we get the ID of the session to end.
+		callCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abandonedCallTimeout)
+		defer cancel()
+		sessionID, key, err := b.brokerer.NewSession(callCtx, username, lang, mode, sessionContext)
+		results <- newSessionResult{sessionID: sessionID, key: key, err: err}
+	}()
+
+	var r newSessionResult
+	select {
+	case r = <-results:
+	case <-ctx.Done():
+		go b.endAbandonedSession(context.WithoutCancel(ctx), results)
+		return "", KeyInfo{}, ctx.Err()
+	}
+	if r.err != nil {
+		return "", KeyInfo{}, r.err
+	}
+	sessionID, key = r.sessionID, r.key
 
 	if sessionID == "" {
-		return "", "", errors.New("no session ID provided by broker")
+		return "", KeyInfo{}, errors.New("no session ID provided by broker")
+	}
+	if err := validateSessionID(sessionID, b.maxSessionIDLength); err != nil {
+		// The broker created the session, so we let it clean it up.
+		if endErr := b.brokerer.EndSession(ctx, sessionID); endErr != nil {
+			log.RateLimitedWarningf(ctx, "Could not end session with invalid ID on broker %q: %v", b.Name, endErr)
+		}
+		return "", KeyInfo{}, err
+	}
+
+	return sessionID, key, nil
+}
+
+// endAbandonedSession waits for the answer of a NewSession call whose caller gave up, and ends the session the broker
+// created, if any, as no client can reach it.
+func (b Broker) endAbandonedSession(ctx context.Context, results <-chan newSessionResult) {
+	r := <-results
+	if r.err != nil || r.sessionID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, abandonedCallTimeout)
+	defer cancel()
+	log.Debugf(ctx, "Ending session %q of broker %q, its creation was abandoned", r.sessionID, b.Name)
+	if err := b.brokerer.EndSession(ctx, r.sessionID); err != nil {
+		log.RateLimitedWarningf(ctx, "Could not end abandoned session %q on broker %q: %v", r.sessionID, b.Name, err)
+	}
+}
+
+// setSessionHandle makes handle the session ID given to clients for the session of the broker.
+func (b Broker) setSessionHandle(handle, sessionID string) {
+	b.sessionHandlesMu.Lock()
+	defer b.sessionHandlesMu.Unlock()
+	b.sessionHandles[handle] = sessionID
+}
+
+// addSession binds the new session of the broker to its handle and its user. It returns an error if the broker
+// returned the ID of an ongoing session, which is left untouched.
+func (b Broker) addSession(handle, sessionID, username string) error {
+	b.ongoingUserRequestsMu.Lock()
+	defer b.ongoingUserRequestsMu.Unlock()
+	if _, exists := b.ongoingUserRequests[sessionID]; exists {
+		return fmt.Errorf("broker %q returned the ID of an ongoing session: %q", b.Name, sessionID)
 	}
+	b.ongoingUserRequests[sessionID] = username
+	b.setSessionHandle(handle, sessionID)
+	return nil
+}
+
+// restoreSession binds the session of the broker to its handle and its user again, after a restart.
+func (b Broker) restoreSession(handle, sessionID, username string) {
+	b.setSessionHandle(handle, sessionID)
 
 	b.ongoingUserRequestsMu.Lock()
+	defer b.ongoingUserRequestsMu.Unlock()
 	b.ongoingUserRequests[sessionID] = username
-	b.ongoingUserRequestsMu.Unlock()
+}
 
-	return fmt.Sprintf("%s-%s", b.ID, sessionID), encryptionKey, nil
+// validateSessionID returns an error if the session ID returned by a broker is longer than maxLength or contains
+// control characters, as it is used as a key and logged.
+func validateSessionID(sessionID string, maxLength int) error {
+	if len(sessionID) > maxLength {
+		return fmt.Errorf("session ID provided by broker is too long: %d bytes, maximum is %d", len(sessionID), maxLength)
+	}
+	if strings.ContainsFunc(sessionID, unicode.IsControl) {
+		return fmt.Errorf("session ID provided by broker contains control characters: %q", sessionID)
+	}
+	return nil
 }
 
 // GetAuthenticationModes calls the broker corresponding method, stripping broker ID prefix from sessionID.
@@ -171,6 +385,10 @@ func (b Broker) IsAuthenticated(ctx context.Context, sessionID, authenticationDa
 	case <-ctx.Done():
 		b.cancelIsAuthenticated(ctx, sessionID)
 		<-done
+		// The call may have been cancelled before reaching the broker.
+		if err != nil {
+			return "", "", err
+		}
 	}
 
 	// Validate access authentication.
@@ -198,6 +416,11 @@ func (b Broker) IsAuthenticated(ctx context.Context, sessionID, authenticationDa
 			return "", "", err
 		}
 
+		// The broker may have canonicalized the username of the session.
+		b.ongoingUserRequestsMu.Lock()
+		b.ongoingUserRequests[sessionID] = info.Name
+		b.ongoingUserRequestsMu.Unlock()
+
 		d, err := json.Marshal(info.UserInfo)
 		if err != nil {
 			return "", "", fmt.Errorf("can't marshal UserInfo: %v", err)
@@ -218,15 +441,53 @@ func (b Broker) IsAuthenticated(ctx context.Context, sessionID, authenticationDa
 	return access, data, nil
 }
 
-// endSession calls the broker corresponding method, stripping broker ID prefix from sessionID.
+// SessionUsername returns the username of the session, as canonicalized by the broker once it granted the
+// authentication, or as requested when starting the session otherwise.
+func (b Broker) SessionUsername(sessionID string) string {
+	sessionID = b.parseSessionID(sessionID)
+
+	b.ongoingUserRequestsMu.Lock()
+	defer b.ongoingUserRequestsMu.Unlock()
+	return b.ongoingUserRequests[sessionID]
+}
+
+// hasSession returns whether the broker still holds the session, which is the case until it is ended, unless the
+// broker lost it, like when it was restarted.
+func (b Broker) hasSession(ctx context.Context, sessionID string) (bool, error) {
+	sessionID = b.parseSessionID(sessionID)
+
+	b.ongoingUserRequestsMu.Lock()
+	_, ok := b.ongoingUserRequests[sessionID]
+	b.ongoingUserRequestsMu.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	return b.brokerer.HasSession(ctx, sessionID)
+}
+
+// endSession calls the broker corresponding method, stripping broker ID prefix from sessionID. The session is only
+// forgotten once the broker ended it, so that ending it can be retried.
 func (b Broker) endSession(ctx context.Context, sessionID string) (err error) {
+	if err := b.brokerer.EndSession(ctx, b.parseSessionID(sessionID)); err != nil {
+		return err
+	}
+	b.forgetSession(sessionID)
+	return nil
+}
+
+// forgetSession drops the session given to the client as sessionID, without ending it on the broker.
+func (b Broker) forgetSession(sessionID string) {
+	handle := sessionID
 	sessionID = b.parseSessionID(sessionID)
 
+	b.sessionHandlesMu.Lock()
+	delete(b.sessionHandles, handle)
+	b.sessionHandlesMu.Unlock()
+
 	b.ongoingUserRequestsMu.Lock()
 	defer b.ongoingUserRequestsMu.Unlock()
 	delete(b.ongoingUserRequests, sessionID)
-
-	return b.brokerer.EndSession(ctx, sessionID)
 }
 
 // cancelIsAuthenticated calls the broker corresponding method.
@@ -237,9 +498,40 @@ func (b Broker) cancelIsAuthenticated(ctx context.Context, sessionID string) {
 	b.brokerer.CancelIsAuthenticated(ctx, sessionID)
 }
 
-// UserPreCheck calls the broker corresponding method.
+// UserPreCheck calls the broker corresponding method. The results are cached for a short time by the manager.
 func (b Broker) UserPreCheck(ctx context.Context, username string) (userinfo string, err error) {
-	return b.brokerer.UserPreCheck(ctx, username)
+	return b.queries.get(ctx, b.ID, "UserPreCheck", username, func(ctx context.Context) (string, error) {
+		return b.brokerer.UserPreCheck(ctx, username)
+	})
+}
+
+// EnumerateUsers returns all the users the broker can provision, with the same validation as the users returned on
+// authentication. It returns an error matching ErrEnumerationNotSupported if the broker can't list its users.
+func (b Broker) EnumerateUsers(ctx context.Context) (r []users.UserInfo, err error) {
+	defer decorate.OnError(&err, "can't enumerate users of broker %q", b.Name)
+
+	rawUserInfos, err := b.brokerer.EnumerateUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []json.RawMessage
+	if err := json.Unmarshal([]byte(rawUserInfos), &infos); err != nil {
+		return nil, fmt.Errorf("response returned by the broker is not a valid json list: %v", err)
+	}
+
+	for _, rawUserInfo := range infos {
+		info, err := unmarshalUserInfo(rawUserInfo)
+		if err != nil {
+			return nil, err
+		}
+		if err = validateUserInfo(info); err != nil {
+			return nil, err
+		}
+		r = append(r, info.UserInfo)
+	}
+
+	return r, nil
 }
 
 // generateValidators generates layout validators based on what is supported by the system.
@@ -332,8 +624,14 @@ func (b Broker) validateUILayout(sessionID string, layout map[string]string) (r
 	return layout, nil
 }
 
-// parseSessionID strips broker ID prefix from sessionID.
+// parseSessionID returns the session ID of the broker matching the session ID given to the client.
+// Session IDs which were not given to clients are returned with the broker ID prefix stripped.
 func (b Broker) parseSessionID(sessionID string) string {
+	b.sessionHandlesMu.Lock()
+	defer b.sessionHandlesMu.Unlock()
+	if id, ok := b.sessionHandles[sessionID]; ok {
+		return id
+	}
 	return strings.TrimPrefix(sessionID, fmt.Sprintf("%s-", b.ID))
 }
 
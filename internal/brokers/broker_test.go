@@ -41,11 +41,15 @@ func TestNewBroker(t *testing.T) {
 
 	tests := map[string]struct {
 		configFile string
+		configDir  string
 
 		wantErr bool
 	}{
-		"No config means local broker":                        {configFile: "-"},
-		"Successfully create broker with correct config file": {configFile: "valid.conf"},
+		"No config means local broker":                                     {configFile: "-"},
+		"Successfully create broker with correct config file":              {configFile: "valid.conf"},
+		"Successfully create broker with an authoritative UID range field": {configFile: "first.conf", configDir: "uid_ranges"},
+		"Successfully create broker with a strict authoritative UID range": {configFile: "strict.conf", configDir: "uid_ranges_strict"},
+		"Successfully create broker with a display name and icon":          {configFile: "with_display_info.conf", configDir: "display_info"},
 
 		// General config errors
 		"Error when config file is invalid":     {configFile: "invalid.conf", wantErr: true},
@@ -56,6 +60,12 @@ func TestNewBroker(t *testing.T) {
 		"Error when config does not have brand_icon field":  {configFile: "no_brand_icon.conf", wantErr: true},
 		"Error when config does not have dbus.name field":   {configFile: "no_dbus_name.conf", wantErr: true},
 		"Error when config does not have dbus.object field": {configFile: "no_dbus_object.conf", wantErr: true},
+
+		// Invalid field errors
+		"Error when config has an invalid session_id_max_length field":             {configFile: "invalid_session_id_max_length.conf", wantErr: true},
+		"Error when config has an invalid authoritative_uid_range field":           {configFile: "invalid_authoritative_uid_range.conf", wantErr: true},
+		"Error when config has an invalid authoritative_uid_range_strict field":    {configFile: "invalid_authoritative_uid_range_strict.conf", wantErr: true},
+		"Error when config has a strict authoritative UID range without any range": {configFile: "authoritative_uid_range_strict_without_range.conf", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -68,6 +78,9 @@ func TestNewBroker(t *testing.T) {
 			if tc.wantErr {
 				configDir = filepath.Join(brokerConfFixtures, "invalid_brokers")
 			}
+			if tc.configDir != "" {
+				configDir = filepath.Join(brokerConfFixtures, tc.configDir)
+			}
 			if tc.configFile == "-" {
 				tc.configFile = ""
 			} else if tc.configFile != "" {
@@ -81,7 +94,14 @@ func TestNewBroker(t *testing.T) {
 			}
 			require.NoError(t, err, "NewBroker should not return an error, but did")
 
-			gotString := fmt.Sprintf("ID: %s\nName: %s\nBrand Icon: %s\n", got.ID, got.Name, got.BrandIconPath)
+			gotString := fmt.Sprintf("ID: %s\nName: %s\nBrand Icon: %s\nDisplay Name: %s\nIcon: %s\n",
+				got.ID, got.Name, got.BrandIconPath, got.DisplayName, got.Icon)
+			if r, ok := got.AuthoritativeUIDRange(); ok {
+				gotString += fmt.Sprintf("Authoritative UID range: %d-%d\n", r.Min, r.Max)
+			}
+			if got.StrictUIDRange() {
+				gotString += "Strict UID range: true\n"
+			}
 
 			wantString := testutils.LoadWithUpdateFromGolden(t, gotString)
 			require.Equal(t, wantString, gotString, "NewBroker should return the expected broker, but did not")
@@ -211,6 +231,8 @@ func TestIsAuthenticated(t *testing.T) {
 		secondCall bool
 
 		cancelFirstCall bool
+
+		wantCanonicalUsername string
 	}{
 		"Successfully authenticate":                                        {sessionID: "success"},
 		"Successfully authenticate after cancelling first call":            {sessionID: "IA_second_call", secondCall: true},
@@ -219,7 +241,7 @@ func TestIsAuthenticated(t *testing.T) {
 		"No error when auth.Next and no data":                              {sessionID: "IA_next"},
 		"No error when broker returns userinfo with empty gecos":           {sessionID: "IA_info_empty_gecos"},
 		"No error when broker returns userinfo with group with empty UGID": {sessionID: "IA_info_empty_ugid"},
-		"No error when broker returns userinfo with mismatching username":  {sessionID: "IA_info_mismatching_user_name"},
+		"No error when broker returns userinfo with mismatching username":  {sessionID: "IA_info_mismatching_user_name", wantCanonicalUsername: "different_username"},
 
 		// broker errors
 		"Error when authenticating":                                           {sessionID: "IA_error"},
@@ -251,7 +273,8 @@ func TestIsAuthenticated(t *testing.T) {
 			sessionID := prefixID(t, tc.sessionID)
 
 			// Add username to the ongoing requests
-			b.AddOngoingUserRequest(sessionID, t.Name()+testutils.IDSeparator+tc.sessionID)
+			username := t.Name() + testutils.IDSeparator + tc.sessionID
+			b.AddOngoingUserRequest(sessionID, username)
 
 			done := make(chan struct{})
 			go func() {
@@ -276,6 +299,12 @@ func TestIsAuthenticated(t *testing.T) {
 			gotStr := firstCallReturn + secondCallReturn
 			want := testutils.LoadWithUpdateFromGolden(t, gotStr)
 			require.Equal(t, want, gotStr, "IsAuthenticated should return the expected combined data, but did not")
+
+			// The username of the session is the one canonicalized by the broker, if any.
+			if tc.wantCanonicalUsername != "" {
+				username = tc.wantCanonicalUsername
+			}
+			require.Equal(t, username, b.SessionUsername(sessionID), "SessionUsername should return the expected username")
 		})
 	}
 }
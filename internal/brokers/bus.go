@@ -0,0 +1,142 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ubuntu/authd/internal/log"
+)
+
+// ErrBusUnavailable is returned by broker calls made while the connection to the system bus is down.
+// The calls can be retried: authd reconnects in the background.
+var ErrBusUnavailable = errors.New("system bus connection unavailable")
+
+const (
+	defaultMinReconnectDelay = 100 * time.Millisecond
+	defaultMaxReconnectDelay = 30 * time.Second
+)
+
+// busConn is a connection to the system bus which is transparently reestablished, with an exponential backoff,
+// when the bus drops it.
+type busConn struct {
+	connect                              func() (*dbus.Conn, error)
+	minReconnectDelay, maxReconnectDelay time.Duration
+
+	// conn is nil while disconnected.
+	conn *dbus.Conn
+	mu   sync.RWMutex
+}
+
+// newBusConn connects to the bus with connect and watches the connection, to reconnect when it's dropped.
+func newBusConn(ctx context.Context, connect func() (*dbus.Conn, error), minReconnectDelay, maxReconnectDelay time.Duration) (*busConn, error) {
+	conn, err := connect()
+	if err != nil {
+		return nil, err
+	}
+
+	b := &busConn{
+		connect:           connect,
+		minReconnectDelay: minReconnectDelay,
+		maxReconnectDelay: maxReconnectDelay,
+		conn:              conn,
+	}
+	go b.watch(ctx, conn)
+
+	return b, nil
+}
+
+// watch waits for conn to be closed, which dbus does when the bus drops it, and reconnects until ctx is done.
+func (b *busConn) watch(ctx context.Context, conn *dbus.Conn) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-conn.Context().Done():
+		}
+
+		log.Warning(ctx, "Connection to the system bus lost, reconnecting")
+		b.mu.Lock()
+		b.conn = nil
+		b.mu.Unlock()
+
+		conn = b.reconnect(ctx)
+		if conn == nil {
+			return
+		}
+		log.Info(ctx, "Reconnected to the system bus")
+	}
+}
+
+// reconnect tries to connect to the bus, waiting longer after each failure, until it succeeds or ctx is done.
+// It returns nil if ctx is done first.
+func (b *busConn) reconnect(ctx context.Context) *dbus.Conn {
+	delay := b.minReconnectDelay
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		conn, err := b.connect()
+		if err != nil {
+			delay = min(delay*2, b.maxReconnectDelay)
+			log.Debugf(ctx, "Could not reconnect to the system bus, retrying in %v: %v", delay, err)
+			continue
+		}
+
+		b.mu.Lock()
+		b.conn = conn
+		b.mu.Unlock()
+		return conn
+	}
+}
+
+// object returns the object of the current connection, or ErrBusUnavailable if disconnected.
+func (b *busConn) object(dest string, path dbus.ObjectPath) (dbus.BusObject, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.conn == nil || !b.conn.Connected() {
+		return nil, ErrBusUnavailable
+	}
+	return b.conn.Object(dest, path), nil
+}
+
+// nameReachable returns whether calls to the bus name can be delivered, which is when the name is owned or can be
+// activated by the bus. It returns ErrBusUnavailable if disconnected.
+func (b *busConn) nameReachable(ctx context.Context, name string) (bool, error) {
+	b.mu.RLock()
+	conn := b.conn
+	b.mu.RUnlock()
+
+	if conn == nil || !conn.Connected() {
+		return false, ErrBusUnavailable
+	}
+
+	var owned bool
+	if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.NameHasOwner", 0, name).Store(&owned); err != nil {
+		return false, err
+	}
+	if owned {
+		return true, nil
+	}
+
+	var activatable []string
+	if err := conn.BusObject().CallWithContext(ctx, "org.freedesktop.DBus.ListActivatableNames", 0).Store(&activatable); err != nil {
+		return false, err
+	}
+	return slices.Contains(activatable, name), nil
+}
+
+// connected returns whether the connection to the bus is currently up.
+func (b *busConn) connected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return b.conn != nil && b.conn.Connected()
+}
@@ -0,0 +1,58 @@
+package brokers
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// callLimiter bounds the number of broker calls in flight at once. A nil callLimiter does not limit anything.
+type callLimiter struct {
+	slots   chan struct{}
+	current atomic.Int64
+}
+
+// newCallLimiter returns a callLimiter allowing n concurrent calls. 0 means no limit.
+func newCallLimiter(n int) *callLimiter {
+	l := &callLimiter{}
+	if n > 0 {
+		l.slots = make(chan struct{}, n)
+	}
+	return l
+}
+
+// acquire waits for a call slot to be available. It returns an error if ctx is done first.
+func (l *callLimiter) acquire(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	l.current.Add(1)
+	return nil
+}
+
+// release frees the slot taken by a previous acquire.
+func (l *callLimiter) release() {
+	if l == nil {
+		return
+	}
+
+	l.current.Add(-1)
+	if l.slots != nil {
+		<-l.slots
+	}
+}
+
+// inFlight returns the number of calls currently holding a slot.
+func (l *callLimiter) inFlight() int {
+	if l == nil {
+		return 0
+	}
+	return int(l.current.Load())
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/ubuntu/authd/internal/log"
@@ -15,14 +17,97 @@ import (
 // DbusInterface is the expected interface that should be implemented by the brokers.
 const DbusInterface string = "com.ubuntu.authd.Broker"
 
+const (
+	// DbusErrorAuthDenied is the dbus error name returned by a broker denying the authentication.
+	DbusErrorAuthDenied = DbusInterface + ".Error.AuthDenied"
+	// DbusErrorUserUnknown is the dbus error name returned by a broker not knowing the user.
+	DbusErrorUserUnknown = DbusInterface + ".Error.UserUnknown"
+	// DbusErrorInternal is the dbus error name returned by a broker failing on its side.
+	DbusErrorInternal = DbusInterface + ".Error.Internal"
+	// DbusErrorEnumerationNotSupported is the dbus error name returned by a broker which can't list its users.
+	DbusErrorEnumerationNotSupported = DbusInterface + ".Error.EnumerationNotSupported"
+)
+
+var (
+	// ErrAuthDenied is returned when the broker denied the authentication.
+	ErrAuthDenied = errors.New("authentication denied by broker")
+	// ErrUserUnknownToBroker is returned when the broker does not know the user.
+	ErrUserUnknownToBroker = errors.New("user unknown to broker")
+	// ErrBrokerInternal is returned when the broker failed on its side.
+	ErrBrokerInternal = errors.New("broker internal error")
+	// ErrEnumerationNotSupported is returned when the broker can't list its users.
+	ErrEnumerationNotSupported = errors.New("broker does not support enumerating its users")
+)
+
+// brokerError is an error reported by a broker. It keeps the broker message while matching its category.
+type brokerError struct {
+	error
+	category error
+}
+
+// Is makes this error match its category.
+func (e brokerError) Is(target error) bool { return target == e.category }
+
+// Unwrap returns the error reported by the broker.
+func (e brokerError) Unwrap() error { return e.error }
+
+// dbusErrorCategories maps the dbus error names returned by brokers to our error categories.
+var dbusErrorCategories = map[string]error{
+	DbusErrorAuthDenied:  ErrAuthDenied,
+	DbusErrorUserUnknown: ErrUserUnknownToBroker,
+	DbusErrorInternal:    ErrBrokerInternal,
+	// Brokers implementing EnumerateUsers return it when they can't list their users, for example for privacy.
+	DbusErrorEnumerationNotSupported: ErrEnumerationNotSupported,
+	// This is the generic error returned by brokers not reporting any specific category.
+	"org.freedesktop.DBus.Error.Failed": ErrBrokerInternal,
+}
+
+// defaultMaxSessionIDLength is the maximum length of the session IDs returned by a broker, unless configured otherwise.
+const defaultMaxSessionIDLength = 256
+
+// sessionContextProbeInterval is how long a broker which rejected the session context is called without it, before
+// being asked again whether it accepts it, in case it was upgraded.
+const sessionContextProbeInterval = 10 * time.Minute
+
+// sessionContextSupport records when the broker last rejected the session context, shared by all the copies of the
+// broker.
+type sessionContextSupport struct {
+	rejectedAt time.Time
+	mu         sync.Mutex
+}
+
+// accepted returns whether the session context should be passed to the broker: unless it rejected it recently.
+func (s *sessionContextSupport) accepted() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rejectedAt.IsZero() || time.Since(s.rejectedAt) > sessionContextProbeInterval
+}
+
+// reject records that the broker rejected the session context.
+func (s *sessionContextSupport) reject() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectedAt = time.Now()
+}
+
 type dbusBroker struct {
-	name string
+	name                  string
+	maxSessionIDLength    int
+	authoritativeUIDRange *UIDRange
+	strictUIDRange        bool
+	displayName           string
+	icon                  string
+
+	bus        *busConn
+	dbusName   string
+	objectPath dbus.ObjectPath
+	calls      *callLimiter
 
-	dbusObject dbus.BusObject
+	sessionContext *sessionContextSupport
 }
 
 // newDbusBroker returns a dbus broker and broker attributes from its configuration file.
-func newDbusBroker(ctx context.Context, bus *dbus.Conn, configFile string) (b dbusBroker, name, brandIcon string, err error) {
+func newDbusBroker(ctx context.Context, bus *busConn, configFile string, calls *callLimiter) (b dbusBroker, name, brandIcon string, err error) {
 	defer decorate.OnError(&err, "dbus broker from configuration file: %q", configFile)
 
 	log.Debugf(ctx, "Dbus broker configuration at %q", configFile)
@@ -52,23 +137,100 @@ func newDbusBroker(ctx context.Context, bus *dbus.Conn, configFile string) (b db
 		return b, "", "", fmt.Errorf("missing field for broker: %v", err)
 	}
 
+	if err := validateDbusName(dbusName.String()); err != nil {
+		return b, "", "", err
+	}
+	if err := validateDbusObjectPath(objectName.String()); err != nil {
+		return b, "", "", err
+	}
+
+	maxSessionIDLength := defaultMaxSessionIDLength
+	if k, err := cfg.Section("authd").GetKey("session_id_max_length"); err == nil {
+		if maxSessionIDLength, err = k.Int(); err != nil || maxSessionIDLength < 1 {
+			return b, "", "", fmt.Errorf("invalid session_id_max_length for broker: %q", k.String())
+		}
+	}
+
+	var authoritativeUIDRange *UIDRange
+	if k, err := cfg.Section("authd").GetKey("authoritative_uid_range"); err == nil {
+		r, err := parseUIDRange(k.String())
+		if err != nil {
+			return b, "", "", fmt.Errorf("invalid authoritative_uid_range for broker: %v", err)
+		}
+		authoritativeUIDRange = &r
+	}
+	var strictUIDRange bool
+	if k, err := cfg.Section("authd").GetKey("authoritative_uid_range_strict"); err == nil {
+		if strictUIDRange, err = k.Bool(); err != nil {
+			return b, "", "", fmt.Errorf("invalid authoritative_uid_range_strict for broker: %q", k.String())
+		}
+		if authoritativeUIDRange == nil && strictUIDRange {
+			return b, "", "", errors.New("authoritative_uid_range_strict is set without any authoritative_uid_range")
+		}
+	}
+
+	// The display name and icon are optional.
+	displayName := cfg.Section("authd").Key("display_name").String()
+	icon := cfg.Section("authd").Key("icon").String()
+
 	return dbusBroker{
-		name:       nameVal.String(),
-		dbusObject: bus.Object(dbusName.String(), dbus.ObjectPath(objectName.String())),
+		name:                  nameVal.String(),
+		displayName:           displayName,
+		icon:                  icon,
+		maxSessionIDLength:    maxSessionIDLength,
+		authoritativeUIDRange: authoritativeUIDRange,
+		strictUIDRange:        strictUIDRange,
+		bus:                   bus,
+		dbusName:              dbusName.String(),
+		objectPath:            dbus.ObjectPath(objectName.String()),
+		calls:                 calls,
+		sessionContext:        &sessionContextSupport{},
 	}, nameVal.String(), brandIconVal.String(), nil
 }
 
 // NewSession calls the corresponding method on the broker bus and returns the session ID and encryption key.
-func (b dbusBroker) NewSession(ctx context.Context, username, lang, mode string) (sessionID, encryptionKey string, err error) {
-	call, err := b.call(ctx, "NewSession", username, lang, mode)
+// Brokers can return the "algorithm" and "encoding" of the key in a dictionary after the key. The default ones are
+// used for brokers returning only the key.
+// The session context is passed as an extra dictionary argument. Brokers rejecting it are called again without it,
+// and are not passed it anymore until sessionContextProbeInterval elapsed.
+func (b dbusBroker) NewSession(ctx context.Context, username, lang, mode string, sessionContext map[string]string) (sessionID string, key KeyInfo, err error) {
+	if !b.sessionContext.accepted() {
+		return b.newSession(ctx, username, lang, mode)
+	}
+
+	if sessionContext == nil {
+		sessionContext = map[string]string{}
+	}
+	sessionID, key, err = b.newSession(ctx, username, lang, mode, sessionContext)
+	// The arguments are checked before calling the broker, so no session was created.
+	var dbusError dbus.Error
+	if errors.As(err, &dbusError) && dbusError.Name == "org.freedesktop.DBus.Error.InvalidArgs" {
+		log.Infof(ctx, "Broker %q does not accept a session context, creating its sessions without it", b.name)
+		b.sessionContext.reject()
+		return b.newSession(ctx, username, lang, mode)
+	}
+	return sessionID, key, err
+}
+
+// newSession calls NewSession on the broker bus with args, and returns the session ID and encryption key.
+func (b dbusBroker) newSession(ctx context.Context, args ...interface{}) (sessionID string, key KeyInfo, err error) {
+	call, err := b.call(ctx, "NewSession", args...)
 	if err != nil {
-		return "", "", err
+		return "", KeyInfo{}, err
 	}
-	if err = call.Store(&sessionID, &encryptionKey); err != nil {
-		return "", "", err
+
+	var encryptionKey string
+	var keyParams map[string]string
+	if len(call.Body) > 2 {
+		err = call.Store(&sessionID, &encryptionKey, &keyParams)
+	} else {
+		err = call.Store(&sessionID, &encryptionKey)
+	}
+	if err != nil {
+		return "", KeyInfo{}, err
 	}
 
-	return sessionID, encryptionKey, nil
+	return sessionID, newKeyInfo(encryptionKey, keyParams), nil
 }
 
 // GetAuthenticationModes calls the corresponding method on the broker bus and returns the authentication modes supported by it.
@@ -98,9 +260,16 @@ func (b dbusBroker) SelectAuthenticationMode(ctx context.Context, sessionID, aut
 }
 
 // IsAuthenticated calls the corresponding method on the broker bus and returns the user information and access.
-func (b dbusBroker) IsAuthenticated(_ context.Context, sessionID, authenticationData string) (access, data string, err error) {
+// Waiting for a call slot stops when ctx is done, but not the call itself, which is cancelled with
+// CancelIsAuthenticated.
+func (b dbusBroker) IsAuthenticated(ctx context.Context, sessionID, authenticationData string) (access, data string, err error) {
+	if err := b.acquireCall(ctx); err != nil {
+		return "", "", err
+	}
+	defer b.calls.release()
+
 	// We don’t want to cancel the context when the parent call is cancelled.
-	call, err := b.call(context.Background(), "IsAuthenticated", sessionID, authenticationData)
+	call, err := b.unlimitedCall(context.WithoutCancel(ctx), "IsAuthenticated", sessionID, authenticationData)
 	if err != nil {
 		return "", "", err
 	}
@@ -112,21 +281,41 @@ func (b dbusBroker) IsAuthenticated(_ context.Context, sessionID, authentication
 }
 
 // EndSession calls the corresponding method on the broker bus.
+// It doesn't wait for a call slot, as it frees the resources of the session on the broker.
 func (b dbusBroker) EndSession(ctx context.Context, sessionID string) (err error) {
-	if _, err := b.call(ctx, "EndSession", sessionID); err != nil {
+	if _, err := b.unlimitedCall(ctx, "EndSession", sessionID); err != nil {
 		return err
 	}
 	return nil
 }
 
 // CancelIsAuthenticated calls the corresponding method on the broker bus.
+// It doesn't wait for a call slot, as the IsAuthenticated call it cancels may be holding the last one.
 func (b dbusBroker) CancelIsAuthenticated(ctx context.Context, sessionID string) {
 	// We don’t want to cancel the context when the parent call is cancelled.
-	if _, err := b.call(context.Background(), "CancelIsAuthenticated", sessionID); err != nil {
+	if _, err := b.unlimitedCall(context.WithoutCancel(ctx), "CancelIsAuthenticated", sessionID); err != nil {
 		log.Errorf(ctx, "could not cancel IsAuthenticated call for session %q: %v", sessionID, err)
 	}
 }
 
+// HasSession calls the corresponding method on the broker bus.
+// Brokers not implementing the method can't tell, so their sessions are assumed to be ongoing until authd ends them.
+func (b dbusBroker) HasSession(ctx context.Context, sessionID string) (ongoing bool, err error) {
+	call, err := b.call(ctx, "HasSession", sessionID)
+	var dbusError dbus.Error
+	if errors.As(err, &dbusError) && dbusError.Name == "org.freedesktop.DBus.Error.UnknownMethod" {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err = call.Store(&ongoing); err != nil {
+		return false, err
+	}
+
+	return ongoing, nil
+}
+
 // UserPreCheck calls the corresponding method on the broker bus.
 func (b dbusBroker) UserPreCheck(ctx context.Context, username string) (userinfo string, err error) {
 	call, err := b.call(ctx, "UserPreCheck", username)
@@ -140,18 +329,92 @@ func (b dbusBroker) UserPreCheck(ctx context.Context, username string) (userinfo
 	return userinfo, nil
 }
 
+// EnumerateUsers calls the corresponding method on the broker bus.
+// Brokers not implementing the method don't support enumerating their users.
+func (b dbusBroker) EnumerateUsers(ctx context.Context) (userinfos string, err error) {
+	call, err := b.call(ctx, "EnumerateUsers")
+	var dbusError dbus.Error
+	if errors.As(err, &dbusError) && dbusError.Name == "org.freedesktop.DBus.Error.UnknownMethod" {
+		return "", fmt.Errorf("%w: %v", ErrEnumerationNotSupported, err)
+	}
+	if err != nil {
+		return "", err
+	}
+	if err = call.Store(&userinfos); err != nil {
+		return "", err
+	}
+
+	return userinfos, nil
+}
+
+// Ready returns whether the broker can take calls, which is when its dbus name is owned or can be activated.
+func (b dbusBroker) Ready(ctx context.Context) (ready bool, err error) {
+	return b.bus.nameReachable(ctx, b.dbusName)
+}
+
+// Ping checks that the broker answers calls, with the standard ping method of dbus peers, which is answered by the
+// dbus library of the broker without reaching its implementation.
+func (b dbusBroker) Ping(ctx context.Context) error {
+	obj, err := b.bus.object(b.dbusName, b.objectPath)
+	if err != nil {
+		return fmt.Errorf("couldn't connect to broker %q: %w", b.name, err)
+	}
+	if err := obj.CallWithContext(ctx, "org.freedesktop.DBus.Peer.Ping", 0).Err; err != nil {
+		return fmt.Errorf("broker %q didn't answer: %w", b.name, err)
+	}
+	return nil
+}
+
 // call is an abstraction over dbus calls to ensure we wrap the returned error to an ErrorToDisplay.
 // All wrapped errors will be logged, but not returned to the UI.
+// Errors reported by the broker are categorized, so that they match ErrAuthDenied, ErrUserUnknownToBroker or
+// ErrBrokerInternal with errors.Is. Calls failing because the connection to the system bus is down match
+// ErrBusUnavailable.
+// The call waits for a call slot first, until ctx is done.
 func (b dbusBroker) call(ctx context.Context, method string, args ...interface{}) (*dbus.Call, error) {
+	if err := b.acquireCall(ctx); err != nil {
+		return nil, err
+	}
+	defer b.calls.release()
+
+	return b.unlimitedCall(ctx, method, args...)
+}
+
+// acquireCall waits for a call slot to be available, until ctx is done. The slot must be released once the call is
+// over.
+func (b dbusBroker) acquireCall(ctx context.Context) error {
+	if err := b.calls.acquire(ctx); err != nil {
+		return errmessages.NewErrorToDisplay(fmt.Errorf("too many concurrent calls to brokers: %w", err))
+	}
+	return nil
+}
+
+// unlimitedCall is call without waiting for a call slot, for the calls which must not be held back by the other ones.
+func (b dbusBroker) unlimitedCall(ctx context.Context, method string, args ...interface{}) (*dbus.Call, error) {
+	obj, err := b.bus.object(b.dbusName, b.objectPath)
+	if err != nil {
+		err = fmt.Errorf("couldn't connect to broker %q: %v", b.name, err)
+		return nil, brokerError{error: errmessages.NewErrorToDisplay(err), category: ErrBusUnavailable}
+	}
+
 	dbusMethod := DbusInterface + "." + method
-	call := b.dbusObject.CallWithContext(ctx, dbusMethod, 0, args...)
+	call := obj.CallWithContext(ctx, dbusMethod, 0, args...)
 	if err := call.Err; err != nil {
+		// The bus dropped the connection during the call: it is reestablished in the background.
+		if !b.bus.connected() {
+			err = fmt.Errorf("connection to broker %q lost: %v", b.name, ErrBusUnavailable)
+			return nil, brokerError{error: errmessages.NewErrorToDisplay(err), category: ErrBusUnavailable}
+		}
+
 		var dbusError dbus.Error
 		// If the broker is not available ib dbus, the original "method was not provided by any .service files" isn't
 		// user-friendly, so we replace it with a better message.
 		if errors.As(err, &dbusError) && dbusError.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
 			err = fmt.Errorf("couldn't connect to broker %q. Is it running?", b.name)
 		}
+		if category, ok := dbusErrorCategories[dbusError.Name]; ok {
+			return nil, brokerError{error: errmessages.NewErrorToDisplay(err), category: category}
+		}
 		return nil, errmessages.NewErrorToDisplay(err)
 	}
 
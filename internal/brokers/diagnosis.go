@@ -0,0 +1,72 @@
+package brokers
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+var (
+	// ErrNotBrokerConfig is the reason of files of the brokers configuration directory not ending with .conf.
+	ErrNotBrokerConfig = errors.New("not a broker configuration file, only .conf files are supported")
+	// ErrInvalidBrokerConfig is the reason of broker configuration files which can't be parsed or miss a field.
+	ErrInvalidBrokerConfig = errors.New("invalid broker configuration")
+	// ErrInvalidDbusName is the reason of broker configuration files with an invalid dbus name or object path.
+	ErrInvalidDbusName = errors.New("invalid dbus name")
+	// ErrDuplicateBrokerID is the reason of brokers with the same ID as a broker loaded before them.
+	ErrDuplicateBrokerID = errors.New("duplicate broker ID")
+)
+
+// BrokerDiagnosis tells whether a broker configuration file was loaded, or why it wasn't.
+type BrokerDiagnosis struct {
+	// File is the path of the configuration file.
+	File string
+	// BrokerID is the ID of the broker, if it was loaded.
+	BrokerID string
+	// Loaded tells whether the broker was loaded.
+	Loaded bool
+	// Reason is why the broker was not loaded. It matches ErrNotBrokerConfig, ErrInvalidBrokerConfig,
+	// ErrInvalidDbusName or ErrDuplicateBrokerID with errors.Is.
+	Reason error
+}
+
+// DiagnoseBrokers returns, for each file of the brokers configuration directory, or each configured broker, whether
+// the broker was loaded when creating the manager, or on the last reload, and if not, why.
+func (m *Manager) DiagnoseBrokers() []BrokerDiagnosis {
+	m.brokersMu.RLock()
+	defer m.brokersMu.RUnlock()
+	return append([]BrokerDiagnosis(nil), m.diagnoses...)
+}
+
+// validateDbusName returns an error matching ErrInvalidDbusName if name is not a valid well-known bus name.
+func validateDbusName(name string) error {
+	if len(name) > 255 {
+		return fmt.Errorf("%w: %q is longer than 255 characters", ErrInvalidDbusName, name)
+	}
+
+	elements := strings.Split(name, ".")
+	if len(elements) < 2 {
+		return fmt.Errorf("%w: %q should have at least 2 elements separated by dots", ErrInvalidDbusName, name)
+	}
+	for _, e := range elements {
+		if e == "" || (e[0] >= '0' && e[0] <= '9') {
+			return fmt.Errorf("%w: %q has an empty element or one starting with a digit", ErrInvalidDbusName, name)
+		}
+		if strings.ContainsFunc(e, func(r rune) bool {
+			return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-')
+		}) {
+			return fmt.Errorf("%w: %q contains invalid characters", ErrInvalidDbusName, name)
+		}
+	}
+	return nil
+}
+
+// validateDbusObjectPath returns an error matching ErrInvalidDbusName if path is not a valid object path.
+func validateDbusObjectPath(path string) error {
+	if !dbus.ObjectPath(path).IsValid() {
+		return fmt.Errorf("%w: %q is not a valid object path", ErrInvalidDbusName, path)
+	}
+	return nil
+}
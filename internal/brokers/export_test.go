@@ -4,13 +4,15 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 )
 
 // NewBroker exports the private newBroker function for testing purposes.
+// The connection to the bus is not reestablished if it's dropped.
 func NewBroker(ctx context.Context, configFile string, bus *dbus.Conn) (Broker, error) {
-	return newBroker(ctx, configFile, bus)
+	return newBroker(ctx, configFile, &busConn{conn: bus}, nil)
 }
 
 // SetBrokerForSession sets the broker for a given session.
@@ -18,10 +20,35 @@ func NewBroker(ctx context.Context, configFile string, bus *dbus.Conn) (Broker,
 // This is to be used only in tests.
 func (m *Manager) SetBrokerForSession(b *Broker, sessionID string) {
 	m.transactionsToBrokerMu.Lock()
-	m.transactionsToBroker[sessionID] = b
+	m.transactionsToBroker[sessionID] = session{broker: b, createdAt: time.Now()}
 	m.transactionsToBrokerMu.Unlock()
 }
 
+// ExpireSession sets the session as past its maximum lifetime, to be ended by the session reaper.
+func (m *Manager) ExpireSession(sessionID string) {
+	m.transactionsToBrokerMu.Lock()
+	defer m.transactionsToBrokerMu.Unlock()
+	s := m.transactionsToBroker[sessionID]
+	s.expiresAt = time.Now().Add(-time.Second)
+	m.transactionsToBroker[sessionID] = s
+}
+
+// ReapExpiredSessions ends the sessions past their maximum lifetime, like the session reaper does on each tick.
+func (m *Manager) ReapExpiredSessions(ctx context.Context) {
+	m.reapExpiredSessions(ctx)
+}
+
+// ExpireSessionContextRejection makes the broker matching brokerID be asked again whether it accepts the session
+// context, as if sessionContextProbeInterval elapsed since it last rejected it.
+func (m *Manager) ExpireSessionContextRejection(brokerID string) {
+	m.brokersMu.RLock()
+	defer m.brokersMu.RUnlock()
+	s := m.brokers[brokerID].brokerer.(dbusBroker).sessionContext
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rejectedAt = time.Now().Add(-sessionContextProbeInterval - time.Second)
+}
+
 // GenerateLayoutValidators generates the layout validators and assign them to the specified broker.
 func GenerateLayoutValidators(b *Broker, sessionID string, supportedUILayouts []map[string]string) {
 	b.layoutValidatorsMu.Lock()
@@ -69,3 +96,12 @@ func (b *Broker) AddOngoingUserRequest(sessionID, username string) {
 	defer b.ongoingUserRequestsMu.Unlock()
 	b.ongoingUserRequests[sessionID] = username
 }
+
+// DropOngoingUserRequest removes an ongoing user request from the broker for tests.
+func (b *Broker) DropOngoingUserRequest(sessionID string) {
+	sessionID = b.parseSessionID(sessionID)
+
+	b.ongoingUserRequestsMu.Lock()
+	defer b.ongoingUserRequestsMu.Unlock()
+	delete(b.ongoingUserRequests, sessionID)
+}
@@ -0,0 +1,107 @@
+package brokers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+)
+
+// BrokerStatus is a broker and whether it answered the last health check.
+type BrokerStatus struct {
+	Broker  *Broker
+	Healthy bool
+}
+
+// healthState holds the result of the last health check of a broker, shared by all the copies of the broker.
+type healthState struct {
+	unhealthy bool
+	mu        sync.RWMutex
+}
+
+// Ping checks that the broker answers calls, with a lightweight dbus call which doesn't reach the implementation of
+// the broker. The local broker always answers.
+func (b Broker) Ping(ctx context.Context) error {
+	// The local broker is always healthy.
+	if b.brokerer == nil {
+		return nil
+	}
+	return b.brokerer.Ping(ctx)
+}
+
+// Healthy returns whether the broker answered the last health check. Brokers are healthy until a health check
+// fails.
+func (b Broker) Healthy() bool {
+	b.health.mu.RLock()
+	defer b.health.mu.RUnlock()
+	return !b.health.unhealthy
+}
+
+// HealthCheck pings all the brokers concurrently, but the local one, and records whether they answered, as returned
+// by AvailableBrokersWithStatus. It returns once all of them answered or failed to before ctx is done.
+func (m *Manager) HealthCheck(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, b := range m.AvailableBrokers() {
+		if b.ID == LocalBrokerName {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.recordHealth(ctx, b.Ping(ctx))
+		}()
+	}
+	wg.Wait()
+}
+
+// StartHealthChecks starts checking the health of the brokers every interval, so that the ones which stopped answering
+// are hidden from the clients until they answer again. Each health check gives up on the brokers which don't answer
+// within the broker call timeout.
+// The health checks stop when ctx is done, and the returned channel is closed once they did.
+func (m *Manager) StartHealthChecks(ctx context.Context, interval time.Duration) (done <-chan struct{}) {
+	d := make(chan struct{})
+	go func() {
+		defer close(d)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			checkCtx, cancel := m.withBrokerCallTimeout(ctx)
+			m.HealthCheck(checkCtx)
+			cancel()
+		}
+	}()
+	return d
+}
+
+// AvailableBrokersWithStatus returns currently loaded and available brokers in preference order, with whether they
+// answered the last health check, so that the ones which don't can be hidden.
+func (m *Manager) AvailableBrokersWithStatus() (r []BrokerStatus) {
+	for _, b := range m.AvailableBrokers() {
+		r = append(r, BrokerStatus{Broker: b, Healthy: b.Healthy()})
+	}
+	return r
+}
+
+// recordHealth records the result of a ping of the broker, logging when it changes.
+func (b Broker) recordHealth(ctx context.Context, pingErr error) {
+	b.health.mu.Lock()
+	defer b.health.mu.Unlock()
+
+	unhealthy := pingErr != nil
+	switch {
+	case unhealthy && !b.health.unhealthy:
+		log.Warningf(ctx, "Broker %q is unhealthy: %v", b.Name, pingErr)
+	case !unhealthy && b.health.unhealthy:
+		log.Infof(ctx, "Broker %q is healthy again", b.Name)
+	}
+	b.health.unhealthy = unhealthy
+}
@@ -1,9 +1,15 @@
 package brokers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/godbus/dbus/v5"
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/authd/internal/testutils"
 )
@@ -94,3 +100,190 @@ func TestUnmarshalUserInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestCallLimiter(t *testing.T) {
+	t.Parallel()
+
+	l := newCallLimiter(2)
+	require.Equal(t, 0, l.inFlight(), "No call should be in flight initially")
+
+	require.NoError(t, l.acquire(context.Background()), "First call should get a slot")
+	require.NoError(t, l.acquire(context.Background()), "Second call should get a slot")
+	require.Equal(t, 2, l.inFlight(), "Two calls should be in flight")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, l.acquire(ctx), context.DeadlineExceeded, "Third call should wait until its deadline")
+	require.Equal(t, 2, l.inFlight(), "Timed out call should not be in flight")
+
+	acquired := make(chan error)
+	go func() { acquired <- l.acquire(context.Background()) }()
+	select {
+	case <-acquired:
+		t.Fatal("Third call should wait for a slot to be released")
+	case <-time.After(10 * time.Millisecond):
+	}
+	l.release()
+	require.NoError(t, <-acquired, "Third call should get the released slot")
+	require.Equal(t, 2, l.inFlight(), "Two calls should be in flight")
+
+	l.release()
+	l.release()
+	require.Equal(t, 0, l.inFlight(), "No call should be in flight once all are released")
+
+	unlimited := newCallLimiter(0)
+	for range 10 {
+		require.NoError(t, unlimited.acquire(context.Background()), "Unlimited calls should always get a slot")
+	}
+	require.Equal(t, 10, unlimited.inFlight(), "All calls should be in flight")
+
+	var noLimiter *callLimiter
+	require.NoError(t, noLimiter.acquire(context.Background()), "Nil limiter should not limit calls")
+	noLimiter.release()
+	require.Equal(t, 0, noLimiter.inFlight(), "Nil limiter should not count calls")
+}
+
+func TestBusConnReconnects(t *testing.T) {
+	t.Parallel()
+
+	cfgPath, cleanup, err := testutils.StartBusBrokerMock(t.TempDir(), "BusConnReconnects")
+	require.NoError(t, err, "Setup: could not start bus broker mock")
+	t.Cleanup(cleanup)
+
+	var busDown atomic.Bool
+	var attempts atomic.Int32
+	conns := make(chan *dbus.Conn, 10)
+	connect := func() (*dbus.Conn, error) {
+		attempts.Add(1)
+		if busDown.Load() {
+			return nil, errors.New("bus is down")
+		}
+		conn, err := testutils.GetSystemBusConnection(t)
+		if err != nil {
+			return nil, err
+		}
+		conns <- conn
+		return conn, nil
+	}
+	t.Cleanup(func() {
+		close(conns)
+		for conn := range conns {
+			_ = conn.Close()
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	bus, err := newBusConn(ctx, connect, time.Millisecond, 10*time.Millisecond)
+	require.NoError(t, err, "Setup: newBusConn should not return an error")
+	b, err := newBroker(ctx, cfgPath, bus, nil)
+	require.NoError(t, err, "Setup: could not create broker")
+
+	_, err = b.UserPreCheck(ctx, "user-pre-check")
+	require.NoError(t, err, "UserPreCheck should succeed while connected")
+	require.True(t, bus.connected(), "Bus should be connected initially")
+
+	// Simulate the bus dropping the connection, and not accepting new ones for a while.
+	busDown.Store(true)
+	attemptsBefore := attempts.Load()
+	require.NoError(t, (<-conns).Close(), "Setup: could not close the connection")
+
+	require.Eventually(t, func() bool { return attempts.Load() >= attemptsBefore+3 }, time.Second, time.Millisecond,
+		"Reconnection should be retried while the bus is down")
+	require.False(t, bus.connected(), "Bus should not be connected while it's down")
+	_, err = b.UserPreCheck(ctx, "user-pre-check")
+	require.ErrorIs(t, err, ErrBusUnavailable, "UserPreCheck should fail with a retryable error while the bus is down")
+
+	busDown.Store(false)
+	require.Eventually(t, bus.connected, time.Second, time.Millisecond, "Bus should be reconnected once it's up again")
+	_, err = b.UserPreCheck(ctx, "user-pre-check")
+	require.NoError(t, err, "UserPreCheck should succeed after reconnection")
+}
+
+func TestQueryCache(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	var result string
+	var resultErr error
+	query := func(context.Context) (string, error) {
+		calls++
+		return result, resultErr
+	}
+
+	c := newQueryCache(50 * time.Millisecond)
+	ctx := context.Background()
+
+	result = "userinfo"
+	got, err := c.get(ctx, "broker", "UserPreCheck", "user1", query)
+	require.NoError(t, err, "First query should not fail")
+	require.Equal(t, "userinfo", got, "First query should return the result of the broker")
+
+	result = "changed"
+	got, err = c.get(ctx, "broker", "UserPreCheck", "user1", query)
+	require.NoError(t, err, "Cached query should not fail")
+	require.Equal(t, "userinfo", got, "Cached query should return the cached result")
+	require.Equal(t, 1, calls, "Cached query should not call the broker")
+
+	_, err = c.get(ctx, "other-broker", "UserPreCheck", "user1", query)
+	require.NoError(t, err, "Query to another broker should not fail")
+	require.Equal(t, 2, calls, "Queries to other brokers should not share the cache")
+
+	result, resultErr = "", ErrUserUnknownToBroker
+	_, err = c.get(ctx, "broker", "UserPreCheck", "unknown", query)
+	require.ErrorIs(t, err, ErrUserUnknownToBroker, "Unknown user should be returned")
+	_, err = c.get(ctx, "broker", "UserPreCheck", "unknown", query)
+	require.ErrorIs(t, err, ErrUserUnknownToBroker, "Cached unknown user should be returned")
+	require.Equal(t, 3, calls, "Unknown users should be cached")
+
+	resultErr = errors.New("transient error")
+	_, err = c.get(ctx, "broker", "UserPreCheck", "user2", query)
+	require.Error(t, err, "Failing query should fail")
+	_, err = c.get(ctx, "broker", "UserPreCheck", "user2", query)
+	require.Error(t, err, "Failing query should fail again")
+	require.Equal(t, 5, calls, "Transient errors should not be cached")
+
+	stats := c.stats()
+	require.Equal(t, QueryCacheStats{Hits: 2, Misses: 5}, stats, "Stats should count hits and misses")
+	require.InDelta(t, 2.0/7, stats.HitRate(), 1e-9, "Hit rate should be the ratio of hits")
+
+	result, resultErr = "changed", nil
+	time.Sleep(60 * time.Millisecond)
+	got, err = c.get(ctx, "broker", "UserPreCheck", "user1", query)
+	require.NoError(t, err, "Expired query should not fail")
+	require.Equal(t, "changed", got, "Expired results should be queried again")
+
+	c.clear()
+	_, _ = c.get(ctx, "broker", "UserPreCheck", "user1", query)
+	require.Equal(t, 7, calls, "Cleared results should be queried again")
+
+	var disabled *queryCache
+	_, _ = disabled.get(ctx, "broker", "UserPreCheck", "user1", query)
+	_, _ = disabled.get(ctx, "broker", "UserPreCheck", "user1", query)
+	require.Equal(t, 9, calls, "A disabled cache should always query the broker")
+	require.Zero(t, disabled.stats().HitRate(), "A disabled cache should have no hit")
+}
+
+func TestSetDefaultBrokerBoundsContexts(t *testing.T) {
+	t.Parallel()
+
+	global, b1, b2 := &Broker{ID: "global"}, &Broker{ID: "b1"}, &Broker{ID: "b2"}
+	m := &Manager{defaultBrokers: make(map[string]*Broker)}
+
+	m.setDefaultBroker("", global)
+	for i := range maxDefaultBrokerContexts {
+		m.setDefaultBroker(fmt.Sprintf("tty%d", i), b1)
+	}
+	require.Len(t, m.defaultBrokers, maxDefaultBrokerContexts+1, "All contexts up to the limit should be memorized")
+
+	// Setting the default of a context again makes it the most recent one.
+	m.setDefaultBroker("tty0", b2)
+	m.setDefaultBroker("new-tty", b2)
+
+	require.Len(t, m.defaultBrokers, maxDefaultBrokerContexts+1, "Contexts beyond the limit should not be memorized")
+	require.Equal(t, global, m.DefaultBroker("tty1"), "The context set the longest ago should fall back to the global default")
+	require.Equal(t, b2, m.DefaultBroker("tty0"), "A context set again should be kept")
+	require.Equal(t, b2, m.DefaultBroker("new-tty"), "The new context should be memorized")
+	require.Equal(t, global, m.DefaultBroker(""), "The global default should never be forgotten")
+}
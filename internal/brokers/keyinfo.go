@@ -0,0 +1,36 @@
+package brokers
+
+const (
+	// DefaultKeyAlgorithm is the algorithm of the encryption keys of brokers not reporting one: RSA with OAEP padding
+	// and SHA-512.
+	DefaultKeyAlgorithm = "RSA-OAEP-SHA512"
+	// DefaultKeyEncoding is the encoding of the encryption keys of brokers not reporting one: the base64 of the
+	// public key in PKIX, ASN.1 DER form.
+	DefaultKeyEncoding = "base64-pkix-der"
+)
+
+// KeyInfo is the encryption key returned by a broker for a session, with how to interpret it.
+type KeyInfo struct {
+	// Algorithm is the algorithm the secrets sent to the broker must be encrypted with.
+	Algorithm string
+	// Encoding is how Key is encoded.
+	Encoding string
+	Key      string
+}
+
+// newKeyInfo returns the information of the key, with the algorithm and encoding reported by the broker in params.
+// Brokers not reporting them use the default ones.
+func newKeyInfo(key string, params map[string]string) KeyInfo {
+	k := KeyInfo{
+		Algorithm: params["algorithm"],
+		Encoding:  params["encoding"],
+		Key:       key,
+	}
+	if k.Algorithm == "" {
+		k.Algorithm = DefaultKeyAlgorithm
+	}
+	if k.Encoding == "" {
+		k.Encoding = DefaultKeyEncoding
+	}
+	return k
+}
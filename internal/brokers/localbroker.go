@@ -10,8 +10,8 @@ type localBroker struct {
 }
 
 //nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
-func (b localBroker) NewSession(ctx context.Context, username, lang, mode string) (sessionID, encryptionKey string, err error) {
-	return "", "", errors.New("NewSession should never be called on local broker")
+func (b localBroker) NewSession(ctx context.Context, username, lang, mode string, sessionContext map[string]string) (sessionID string, key KeyInfo, err error) {
+	return "", KeyInfo{}, errors.New("NewSession should never be called on local broker")
 }
 
 //nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
@@ -38,7 +38,27 @@ func (b localBroker) EndSession(ctx context.Context, sessionID string) (err erro
 func (b localBroker) CancelIsAuthenticated(ctx context.Context, sessionID string) {
 }
 
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) HasSession(ctx context.Context, sessionID string) (bool, error) {
+	return false, errors.New("HasSession should never be called on local broker")
+}
+
 //nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
 func (b localBroker) UserPreCheck(ctx context.Context, username string) (string, error) {
 	return "", errors.New("UserPreCheck should never be called on local broker")
 }
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) EnumerateUsers(ctx context.Context) (string, error) {
+	return "", errors.New("EnumerateUsers should never be called on local broker")
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) Ready(ctx context.Context) (bool, error) {
+	return true, nil
+}
+
+//nolint:unused // We still need localBroker to implement the brokerer interface, even though this method should never be called on it.
+func (b localBroker) Ping(ctx context.Context) error {
+	return nil
+}
@@ -0,0 +1,68 @@
+package brokers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+)
+
+// BrokerMaintenance is the maintenance notice of a broker, to be shown to users.
+type BrokerMaintenance struct {
+	Message string
+	// Until is when the maintenance ends. The maintenance lasts until it is cleared if zero.
+	Until time.Time
+}
+
+// MaintenanceError is returned when creating a session with a broker under maintenance.
+type MaintenanceError struct {
+	BrokerID string
+	BrokerMaintenance
+}
+
+func (e MaintenanceError) Error() string {
+	return e.Message
+}
+
+// maintenanceState holds the maintenance notice of a broker, shared by all the copies of the broker.
+type maintenanceState struct {
+	notice *BrokerMaintenance
+	mu     sync.RWMutex
+}
+
+// Maintenance returns the maintenance notice of the broker and whether it is currently under maintenance.
+func (b Broker) Maintenance() (BrokerMaintenance, bool) {
+	b.maintenance.mu.RLock()
+	defer b.maintenance.mu.RUnlock()
+
+	n := b.maintenance.notice
+	if n == nil || (!n.Until.IsZero() && time.Now().After(n.Until)) {
+		return BrokerMaintenance{}, false
+	}
+	return *n, true
+}
+
+// SetBrokerMaintenance puts the broker under maintenance until the given time, or until it is cleared if zero.
+// Sessions can't be created with the broker during the maintenance, and the message is returned to the client
+// instead. An empty message clears the maintenance.
+func (m *Manager) SetBrokerMaintenance(id, message string, until time.Time) error {
+	b, err := m.brokerFromID(id)
+	if err != nil {
+		return fmt.Errorf("invalid broker: %v", err)
+	}
+
+	b.maintenance.mu.Lock()
+	defer b.maintenance.mu.Unlock()
+
+	if message == "" {
+		log.Infof(context.Background(), "Broker %q is not under maintenance anymore", b.Name)
+		b.maintenance.notice = nil
+		return nil
+	}
+
+	log.Infof(context.Background(), "Broker %q is under maintenance: %s", b.Name, message)
+	b.maintenance.notice = &BrokerMaintenance{Message: message, Until: until}
+	return nil
+}
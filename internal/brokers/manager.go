@@ -4,37 +4,183 @@ package brokers
 
 import (
 	"context"
-	"errors"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
-	"io/fs"
-	"os"
-	"path/filepath"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/authd/internal/services/errmessages"
+	"github.com/ubuntu/authd/internal/users"
 	"github.com/ubuntu/decorate"
 )
 
 // Manager is the object that manages the available brokers and the session->broker and user->broker relationships.
 type Manager struct {
-	brokers      map[string]*Broker
-	brokersOrder []string
+	brokerSet
+	brokersMu sync.RWMutex
+	reloadMu  sync.Mutex
+
+	brokersConfPath   string
+	configuredBrokers []string
+	brokerOrder       []string
 
 	usersToBroker   map[string]*Broker
 	usersToBrokerMu sync.RWMutex
 
-	transactionsToBroker   map[string]*Broker
+	defaultBrokers        map[string]*Broker
+	defaultBrokerContexts []string
+	defaultBrokersMu      sync.RWMutex
+
+	breakGlassUsers   map[string]struct{}
+	breakGlassUsersMu sync.RWMutex
+
+	brokerPolicy   BrokerPolicy
+	brokerPolicyMu sync.RWMutex
+
+	domainBrokers   map[string]*Broker
+	domainBrokerIDs map[string]string
+
+	transactionsToBroker   map[string]session
+	sessionContexts        map[string]map[string]string
+	expiredSessions        map[string]time.Time
 	transactionsToBrokerMu sync.RWMutex
+	sessionTTL             time.Duration
+	sessionStore           *sessionStore
+
+	resumptions        map[string]resumption
+	resumptionsMu      sync.Mutex
+	resumptionTokenTTL time.Duration
+
+	brokerReadyTimeout time.Duration
+	brokerCallTimeout  time.Duration
+
+	bus          *busConn
+	calls        *callLimiter
+	queries      *queryCache
+	tracer       Tracer
+	newSessionID func() string
 
 	cleanup func()
 }
 
+type options struct {
+	maxConcurrentBrokerCalls int
+	breakGlassUsers          []string
+	resumptionTokenTTL       time.Duration
+	tracer                   Tracer
+	sessionIDGenerator       func() string
+	brokerQueryTTL           time.Duration
+	domainBrokers            map[string]string
+	brokerReadyTimeout       time.Duration
+	brokerCallTimeout        time.Duration
+	sessionTTL               time.Duration
+	sessionStorePath         string
+	brokerOrder              []string
+}
+
+// Option represents an optional function to override NewManager default values.
+type Option func(*options)
+
+// WithMaxConcurrentBrokerCalls bounds how many broker calls can be in flight at once, across all brokers and sessions.
+// Calls beyond the limit wait for a slot or for their context to be done. 0 means no limit.
+func WithMaxConcurrentBrokerCalls(n int) Option {
+	return func(o *options) {
+		o.maxConcurrentBrokerCalls = n
+	}
+}
+
+// WithBreakGlassUsers sets the users which are always routed to the local broker, whatever broker they previously
+// used, so that they can still log in when remote brokers are unavailable.
+func WithBreakGlassUsers(users []string) Option {
+	return func(o *options) {
+		o.breakGlassUsers = users
+	}
+}
+
+// defaultBrokerCallTimeout is how long the calls to the brokers starting and ending sessions can take, unless
+// configured otherwise.
+const defaultBrokerCallTimeout = 30 * time.Second
+
+// WithBrokerCallTimeout sets how long the calls to the brokers made by NewSession, EndSession and the session reaper
+// can take before failing with an error matching context.DeadlineExceeded, so that a hung broker doesn't block its
+// caller. 0 disables the timeout, leaving it to the context of the caller.
+func WithBrokerCallTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.brokerCallTimeout = d
+	}
+}
+
+// WithResumptionTokenTTL sets how long the resumption tokens returned by NewSession can be used.
+func WithResumptionTokenTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.resumptionTokenTTL = d
+	}
+}
+
+// WithSessionIDGenerator sets the function generating the session IDs given to clients, which are mapped to the
+// session IDs of the brokers. It must return unique values.
+func WithSessionIDGenerator(fn func() string) Option {
+	return func(o *options) {
+		o.sessionIDGenerator = fn
+	}
+}
+
+// WithBrokerOrder sets the preference order of the brokers by name, as returned by AvailableBrokers. The brokers which
+// are not listed come after the listed ones, in the order of their configuration files. The local broker always comes
+// first.
+func WithBrokerOrder(names []string) Option {
+	return func(o *options) {
+		o.brokerOrder = names
+	}
+}
+
+// WithDomainBrokerMap sets, by domain, the ID of the broker to route users named user@domain to when they have no
+// previous broker. Domains are matched case-insensitively, and all the broker IDs must match loaded brokers.
+func WithDomainBrokerMap(domainBrokers map[string]string) Option {
+	return func(o *options) {
+		o.domainBrokers = domainBrokers
+	}
+}
+
+// randomSessionID returns a random session ID.
+func randomSessionID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// This only happens if the random source of the system is broken, in which case we can't do anything secure.
+		panic(fmt.Sprintf("could not generate session ID: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
+
 // NewManager creates a new broker manager object.
-func NewManager(ctx context.Context, brokersConfPath string, configuredBrokers []string) (m *Manager, err error) {
+func NewManager(ctx context.Context, brokersConfPath string, configuredBrokers []string, args ...Option) (m *Manager, err error) {
 	defer decorate.OnError(&err /*i18n.G(*/, "can't create brokers detection object") //)
 
+	opts := options{
+		resumptionTokenTTL: defaultResumptionTokenTTL,
+		sessionIDGenerator: randomSessionID,
+		brokerQueryTTL:     DefaultBrokerQueryTTL,
+		sessionTTL:         DefaultSessionTTL,
+		brokerCallTimeout:  defaultBrokerCallTimeout,
+	}
+	for _, arg := range args {
+		arg(&opts)
+	}
+	if opts.tracer == nil {
+		opts.tracer = noopTracer{}
+	}
+	if opts.maxConcurrentBrokerCalls < 0 {
+		return nil, fmt.Errorf("invalid maximum number of concurrent broker calls: %d", opts.maxConcurrentBrokerCalls)
+	}
+	calls := newCallLimiter(opts.maxConcurrentBrokerCalls)
+	queries := newQueryCache(opts.brokerQueryTTL)
+
 	log.Debug(ctx, "Building broker detection")
 
 	brokersConfPathWithExample, cleanup, err := useExampleBrokers()
@@ -46,73 +192,130 @@ func NewManager(ctx context.Context, brokersConfPath string, configuredBrokers [
 
 	// Connect to the system bus
 	// Don't call dbus.SystemBus which caches globally system dbus (issues in tests)
-	bus, err := dbus.ConnectSystemBus()
+	bus, err := newBusConn(ctx, func() (*dbus.Conn, error) { return dbus.ConnectSystemBus() },
+		defaultMinReconnectDelay, defaultMaxReconnectDelay)
 	if err != nil {
 		return m, err
 	}
 
-	// Select all brokers in ascii order if none is configured
-	if len(configuredBrokers) == 0 {
-		log.Debug(ctx, "Auto-detecting brokers")
+	configFiles, diagnoses, err := brokerConfigFiles(ctx, brokersConfPath, configuredBrokers)
+	if err != nil {
+		return m, err
+	}
+	loaded := loadBrokers(ctx, configFiles, opts.brokerOrder, bus, calls, queries, brokerSet{})
+	loaded.diagnoses = append(diagnoses, loaded.diagnoses...)
 
-		entries, err := os.ReadDir(brokersConfPath)
-		if errors.Is(err, fs.ErrNotExist) {
-			log.Warningf(ctx, "Broker configuration directory %q does not exist, only local broker will be available", brokersConfPath)
-		} else if err != nil {
-			return m, fmt.Errorf("could not read brokers directory to detect brokers: %v", err)
-		}
+	m = &Manager{
+		brokerSet: loaded,
 
-		for _, e := range entries {
-			if !e.Type().IsRegular() {
-				continue
-			}
-			if !strings.HasSuffix(e.Name(), ".conf") {
-				log.Infof(ctx, "Skipping file %q in brokers configuration directory, only .conf files are supported", e.Name())
-				continue
-			}
-			configuredBrokers = append(configuredBrokers, e.Name())
-		}
+		brokersConfPath:   brokersConfPath,
+		configuredBrokers: configuredBrokers,
+		brokerOrder:       opts.brokerOrder,
+
+		usersToBroker:        make(map[string]*Broker),
+		defaultBrokers:       make(map[string]*Broker),
+		breakGlassUsers:      usersSet(opts.breakGlassUsers),
+		transactionsToBroker: make(map[string]session),
+		sessionContexts:      make(map[string]map[string]string),
+		expiredSessions:      make(map[string]time.Time),
+		sessionTTL:           opts.sessionTTL,
+
+		resumptions:        make(map[string]resumption),
+		resumptionTokenTTL: opts.resumptionTokenTTL,
+
+		brokerReadyTimeout: opts.brokerReadyTimeout,
+		brokerCallTimeout:  opts.brokerCallTimeout,
+
+		bus:          bus,
+		calls:        calls,
+		queries:      queries,
+		tracer:       opts.tracer,
+		newSessionID: opts.sessionIDGenerator,
+
+		cleanup: cleanup,
 	}
 
-	brokers := make(map[string]*Broker)
-	var brokersOrder []string
+	if err := m.SetDomainBrokerMap(opts.domainBrokers); err != nil {
+		return nil, err
+	}
 
-	// First broker is always the local one.
-	b, err := newBroker(ctx, "", nil)
-	brokersOrder = append(brokersOrder, b.ID)
-	brokers[b.ID] = &b
+	if opts.sessionStorePath != "" {
+		if m.sessionStore, err = openSessionStore(opts.sessionStorePath); err != nil {
+			return nil, err
+		}
+		if err := m.restoreSessions(ctx); err != nil {
+			_ = m.sessionStore.close()
+			return nil, err
+		}
+	}
 
-	// Load brokers configuration
-	for _, cfgFileName := range configuredBrokers {
-		configFile := filepath.Join(brokersConfPath, cfgFileName)
-		b, err := newBroker(ctx, configFile, bus)
-		if err != nil {
-			log.Warningf(ctx, "Skipping broker %q is not correctly configured: %v", cfgFileName, err)
+	return m, nil
+}
+
+// logOverlappingUIDRanges logs an error for each pair of brokers declaring overlapping authoritative UID ranges.
+// Routing by UID picks the first of them in preference order.
+func logOverlappingUIDRanges(ctx context.Context, brokers map[string]*Broker, brokersOrder []string) {
+	for i, id := range brokersOrder {
+		r, ok := brokers[id].AuthoritativeUIDRange()
+		if !ok {
 			continue
 		}
-		brokersOrder = append(brokersOrder, b.ID)
-		brokers[b.ID] = &b
+		for _, otherID := range brokersOrder[i+1:] {
+			other, ok := brokers[otherID].AuthoritativeUIDRange()
+			if !ok || !r.overlaps(other) {
+				continue
+			}
+			log.Errorf(ctx, "Authoritative UID ranges of brokers %q (%d-%d) and %q (%d-%d) overlap",
+				brokers[id].Name, r.Min, r.Max, brokers[otherID].Name, other.Min, other.Max)
+		}
 	}
+}
 
-	return &Manager{
-		brokers:      brokers,
-		brokersOrder: brokersOrder,
-
-		usersToBroker:        make(map[string]*Broker),
-		transactionsToBroker: make(map[string]*Broker),
+// BrokerForUID returns the first broker, in preference order, which is authoritative for the given UID, if any.
+func (m *Manager) BrokerForUID(uid uint32) *Broker {
+	m.brokersMu.RLock()
+	defer m.brokersMu.RUnlock()
 
-		cleanup: cleanup,
-	}, nil
+	for _, id := range m.brokersOrder {
+		if r, ok := m.brokers[id].AuthoritativeUIDRange(); ok && r.Contains(uid) {
+			return m.brokers[id]
+		}
+	}
+	return nil
 }
 
 // AvailableBrokers returns currently loaded and available brokers in preference order.
 func (m *Manager) AvailableBrokers() (r []*Broker) {
+	m.brokersMu.RLock()
+	defer m.brokersMu.RUnlock()
+
 	for _, id := range m.brokersOrder {
 		r = append(r, m.brokers[id])
 	}
 	return r
 }
 
+// BrokerInfo is the human readable information of a broker, to be shown to users.
+type BrokerInfo struct {
+	ID string
+	// Name and BrandIconPath are the ones of the configuration of the broker, empty if not configured.
+	Name          string
+	BrandIconPath string
+	// DisplayName and Icon are the ones to render the broker with, falling back to Name and BrandIconPath.
+	DisplayName string
+	Icon        string
+	// Maintenance is the maintenance notice of the broker, if it is under maintenance.
+	Maintenance *BrokerMaintenance
+}
+
+// LoadedBrokers returns the information of the currently loaded brokers in preference order.
+func (m *Manager) LoadedBrokers() (r []BrokerInfo) {
+	for _, b := range m.AvailableBrokers() {
+		r = append(r, b.Info())
+	}
+	return r
+}
+
 // SetDefaultBrokerForUser memorizes which broker was used for which user.
 func (m *Manager) SetDefaultBrokerForUser(brokerID, username string) error {
 	broker, err := m.brokerFromID(brokerID)
@@ -126,72 +329,426 @@ func (m *Manager) SetDefaultBrokerForUser(brokerID, username string) error {
 	return nil
 }
 
-// BrokerForUser returns any previously selected broker for a given user, if any.
+// SetBreakGlassUsers replaces the users which are always routed to the local broker.
+func (m *Manager) SetBreakGlassUsers(users []string) {
+	m.breakGlassUsersMu.Lock()
+	defer m.breakGlassUsersMu.Unlock()
+	m.breakGlassUsers = usersSet(users)
+}
+
+// isBreakGlassUser returns whether the user is always routed to the local broker.
+func (m *Manager) isBreakGlassUser(username string) bool {
+	m.breakGlassUsersMu.RLock()
+	defer m.breakGlassUsersMu.RUnlock()
+	_, ok := m.breakGlassUsers[username]
+	return ok
+}
+
+// BrokerForUser returns any previously selected broker for a given user, if any, unless the broker policy doesn't
+// permit the user to use it anymore. Break-glass users always get the local broker, whatever the policy.
 func (m *Manager) BrokerForUser(username string) (broker *Broker) {
-	m.usersToBrokerMu.RLock()
-	defer m.usersToBrokerMu.RUnlock()
-	return m.usersToBroker[username]
+	if m.isBreakGlassUser(username) {
+		broker, _ = m.brokerFromID(LocalBrokerName)
+	} else {
+		m.usersToBrokerMu.RLock()
+		broker = m.usersToBroker[username]
+		m.usersToBrokerMu.RUnlock()
+	}
+
+	if broker == nil || !m.isPermittedBroker(username, broker) {
+		return nil
+	}
+	return broker
+}
+
+// SetDomainBrokerMap replaces, by domain, the ID of the broker to route users named user@domain to when they have no
+// previous broker. Domains are matched case-insensitively.
+// It returns an error if any of the broker IDs doesn't match a loaded broker, in which case the previous map is kept.
+func (m *Manager) SetDomainBrokerMap(domainBrokers map[string]string) error {
+	m.brokersMu.Lock()
+	defer m.brokersMu.Unlock()
+
+	byDomain := make(map[string]*Broker, len(domainBrokers))
+	for domain, brokerID := range domainBrokers {
+		b, exists := m.brokers[brokerID]
+		if !exists {
+			return fmt.Errorf("domain %q is mapped to broker %q, which is not loaded", domain, brokerID)
+		}
+		byDomain[strings.ToLower(domain)] = b
+	}
+	m.domainBrokers = byDomain
+	m.domainBrokerIDs = maps.Clone(domainBrokers)
+	return nil
+}
+
+// brokerForDomain returns the broker mapped to the domain of a username of the form user@domain, if any.
+func (m *Manager) brokerForDomain(username string) *Broker {
+	i := strings.LastIndex(username, "@")
+	if i < 0 {
+		return nil
+	}
+
+	m.brokersMu.RLock()
+	defer m.brokersMu.RUnlock()
+	return m.domainBrokers[strings.ToLower(username[i+1:])]
+}
+
+// DefaultBroker returns the broker last used to start a session in the given login context, falling back to
+// the one last used without any login context. It returns nil if no broker was selected yet.
+func (m *Manager) DefaultBroker(contextKey string) *Broker {
+	m.defaultBrokersMu.RLock()
+	defer m.defaultBrokersMu.RUnlock()
+
+	if b, ok := m.defaultBrokers[contextKey]; ok {
+		return b
+	}
+	return m.defaultBrokers[""]
+}
+
+// maxDefaultBrokerContexts bounds the number of login contexts with their own default broker. When it's reached, the
+// context whose default was set the longest ago is forgotten and falls back to the global default again.
+const maxDefaultBrokerContexts = 1024
+
+// setDefaultBroker memorizes the broker as the default of the login context.
+func (m *Manager) setDefaultBroker(contextKey string, broker *Broker) {
+	m.defaultBrokersMu.Lock()
+	defer m.defaultBrokersMu.Unlock()
+
+	m.defaultBrokers[contextKey] = broker
+	if contextKey == "" {
+		return
+	}
+
+	m.defaultBrokerContexts = slices.DeleteFunc(m.defaultBrokerContexts, func(k string) bool { return k == contextKey })
+	m.defaultBrokerContexts = append(m.defaultBrokerContexts, contextKey)
+	if len(m.defaultBrokerContexts) > maxDefaultBrokerContexts {
+		delete(m.defaultBrokers, m.defaultBrokerContexts[0])
+		m.defaultBrokerContexts = m.defaultBrokerContexts[1:]
+	}
 }
 
 // BrokerFromSessionID returns broker currently in use for a given transaction sessionID.
 func (m *Manager) BrokerFromSessionID(id string) (broker *Broker, err error) {
-	m.transactionsToBrokerMu.RLock()
-	defer m.transactionsToBrokerMu.RUnlock()
-
 	// no session ID means local broker
 	if id == "" {
 		return m.brokerFromID(LocalBrokerName)
 	}
 
-	broker, exists := m.transactionsToBroker[id]
+	m.transactionsToBrokerMu.RLock()
+	defer m.transactionsToBrokerMu.RUnlock()
+
+	s, exists := m.transactionsToBroker[id]
 	if !exists {
-		return nil, fmt.Errorf("no broker found for session %q", id)
+		return nil, m.sessionNotFoundError(id)
 	}
 
-	return broker, nil
+	return s.broker, nil
 }
 
-// NewSession create a new session for the broker and store the sesssionID on the manager.
-func (m *Manager) NewSession(brokerID, username, lang, mode string) (sessionID string, encryptionKey string, err error) {
-	broker, err := m.brokerFromID(brokerID)
+type sessionOptions struct {
+	contextKey     string
+	sessionContext map[string]string
+	ttl            *time.Duration
+}
+
+// SessionOption represents an optional function to override NewSession default values.
+type SessionOption func(*sessionOptions)
+
+// WithContextKey scopes the default broker memorized by NewSession to the given login context (e.g. a tty), so that
+// the selection made in one context does not become the default of another one.
+func WithContextKey(key string) SessionOption {
+	return func(o *sessionOptions) {
+		o.contextKey = key
+	}
+}
+
+// WithSessionContext passes opaque context, like a device ID or the IP address of the client, to the broker when
+// creating the session. authd does not interpret it, and brokers ignore the keys they don't understand.
+// The context is kept for the lifetime of the session.
+func WithSessionContext(sessionContext map[string]string) SessionOption {
+	return func(o *sessionOptions) {
+		o.sessionContext = maps.Clone(sessionContext)
+	}
+}
+
+// NewSession create a new session for the broker and store the sesssionID on the manager. It returns the broker the
+// session was created with, so that callers don't have to look it up again with BrokerFromSessionID, as it could be
+// gone by then if the session was already ended or reaped.
+// The returned resumption token can be passed once to ResumeSession to reconnect to the session.
+// It returns an error matching ErrBrokerNotPermitted if the broker policy doesn't permit the user to use the broker,
+// and a MaintenanceError if the broker is under maintenance. With WithBrokerReadyTimeout, it first waits for
+// the broker to be ready, and returns an error matching ErrBrokerNotReady if it isn't in time.
+// Canceling ctx aborts the call to the broker.
+func (m *Manager) NewSession(ctx context.Context, brokerID, username, lang, mode string, args ...SessionOption) (broker *Broker, sessionID string, key KeyInfo, resumptionToken string, err error) {
+	opts := sessionOptions{}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	ctx, timer := newStepTimer(ctx, m.tracer, "NewSession")
+	defer logNewSessionTiming(ctx, timer, brokerID, username)
+
+	_, endStep := timer.step(ctx, "broker lookup")
+	broker, err = m.brokerFromID(brokerID)
+	endStep()
 	if err != nil {
-		return "", "", fmt.Errorf("invalid broker: %v", err)
+		return nil, "", KeyInfo{}, "", fmt.Errorf("invalid broker: %v", err)
+	}
+	ctx = log.WithAttrs(ctx, "broker_id", broker.ID)
+	if !m.isPermittedBroker(username, broker) {
+		return nil, "", KeyInfo{}, "", errmessages.NewErrorToDisplay(
+			fmt.Errorf("%w: user %q can't authenticate with broker %q", ErrBrokerNotPermitted, username, broker.Name))
+	}
+	if maintenance, ok := broker.Maintenance(); ok {
+		return nil, "", KeyInfo{}, "", errmessages.NewErrorToDisplay(MaintenanceError{BrokerID: broker.ID, BrokerMaintenance: maintenance})
+	}
+
+	if m.brokerReadyTimeout > 0 {
+		stepCtx, endStep := timer.step(ctx, "broker readiness")
+		readyCtx, cancel := context.WithTimeout(stepCtx, m.brokerReadyTimeout)
+		err := broker.waitReady(readyCtx)
+		cancel()
+		endStep()
+		if err != nil {
+			return nil, "", KeyInfo{}, "", errmessages.NewErrorToDisplay(err)
+		}
 	}
 
-	sessionID, encryptionKey, err = broker.newSession(context.Background(), username, lang, mode)
+	stepCtx, endStep := timer.step(ctx, "broker call")
+	callCtx, cancel := m.withBrokerCallTimeout(stepCtx)
+	brokerSessionID, key, err := broker.newSession(callCtx, username, lang, mode, opts.sessionContext)
+	cancel()
+	endStep()
 	if err != nil {
-		return "", "", err
+		return nil, "", KeyInfo{}, "", err
+	}
+	// The client gave up once the broker created the session, so no one can reach it.
+	if err := ctx.Err(); err != nil {
+		m.endRejectedSession(context.WithoutCancel(ctx), broker, brokerSessionID)
+		return nil, "", KeyInfo{}, "", err
+	}
+
+	_, endStep = timer.step(ctx, "session registration")
+	sessionID, resumptionToken, err = m.registerSession(ctx, broker, brokerSessionID, key, username, opts)
+	endStep()
+	if err != nil {
+		return nil, "", KeyInfo{}, "", err
+	}
+
+	return broker, sessionID, key, resumptionToken, nil
+}
+
+// registerSession generates the session ID given to the client for the session of the broker and maps it to the
+// broker with its session context, makes the broker the default one of the context and returns a resumption token for
+// the session.
+func (m *Manager) registerSession(ctx context.Context, broker *Broker, brokerSessionID string, key KeyInfo, username string, opts sessionOptions) (sessionID, resumptionToken string, err error) {
+	sessionID = m.newSessionID()
+	ctx = log.WithAttrs(ctx, "session_id", sessionID)
+
+	// Holding the lock of the brokers prevents a concurrent reload from dropping the broker before its session is
+	// registered, which would leave the session behind.
+	m.brokersMu.RLock()
+	defer m.brokersMu.RUnlock()
+
+	if m.brokers[broker.ID] != broker {
+		m.endRejectedSession(ctx, broker, brokerSessionID)
+		return "", "", fmt.Errorf("broker %q was dropped while creating the session", broker.Name)
 	}
 
 	m.transactionsToBrokerMu.Lock()
-	defer m.transactionsToBrokerMu.Unlock()
-	log.Debug(context.Background(), fmt.Sprintf("%s: New session for %q", sessionID, username))
-	m.transactionsToBroker[sessionID] = broker
-	return sessionID, encryptionKey, nil
+	if _, exists := m.transactionsToBroker[sessionID]; sessionID == "" || exists {
+		m.transactionsToBrokerMu.Unlock()
+		m.endRejectedSession(ctx, broker, brokerSessionID)
+		return "", "", fmt.Errorf("invalid session ID generated: %q is empty or already used", sessionID)
+	}
+	// The broker state is only changed once the session is accepted. The session of the broker is not ended if it
+	// has the ID of an ongoing one, as it would end the ongoing one.
+	if err := broker.addSession(sessionID, brokerSessionID, username); err != nil {
+		m.transactionsToBrokerMu.Unlock()
+		return "", "", err
+	}
+	log.Debugf(ctx, "%s: New session for %q (broker session %q)", sessionID, username, brokerSessionID)
+	ttl := m.sessionTTL
+	if opts.ttl != nil {
+		ttl = *opts.ttl
+	}
+	s := session{broker: broker, username: username, createdAt: time.Now()}
+	if ttl > 0 {
+		s.expiresAt = s.createdAt.Add(ttl)
+	}
+	m.transactionsToBroker[sessionID] = s
+	if opts.sessionContext != nil {
+		m.sessionContexts[sessionID] = opts.sessionContext
+	}
+	m.transactionsToBrokerMu.Unlock()
+
+	m.setDefaultBroker(opts.contextKey, broker)
+
+	resumptionToken, err = m.newResumptionToken(sessionID, key)
+	// The session is persisted with its resumption token, so that it can be resumed after a restart.
+	m.updatePersistedSession(ctx, sessionID)
+	if err != nil {
+		return "", "", err
+	}
+	return sessionID, resumptionToken, nil
+}
+
+// endRejectedSession ends the session of the broker which was rejected, as the client can't reach it. The broker
+// forgets the session even if it could not be ended.
+func (m *Manager) endRejectedSession(ctx context.Context, broker *Broker, brokerSessionID string) {
+	callCtx, cancel := m.withBrokerCallTimeout(ctx)
+	defer cancel()
+	if err := broker.endSession(callCtx, brokerSessionID); err != nil {
+		log.RateLimitedWarningf(ctx, "Could not end session %q on broker %q: %v", brokerSessionID, broker.Name, err)
+		broker.forgetSession(brokerSessionID)
+	}
+}
+
+// NewSessionForUser creates a new session for the user with the broker selected by selectedBrokerID or, if it is
+// empty, with the broker they previously used, and returns the broker the session was created with. Break-glass users
+// are always routed to the local broker, and users without any selected or previous broker to the one mapped to the
+// domain of their name with WithDomainBrokerMap, if any. Previous and domain brokers the broker policy doesn't permit
+// the user to use are not selected.
+// As the authentication with the local broker is not handled by authd, no session is created for it and the returned
+// session ID is empty.
+func (m *Manager) NewSessionForUser(ctx context.Context, selectedBrokerID, username, lang, mode string, args ...SessionOption) (broker *Broker, sessionID string, key KeyInfo, resumptionToken string, err error) {
+	switch {
+	case m.isBreakGlassUser(username):
+		log.Warningf(ctx, "Break-glass login for user %q, routing it to the local broker", username)
+		broker, err = m.brokerFromID(LocalBrokerName)
+	case selectedBrokerID != "":
+		if broker, err = m.brokerFromID(selectedBrokerID); err != nil {
+			err = fmt.Errorf("invalid broker: %v", err)
+		}
+	default:
+		broker = m.BrokerForUser(username)
+		if broker == nil {
+			broker = m.brokerForDomain(username)
+		}
+		if broker != nil && !m.isPermittedBroker(username, broker) {
+			broker = nil
+		}
+		if broker == nil {
+			err = fmt.Errorf("no broker selected for user %q", username)
+		}
+	}
+	if err != nil {
+		return nil, "", KeyInfo{}, "", err
+	}
+	if broker.ID == LocalBrokerName {
+		return broker, "", KeyInfo{}, "", nil
+	}
+
+	return m.NewSession(ctx, broker.ID, username, lang, mode, args...)
 }
 
 // EndSession signals the end of the session to the broker associated with the sessionID and then removes the
-// session -> broker mapping.
-func (m *Manager) EndSession(sessionID string) error {
+// session -> broker mapping. Canceling ctx aborts the call to the broker.
+func (m *Manager) EndSession(ctx context.Context, sessionID string) error {
 	b, err := m.BrokerFromSessionID(sessionID)
 	if err != nil {
 		return err
 	}
+	ctx = log.WithAttrs(ctx, "broker_id", b.ID)
 
-	if err = b.endSession(context.Background(), sessionID); err != nil {
+	callCtx, cancel := m.withBrokerCallTimeout(ctx)
+	err = b.endSession(callCtx, sessionID)
+	cancel()
+	if err != nil {
 		return err
 	}
 
 	m.transactionsToBrokerMu.Lock()
-	log.Debug(context.Background(), fmt.Sprintf("%s: End session %q",
-		sessionID, m.transactionsToBroker[sessionID].Name))
+	log.Debugf(ctx, "%s: End session %q", sessionID, b.Name)
 	delete(m.transactionsToBroker, sessionID)
+	delete(m.sessionContexts, sessionID)
+	m.unpersistSessions(ctx, sessionID)
 	m.transactionsToBrokerMu.Unlock()
+
+	m.dropResumptionTokens(sessionID)
 	return nil
 }
 
+// withBrokerCallTimeout returns a context for a call to a broker, which is done once the broker call timeout expires.
+func (m *Manager) withBrokerCallTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.brokerCallTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, m.brokerCallTimeout)
+}
+
+// EnumerateBrokerUsers returns all the users the broker matching brokerID can provision, to sync them before they
+// log in. Brokers which don't support listing their users return an error matching ErrEnumerationNotSupported, in
+// which case users are only provisioned when they log in.
+func (m *Manager) EnumerateBrokerUsers(ctx context.Context, brokerID string) ([]users.UserInfo, error) {
+	b, err := m.brokerFromID(brokerID)
+	if err != nil {
+		return nil, err
+	}
+	if b.ID == LocalBrokerName {
+		return nil, fmt.Errorf("%w: local users are not provisioned by authd", ErrEnumerationNotSupported)
+	}
+
+	return b.EnumerateUsers(ctx)
+}
+
+// SessionContext returns the context passed to the broker when creating the session with WithSessionContext, or nil
+// if there was none.
+func (m *Manager) SessionContext(sessionID string) map[string]string {
+	m.transactionsToBrokerMu.RLock()
+	defer m.transactionsToBrokerMu.RUnlock()
+	return maps.Clone(m.sessionContexts[sessionID])
+}
+
+// SessionCountsByBroker returns the number of ongoing sessions of each available broker, keyed by broker ID.
+// Brokers without any session are included with a count of 0.
+func (m *Manager) SessionCountsByBroker() map[string]int {
+	m.brokersMu.RLock()
+	counts := make(map[string]int, len(m.brokersOrder))
+	for _, id := range m.brokersOrder {
+		counts[id] = 0
+	}
+	m.brokersMu.RUnlock()
+
+	m.transactionsToBrokerMu.RLock()
+	defer m.transactionsToBrokerMu.RUnlock()
+	for _, s := range m.transactionsToBroker {
+		counts[s.broker.ID]++
+	}
+
+	return counts
+}
+
+// InFlightBrokerCalls returns the number of broker calls currently in flight, for debugging purposes.
+func (m *Manager) InFlightBrokerCalls() int {
+	return m.calls.inFlight()
+}
+
+// BusConnected returns whether authd is currently connected to the system bus, for debugging purposes.
+// Broker calls fail with ErrBusUnavailable while it's not.
+func (m *Manager) BusConnected() bool {
+	return m.bus.connected()
+}
+
+// BrokerQueryCacheStats returns the statistics of the cache of idempotent broker queries, for debugging purposes.
+func (m *Manager) BrokerQueryCacheStats() QueryCacheStats {
+	return m.queries.stats()
+}
+
+// usersSet returns the set of the given user names.
+func usersSet(users []string) map[string]struct{} {
+	set := make(map[string]struct{}, len(users))
+	for _, u := range users {
+		set[u] = struct{}{}
+	}
+	return set
+}
+
 // brokerFromID returns the broker matching this brokerID.
 func (m *Manager) brokerFromID(id string) (broker *Broker, err error) {
+	m.brokersMu.RLock()
+	defer m.brokersMu.RUnlock()
+
 	broker, exists := m.brokers[id]
 	if !exists {
 		return nil, fmt.Errorf("no broker found matching %q", id)
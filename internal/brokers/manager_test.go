@@ -2,15 +2,20 @@ package brokers_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/godbus/dbus/v5"
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/authd/internal/brokers"
+	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/authd/internal/services/errmessages"
 	"github.com/ubuntu/authd/internal/testutils"
 )
 
@@ -22,7 +27,9 @@ func TestNewManager(t *testing.T) {
 	tests := map[string]struct {
 		brokerConfigDir   string
 		configuredBrokers []string
+		brokerOrder       []string
 		noBus             bool
+		maxCalls          int
 
 		wantErr bool
 	}{
@@ -32,12 +39,23 @@ func TestNewManager(t *testing.T) {
 		"Creates only local broker when config dir has only invalid ones":            {brokerConfigDir: "invalid_brokers"},
 		"Creates only local broker when config dir does not exist":                   {brokerConfigDir: "does/not/exist"},
 		"Creates manager even if broker is not exported on dbus":                     {brokerConfigDir: "not_on_bus"},
-
-		"Ignores broker configuration file not ending with .conf": {brokerConfigDir: "some_ignored_brokers"},
-		"Ignores any unknown sections and fields":                 {brokerConfigDir: "extra_fields"},
-
-		"Error when can't connect to system bus": {brokerConfigDir: "valid_brokers", noBus: true, wantErr: true},
-		"Error when broker config dir is a file": {brokerConfigDir: "file_config_dir", wantErr: true},
+		"Creates brokers configured in files and subdirectories":                     {brokerConfigDir: "subdir_brokers"},
+		"Creates broker configured as a subdirectory":                                {brokerConfigDir: "subdir_brokers", configuredBrokers: []string{"second"}},
+
+		"Ignores broker configuration file not ending with .conf":  {brokerConfigDir: "some_ignored_brokers"},
+		"Ignores any unknown sections and fields":                  {brokerConfigDir: "extra_fields"},
+		"Creates manager with bounded concurrent broker calls":     {brokerConfigDir: "valid_brokers", maxCalls: 2},
+		"Creates manager even if authoritative UID ranges overlap": {brokerConfigDir: "uid_ranges"},
+
+		"Orders brokers by the broker order":                       {brokerConfigDir: "valid_brokers", brokerOrder: []string{"Broker2", "Broker"}},
+		"Appends brokers missing from the broker order":            {brokerConfigDir: "valid_brokers", brokerOrder: []string{"Broker2"}},
+		"Keeps local broker first whatever the broker order":       {brokerConfigDir: "valid_brokers", brokerOrder: []string{"Broker2", "local"}},
+		"Ignores brokers of the broker order which are not loaded": {brokerConfigDir: "valid_brokers", brokerOrder: []string{"Unknown", "Broker2"}},
+		"Orders configured brokers by the broker order":            {brokerConfigDir: "valid_brokers", configuredBrokers: []string{"valid.conf", "valid_2.conf"}, brokerOrder: []string{"Broker2"}},
+
+		"Error when can't connect to system bus":      {brokerConfigDir: "valid_brokers", noBus: true, wantErr: true},
+		"Error when broker config dir is a file":      {brokerConfigDir: "file_config_dir", wantErr: true},
+		"Error when max concurrent calls is negative": {brokerConfigDir: "valid_brokers", maxCalls: -1, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -45,7 +63,8 @@ func TestNewManager(t *testing.T) {
 				t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", "/dev/null")
 			}
 
-			got, err := brokers.NewManager(context.Background(), filepath.Join(brokerConfFixtures, tc.brokerConfigDir), tc.configuredBrokers)
+			got, err := brokers.NewManager(context.Background(), filepath.Join(brokerConfFixtures, tc.brokerConfigDir), tc.configuredBrokers,
+				brokers.WithMaxConcurrentBrokerCalls(tc.maxCalls), brokers.WithBrokerOrder(tc.brokerOrder))
 			if tc.wantErr {
 				require.Error(t, err, "NewManager should return an error, but did not")
 				return
@@ -64,6 +83,50 @@ func TestNewManager(t *testing.T) {
 	}
 }
 
+func TestDiagnoseBrokers(t *testing.T) {
+	t.Parallel()
+
+	confDir := filepath.Join(brokerConfFixtures, "diagnosed_brokers")
+	m, err := brokers.NewManager(context.Background(), confDir, nil)
+	require.NoError(t, err, "Setup: could not create manager")
+
+	wantReasons := map[string]error{
+		"valid.conf":               nil,
+		"valid.conf.bak":           brokers.ErrNotBrokerConfig,
+		"invalid.conf":             brokers.ErrInvalidBrokerConfig,
+		"invalid_dbus_name.conf":   brokers.ErrInvalidDbusName,
+		"invalid_dbus_object.conf": brokers.ErrInvalidDbusName,
+		"valid_duplicate.conf":     brokers.ErrDuplicateBrokerID,
+		"unconfigured/broker.conf": brokers.ErrInvalidBrokerConfig,
+	}
+
+	got := m.DiagnoseBrokers()
+	require.Len(t, got, len(wantReasons), "DiagnoseBrokers should return a diagnosis for each file")
+	for _, d := range got {
+		file, err := filepath.Rel(confDir, d.File)
+		require.NoError(t, err, "Diagnosed file %q should be in the brokers configuration directory", d.File)
+		want, ok := wantReasons[file]
+		require.True(t, ok, "DiagnoseBrokers returned an unexpected file %q", d.File)
+
+		if want == nil {
+			require.True(t, d.Loaded, "Broker of %q should be loaded", file)
+			require.NoError(t, d.Reason, "Broker of %q should have no reason to not be loaded", file)
+			require.NotEmpty(t, d.BrokerID, "Loaded broker of %q should have an ID", file)
+			continue
+		}
+		require.False(t, d.Loaded, "Broker of %q should not be loaded", file)
+		require.Empty(t, d.BrokerID, "Broker of %q should have no ID", file)
+		require.ErrorIs(t, d.Reason, want, "Broker of %q should not be loaded for the expected reason", file)
+	}
+
+	// Only the first of the brokers with the same name is available.
+	var names []string
+	for _, b := range m.AvailableBrokers() {
+		names = append(names, b.Name)
+	}
+	require.Equal(t, []string{brokers.LocalBrokerName, "Broker"}, names, "AvailableBrokers should not list duplicates")
+}
+
 func TestSetDefaultBrokerForUser(t *testing.T) {
 	t.Parallel()
 
@@ -119,6 +182,181 @@ func TestBrokerForUser(t *testing.T) {
 	require.Nil(t, got, "BrokerForUser should return nil if no broker is assigned, but did not")
 }
 
+func TestBrokerPolicy(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b1 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker1.conf")
+	b2 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker2.conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b1.Name + ".conf", b2.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+	b1.ID = m.AvailableBrokers()[1].ID
+	b2.ID = m.AvailableBrokers()[2].ID
+
+	require.Error(t, m.SetBrokerPolicy(brokers.BrokerPolicy{{Pattern: "["}}), "SetBrokerPolicy should reject malformed patterns")
+	err = m.SetBrokerPolicy(brokers.BrokerPolicy{
+		{Pattern: "admin", Deny: []string{b2.ID}},
+		{Pattern: "*@corp", Allow: []string{b1.ID}},
+	})
+	require.NoError(t, err, "Setup: SetBrokerPolicy should not return an error, but did")
+
+	tests := map[string]struct {
+		username string
+		brokerID string
+
+		wantErr bool
+	}{
+		"Permits any broker to users without any matching rule": {username: "user", brokerID: b2.ID},
+		"Permits brokers which are not denied":                  {username: "admin", brokerID: b1.ID},
+		"Permits allowed brokers to users matching a glob":      {username: "user@corp", brokerID: b1.ID},
+
+		"Error when broker is denied":                      {username: "admin", brokerID: b2.ID, wantErr: true},
+		"Error when broker is not allowed to glob matches": {username: "user@corp", brokerID: b2.ID, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, _, _, err := m.NewSession(context.Background(), tc.brokerID, tc.username, "some_lang", "auth")
+			if tc.wantErr {
+				require.ErrorIs(t, err, brokers.ErrBrokerNotPermitted, "NewSession should reject brokers not permitted by the policy")
+				return
+			}
+			require.NoError(t, err, "NewSession should not return an error, but did")
+		})
+	}
+}
+
+func TestBrokerPolicyFiltersPreviousBroker(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, "")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+	b.ID = m.AvailableBrokers()[1].ID
+
+	require.NoError(t, m.SetDefaultBrokerForUser(b.ID, "user1"), "Setup: could not set default broker")
+	require.NoError(t, m.SetDefaultBrokerForUser(brokers.LocalBrokerName, "user2"), "Setup: could not set default broker")
+	require.NoError(t, m.SetDefaultBrokerForUser(brokers.LocalBrokerName, "user3"), "Setup: could not set default broker")
+	m.SetBreakGlassUsers([]string{"rescue"})
+	require.NoError(t, m.SetBrokerPolicy(brokers.BrokerPolicy{
+		{Pattern: "user1", Deny: []string{b.ID}},
+		{Pattern: "user2", Allow: []string{b.ID}},
+		{Pattern: "user3", Deny: []string{brokers.LocalBrokerName}},
+		{Pattern: "rescue", Deny: []string{brokers.LocalBrokerName}},
+	}), "Setup: SetBrokerPolicy should not return an error, but did")
+
+	require.Nil(t, m.BrokerForUser("user1"), "BrokerForUser should not return a broker the user is not permitted to use")
+	require.Equal(t, brokers.LocalBrokerName, m.BrokerForUser("user2").ID, "BrokerForUser should return the local broker even if it is not allowed")
+	require.Nil(t, m.BrokerForUser("user3"), "BrokerForUser should not return the local broker if it is explicitly denied")
+	require.Equal(t, brokers.LocalBrokerName, m.BrokerForUser("rescue").ID, "BrokerForUser should return the local broker to break-glass users even if it is denied")
+	broker, _, _, _, err := m.NewSessionForUser(context.Background(), "", "rescue", "some_lang", "auth")
+	require.NoError(t, err, "NewSessionForUser should route break-glass users to the local broker even if it is denied")
+	require.Equal(t, brokers.LocalBrokerName, broker.ID, "NewSessionForUser should route break-glass users to the local broker even if it is denied")
+	_, _, _, _, err = m.NewSessionForUser(context.Background(), "", "user1", "some_lang", "auth")
+	require.Error(t, err, "NewSessionForUser should not route the user to a broker they are not permitted to use")
+
+	require.NoError(t, m.SetBrokerPolicy(nil), "Setup: SetBrokerPolicy should not return an error, but did")
+	require.Equal(t, b.ID, m.BrokerForUser("user1").ID, "BrokerForUser should return the previous broker once the policy is cleared")
+}
+
+func TestNewSessionForUser(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, "")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"},
+		brokers.WithBreakGlassUsers([]string{"rescue"}))
+	require.NoError(t, err, "Setup: could not create manager")
+	b.ID = m.AvailableBrokers()[1].ID
+
+	for _, u := range []string{"user1", "rescue"} {
+		err = m.SetDefaultBrokerForUser(b.ID, u)
+		require.NoError(t, err, "Setup: could not set default broker")
+	}
+
+	// Regular users are routed to their previous broker.
+	broker, sessionID, _, _, err := m.NewSessionForUser(context.Background(), "", "user1", "some_lang", "auth")
+	require.NoError(t, err, "NewSessionForUser should not return an error, but did")
+	require.Equal(t, b.ID, broker.ID, "NewSessionForUser should route the user to their previous broker")
+	got, err := m.BrokerFromSessionID(sessionID)
+	require.NoError(t, err, "BrokerFromSessionID should not return an error, but did")
+	require.Equal(t, b.ID, got.ID, "Session should be assigned to the previous broker of the user")
+
+	// Break-glass users are routed to the local broker, whatever their previous broker.
+	broker, sessionID, _, _, err = m.NewSessionForUser(context.Background(), "", "rescue", "some_lang", "auth")
+	require.NoError(t, err, "NewSessionForUser should not return an error, but did")
+	require.Equal(t, brokers.LocalBrokerName, broker.ID, "NewSessionForUser should route break-glass users to the local broker")
+	require.Empty(t, sessionID, "NewSessionForUser should not create a session with the local broker")
+	require.Equal(t, brokers.LocalBrokerName, m.BrokerForUser("rescue").ID, "BrokerForUser should return the local broker for break-glass users")
+
+	// Users without any previous broker are rejected.
+	_, _, _, _, err = m.NewSessionForUser(context.Background(), "", "no_broker", "some_lang", "auth")
+	require.Error(t, err, "NewSessionForUser should return an error when the user has no previous broker, but did not")
+
+	// The broker selected by the user is used when they are not a break-glass user.
+	broker, _, _, _, err = m.NewSessionForUser(context.Background(), b.ID, "no_broker", "some_lang", "auth")
+	require.NoError(t, err, "NewSessionForUser should not return an error, but did")
+	require.Equal(t, b.ID, broker.ID, "NewSessionForUser should route the user to the selected broker")
+	_, _, _, _, err = m.NewSessionForUser(context.Background(), "not-loaded", "no_broker", "some_lang", "auth")
+	require.Error(t, err, "NewSessionForUser should return an error when the selected broker is not loaded, but did not")
+
+	// Reloading the break-glass users applies to the next sessions.
+	m.SetBreakGlassUsers(nil)
+	broker, _, _, _, err = m.NewSessionForUser(context.Background(), "", "rescue", "some_lang", "auth")
+	require.NoError(t, err, "NewSessionForUser should not return an error, but did")
+	require.Equal(t, b.ID, broker.ID, "NewSessionForUser should route former break-glass users to their previous broker")
+}
+
+func TestNewSessionForUserWithDomainBrokerMap(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, "")
+
+	// Broker IDs are derived from their names, so we get the ID of the broker from a first manager.
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+	b.ID = m.AvailableBrokers()[1].ID
+
+	_, err = brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"},
+		brokers.WithDomainBrokerMap(map[string]string{"corp.example": "not-loaded"}))
+	require.Error(t, err, "NewManager should return an error when a domain is mapped to a broker which is not loaded")
+
+	m, err = brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"},
+		brokers.WithDomainBrokerMap(map[string]string{"Corp.Example": b.ID, "local.example": brokers.LocalBrokerName}))
+	require.NoError(t, err, "Setup: could not create manager")
+
+	// Users without any previous broker are routed to the broker of their domain.
+	broker, sessionID, _, _, err := m.NewSessionForUser(context.Background(), "", "user1@corp.EXAMPLE", "some_lang", "auth")
+	require.NoError(t, err, "NewSessionForUser should not return an error, but did")
+	require.Equal(t, b.ID, broker.ID, "NewSessionForUser should route the user to the broker of their domain")
+	got, err := m.BrokerFromSessionID(sessionID)
+	require.NoError(t, err, "BrokerFromSessionID should not return an error, but did")
+	require.Equal(t, b.ID, got.ID, "Session should be assigned to the broker of the domain")
+
+	// The previous broker of the user takes precedence over the one of their domain.
+	require.NoError(t, m.SetDefaultBrokerForUser(b.ID, "user2@local.example"), "Setup: could not set default broker")
+	broker, _, _, _, err = m.NewSessionForUser(context.Background(), "", "user2@local.example", "some_lang", "auth")
+	require.NoError(t, err, "NewSessionForUser should not return an error, but did")
+	require.Equal(t, b.ID, broker.ID, "NewSessionForUser should route the user to their previous broker")
+
+	broker, sessionID, _, _, err = m.NewSessionForUser(context.Background(), "", "user3@local.example", "some_lang", "auth")
+	require.NoError(t, err, "NewSessionForUser should not return an error, but did")
+	require.Equal(t, brokers.LocalBrokerName, broker.ID, "NewSessionForUser should route the user to the broker of their domain")
+	require.Empty(t, sessionID, "NewSessionForUser should not create a session with the local broker")
+
+	// Users without any domain, or with an unmapped one, fall through to the default selection.
+	for _, u := range []string{"user4", "user4@other.example"} {
+		_, _, _, _, err = m.NewSessionForUser(context.Background(), "", u, "some_lang", "auth")
+		require.Error(t, err, "NewSessionForUser should return an error when the user has no previous broker, but did not")
+	}
+}
+
 func TestBrokerFromSessionID(t *testing.T) {
 	t.Parallel()
 
@@ -174,10 +412,12 @@ func TestNewSession(t *testing.T) {
 		username    string
 		sessionMode string
 
-		configuredBrokers []string
-		unavailableBroker bool
+		configuredBrokers  []string
+		unavailableBroker  bool
+		maxSessionIDLength int
 
-		wantErr bool
+		wantErr   bool
+		wantErrIs error
 	}{
 		"Successfully start a new auth session":                    {username: "success"},
 		"Successfully start a new passwd session":                  {username: "success", sessionMode: "passwd"},
@@ -185,7 +425,12 @@ func TestNewSession(t *testing.T) {
 
 		"Error when broker does not exist":           {brokerID: "does_not_exist", wantErr: true},
 		"Error when broker does not provide an ID":   {username: "NS_no_id", wantErr: true},
-		"Error when starting a new session":          {username: "NS_error", wantErr: true},
+		"Error when broker provides an invalid ID":   {username: "NS_invalid_id", wantErr: true},
+		"Error when broker provides a too long ID":   {username: "success", maxSessionIDLength: 5, wantErr: true},
+		"Error when starting a new session":          {username: "NS_error", wantErr: true, wantErrIs: brokers.ErrBrokerInternal},
+		"Error when broker denies authentication":    {username: "NS_auth_denied", wantErr: true, wantErrIs: brokers.ErrAuthDenied},
+		"Error when user is unknown to broker":       {username: "NS_user_unknown", wantErr: true, wantErrIs: brokers.ErrUserUnknownToBroker},
+		"Error when broker returns an unknown error": {username: "NS_unknown_error", wantErr: true},
 		"Error when broker is not available on dbus": {unavailableBroker: true, wantErr: true},
 	}
 	for name, tc := range tests {
@@ -204,6 +449,15 @@ func TestNewSession(t *testing.T) {
 				}
 			}
 
+			if tc.maxSessionIDLength > 0 {
+				cfgPath := filepath.Join(brokersConfPath, tc.configuredBrokers[0])
+				f, err := os.OpenFile(cfgPath, os.O_APPEND|os.O_WRONLY, 0600)
+				require.NoError(t, err, "Setup: could not open broker configuration file")
+				_, err = fmt.Fprintf(f, "\nsession_id_max_length = %d\n", tc.maxSessionIDLength)
+				require.NoError(t, err, "Setup: could not write broker configuration file")
+				require.NoError(t, f.Close(), "Setup: could not close broker configuration file")
+			}
+
 			if tc.unavailableBroker {
 				// We need to manually configure the broker without exporting it on the bus.
 				content, err := os.ReadFile(filepath.Join(brokerConfFixtures, "not_on_bus", "not_on_bus.conf"))
@@ -214,7 +468,8 @@ func TestNewSession(t *testing.T) {
 				tc.configuredBrokers = nil
 			}
 
-			m, err := brokers.NewManager(context.Background(), brokersConfPath, tc.configuredBrokers)
+			m, err := brokers.NewManager(context.Background(), brokersConfPath, tc.configuredBrokers,
+				brokers.WithSessionIDGenerator(func() string { return "authd-session-id" }))
 			require.NoError(t, err, "Setup: could not create manager")
 
 			if tc.brokerID == "" {
@@ -235,21 +490,33 @@ func TestNewSession(t *testing.T) {
 				tc.sessionMode = "auth"
 			}
 
-			gotID, gotEKey, err := m.NewSession(tc.brokerID, tc.username, "some_lang", tc.sessionMode)
+			gotNewSessionBroker, gotID, gotEKey, _, err := m.NewSession(context.Background(), tc.brokerID, tc.username, "some_lang", tc.sessionMode)
 			if tc.wantErr {
 				require.Error(t, err, "NewSession should return an error, but did not")
+				require.Nil(t, gotNewSessionBroker, "NewSession should not return a broker on error")
+				for _, category := range []error{brokers.ErrAuthDenied, brokers.ErrUserUnknownToBroker, brokers.ErrBrokerInternal} {
+					if category == tc.wantErrIs {
+						require.ErrorIs(t, err, category, "NewSession should return the expected error category")
+						continue
+					}
+					require.NotErrorIs(t, err, category, "NewSession should not return an unexpected error category")
+				}
+				var errToDisplay errmessages.ErrToDisplay
+				if tc.wantErrIs != nil {
+					require.ErrorAs(t, err, &errToDisplay, "NewSession should still return an error to display")
+				}
 				return
 			}
 			require.NoError(t, err, "NewSession should not return an error, but did")
 
-			// Replaces the autogenerated part of the ID with a placeholder before saving the file.
-			gotStr := fmt.Sprintf("ID: %s\nEncryption Key: %s\n", strings.ReplaceAll(gotID, wantBroker.ID, "BROKER_ID"), gotEKey)
+			gotStr := fmt.Sprintf("ID: %s\nEncryption Key: %s\n", gotID, gotEKey.Key)
 			wantStr := testutils.LoadWithUpdateFromGolden(t, gotStr)
 			require.Equal(t, wantStr, gotStr, "NewSession should return the expected session, but did not")
 
 			gotBroker, err := m.BrokerFromSessionID(gotID)
 			require.NoError(t, err, "NewSession should have assigned a broker for the session, but did not")
 			require.Equal(t, wantBroker.ID, gotBroker.ID, "BrokerFromSessionID should have assigned the expected broker for the session, but did not")
+			require.Same(t, gotBroker, gotNewSessionBroker, "NewSession should return the broker assigned to the session")
 		})
 	}
 }
@@ -294,7 +561,7 @@ func TestEndSession(t *testing.T) {
 				m.SetBrokerForSession(&wantBroker, tc.sessionID)
 			}
 
-			err = m.EndSession(tc.sessionID)
+			err = m.EndSession(context.Background(), tc.sessionID)
 			if tc.wantErr {
 				require.Error(t, err, "EndSession should return an error, but did not")
 				return
@@ -332,26 +599,25 @@ func TestStartAndEndSession(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		id, key, err := m.NewSession(b1.ID, "user1", "some_lang", "auth")
-		firstID, firstKey, firstErr = &id, &key, &err
+		_, id, key, _, err := m.NewSession(context.Background(), b1.ID, "user1", "some_lang", "auth")
+		firstID, firstKey, firstErr = &id, &key.Key, &err
 	}()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		id, key, err := m.NewSession(b2.ID, "user2", "some_lang", "auth")
-		secondID, secondKey, secondErr = &id, &key, &err
+		_, id, key, _, err := m.NewSession(context.Background(), b2.ID, "user2", "some_lang", "auth")
+		secondID, secondKey, secondErr = &id, &key.Key, &err
 	}()
 	wg.Wait()
 
 	require.NoError(t, *firstErr, "First NewSession should not return an error, but did")
 	require.NoError(t, *secondErr, "Second NewSession should not return an error, but did")
 
-	require.Equal(t, b1.ID+"-"+testutils.GenerateSessionID("user1"),
-		*firstID, "First NewSession should return the expected session ID, but did not")
+	require.NotEmpty(t, *firstID, "First NewSession should return a session ID, but did not")
 	require.Equal(t, testutils.GenerateEncryptionKey(b1.Name),
 		*firstKey, "First NewSession should return the expected encryption key, but did not")
-	require.Equal(t, b2.ID+"-"+testutils.GenerateSessionID("user2"),
-		*secondID, "Second NewSession should return the expected session ID, but did not")
+	require.NotEmpty(t, *secondID, "Second NewSession should return a session ID, but did not")
+	require.NotEqual(t, *firstID, *secondID, "NewSession should return different session IDs")
 	require.Equal(t, testutils.GenerateEncryptionKey(b2.Name),
 		*secondKey, "Second NewSession should return the expected encryption key, but did not")
 
@@ -366,12 +632,12 @@ func TestStartAndEndSession(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		*firstErr = m.EndSession(*firstID)
+		*firstErr = m.EndSession(context.Background(), *firstID)
 	}()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		*secondErr = m.EndSession(*secondID)
+		*secondErr = m.EndSession(context.Background(), *secondID)
 	}()
 	wg.Wait()
 
@@ -383,6 +649,1416 @@ func TestStartAndEndSession(t *testing.T) {
 
 	_, err = m.BrokerFromSessionID(*secondID)
 	require.Error(t, err, "Second EndSession should have removed the broker for the session, but did not")
+
+	require.Zero(t, m.InFlightBrokerCalls(), "No broker call should be in flight once the sessions ended")
+}
+
+func TestNewSessionWithSameIDFromDifferentBrokers(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b1 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker1.conf")
+	b2 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker2.conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b1.Name + ".conf", b2.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b1.Name {
+			b1.ID = broker.ID
+		} else if broker.Name == b2.Name {
+			b2.ID = broker.ID
+		}
+	}
+
+	// The broker mocks generate the session ID from the username only, so both return the same ID.
+	_, firstID, _, _, err := m.NewSession(context.Background(), b1.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "First NewSession should not return an error, but did")
+	_, secondID, _, _, err := m.NewSession(context.Background(), b2.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "Second NewSession should not return an error, but did")
+	require.NotEqual(t, firstID, secondID, "Sessions from different brokers should have different IDs")
+
+	got, err := m.BrokerFromSessionID(firstID)
+	require.NoError(t, err, "BrokerFromSessionID should not return an error, but did")
+	require.Equal(t, b1.ID, got.ID, "First session should be assigned to the broker which created it")
+	got, err = m.BrokerFromSessionID(secondID)
+	require.NoError(t, err, "BrokerFromSessionID should not return an error, but did")
+	require.Equal(t, b2.ID, got.ID, "Second session should be assigned to the broker which created it")
+
+	// The same broker returning the ID of an ongoing session is rejected.
+	_, _, _, _, err = m.NewSession(context.Background(), b1.ID, "user1", "some_lang", "auth")
+	require.Error(t, err, "NewSession should return an error when the broker reuses an ongoing session ID, but did not")
+	got, err = m.BrokerFromSessionID(firstID)
+	require.NoError(t, err, "BrokerFromSessionID should not return an error, but did")
+	require.Equal(t, b1.ID, got.ID, "Ongoing session should still be assigned to its broker")
+}
+
+func TestSessionIDGenerator(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker.conf")
+
+	var generated []string
+	generator := func() string {
+		id := fmt.Sprintf("handle-%d", len(generated))
+		if len(generated) == 2 {
+			// Reuse the ID of an ongoing session.
+			id = generated[0]
+		}
+		generated = append(generated, id)
+		return id
+	}
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"}, brokers.WithSessionIDGenerator(generator))
+	require.NoError(t, err, "Setup: could not create manager")
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b.Name {
+			b.ID = broker.ID
+		}
+	}
+
+	_, firstID, _, _, err := m.NewSession(context.Background(), b.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "NewSession should not return an error, but did")
+	require.Equal(t, "handle-0", firstID, "NewSession should return the generated session ID")
+	_, secondID, _, _, err := m.NewSession(context.Background(), b.ID, "user2", "some_lang", "auth")
+	require.NoError(t, err, "NewSession should not return an error, but did")
+	require.Equal(t, "handle-1", secondID, "NewSession should return the generated session ID")
+
+	got, err := m.BrokerFromSessionID(firstID)
+	require.NoError(t, err, "BrokerFromSessionID should resolve the generated session ID")
+	require.Equal(t, b.ID, got.ID, "BrokerFromSessionID should return the broker of the session")
+	require.Equal(t, "user1", got.SessionUsername(firstID), "The generated session ID should be mapped to the session of the broker")
+	require.Equal(t, "user2", got.SessionUsername(secondID), "The generated session ID should be mapped to the session of the broker")
+
+	// The broker fails to end the rejected session, which must be forgotten anyway.
+	_, _, _, _, err = m.NewSession(context.Background(), b.ID, "ES_error", "some_lang", "auth")
+	require.Error(t, err, "NewSession should return an error when the generated session ID is already used")
+	require.Equal(t, "user1", got.SessionUsername(firstID), "Ongoing session should still be mapped to the session of the broker")
+	require.Empty(t, got.SessionUsername(testutils.GenerateSessionID("ES_error")), "Rejected session should not be tracked by the broker")
+
+	require.NoError(t, m.EndSession(context.Background(), firstID), "EndSession should not return an error, but did")
+	_, err = m.BrokerFromSessionID(firstID)
+	require.Error(t, err, "BrokerFromSessionID should not resolve the session ID of an ended session")
+	require.Equal(t, "user2", got.SessionUsername(secondID), "Other sessions should still be mapped to the sessions of the broker")
+}
+
+func TestSessionCountsByBroker(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b1 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker1.conf")
+	b2 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker2.conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b1.Name + ".conf", b2.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b1.Name {
+			b1.ID = broker.ID
+		} else if broker.Name == b2.Name {
+			b2.ID = broker.ID
+		}
+	}
+
+	want := map[string]int{brokers.LocalBrokerName: 0, b1.ID: 0, b2.ID: 0}
+	require.Equal(t, want, m.SessionCountsByBroker(), "SessionCountsByBroker should include all brokers without sessions")
+
+	_, firstID, _, _, err := m.NewSession(context.Background(), b1.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	_, _, _, _, err = m.NewSession(context.Background(), b1.ID, "user2", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+
+	want[b1.ID] = 2
+	require.Equal(t, want, m.SessionCountsByBroker(), "SessionCountsByBroker should count the sessions of each broker")
+
+	require.NoError(t, m.EndSession(context.Background(), firstID), "Setup: EndSession should not return an error, but did")
+	want[b1.ID] = 1
+	require.Equal(t, want, m.SessionCountsByBroker(), "SessionCountsByBroker should not count ended sessions")
+}
+
+func TestActiveSessions(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b1 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker1.conf")
+	b2 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker2.conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b1.Name + ".conf", b2.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b1.Name {
+			b1.ID = broker.ID
+		} else if broker.Name == b2.Name {
+			b2.ID = broker.ID
+		}
+	}
+
+	require.Empty(t, m.ActiveSessions(), "ActiveSessions should be empty without any session")
+
+	before := time.Now()
+	_, firstID, _, _, err := m.NewSession(context.Background(), b1.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	_, secondID, _, _, err := m.NewSession(context.Background(), b2.ID, "user2", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	_, thirdID, _, _, err := m.NewSession(context.Background(), b2.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	after := time.Now()
+
+	sessions := m.ActiveSessions()
+	require.Len(t, sessions, 3, "ActiveSessions should return all sessions")
+	type sessionKey struct{ ID, BrokerID, Username string }
+	var got []sessionKey
+	for _, s := range sessions {
+		require.False(t, s.StartTime.Before(before) || s.StartTime.After(after), "StartTime should be when the session was created")
+		got = append(got, sessionKey{s.ID, s.BrokerID, s.Username})
+	}
+	require.ElementsMatch(t, []sessionKey{
+		{firstID, b1.ID, "user1"},
+		{secondID, b2.ID, "user2"},
+		{thirdID, b2.ID, "user1"},
+	}, got, "ActiveSessions should return the broker and user of each session")
+
+	var user1Sessions []string
+	for _, s := range m.ActiveSessionsForUser("user1") {
+		user1Sessions = append(user1Sessions, s.ID)
+	}
+	require.ElementsMatch(t, []string{firstID, thirdID}, user1Sessions, "ActiveSessionsForUser should only return the sessions of the user")
+	require.Empty(t, m.ActiveSessionsForUser("unknown"), "ActiveSessionsForUser should be empty for a user without sessions")
+
+	sessions[0].Username = "modified"
+	require.NotContains(t, m.ActiveSessions(), sessions[0], "Modifying the returned sessions should not change the manager")
+
+	require.NoError(t, m.EndSession(context.Background(), firstID), "Setup: EndSession should not return an error, but did")
+	for _, s := range m.ActiveSessions() {
+		require.NotEqual(t, firstID, s.ID, "ActiveSessions should not return ended sessions")
+	}
+}
+
+func TestSessionStore(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b1 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker1.conf")
+	b2 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker2.conf")
+	storePath := filepath.Join(t.TempDir(), "sessions.db")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b1.Name + ".conf", b2.Name + ".conf"},
+		brokers.WithSessionStore(storePath))
+	require.NoError(t, err, "Setup: could not create manager")
+
+	brokerIDs := make(map[string]string)
+	for _, broker := range m.AvailableBrokers() {
+		brokerIDs[broker.Name] = broker.ID
+	}
+
+	_, keptID, _, _, err := m.NewSession(context.Background(), brokerIDs[b1.Name], "user1", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	_, endedID, _, _, err := m.NewSession(context.Background(), brokerIDs[b1.Name], "user2", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	_, droppedID, _, _, err := m.NewSession(context.Background(), brokerIDs[b2.Name], "user3", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	require.NoError(t, m.EndSession(context.Background(), endedID), "Setup: EndSession should not return an error, but did")
+	want := m.ActiveSessionsForUser("user1")
+	m.Stop()
+
+	// The second broker is not configured anymore after the restart.
+	m, err = brokers.NewManager(context.Background(), brokersConfPath, []string{b1.Name + ".conf"},
+		brokers.WithSessionStore(storePath))
+	require.NoError(t, err, "NewManager should not return an error when restoring sessions, but did")
+
+	b, err := m.BrokerFromSessionID(keptID)
+	require.NoError(t, err, "BrokerFromSessionID should return the broker of a restored session")
+	require.Equal(t, brokerIDs[b1.Name], b.ID, "BrokerFromSessionID should return the broker the session was created on")
+	got := m.ActiveSessions()
+	require.Len(t, got, 1, "ActiveSessions should only return the restored sessions")
+	require.True(t, want[0].StartTime.Equal(got[0].StartTime), "Restored sessions should keep their start time")
+	// The stored start time doesn't have a monotonic clock reading.
+	got[0].StartTime = want[0].StartTime
+	require.Equal(t, want, got, "Restored sessions should keep their broker and user")
+	_, err = m.BrokerFromSessionID(endedID)
+	require.Error(t, err, "BrokerFromSessionID should return an error for a session ended before the restart")
+	_, err = m.BrokerFromSessionID(droppedID)
+	require.Error(t, err, "BrokerFromSessionID should return an error for a session whose broker is gone")
+
+	require.NoError(t, m.EndSession(context.Background(), keptID), "EndSession should end a restored session on its broker")
+	m.Stop()
+
+	m, err = brokers.NewManager(context.Background(), brokersConfPath, []string{b1.Name + ".conf", b2.Name + ".conf"},
+		brokers.WithSessionStore(storePath))
+	require.NoError(t, err, "Setup: could not create manager")
+	t.Cleanup(m.Stop)
+	require.Empty(t, m.ActiveSessions(), "Ended and dropped sessions should not be restored")
+}
+
+func TestResumeSessionAfterRestart(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker.conf")
+	storePath := filepath.Join(t.TempDir(), "sessions.db")
+
+	newManager := func(t *testing.T) *brokers.Manager {
+		t.Helper()
+
+		m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"},
+			brokers.WithSessionStore(storePath))
+		require.NoError(t, err, "Setup: could not create manager")
+		return m
+	}
+
+	m := newManager(t)
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b.Name {
+			b.ID = broker.ID
+		}
+	}
+	broker, wantID, wantKey, token, err := m.NewSession(context.Background(), b.ID,
+		t.Name()+testutils.IDSeparator+"IA_info_mismatching_user_name", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	access, _, err := broker.IsAuthenticated(context.Background(), wantID, "password")
+	require.NoError(t, err, "Setup: IsAuthenticated should not return an error, but did")
+	require.Equal(t, brokers.AuthGranted, access, "Setup: IsAuthenticated should grant the authentication")
+	m.RefreshSessionUsername(context.Background(), wantID)
+	m.Stop()
+
+	m = newManager(t)
+	gotID, gotKey, err := m.ResumeSession(context.Background(), token)
+	require.NoError(t, err, "ResumeSession should resume a restored session")
+	require.Equal(t, wantID, gotID, "ResumeSession should return the ID of the restored session")
+	require.Equal(t, wantKey, gotKey, "ResumeSession should return the encryption key of the restored session")
+	broker, err = m.BrokerFromSessionID(gotID)
+	require.NoError(t, err, "BrokerFromSessionID should return the broker of the restored session")
+	require.Equal(t, "different_username", broker.SessionUsername(gotID),
+		"Restored session should keep the username canonicalized by the broker")
+	require.Equal(t, "different_username", m.ActiveSessions()[0].Username,
+		"Restored session should be listed with the username canonicalized by the broker")
+	m.Stop()
+
+	m = newManager(t)
+	t.Cleanup(m.Stop)
+	_, _, err = m.ResumeSession(context.Background(), token)
+	require.ErrorIs(t, err, brokers.ErrInvalidResumptionToken, "ResumeSession should reject a token used before the restart")
+	require.NoError(t, m.EndSession(context.Background(), wantID), "EndSession should end the restored session")
+}
+
+func TestRegisterTestBroker(t *testing.T) {
+	t.Parallel()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to system bus")
+	t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+	stub := &stubBroker{}
+	const objPath = dbus.ObjectPath("/com/ubuntu/authd/StubBroker")
+	require.NoError(t, conn.Export(stub, objPath, brokers.DbusInterface), "Setup: could not export stub broker")
+
+	m, err := brokers.NewManager(context.Background(), t.TempDir(), nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	m.RegisterTestBroker("stub", conn, objPath)
+
+	var brokerIDs []string
+	for _, b := range m.AvailableBrokers() {
+		brokerIDs = append(brokerIDs, b.ID)
+	}
+	require.Equal(t, []string{brokers.LocalBrokerName, "stub"}, brokerIDs, "Test broker should be added last")
+	require.Panics(t, func() { m.RegisterTestBroker("stub", conn, objPath) }, "Registering a broker twice should panic")
+
+	_, sessionID, key, _, err := m.NewSession(context.Background(), "stub", "user1", "some_lang", "auth")
+	require.NoError(t, err, "NewSession should not return an error, but did")
+	require.Equal(t, brokers.KeyInfo{
+		Algorithm: brokers.DefaultKeyAlgorithm,
+		Encoding:  brokers.DefaultKeyEncoding,
+		Key:       "stub-key",
+	}, key, "NewSession should return the encryption key of the stub, with the default algorithm and encoding")
+	b, err := m.BrokerFromSessionID(sessionID)
+	require.NoError(t, err, "BrokerFromSessionID should not return an error, but did")
+	require.Equal(t, "stub", b.ID, "Session should be routed to the test broker")
+
+	require.NoError(t, m.EndSession(context.Background(), sessionID), "EndSession should not return an error, but did")
+	require.Equal(t, []string{"stub-session-user1"}, stub.endedSessions(), "EndSession should be called on the stub")
+}
+
+// stubBroker is a minimal broker exported on dbus, recording the sessions it ended.
+type stubBroker struct {
+	ended   []string
+	endedMu sync.Mutex
+}
+
+func (b *stubBroker) NewSession(username, _, _ string) (sessionID, encryptionKey string, dbusErr *dbus.Error) {
+	return "stub-session-" + username, "stub-key", nil
+}
+
+func (b *stubBroker) EndSession(sessionID string) *dbus.Error {
+	b.endedMu.Lock()
+	defer b.endedMu.Unlock()
+	b.ended = append(b.ended, sessionID)
+	return nil
+}
+
+func (b *stubBroker) endedSessions() []string {
+	b.endedMu.Lock()
+	defer b.endedMu.Unlock()
+	return b.ended
+}
+
+func TestNewSessionKeyInfo(t *testing.T) {
+	t.Parallel()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to system bus")
+	t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+	const objPath = dbus.ObjectPath("/com/ubuntu/authd/KeyInfoStubBroker")
+	require.NoError(t, conn.Export(&keyInfoStubBroker{}, objPath, brokers.DbusInterface), "Setup: could not export stub broker")
+
+	m, err := brokers.NewManager(context.Background(), t.TempDir(), nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	m.RegisterTestBroker("stub", conn, objPath)
+
+	_, _, key, token, err := m.NewSession(context.Background(), "stub", "user1", "some_lang", "auth")
+	require.NoError(t, err, "NewSession should not return an error, but did")
+	want := brokers.KeyInfo{Algorithm: "X25519", Encoding: brokers.DefaultKeyEncoding, Key: "stub-key"}
+	require.Equal(t, want, key, "NewSession should return the algorithm reported by the broker, and the default encoding")
+
+	_, key, err = m.ResumeSession(context.Background(), token)
+	require.NoError(t, err, "ResumeSession should not return an error, but did")
+	require.Equal(t, want, key, "ResumeSession should return the same key information as NewSession")
+}
+
+func TestSessionReaper(t *testing.T) {
+	t.Parallel()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to system bus")
+	t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+	const objPath = dbus.ObjectPath("/com/ubuntu/authd/ReaperStubBroker")
+	require.NoError(t, conn.Export(&keyInfoStubBroker{}, objPath, brokers.DbusInterface), "Setup: could not export stub broker")
+
+	m, err := brokers.NewManager(context.Background(), t.TempDir(), nil, brokers.WithDefaultSessionTTL(50*time.Millisecond))
+	require.NoError(t, err, "Setup: could not create manager")
+	m.RegisterTestBroker("stub", conn, objPath)
+
+	_, expiring, _, token, err := m.NewSession(context.Background(), "stub", "expiring", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	_, lasting, _, _, err := m.NewSession(context.Background(), "stub", "lasting", "some_lang", "auth", brokers.WithSessionTTL(0))
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := m.StartSessionReaper(ctx, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		_, err := m.BrokerFromSessionID(expiring)
+		return errors.Is(err, brokers.ErrSessionExpired)
+	}, 5*time.Second, 10*time.Millisecond, "BrokerFromSessionID should return ErrSessionExpired once the session was reaped")
+
+	_, err = m.BrokerFromSessionID(lasting)
+	require.NoError(t, err, "BrokerFromSessionID should not return an error for a session without a maximum lifetime")
+	_, _, err = m.ResumeSession(context.Background(), token)
+	require.Error(t, err, "ResumeSession should return an error for a reaped session")
+	_, err = m.BrokerFromSessionID("unknown")
+	require.NotErrorIs(t, err, brokers.ErrSessionExpired, "BrokerFromSessionID should not return ErrSessionExpired for an unknown session")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The session reaper should stop when its context is cancelled")
+	}
+}
+
+func TestWaitBrokerReady(t *testing.T) {
+	t.Parallel()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to system bus")
+	t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+	brokersConfPath := t.TempDir()
+	for name, dbusName := range map[string]string{
+		"NotRunning": "com.ubuntu.authd.WaitBrokerReadyNotRunning",
+		"Late":       "com.ubuntu.authd.WaitBrokerReadyLate",
+	} {
+		cfg := fmt.Sprintf("[authd]\nname = %s\nbrand_icon = icon.png\ndbus_name = %s\ndbus_object = /com/ubuntu/authd/%s\n",
+			name, dbusName, name)
+		err := os.WriteFile(filepath.Join(brokersConfPath, name+".conf"), []byte(cfg), 0600)
+		require.NoError(t, err, "Setup: could not write broker configuration")
+	}
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, nil,
+		brokers.WithBrokerReadyTimeout(200*time.Millisecond))
+	require.NoError(t, err, "Setup: could not create manager")
+	m.RegisterTestBroker("running", conn, "/com/ubuntu/authd/Running")
+
+	brokerIDs := make(map[string]string)
+	for _, b := range m.AvailableBrokers() {
+		brokerIDs[b.Name] = b.ID
+	}
+
+	tests := map[string]struct {
+		broker  string
+		ownLate string
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Local broker is ready":                {broker: brokers.LocalBrokerName},
+		"Broker owning its name is ready":      {broker: "running"},
+		"Broker owning its name late is ready": {broker: "Late", ownLate: "com.ubuntu.authd.WaitBrokerReadyLate"},
+
+		"Error on unknown broker":                        {broker: "doesnotexist", wantErr: true},
+		"Error when the broker is not ready before done": {broker: "NotRunning", wantErrType: brokers.ErrBrokerNotReady},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.ownLate != "" {
+				go func() {
+					time.Sleep(100 * time.Millisecond)
+					if _, err := conn.RequestName(tc.ownLate, dbus.NameFlagDoNotQueue); err != nil {
+						t.Errorf("Setup: could not request the name of the broker: %v", err)
+					}
+				}()
+			}
+
+			timeout := 5 * time.Second
+			if tc.wantErrType != nil {
+				timeout = 200 * time.Millisecond
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			brokerID, ok := brokerIDs[tc.broker]
+			if !ok {
+				brokerID = tc.broker
+			}
+			err := m.WaitBrokerReady(ctx, brokerID)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "WaitBrokerReady should return the expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "WaitBrokerReady should return an error")
+				return
+			}
+			require.NoError(t, err, "WaitBrokerReady should not return an error")
+		})
+	}
+
+	// NewSession fails with a clear error when the broker is not ready in time.
+	_, _, _, _, err = m.NewSession(context.Background(), brokerIDs["NotRunning"], "user1", "some_lang", "auth")
+	require.ErrorIs(t, err, brokers.ErrBrokerNotReady, "NewSession should fail when the broker is not ready in time")
+}
+
+func TestHealthCheck(t *testing.T) {
+	t.Parallel()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to system bus")
+	t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+	brokersConfPath := t.TempDir()
+	for name, dbusName := range map[string]string{
+		"NotRunning": "com.ubuntu.authd.HealthCheckNotRunning",
+		"Restarted":  "com.ubuntu.authd.HealthCheckRestarted",
+	} {
+		cfg := fmt.Sprintf("[authd]\nname = %s\nbrand_icon = icon.png\ndbus_name = %s\ndbus_object = /com/ubuntu/authd/%s\n",
+			name, dbusName, name)
+		err := os.WriteFile(filepath.Join(brokersConfPath, name+".conf"), []byte(cfg), 0600)
+		require.NoError(t, err, "Setup: could not write broker configuration")
+	}
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	m.RegisterTestBroker("running", conn, "/com/ubuntu/authd/Running")
+
+	healthiness := func() map[string]bool {
+		r := make(map[string]bool)
+		for _, s := range m.AvailableBrokersWithStatus() {
+			r[s.Broker.Name] = s.Healthy
+		}
+		return r
+	}
+
+	want := map[string]bool{brokers.LocalBrokerName: true, "running": true, "NotRunning": true, "Restarted": true}
+	require.Equal(t, want, healthiness(), "Brokers should be healthy before any health check")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	m.HealthCheck(ctx)
+	want = map[string]bool{brokers.LocalBrokerName: true, "running": true, "NotRunning": false, "Restarted": false}
+	require.Equal(t, want, healthiness(), "Brokers not answering should be unhealthy after a health check")
+
+	_, err = conn.RequestName("com.ubuntu.authd.HealthCheckRestarted", dbus.NameFlagDoNotQueue)
+	require.NoError(t, err, "Setup: could not request the name of the broker")
+
+	m.HealthCheck(ctx)
+	want = map[string]bool{brokers.LocalBrokerName: true, "running": true, "NotRunning": false, "Restarted": true}
+	require.Equal(t, want, healthiness(), "Brokers answering again should be healthy after a health check")
+}
+
+func TestStartHealthChecks(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	cfg := "[authd]\nname = NotRunning\nbrand_icon = icon.png\n" +
+		"dbus_name = com.ubuntu.authd.StartHealthChecksNotRunning\ndbus_object = /com/ubuntu/authd/NotRunning\n"
+	err := os.WriteFile(filepath.Join(brokersConfPath, "NotRunning.conf"), []byte(cfg), 0600)
+	require.NoError(t, err, "Setup: could not write broker configuration")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, nil)
+	require.NoError(t, err, "Setup: could not create manager")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := m.StartHealthChecks(ctx, 10*time.Millisecond)
+
+	require.Eventually(t, func() bool {
+		for _, s := range m.AvailableBrokersWithStatus() {
+			if s.Broker.Name == "NotRunning" {
+				return !s.Healthy
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond, "Brokers not answering should be unhealthy once they were checked")
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The health checks should stop when their context is cancelled")
+	}
+}
+
+func TestReload(t *testing.T) {
+	t.Parallel()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to system bus")
+	t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+	const dbusName = "com.ubuntu.authd.ReloadRemoved"
+	_, err = conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	require.NoError(t, err, "Setup: could not request the name of the broker")
+	const objPath = dbus.ObjectPath("/com/ubuntu/authd/Removed")
+	require.NoError(t, conn.Export(&stubBroker{}, objPath, brokers.DbusInterface), "Setup: could not export stub broker")
+
+	brokersConfPath := t.TempDir()
+	writeConfig := func(name, dbusName string) {
+		t.Helper()
+		cfg := fmt.Sprintf("[authd]\nname = %s\nbrand_icon = icon.png\ndbus_name = %s\ndbus_object = /com/ubuntu/authd/%s\n",
+			name, dbusName, name)
+		err := os.WriteFile(filepath.Join(brokersConfPath, name+".conf"), []byte(cfg), 0600)
+		require.NoError(t, err, "Setup: could not write broker configuration")
+	}
+	writeConfig("Kept", "com.ubuntu.authd.ReloadKept")
+	writeConfig("Removed", dbusName)
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	m.RegisterTestBroker("stub", conn, objPath)
+
+	brokerNames := func() (names []string) {
+		for _, b := range m.AvailableBrokers() {
+			names = append(names, b.Name)
+		}
+		return names
+	}
+	brokerByName := func(name string) *brokers.Broker {
+		for _, b := range m.AvailableBrokers() {
+			if b.Name == name {
+				return b
+			}
+		}
+		return nil
+	}
+	require.Equal(t, []string{brokers.LocalBrokerName, "Kept", "Removed", "stub"}, brokerNames(), "Setup: unexpected brokers")
+
+	kept := brokerByName("Kept")
+	removed := brokerByName("Removed")
+	_, sessionID, _, _, err := m.NewSession(context.Background(), removed.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "Setup: could not create session with the broker to remove")
+	require.NoError(t, m.SetDefaultBrokerForUser(removed.ID, "user1"), "Setup: could not set default broker of user")
+
+	require.NoError(t, os.Remove(filepath.Join(brokersConfPath, "Removed.conf")), "Setup: could not remove broker configuration")
+	writeConfig("Added", "com.ubuntu.authd.ReloadAdded")
+	require.NoError(t, os.WriteFile(filepath.Join(brokersConfPath, "notaconf"), nil, 0600), "Setup: could not write file")
+
+	// Sessions are created and ended while reloading.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 10 {
+			_, id, _, _, err := m.NewSession(context.Background(), "stub", "user2", "some_lang", "auth")
+			if err != nil {
+				t.Errorf("NewSession should not fail while reloading: %v", err)
+				return
+			}
+			if err := m.EndSession(context.Background(), id); err != nil {
+				t.Errorf("EndSession should not fail while reloading: %v", err)
+				return
+			}
+		}
+	}()
+	err = m.Reload(context.Background())
+	wg.Wait()
+	require.NoError(t, err, "Reload should not return an error")
+
+	require.Equal(t, []string{brokers.LocalBrokerName, "Added", "Kept", "stub"}, brokerNames(), "Reload should load added brokers and drop removed ones")
+	require.Same(t, kept, brokerByName("Kept"), "Reload should keep the brokers which are still configured")
+
+	_, err = m.BrokerFromSessionID(sessionID)
+	require.Error(t, err, "Sessions of dropped brokers should be ended")
+	require.Nil(t, m.BrokerForUser("user1"), "Dropped brokers should not be the default broker of users anymore")
+
+	var diagnosed []string
+	for _, d := range m.DiagnoseBrokers() {
+		diagnosed = append(diagnosed, filepath.Base(d.File))
+	}
+	require.Equal(t, []string{"notaconf", "Added.conf", "Kept.conf"}, diagnosed, "Reload should update the diagnoses of the brokers")
+
+	// Reloading without any change keeps all brokers.
+	err = m.Reload(context.Background())
+	require.NoError(t, err, "Reload should not return an error")
+	require.Equal(t, []string{brokers.LocalBrokerName, "Added", "Kept", "stub"}, brokerNames(), "Reload without changes should keep all brokers")
+
+	// Only the local broker survives the removal of the directory.
+	require.NoError(t, os.RemoveAll(brokersConfPath), "Setup: could not remove brokers configuration directory")
+	err = m.Reload(context.Background())
+	require.NoError(t, err, "Reload should not return an error")
+	require.Equal(t, []string{brokers.LocalBrokerName, "stub"}, brokerNames(), "Reload should keep the local broker")
+}
+
+func TestReloadWarnsOnceAboutSkippedBrokers(t *testing.T) {
+	// The warnings are captured through the global log handler, so this test can't run in parallel.
+	defaultLevel := log.GetLevel()
+	t.Cleanup(func() {
+		log.SetLevel(defaultLevel)
+		log.SetLevelHandler(log.WarnLevel, nil)
+	})
+
+	var warnings []string
+	var warningsMu sync.Mutex
+	log.SetLevel(log.WarnLevel)
+	log.SetLevelHandler(log.WarnLevel, func(_ context.Context, _ log.Level, format string, args ...interface{}) {
+		warningsMu.Lock()
+		defer warningsMu.Unlock()
+		if msg := fmt.Sprintf(format, args...); strings.HasPrefix(msg, "Skipping broker") {
+			warnings = append(warnings, msg)
+		}
+	})
+	skippedWarnings := func() int {
+		warningsMu.Lock()
+		defer warningsMu.Unlock()
+		return len(warnings)
+	}
+
+	brokersConfPath := t.TempDir()
+	err := os.WriteFile(filepath.Join(brokersConfPath, "Invalid.conf"), []byte("[authd]\nname = Invalid\n"), 0600)
+	require.NoError(t, err, "Setup: could not write broker configuration")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	require.Equal(t, 1, skippedWarnings(), "NewManager should warn about the skipped broker")
+
+	for range 2 {
+		require.NoError(t, m.Reload(context.Background()), "Reload should not return an error")
+	}
+	require.Equal(t, 1, skippedWarnings(), "Reload should not warn again about a broker skipped for the same reason")
+
+	err = os.WriteFile(filepath.Join(brokersConfPath, "Invalid.conf"), []byte("[authd]\nname = Invalid\nbrand_icon = icon.png\n"), 0600)
+	require.NoError(t, err, "Setup: could not update broker configuration")
+	require.NoError(t, m.Reload(context.Background()), "Reload should not return an error")
+	require.Equal(t, 2, skippedWarnings(), "Reload should warn about a broker skipped for another reason")
+}
+
+// keyInfoStubBroker is a minimal broker exported on dbus, reporting the algorithm of its encryption key.
+type keyInfoStubBroker struct{}
+
+func (b *keyInfoStubBroker) NewSession(username, _, _ string) (sessionID, encryptionKey string, keyParams map[string]string, dbusErr *dbus.Error) {
+	return "stub-session-" + username, "stub-key", map[string]string{"algorithm": "X25519"}, nil
+}
+
+func TestNewSessionWithSessionContext(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		sessionContext     map[string]string
+		brokerSkipsContext bool
+
+		wantBrokerContext map[string]string
+	}{
+		"Passes the session context to the broker": {
+			sessionContext:    map[string]string{"device_id": "some-device", "client_ip": "192.0.2.1"},
+			wantBrokerContext: map[string]string{"device_id": "some-device", "client_ip": "192.0.2.1"},
+		},
+		"Passes an empty session context to the broker if there is none": {wantBrokerContext: map[string]string{}},
+		"Creates session without context if the broker does not accept it": {
+			sessionContext:     map[string]string{"device_id": "some-device"},
+			brokerSkipsContext: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			conn, err := testutils.GetSystemBusConnection(t)
+			require.NoError(t, err, "Setup: could not connect to system bus")
+			t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+			var stub any = &sessionContextStubBroker{}
+			if tc.brokerSkipsContext {
+				stub = &stubBroker{}
+			}
+			const objPath = dbus.ObjectPath("/com/ubuntu/authd/SessionContextStubBroker")
+			require.NoError(t, conn.Export(stub, objPath, brokers.DbusInterface), "Setup: could not export stub broker")
+
+			m, err := brokers.NewManager(context.Background(), t.TempDir(), nil)
+			require.NoError(t, err, "Setup: could not create manager")
+			m.RegisterTestBroker("stub", conn, objPath)
+
+			_, sessionID, _, _, err := m.NewSession(context.Background(), "stub", "user1", "some_lang", "auth",
+				brokers.WithSessionContext(tc.sessionContext))
+			require.NoError(t, err, "NewSession should not return an error, but did")
+
+			if s, ok := stub.(*sessionContextStubBroker); ok {
+				require.Equal(t, tc.wantBrokerContext, s.sessionContext, "Broker should receive the session context")
+			}
+			require.Equal(t, tc.sessionContext, m.SessionContext(sessionID), "Session context should be kept for the session")
+
+			require.NoError(t, m.EndSession(context.Background(), sessionID), "EndSession should not return an error, but did")
+			require.Nil(t, m.SessionContext(sessionID), "Session context should be dropped with the session")
+		})
+	}
+}
+
+func TestNewSessionWithSessionContextAfterBrokerUpgrade(t *testing.T) {
+	t.Parallel()
+
+	conn, err := testutils.GetSystemBusConnection(t)
+	require.NoError(t, err, "Setup: could not connect to system bus")
+	t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+	const objPath = dbus.ObjectPath("/com/ubuntu/authd/UpgradedStubBroker")
+	require.NoError(t, conn.Export(&stubBroker{}, objPath, brokers.DbusInterface), "Setup: could not export stub broker")
+
+	m, err := brokers.NewManager(context.Background(), t.TempDir(), nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	m.RegisterTestBroker("stub", conn, objPath)
+
+	sessionContext := map[string]string{"device_id": "some-device"}
+	_, _, _, _, err = m.NewSession(context.Background(), "stub", "user1", "some_lang", "auth", brokers.WithSessionContext(sessionContext))
+	require.NoError(t, err, "Setup: NewSession should not return an error with a broker not accepting a session context")
+
+	upgraded := &sessionContextStubBroker{}
+	require.NoError(t, conn.Export(upgraded, objPath, brokers.DbusInterface), "Setup: could not export upgraded stub broker")
+
+	// The broker is not asked again right away whether it accepts the session context.
+	_, _, _, _, err = m.NewSession(context.Background(), "stub", "user2", "some_lang", "auth", brokers.WithSessionContext(sessionContext))
+	require.Error(t, err, "NewSession should return an error while the upgraded broker is still called without a session context")
+	require.Nil(t, upgraded.sessionContext, "Upgraded broker should not receive the session context before being asked again")
+
+	m.ExpireSessionContextRejection("stub")
+	_, _, _, _, err = m.NewSession(context.Background(), "stub", "user3", "some_lang", "auth", brokers.WithSessionContext(sessionContext))
+	require.NoError(t, err, "NewSession should not return an error, but did")
+	require.Equal(t, sessionContext, upgraded.sessionContext, "Upgraded broker should receive the session context")
+}
+
+// sessionContextStubBroker is a minimal broker exported on dbus, recording the context of the session it created.
+type sessionContextStubBroker struct {
+	stubBroker
+	sessionContext map[string]string
+}
+
+func (b *sessionContextStubBroker) NewSession(username, _, _ string, sessionContext map[string]string) (sessionID, encryptionKey string, dbusErr *dbus.Error) {
+	b.sessionContext = sessionContext
+	return "stub-session-" + username, "stub-key", nil
+}
+
+func TestEnumerateBrokerUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		stub     any
+		brokerID string
+
+		wantNoUsers bool
+		wantErr     bool
+		wantErrType error
+	}{
+		"Returns the users of the broker":              {stub: &enumerationStubBroker{userinfos: `[{"Name":"user1","UUID":"uuid1","Dir":"/home/user1","Shell":"/bin/sh","Groups":[{"Name":"group1","UGID":"ugid1"}]},{"Name":"user2","UUID":"uuid2","Dir":"/home/user2","Shell":"/bin/sh","Groups":[]}]`}},
+		"Returns no users if the broker has none":      {stub: &enumerationStubBroker{userinfos: `[]`}, wantNoUsers: true},
+		"Error when broker does not exist":             {brokerID: "does not exist", wantErr: true},
+		"Error when broker returns invalid JSON":       {stub: &enumerationStubBroker{userinfos: `not json`}, wantErr: true},
+		"Error when broker returns an invalid user":    {stub: &enumerationStubBroker{userinfos: `[{"Name":"","UUID":"uuid1","Dir":"/home/user1","Shell":"/bin/sh"}]`}, wantErr: true},
+		"Error when broker fails to list its users":    {stub: &enumerationStubBroker{err: dbus.MakeFailedError(errors.New("failed"))}, wantErrType: brokers.ErrBrokerInternal},
+		"Error when broker does not implement listing": {stub: &keyInfoStubBroker{}, wantErrType: brokers.ErrEnumerationNotSupported},
+		"Error when broker refuses listing": {stub: &enumerationStubBroker{
+			err: dbus.NewError(brokers.DbusErrorEnumerationNotSupported, []any{"not for you"}),
+		}, wantErrType: brokers.ErrEnumerationNotSupported},
+		"Error on local broker": {brokerID: brokers.LocalBrokerName, wantErrType: brokers.ErrEnumerationNotSupported},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m, err := brokers.NewManager(context.Background(), t.TempDir(), nil)
+			require.NoError(t, err, "Setup: could not create manager")
+
+			if tc.stub != nil {
+				conn, err := testutils.GetSystemBusConnection(t)
+				require.NoError(t, err, "Setup: could not connect to system bus")
+				t.Cleanup(func() { require.NoError(t, conn.Close(), "Teardown: Failed to close the connection") })
+
+				const objPath = dbus.ObjectPath("/com/ubuntu/authd/EnumerationStubBroker")
+				require.NoError(t, conn.Export(tc.stub, objPath, brokers.DbusInterface), "Setup: could not export stub broker")
+				m.RegisterTestBroker("stub", conn, objPath)
+				tc.brokerID = "stub"
+			}
+
+			got, err := m.EnumerateBrokerUsers(context.Background(), tc.brokerID)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "EnumerateBrokerUsers should return the expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "EnumerateBrokerUsers should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "EnumerateBrokerUsers should not return an error, but did")
+			if tc.wantNoUsers {
+				require.Empty(t, got, "EnumerateBrokerUsers should not return any user")
+				return
+			}
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "EnumerateBrokerUsers should return the expected users")
+		})
+	}
+}
+
+// enumerationStubBroker is a minimal broker exported on dbus, listing its users.
+type enumerationStubBroker struct {
+	userinfos string
+	err       *dbus.Error
+}
+
+func (b *enumerationStubBroker) EnumerateUsers() (userinfos string, dbusErr *dbus.Error) {
+	return b.userinfos, b.err
+}
+
+func TestResumeSession(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker.conf")
+
+	newManager := func(t *testing.T, args ...brokers.Option) *brokers.Manager {
+		t.Helper()
+
+		m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"}, args...)
+		require.NoError(t, err, "Setup: could not create manager")
+		return m
+	}
+	for _, broker := range newManager(t).AvailableBrokers() {
+		if broker.Name == b.Name {
+			b.ID = broker.ID
+		}
+	}
+
+	t.Run("Resumes_an_ongoing_session_once", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManager(t)
+		_, wantID, wantKey, token, err := m.NewSession(context.Background(), b.ID, "user1", "some_lang", "auth")
+		require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+		require.NotEmpty(t, token, "NewSession should return a resumption token")
+		require.NotEqual(t, wantKey, token, "Resumption token should not be the encryption key")
+
+		gotID, gotKey, err := m.ResumeSession(context.Background(), token)
+		require.NoError(t, err, "ResumeSession should not return an error, but did")
+		require.Equal(t, wantID, gotID, "ResumeSession should return the ID of the session")
+		require.Equal(t, wantKey, gotKey, "ResumeSession should return the encryption key of the session")
+
+		_, _, err = m.ResumeSession(context.Background(), token)
+		require.ErrorIs(t, err, brokers.ErrInvalidResumptionToken, "ResumeSession should reject an already used token")
+	})
+
+	t.Run("Error_when_token_is_unknown", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManager(t)
+		_, _, err := m.ResumeSession(context.Background(), "unknown")
+		require.ErrorIs(t, err, brokers.ErrInvalidResumptionToken, "ResumeSession should reject an unknown token")
+	})
+
+	t.Run("Error_when_token_is_expired", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManager(t, brokers.WithResumptionTokenTTL(time.Nanosecond))
+		_, _, _, token, err := m.NewSession(context.Background(), b.ID, "user2", "some_lang", "auth")
+		require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+		time.Sleep(time.Millisecond)
+
+		_, _, err = m.ResumeSession(context.Background(), token)
+		require.ErrorIs(t, err, brokers.ErrResumptionTokenExpired, "ResumeSession should reject an expired token")
+	})
+
+	t.Run("Error_when_token_is_expired_and_pruned", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManager(t, brokers.WithResumptionTokenTTL(time.Nanosecond))
+		_, _, _, token, err := m.NewSession(context.Background(), b.ID, "user5", "some_lang", "auth")
+		require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+		time.Sleep(time.Millisecond)
+		_, _, _, _, err = m.NewSession(context.Background(), b.ID, "user6", "some_lang", "auth")
+		require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+
+		_, _, err = m.ResumeSession(context.Background(), token)
+		require.ErrorIs(t, err, brokers.ErrInvalidResumptionToken, "Issuing a token should prune the expired ones")
+	})
+
+	t.Run("Error_when_session_has_ended", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManager(t)
+		_, sessionID, _, token, err := m.NewSession(context.Background(), b.ID, "user3", "some_lang", "auth")
+		require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+		require.NoError(t, m.EndSession(context.Background(), sessionID), "Setup: EndSession should not return an error, but did")
+
+		_, _, err = m.ResumeSession(context.Background(), token)
+		require.ErrorIs(t, err, brokers.ErrInvalidResumptionToken, "ResumeSession should reject the token of an ended session")
+	})
+
+	t.Run("Error_when_broker_does_not_hold_the_session", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManager(t)
+		_, sessionID, _, token, err := m.NewSession(context.Background(), b.ID, "user4", "some_lang", "auth")
+		require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+		broker, err := m.BrokerFromSessionID(sessionID)
+		require.NoError(t, err, "Setup: BrokerFromSessionID should not return an error, but did")
+		broker.DropOngoingUserRequest(sessionID)
+
+		_, _, err = m.ResumeSession(context.Background(), token)
+		require.ErrorIs(t, err, brokers.ErrSessionEnded, "ResumeSession should reject a session the broker does not hold")
+	})
+
+	t.Run("Error_when_broker_has_lost_the_session", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManager(t)
+		_, _, _, token, err := m.NewSession(context.Background(), b.ID, "HS_lost", "some_lang", "auth")
+		require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+
+		_, _, err = m.ResumeSession(context.Background(), token)
+		require.ErrorIs(t, err, brokers.ErrSessionEnded, "ResumeSession should reject a session the broker lost")
+	})
+
+	t.Run("Error_when_broker_fails_to_check_the_session", func(t *testing.T) {
+		t.Parallel()
+
+		m := newManager(t)
+		_, _, _, token, err := m.NewSession(context.Background(), b.ID, "HS_error", "some_lang", "auth")
+		require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+
+		_, _, err = m.ResumeSession(context.Background(), token)
+		require.Error(t, err, "ResumeSession should return an error when the broker can't check the session")
+		require.NotErrorIs(t, err, brokers.ErrSessionEnded, "ResumeSession should not report the session as ended when the broker can't check it")
+	})
+}
+
+func TestNewSessionIsTraced(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker.conf")
+
+	tracer := &testTracer{}
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"}, brokers.WithTracer(tracer))
+	require.NoError(t, err, "Setup: could not create manager")
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b.Name {
+			b.ID = broker.ID
+		}
+	}
+
+	_, _, _, _, err = m.NewSession(context.Background(), b.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "NewSession should not return an error, but did")
+
+	want := []string{"NewSession", "broker lookup", "broker call", "session registration"}
+	require.Equal(t, want, tracer.started, "NewSession should trace each of its steps")
+	require.ElementsMatch(t, want, tracer.ended, "NewSession should end all its spans")
+}
+
+type testTracer struct {
+	started []string
+	ended   []string
+}
+
+func (t *testTracer) Start(ctx context.Context, name string) (context.Context, brokers.Span) {
+	t.started = append(t.started, name)
+	return ctx, testSpan{end: func() { t.ended = append(t.ended, name) }}
+}
+
+type testSpan struct {
+	end func()
+}
+
+func (s testSpan) End() { s.end() }
+
+func TestBrokerCallsAreAbortedOnContextCancellation(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker.conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b.Name {
+			b = *broker
+		}
+	}
+
+	// A canceled call returns before the broker answers.
+	const cancelAfter = 100 * time.Millisecond
+	require.Less(t, 2*cancelAfter, testutils.BrokerSlowCallDuration, "Setup: broker mock calls are not slow enough")
+
+	ctx, cancel := context.WithTimeout(context.Background(), cancelAfter)
+	defer cancel()
+	start := time.Now()
+	_, _, _, _, err = m.NewSession(ctx, b.ID, "NS_slow", "some_lang", "auth")
+	require.ErrorIs(t, err, context.DeadlineExceeded, "NewSession should return the context error")
+	require.Less(t, time.Since(start), testutils.BrokerSlowCallDuration, "NewSession should not wait for the broker to answer")
+
+	m.SetBrokerForSession(&b, "ES_slow")
+	ctx, cancel = context.WithTimeout(context.Background(), cancelAfter)
+	defer cancel()
+	start = time.Now()
+	err = m.EndSession(ctx, "ES_slow")
+	require.ErrorIs(t, err, context.DeadlineExceeded, "EndSession should return the context error")
+	require.Less(t, time.Since(start), testutils.BrokerSlowCallDuration, "EndSession should not wait for the broker to answer")
+	_, err = m.BrokerFromSessionID("ES_slow")
+	require.NoError(t, err, "EndSession should keep the session when the call to the broker was aborted")
+}
+
+func TestNewSessionEndsSessionCreatedAfterContextCancellation(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker.conf")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// The client gives up right after the broker created the session.
+	tracer := cancelingTracer{step: "broker call", cancel: cancel}
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"}, brokers.WithTracer(tracer))
+	require.NoError(t, err, "Setup: could not create manager")
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b.Name {
+			b = *broker
+		}
+	}
+
+	// The broker mock only creates one session at a time for the user, so the second call succeeds only if the
+	// session created by the first one was ended.
+	username := t.Name() + testutils.IDSeparator + "NS_slow_once"
+	_, _, _, _, err = m.NewSession(ctx, b.ID, username, "some_lang", "auth")
+	require.ErrorIs(t, err, context.Canceled, "NewSession should return the context error")
+	require.Zero(t, m.SessionCountsByBroker()[b.ID], "NewSession should not record the session when the context is done")
+	require.Empty(t, b.SessionUsername(testutils.GenerateSessionID(username)), "NewSession should not leave the session of the broker behind")
+
+	_, sessionID, _, _, err := m.NewSession(context.Background(), b.ID, username, "some_lang", "auth")
+	require.NoError(t, err, "NewSession should succeed once the session created after the context was done is ended")
+	require.NoError(t, m.EndSession(context.Background(), sessionID), "Teardown: EndSession should not return an error")
+}
+
+// cancelingTracer is a tracer calling cancel once the step is done.
+type cancelingTracer struct {
+	step   string
+	cancel context.CancelFunc
+}
+
+func (t cancelingTracer) Start(ctx context.Context, name string) (context.Context, brokers.Span) {
+	if name != t.step {
+		return ctx, testSpan{end: func() {}}
+	}
+	return ctx, testSpan{end: t.cancel}
+}
+
+func TestCancelIsAuthenticatedWithAllCallSlotsTaken(t *testing.T) {
+	t.Parallel()
+
+	const maxCalls = 2
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker.conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"},
+		brokers.WithMaxConcurrentBrokerCalls(maxCalls))
+	require.NoError(t, err, "Setup: could not create manager")
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b.Name {
+			b = *broker
+		}
+	}
+
+	type result struct {
+		access string
+		err    error
+	}
+	isAuthenticated := func(ctx context.Context, sessionID string) <-chan result {
+		r := make(chan result, 1)
+		go func() {
+			access, _, err := b.IsAuthenticated(ctx, sessionID, "password")
+			r <- result{access, err}
+		}()
+		return r
+	}
+
+	// Fill all the call slots with calls waiting to be cancelled.
+	var cancels []context.CancelFunc
+	var results []<-chan result
+	for i := range maxCalls {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cancels = append(cancels, cancel)
+		results = append(results, isAuthenticated(ctx, prefixID(t, fmt.Sprint(i))+testutils.IDSeparator+"IA_wait"))
+	}
+	require.Eventually(t, func() bool { return m.InFlightBrokerCalls() == maxCalls }, 5*time.Second, 10*time.Millisecond,
+		"Setup: IsAuthenticated calls should take all the call slots")
+
+	// A call waiting for a slot returns as soon as it is cancelled.
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := isAuthenticated(ctx, prefixID(t, "queued")+testutils.IDSeparator+"IA_wait")
+	cancel()
+	select {
+	case r := <-queued:
+		require.ErrorIs(t, r.err, context.Canceled, "IsAuthenticated waiting for a call slot should return the context error")
+	case <-time.After(5 * time.Second):
+		t.Fatal("IsAuthenticated waiting for a call slot should return once cancelled")
+	}
+
+	// The calls holding the slots are cancelled on the broker, without waiting for a slot.
+	for i, cancel := range cancels {
+		cancel()
+		select {
+		case r := <-results[i]:
+			require.NoError(t, r.err, "Cancelled IsAuthenticated should not return an error")
+			require.Equal(t, brokers.AuthCancelled, r.access, "Cancelled IsAuthenticated should be cancelled on the broker")
+		case <-time.After(5 * time.Second):
+			t.Fatal("IsAuthenticated holding a call slot should return once cancelled")
+		}
+	}
+	require.Zero(t, m.InFlightBrokerCalls(), "No broker call should be in flight once the calls are cancelled")
+}
+
+func TestBrokerCallsTimeOutAndCanBeRetried(t *testing.T) {
+	t.Parallel()
+
+	const timeout = 100 * time.Millisecond
+	require.Less(t, 2*timeout, testutils.BrokerSlowCallDuration, "Setup: broker mock calls are not slow enough")
+
+	brokersConfPath := t.TempDir()
+	b := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker.conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"},
+		brokers.WithBrokerCallTimeout(timeout))
+	require.NoError(t, err, "Setup: could not create manager")
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b.Name {
+			b = *broker
+		}
+	}
+
+	// The broker mock only creates one session at a time for the user, so the retry succeeds once the session created
+	// by the call which timed out is ended.
+	username := t.Name() + testutils.IDSeparator + "NS_slow_once"
+	start := time.Now()
+	_, _, _, _, err = m.NewSession(context.Background(), b.ID, username, "some_lang", "auth")
+	require.ErrorIs(t, err, context.DeadlineExceeded, "NewSession should time out")
+	require.Less(t, time.Since(start), testutils.BrokerSlowCallDuration, "NewSession should not wait for the broker to answer")
+	require.Zero(t, m.SessionCountsByBroker()[b.ID], "NewSession should not record the session when the call timed out")
+	require.Eventually(t, func() bool {
+		_, sessionID, _, _, err := m.NewSession(context.Background(), b.ID, username, "some_lang", "auth")
+		if err != nil {
+			return false
+		}
+		require.NoError(t, m.EndSession(context.Background(), sessionID), "Teardown: EndSession should not return an error")
+		return true
+	}, 2*testutils.BrokerSlowCallDuration, timeout, "NewSession should succeed once the session created by the call which timed out is ended")
+
+	_, sessionID, _, _, err := m.NewSession(context.Background(), b.ID, t.Name()+testutils.IDSeparator+"ES_slow_once", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	start = time.Now()
+	err = m.EndSession(context.Background(), sessionID)
+	require.ErrorIs(t, err, context.DeadlineExceeded, "EndSession should time out")
+	require.Less(t, time.Since(start), testutils.BrokerSlowCallDuration, "EndSession should not wait for the broker to answer")
+	broker, err := m.BrokerFromSessionID(sessionID)
+	require.NoError(t, err, "EndSession should keep the session when the call to the broker timed out")
+	require.NotEmpty(t, broker.SessionUsername(sessionID), "EndSession should keep the session of the broker when the call timed out")
+	require.NoError(t, m.EndSession(context.Background(), sessionID), "EndSession should succeed when retried")
+	_, err = m.BrokerFromSessionID(sessionID)
+	require.Error(t, err, "EndSession should remove the session once the broker ended it")
+	require.Empty(t, broker.SessionUsername(sessionID), "EndSession should remove the session of the broker once it ended it")
+
+	m.SetBrokerForSession(&b, "ES_slow")
+	m.ExpireSession("ES_slow")
+	start = time.Now()
+	m.ReapExpiredSessions(context.Background())
+	require.Less(t, time.Since(start), testutils.BrokerSlowCallDuration, "The session reaper should not wait for the broker to answer")
+	_, err = m.BrokerFromSessionID("ES_slow")
+	require.ErrorIs(t, err, brokers.ErrSessionExpired, "The session reaper should end the session even when the call to the broker timed out")
+}
+
+func TestLoadedBrokers(t *testing.T) {
+	t.Parallel()
+
+	m, err := brokers.NewManager(context.Background(), filepath.Join(brokerConfFixtures, "display_info"), nil)
+	require.NoError(t, err, "Setup: could not create manager")
+
+	got := m.LoadedBrokers()
+	want := []brokers.BrokerInfo{
+		{ID: brokers.LocalBrokerName, Name: brokers.LocalBrokerName, DisplayName: "Local Broker"},
+		{
+			ID:            m.AvailableBrokers()[1].ID,
+			Name:          "Broker",
+			BrandIconPath: "some_icon.png",
+			DisplayName:   "Corporate SSO",
+			Icon:          "file:///usr/share/icons/corporate.svg",
+		},
+	}
+	require.Equal(t, want, got, "LoadedBrokers should return the information of all brokers in order")
+}
+
+func TestSetBrokerMaintenance(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		message string
+		until   time.Time
+		cleared bool
+
+		wantMaintenance bool
+	}{
+		"Broker under maintenance until cleared":       {message: "SSO is under maintenance", wantMaintenance: true},
+		"Broker under maintenance until a given time":  {message: "SSO is under maintenance", until: time.Now().Add(time.Hour), wantMaintenance: true},
+		"Broker not under maintenance after it ended":  {message: "SSO is under maintenance", until: time.Now().Add(-time.Hour)},
+		"Broker not under maintenance once it cleared": {message: "SSO is under maintenance", cleared: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			brokersConfPath := t.TempDir()
+			b := newBrokerForTests(t, brokersConfPath, "")
+			m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b.Name + ".conf"})
+			require.NoError(t, err, "Setup: could not create manager")
+			id := m.AvailableBrokers()[1].ID
+
+			err = m.SetBrokerMaintenance(id, tc.message, tc.until)
+			require.NoError(t, err, "SetBrokerMaintenance should not return an error, but did")
+			if tc.cleared {
+				err = m.SetBrokerMaintenance(id, "", time.Time{})
+				require.NoError(t, err, "SetBrokerMaintenance should not return an error, but did")
+			}
+
+			gotInfo := m.LoadedBrokers()[1].Maintenance
+			_, _, _, _, err = m.NewSession(context.Background(), id, "user1", "some_lang", "auth")
+			if !tc.wantMaintenance {
+				require.Nil(t, gotInfo, "LoadedBrokers should not report a maintenance")
+				require.NoError(t, err, "NewSession should not return an error, but did")
+				return
+			}
+
+			want := brokers.BrokerMaintenance{Message: tc.message, Until: tc.until}
+			require.NotNil(t, gotInfo, "LoadedBrokers should report the maintenance")
+			require.Equal(t, want, *gotInfo, "LoadedBrokers should report the maintenance notice")
+
+			var maintenanceErr brokers.MaintenanceError
+			require.ErrorAs(t, err, &maintenanceErr, "NewSession should return a maintenance error")
+			require.Equal(t, want, maintenanceErr.BrokerMaintenance, "NewSession should return the maintenance notice")
+			require.Equal(t, tc.message, err.Error(), "NewSession error should be the maintenance message")
+		})
+	}
+
+	m, err := brokers.NewManager(context.Background(), t.TempDir(), nil)
+	require.NoError(t, err, "Setup: could not create manager")
+	err = m.SetBrokerMaintenance("nonexistent", "message", time.Time{})
+	require.Error(t, err, "SetBrokerMaintenance should return an error for an unknown broker")
+}
+
+func TestBrokerForUID(t *testing.T) {
+	t.Parallel()
+
+	m, err := brokers.NewManager(context.Background(), filepath.Join(brokerConfFixtures, "uid_ranges"), nil)
+	require.NoError(t, err, "Setup: could not create manager")
+
+	tests := map[string]struct {
+		uid uint32
+
+		wantBroker string
+	}{
+		"Broker authoritative for the UID":              {uid: 100000, wantBroker: "First"},
+		"Broker authoritative for the UID at range end": {uid: 299999, wantBroker: "Second"},
+		"First broker in order when ranges overlap":     {uid: 155555, wantBroker: "First"},
+
+		"No broker when none is authoritative for the UID": {uid: 300000},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := m.BrokerForUID(tc.uid)
+			if tc.wantBroker == "" {
+				require.Nil(t, got, "BrokerForUID should not return a broker")
+				return
+			}
+			require.NotNil(t, got, "BrokerForUID should return a broker")
+			require.Equal(t, tc.wantBroker, got.Name, "BrokerForUID should return the expected broker")
+		})
+	}
+}
+
+func TestDefaultBroker(t *testing.T) {
+	t.Parallel()
+
+	brokersConfPath := t.TempDir()
+	b1 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker1.conf")
+	b2 := newBrokerForTests(t, brokersConfPath, t.Name()+"_Broker2.conf")
+
+	m, err := brokers.NewManager(context.Background(), brokersConfPath, []string{b1.Name + ".conf", b2.Name + ".conf"})
+	require.NoError(t, err, "Setup: could not create manager")
+
+	for _, broker := range m.AvailableBrokers() {
+		if broker.Name == b1.Name {
+			b1.ID = broker.ID
+		} else if broker.Name == b2.Name {
+			b2.ID = broker.ID
+		}
+	}
+
+	require.Nil(t, m.DefaultBroker(""), "DefaultBroker should return nil before any session was started, but did not")
+	require.Nil(t, m.DefaultBroker("tty1"), "DefaultBroker should return nil before any session was started, but did not")
+
+	_, _, _, _, err = m.NewSession(context.Background(), b1.ID, "user1", "some_lang", "auth")
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	require.Equal(t, b1.ID, m.DefaultBroker("").ID, "DefaultBroker should return the broker of the last session without context")
+	require.Equal(t, b1.ID, m.DefaultBroker("tty1").ID, "DefaultBroker should fall back to the global default for unknown contexts")
+
+	_, _, _, _, err = m.NewSession(context.Background(), b2.ID, "user2", "some_lang", "auth", brokers.WithContextKey("tty1"))
+	require.NoError(t, err, "Setup: NewSession should not return an error, but did")
+	require.Equal(t, b2.ID, m.DefaultBroker("tty1").ID, "DefaultBroker should return the broker of the last session in this context")
+	require.Equal(t, b1.ID, m.DefaultBroker("").ID, "DefaultBroker should not change the global default when a context is given")
+	require.Equal(t, b1.ID, m.DefaultBroker("tty2").ID, "DefaultBroker should fall back to the global default for other contexts")
+
+	_, _, _, _, err = m.NewSession(context.Background(), b2.ID, "NS_error", "some_lang", "auth", brokers.WithContextKey("tty2"))
+	require.Error(t, err, "Setup: NewSession should return an error, but did not")
+	require.Equal(t, b1.ID, m.DefaultBroker("tty2").ID, "DefaultBroker should not be updated when NewSession fails")
 }
 
 func TestMain(m *testing.M) {
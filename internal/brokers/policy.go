@@ -0,0 +1,72 @@
+package brokers
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+)
+
+// ErrBrokerNotPermitted is returned when creating a session for a user with a broker the broker policy doesn't permit
+// them to use.
+var ErrBrokerNotPermitted = errors.New("broker not permitted for user")
+
+// BrokerPolicyRule restricts the brokers the users matching Pattern can authenticate with.
+type BrokerPolicyRule struct {
+	// Pattern matches usernames, with the syntax of path.Match. A pattern without any special character matches a
+	// single user.
+	Pattern string
+	// Allow is the IDs of the only brokers the users can use. Any broker can be used if it is empty. The local broker
+	// can be used even if it is not listed.
+	Allow []string
+	// Deny is the IDs of the brokers the users can't use, including the local broker, which break-glass users can use
+	// anyway.
+	Deny []string
+}
+
+// BrokerPolicy is the list of rules restricting the brokers users can authenticate with. The first rule matching a
+// user applies to them, and users without any matching rule can use any broker.
+type BrokerPolicy []BrokerPolicyRule
+
+// permits returns whether the user can use the broker matching brokerID.
+func (p BrokerPolicy) permits(username, brokerID string) bool {
+	for _, r := range p {
+		// Patterns are validated when the policy is set.
+		if ok, _ := path.Match(r.Pattern, username); !ok {
+			continue
+		}
+		if slices.Contains(r.Deny, brokerID) {
+			return false
+		}
+		return brokerID == LocalBrokerName || len(r.Allow) == 0 || slices.Contains(r.Allow, brokerID)
+	}
+	return true
+}
+
+// SetBrokerPolicy replaces the policy restricting the brokers users can authenticate with. An empty policy permits any
+// broker to any user.
+// It returns an error if any of the patterns is malformed, in which case the previous policy is kept.
+func (m *Manager) SetBrokerPolicy(policy BrokerPolicy) error {
+	for _, r := range policy {
+		if _, err := path.Match(r.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid username pattern %q in broker policy: %v", r.Pattern, err)
+		}
+	}
+
+	m.brokerPolicyMu.Lock()
+	defer m.brokerPolicyMu.Unlock()
+	m.brokerPolicy = slices.Clone(policy)
+	return nil
+}
+
+// isPermittedBroker returns whether the broker policy permits the user to use the broker. Break-glass users are always
+// permitted to use the local broker, so that the policy can't lock them out.
+func (m *Manager) isPermittedBroker(username string, broker *Broker) bool {
+	if broker.ID == LocalBrokerName && m.isBreakGlassUser(username) {
+		return true
+	}
+
+	m.brokerPolicyMu.RLock()
+	defer m.brokerPolicyMu.RUnlock()
+	return m.brokerPolicy.permits(username, broker.ID)
+}
@@ -0,0 +1,117 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBrokerQueryTTL is how long the results of idempotent broker queries are cached, unless configured otherwise.
+const DefaultBrokerQueryTTL = 5 * time.Second
+
+// maxQueryCacheEntries bounds the number of cached results. The cache is cleared when it's reached.
+const maxQueryCacheEntries = 1024
+
+// WithBrokerQueryTTL sets how long the results of the idempotent broker queries, like UserPreCheck, are cached.
+// 0 disables the cache.
+func WithBrokerQueryTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.brokerQueryTTL = d
+	}
+}
+
+// QueryCacheStats are the statistics of the cache of broker queries.
+type QueryCacheStats struct {
+	Hits   uint64
+	Misses uint64
+}
+
+// HitRate returns the ratio of queries answered from the cache, or 0 if there was none.
+func (s QueryCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// queryCache caches the results of idempotent broker queries. A nil queryCache doesn't cache anything.
+// Calls changing the state of brokers, like creating or ending sessions, must never go through it.
+type queryCache struct {
+	ttl time.Duration
+
+	entries map[queryKey]cachedResult
+	mu      sync.Mutex
+
+	hits, misses atomic.Uint64
+}
+
+type queryKey struct {
+	brokerID, query, arg string
+}
+
+type cachedResult struct {
+	value   string
+	err     error
+	expires time.Time
+}
+
+// newQueryCache returns a queryCache keeping results for ttl, or nil if ttl is not positive.
+func newQueryCache(ttl time.Duration) *queryCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &queryCache{ttl: ttl, entries: make(map[queryKey]cachedResult)}
+}
+
+// get returns the result of the query of the broker, calling fn if it's not cached.
+// Only successful results and users unknown to the broker are cached, as other errors can be transient.
+func (c *queryCache) get(ctx context.Context, brokerID, query, arg string, fn func(context.Context) (string, error)) (string, error) {
+	if c == nil {
+		return fn(ctx)
+	}
+
+	key := queryKey{brokerID: brokerID, query: query, arg: arg}
+	c.mu.Lock()
+	r, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(r.expires) {
+		c.hits.Add(1)
+		return r.value, r.err
+	}
+	c.misses.Add(1)
+
+	value, err := fn(ctx)
+	if err != nil && !errors.Is(err, ErrUserUnknownToBroker) {
+		return value, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= maxQueryCacheEntries {
+		c.entries = make(map[queryKey]cachedResult)
+	}
+	c.entries[key] = cachedResult{value: value, err: err, expires: time.Now().Add(c.ttl)}
+
+	return value, err
+}
+
+// clear drops all the cached results.
+func (c *queryCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[queryKey]cachedResult)
+}
+
+// stats returns the hits and misses of the cache.
+func (c *queryCache) stats() QueryCacheStats {
+	if c == nil {
+		return QueryCacheStats{}
+	}
+	return QueryCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
@@ -0,0 +1,64 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+)
+
+// ErrBrokerNotReady is returned when a broker didn't become ready before the context was done.
+var ErrBrokerNotReady = errors.New("broker not ready")
+
+const (
+	minReadinessPollDelay = 50 * time.Millisecond
+	maxReadinessPollDelay = 2 * time.Second
+)
+
+// WithBrokerReadyTimeout makes NewSession wait up to d for the broker to be ready, like when it's started on demand
+// on boot, instead of failing right away. 0, the default, doesn't wait.
+func WithBrokerReadyTimeout(d time.Duration) Option {
+	return func(o *options) {
+		o.brokerReadyTimeout = d
+	}
+}
+
+// WaitBrokerReady waits until the broker matching brokerID is ready to take calls, which is when its dbus name is
+// owned or can be activated by the bus. The broker is polled with an exponential backoff.
+// It returns an error matching ErrBrokerNotReady if ctx is done first, so that callers can fall back to another broker.
+func (m *Manager) WaitBrokerReady(ctx context.Context, brokerID string) error {
+	b, err := m.brokerFromID(brokerID)
+	if err != nil {
+		return err
+	}
+
+	return b.waitReady(ctx)
+}
+
+// waitReady waits until the broker is ready to take calls or ctx is done.
+func (b Broker) waitReady(ctx context.Context) error {
+	// The local broker is always ready.
+	if b.brokerer == nil {
+		return nil
+	}
+
+	delay := minReadinessPollDelay
+	for {
+		ready, err := b.brokerer.Ready(ctx)
+		if ready {
+			return nil
+		}
+		if err != nil && ctx.Err() == nil {
+			log.Debugf(ctx, "Could not check if broker %q is ready, retrying in %v: %v", b.Name, delay, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w: broker %q is not available: %w", ErrBrokerNotReady, b.Name, ctx.Err())
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxReadinessPollDelay)
+	}
+}
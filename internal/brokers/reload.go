@@ -0,0 +1,335 @@
+package brokers
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/decorate"
+)
+
+// brokerSet is the set of loaded brokers.
+type brokerSet struct {
+	brokers      map[string]*Broker
+	brokersOrder []string
+	// configFiles maps the configuration files of the loaded brokers to their ID. The local broker, and the brokers
+	// registered for tests, don't have any.
+	configFiles map[string]string
+
+	diagnoses []BrokerDiagnosis
+}
+
+// Reload loads the brokers whose configuration file was added since the brokers were last loaded, and drops the ones
+// whose configuration file was removed, after ending their sessions. The brokers which are still configured are kept
+// as they are with their sessions, even if their configuration file changed. The local broker is always kept.
+// It can be called concurrently with the other methods of the manager.
+func (m *Manager) Reload(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, "can't reload brokers")
+
+	m.reloadMu.Lock()
+	defer m.reloadMu.Unlock()
+
+	configFiles, diagnoses, err := brokerConfigFiles(ctx, m.brokersConfPath, m.configuredBrokers)
+	if err != nil {
+		return err
+	}
+
+	m.brokersMu.RLock()
+	previous := m.brokerSet
+	m.brokersMu.RUnlock()
+
+	// Loading brokers can take some time, so we don't block the other calls meanwhile. Only Reload changes the
+	// brokers, so previous can't be outdated.
+	loaded := loadBrokers(ctx, configFiles, m.brokerOrder, m.bus, m.calls, m.queries, previous)
+	loaded.diagnoses = append(diagnoses, loaded.diagnoses...)
+
+	var dropped []*Broker
+	for _, id := range previous.brokersOrder {
+		if b := previous.brokers[id]; loaded.brokers[id] != b {
+			log.Infof(ctx, "Dropping broker %q, its configuration file was removed", b.Name)
+			dropped = append(dropped, b)
+		}
+	}
+	for _, id := range loaded.brokersOrder {
+		if b := loaded.brokers[id]; previous.brokers[id] != b {
+			log.Infof(ctx, "Loaded broker %q", b.Name)
+		}
+	}
+
+	m.brokersMu.Lock()
+	m.brokerSet = loaded
+	m.domainBrokers = m.brokersOfDomains(ctx)
+	sessions := m.forgetBrokers(dropped)
+	m.brokersMu.Unlock()
+
+	// The results of the queries of the dropped brokers must not be returned if another broker gets the same ID.
+	m.queries.clear()
+
+	// The brokers are not reachable anymore, so their sessions can't be ended by the clients.
+	var sessionIDs []string
+	for sessionID, b := range sessions {
+		if err := b.endSession(ctx, sessionID); err != nil {
+			log.Warningf(ctx, "Could not end session %q of dropped broker %q: %v", sessionID, b.Name, err)
+			b.forgetSession(sessionID)
+		}
+		m.dropResumptionTokens(sessionID)
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	m.unpersistSessions(ctx, sessionIDs...)
+
+	return nil
+}
+
+// forgetBrokers removes the given brokers from the user and default brokers, and returns their sessions, which are
+// forgotten too.
+func (m *Manager) forgetBrokers(dropped []*Broker) (sessions map[string]*Broker) {
+	isDropped := func(b *Broker) bool {
+		for _, d := range dropped {
+			if b == d {
+				return true
+			}
+		}
+		return false
+	}
+
+	m.usersToBrokerMu.Lock()
+	for username, b := range m.usersToBroker {
+		if isDropped(b) {
+			delete(m.usersToBroker, username)
+		}
+	}
+	m.usersToBrokerMu.Unlock()
+
+	m.defaultBrokersMu.Lock()
+	for contextKey, b := range m.defaultBrokers {
+		if isDropped(b) {
+			delete(m.defaultBrokers, contextKey)
+		}
+	}
+	m.defaultBrokerContexts = slices.DeleteFunc(m.defaultBrokerContexts, func(k string) bool {
+		_, ok := m.defaultBrokers[k]
+		return !ok
+	})
+	m.defaultBrokersMu.Unlock()
+
+	sessions = make(map[string]*Broker)
+	m.transactionsToBrokerMu.Lock()
+	defer m.transactionsToBrokerMu.Unlock()
+	for sessionID, s := range m.transactionsToBroker {
+		if !isDropped(s.broker) {
+			continue
+		}
+		sessions[sessionID] = s.broker
+		delete(m.transactionsToBroker, sessionID)
+		delete(m.sessionContexts, sessionID)
+	}
+	return sessions
+}
+
+// brokersOfDomains returns the loaded brokers the domains passed to WithDomainBrokerMap are mapped to. Domains mapped
+// to brokers which are not loaded anymore are skipped.
+func (m *Manager) brokersOfDomains(ctx context.Context) map[string]*Broker {
+	domainBrokers := make(map[string]*Broker, len(m.domainBrokerIDs))
+	for domain, brokerID := range m.domainBrokerIDs {
+		b, exists := m.brokers[brokerID]
+		if !exists {
+			log.Warningf(ctx, "Domain %q is mapped to broker %q, which is not loaded anymore", domain, brokerID)
+			continue
+		}
+		domainBrokers[strings.ToLower(domain)] = b
+	}
+	return domainBrokers
+}
+
+// brokerConfigFileName is the name of the configuration file of the brokers which have their own directory in the
+// brokers configuration directory, so that they can ship other files along with it.
+const brokerConfigFileName = "broker.conf"
+
+// brokerConfigFiles returns the configuration files of the brokers to load, in preference order: the configured ones
+// or, if none is, the .conf files and the subdirectories of the brokers configuration directory in ascii order. The
+// other files of the directory are diagnosed as not being broker configuration files.
+// The configuration file of a broker configured as a subdirectory is its brokerConfigFileName file. Subdirectories
+// without any are diagnosed like invalid broker configuration files once loaded.
+func brokerConfigFiles(ctx context.Context, brokersConfPath string, configuredBrokers []string) (configFiles []string, diagnoses []BrokerDiagnosis, err error) {
+	// Select all brokers in ascii order if none is configured
+	if len(configuredBrokers) == 0 {
+		log.Debug(ctx, "Auto-detecting brokers")
+
+		entries, err := os.ReadDir(brokersConfPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Warningf(ctx, "Broker configuration directory %q does not exist, only local broker will be available", brokersConfPath)
+		} else if err != nil {
+			return nil, nil, fmt.Errorf("could not read brokers directory to detect brokers: %v", err)
+		}
+
+		for _, e := range entries {
+			if e.IsDir() {
+				configuredBrokers = append(configuredBrokers, e.Name())
+				continue
+			}
+			if !e.Type().IsRegular() {
+				continue
+			}
+			if !strings.HasSuffix(e.Name(), ".conf") {
+				log.Infof(ctx, "Skipping file %q in brokers configuration directory, only .conf files are supported", e.Name())
+				diagnoses = append(diagnoses, BrokerDiagnosis{
+					File:   filepath.Join(brokersConfPath, e.Name()),
+					Reason: ErrNotBrokerConfig,
+				})
+				continue
+			}
+			configuredBrokers = append(configuredBrokers, e.Name())
+		}
+	}
+
+	for _, cfgFileName := range configuredBrokers {
+		configFile := filepath.Join(brokersConfPath, cfgFileName)
+		if fi, err := os.Stat(configFile); err == nil && fi.IsDir() {
+			configFile = filepath.Join(configFile, brokerConfigFileName)
+		}
+		configFiles = append(configFiles, configFile)
+	}
+	return configFiles, diagnoses, nil
+}
+
+// brokerConfigName returns the name of configFile to log: its base name, prefixed by its directory for the
+// brokerConfigFileName file of a broker configured as a subdirectory.
+func brokerConfigName(configFile string) string {
+	if filepath.Base(configFile) != brokerConfigFileName {
+		return filepath.Base(configFile)
+	}
+	return filepath.Join(filepath.Base(filepath.Dir(configFile)), brokerConfigFileName)
+}
+
+// loadBrokers returns the local broker first, followed by the brokers of configFiles, reordered by order. The brokers
+// of previous which were loaded from one of configFiles, and the ones without configuration file, are kept as is.
+func loadBrokers(ctx context.Context, configFiles, order []string, bus *busConn, calls *callLimiter, queries *queryCache, previous brokerSet) (loaded brokerSet) {
+	loaded = brokerSet{
+		brokers:     make(map[string]*Broker),
+		configFiles: make(map[string]string),
+	}
+
+	// First broker is always the local one.
+	local, ok := previous.brokers[LocalBrokerName]
+	if !ok {
+		// Creating the local broker can't fail.
+		b, _ := newBroker(ctx, "", nil, calls)
+		local = &b
+	}
+	loaded.brokersOrder = append(loaded.brokersOrder, local.ID)
+	loaded.brokers[local.ID] = local
+
+	// The files skipped for the same reason by the previous load were already warned about.
+	warned := make(map[string]string)
+	for _, d := range previous.diagnoses {
+		if d.Reason != nil {
+			warned[d.File] = d.Reason.Error()
+		}
+	}
+	skip := func(configFile string, reason error, format string, args ...any) {
+		loaded.diagnoses = append(loaded.diagnoses, BrokerDiagnosis{File: configFile, Reason: reason})
+		if warned[configFile] == reason.Error() {
+			log.Debugf(ctx, format, args...)
+			return
+		}
+		log.Warningf(ctx, format, args...)
+	}
+
+	// Load brokers configuration
+	for _, configFile := range configFiles {
+		if id, ok := previous.configFiles[configFile]; ok {
+			loaded.brokersOrder = append(loaded.brokersOrder, id)
+			loaded.brokers[id] = previous.brokers[id]
+			loaded.configFiles[configFile] = id
+			loaded.diagnoses = append(loaded.diagnoses, BrokerDiagnosis{File: configFile, BrokerID: id, Loaded: true})
+			continue
+		}
+
+		b, err := newBroker(ctx, configFile, bus, calls)
+		if err != nil {
+			reason := err
+			if !errors.Is(err, ErrInvalidDbusName) {
+				reason = fmt.Errorf("%w: %v", ErrInvalidBrokerConfig, err)
+			}
+			skip(configFile, reason, "Skipping broker %q is not correctly configured: %v", brokerConfigName(configFile), err)
+			continue
+		}
+		if _, exists := loaded.brokers[b.ID]; exists {
+			skip(configFile, fmt.Errorf("%w: a broker named %q is already loaded", ErrDuplicateBrokerID, b.Name),
+				"Skipping broker %q: a broker named %q is already loaded", brokerConfigName(configFile), b.Name)
+			continue
+		}
+		b.queries = queries
+		loaded.brokersOrder = append(loaded.brokersOrder, b.ID)
+		loaded.brokers[b.ID] = &b
+		loaded.configFiles[configFile] = b.ID
+		loaded.diagnoses = append(loaded.diagnoses, BrokerDiagnosis{File: configFile, BrokerID: b.ID, Loaded: true})
+	}
+
+	// Brokers without configuration file, registered for tests, are never dropped.
+	withConfigFile := make(map[string]bool)
+	for _, id := range previous.configFiles {
+		withConfigFile[id] = true
+	}
+	for _, id := range previous.brokersOrder {
+		if id == LocalBrokerName || withConfigFile[id] {
+			continue
+		}
+		if _, exists := loaded.brokers[id]; exists {
+			continue
+		}
+		loaded.brokersOrder = append(loaded.brokersOrder, id)
+		loaded.brokers[id] = previous.brokers[id]
+	}
+
+	orderBrokers(ctx, loaded, order)
+	logOverlappingUIDRanges(ctx, loaded.brokers, loaded.brokersOrder)
+
+	return loaded
+}
+
+// orderBrokers moves the brokers named in order first, in that order, right after the local broker. The other brokers
+// keep their relative order.
+func orderBrokers(ctx context.Context, loaded brokerSet, order []string) {
+	if len(order) == 0 {
+		return
+	}
+
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, exists := rank[name]; !exists {
+			rank[name] = i
+		}
+	}
+	loadedNames := make(map[string]bool, len(loaded.brokersOrder))
+	for _, id := range loaded.brokersOrder {
+		loadedNames[loaded.brokers[id].Name] = true
+	}
+	for _, name := range order {
+		if !loadedNames[name] {
+			log.Warningf(ctx, "Broker %q of the broker order is not loaded", name)
+		}
+	}
+
+	// The local broker is always first.
+	slices.SortStableFunc(loaded.brokersOrder[1:], func(a, b string) int {
+		rankA, orderedA := rank[loaded.brokers[a].Name]
+		rankB, orderedB := rank[loaded.brokers[b].Name]
+		switch {
+		case orderedA && orderedB:
+			return cmp.Compare(rankA, rankB)
+		case orderedA:
+			return -1
+		case orderedB:
+			return 1
+		}
+		return 0
+	})
+}
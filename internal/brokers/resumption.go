@@ -0,0 +1,121 @@
+package brokers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+)
+
+var (
+	// ErrInvalidResumptionToken is returned when the resumption token is unknown or was already used.
+	ErrInvalidResumptionToken = errors.New("invalid resumption token")
+	// ErrResumptionTokenExpired is returned when the resumption token is past its lifetime.
+	ErrResumptionTokenExpired = errors.New("resumption token expired")
+	// ErrSessionEnded is returned when the session to resume has already ended.
+	ErrSessionEnded = errors.New("session already ended")
+)
+
+// defaultResumptionTokenTTL is how long a resumption token can be used, unless configured otherwise.
+const defaultResumptionTokenTTL = 5 * time.Minute
+
+// resumption is the session information a resumption token gives access to.
+type resumption struct {
+	sessionID string
+	key       KeyInfo
+	expiresAt time.Time
+}
+
+// newResumptionToken returns a new random resumption token for the session, valid for the configured lifetime.
+func (m *Manager) newResumptionToken(sessionID string, key KeyInfo) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("could not generate resumption token: %v", err)
+	}
+	token := hex.EncodeToString(b)
+
+	now := time.Now()
+
+	m.resumptionsMu.Lock()
+	defer m.resumptionsMu.Unlock()
+	// Tokens of sessions which are never resumed nor ended would otherwise be kept forever.
+	for t, r := range m.resumptions {
+		if now.After(r.expiresAt) {
+			delete(m.resumptions, t)
+		}
+	}
+	m.resumptions[token] = resumption{
+		sessionID: sessionID,
+		key:       key,
+		expiresAt: now.Add(m.resumptionTokenTTL),
+	}
+
+	return token, nil
+}
+
+// resumptionOfSession returns the resumption token of the session not used yet, if any.
+func (m *Manager) resumptionOfSession(sessionID string) (token string, r resumption, ok bool) {
+	m.resumptionsMu.Lock()
+	defer m.resumptionsMu.Unlock()
+
+	for token, r := range m.resumptions {
+		if r.sessionID == sessionID {
+			return token, r, true
+		}
+	}
+	return "", resumption{}, false
+}
+
+// ResumeSession returns the session ID and encryption key of the ongoing session the resumption token was issued for
+// by NewSession, so that a client can reconnect to it. A token can only be used once, before it expires. Expired tokens
+// are pruned when new ones are issued, after which they are rejected as invalid.
+// The session must still be ongoing, both in authd and on the broker, which is asked whether it still holds it.
+// Canceling ctx aborts the call to the broker.
+func (m *Manager) ResumeSession(ctx context.Context, token string) (sessionID string, key KeyInfo, err error) {
+	m.resumptionsMu.Lock()
+	r, ok := m.resumptions[token]
+	delete(m.resumptions, token)
+	m.resumptionsMu.Unlock()
+
+	if !ok {
+		return "", KeyInfo{}, ErrInvalidResumptionToken
+	}
+	// The token can't be used anymore after a restart either.
+	m.updatePersistedSession(ctx, r.sessionID)
+	if time.Now().After(r.expiresAt) {
+		return "", KeyInfo{}, ErrResumptionTokenExpired
+	}
+
+	broker, err := m.BrokerFromSessionID(r.sessionID)
+	if err != nil {
+		return "", KeyInfo{}, ErrSessionEnded
+	}
+	callCtx, cancel := m.withBrokerCallTimeout(ctx)
+	ongoing, err := broker.hasSession(callCtx, r.sessionID)
+	cancel()
+	if err != nil {
+		return "", KeyInfo{}, fmt.Errorf("could not check that the broker still holds the session: %w", err)
+	}
+	if !ongoing {
+		return "", KeyInfo{}, ErrSessionEnded
+	}
+
+	log.Debugf(ctx, "%s: Session resumed", r.sessionID)
+	return r.sessionID, r.key, nil
+}
+
+// dropResumptionTokens removes the resumption tokens of the session.
+func (m *Manager) dropResumptionTokens(sessionID string) {
+	m.resumptionsMu.Lock()
+	defer m.resumptionsMu.Unlock()
+
+	for token, r := range m.resumptions {
+		if r.sessionID == sessionID {
+			delete(m.resumptions, token)
+		}
+	}
+}
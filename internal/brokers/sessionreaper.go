@@ -0,0 +1,109 @@
+package brokers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+)
+
+// ErrSessionExpired is returned when looking up a session which was ended by the session reaper, as it outlived its
+// maximum lifetime.
+var ErrSessionExpired = errors.New("session expired")
+
+// DefaultSessionTTL is the maximum lifetime of sessions, unless configured otherwise.
+const DefaultSessionTTL = time.Hour
+
+// expiredSessionsRetention is how long the sessions ended by the session reaper are remembered as expired.
+const expiredSessionsRetention = time.Hour
+
+// WithDefaultSessionTTL sets the maximum lifetime of the sessions created without WithSessionTTL, after which they
+// are ended by the session reaper. It defaults to one hour, and 0 means that sessions never expire.
+func WithDefaultSessionTTL(d time.Duration) Option {
+	return func(o *options) {
+		o.sessionTTL = d
+	}
+}
+
+// WithSessionTTL sets the maximum lifetime of the session, after which it is ended by the session reaper. 0 means that
+// the session never expires.
+func WithSessionTTL(d time.Duration) SessionOption {
+	return func(o *sessionOptions) {
+		o.ttl = &d
+	}
+}
+
+// StartSessionReaper starts ending the sessions past their maximum lifetime every interval, so that the sessions of
+// clients which never ended them are not leaked. Looking up the ended sessions returns an error matching
+// ErrSessionExpired.
+// The reaper stops when ctx is done, and the returned channel is closed once it did.
+func (m *Manager) StartSessionReaper(ctx context.Context, interval time.Duration) (done <-chan struct{}) {
+	d := make(chan struct{})
+	go func() {
+		defer close(d)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			m.reapExpiredSessions(ctx)
+		}
+	}()
+	return d
+}
+
+// reapExpiredSessions ends the sessions past their maximum lifetime and forgets the ones which expired long ago.
+func (m *Manager) reapExpiredSessions(ctx context.Context) {
+	now := time.Now()
+	expired := make(map[string]*Broker)
+
+	m.transactionsToBrokerMu.Lock()
+	for sessionID, s := range m.transactionsToBroker {
+		if !s.expired(now) {
+			continue
+		}
+		// We forget the session before ending it, so that the client can't use it meanwhile.
+		expired[sessionID] = s.broker
+		delete(m.transactionsToBroker, sessionID)
+		delete(m.sessionContexts, sessionID)
+		m.expiredSessions[sessionID] = now
+	}
+	for sessionID, t := range m.expiredSessions {
+		if now.Sub(t) > expiredSessionsRetention {
+			delete(m.expiredSessions, sessionID)
+		}
+	}
+	m.transactionsToBrokerMu.Unlock()
+
+	var sessionIDs []string
+	for sessionID, b := range expired {
+		log.Infof(ctx, "%s: Session expired, ending it", sessionID)
+		callCtx, cancel := m.withBrokerCallTimeout(ctx)
+		err := b.endSession(callCtx, sessionID)
+		cancel()
+		if err != nil {
+			log.Warningf(ctx, "Could not end expired session %q on broker %q: %v", sessionID, b.Name, err)
+			b.forgetSession(sessionID)
+		}
+		m.dropResumptionTokens(sessionID)
+		sessionIDs = append(sessionIDs, sessionID)
+	}
+	m.unpersistSessions(ctx, sessionIDs...)
+}
+
+// sessionNotFoundError returns the error of a lookup of a session which is not ongoing, which matches
+// ErrSessionExpired if the session reaper ended it.
+// It must be called with transactionsToBrokerMu held.
+func (m *Manager) sessionNotFoundError(sessionID string) error {
+	if _, ok := m.expiredSessions[sessionID]; ok {
+		return fmt.Errorf("%w: session %q", ErrSessionExpired, sessionID)
+	}
+	return fmt.Errorf("no broker found for session %q", sessionID)
+}
@@ -0,0 +1,86 @@
+package brokers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+)
+
+// session is an ongoing session, with the broker it was created on.
+type session struct {
+	broker    *Broker
+	username  string
+	createdAt time.Time
+	// expiresAt is when the session reaper ends the session. It is zero if the session never expires.
+	expiresAt time.Time
+}
+
+// expired returns whether the session is past its deadline at t.
+func (s session) expired(t time.Time) bool {
+	return !s.expiresAt.IsZero() && t.After(s.expiresAt)
+}
+
+// SessionInfo describes an ongoing session.
+type SessionInfo struct {
+	ID        string
+	BrokerID  string
+	Username  string
+	StartTime time.Time
+}
+
+// ActiveSessions returns the ongoing sessions, ordered by start time.
+func (m *Manager) ActiveSessions() []SessionInfo {
+	return m.activeSessions(func(session) bool { return true })
+}
+
+// ActiveSessionsForUser returns the ongoing sessions of the user matching username, ordered by start time.
+func (m *Manager) ActiveSessionsForUser(username string) []SessionInfo {
+	return m.activeSessions(func(s session) bool { return strings.EqualFold(s.username, username) })
+}
+
+// activeSessions returns the ongoing sessions matching filter, ordered by start time.
+func (m *Manager) activeSessions(filter func(session) bool) []SessionInfo {
+	m.transactionsToBrokerMu.RLock()
+	defer m.transactionsToBrokerMu.RUnlock()
+
+	var sessions []SessionInfo
+	for id, s := range m.transactionsToBroker {
+		if !filter(s) {
+			continue
+		}
+		sessions = append(sessions, SessionInfo{
+			ID:        id,
+			BrokerID:  s.broker.ID,
+			Username:  s.username,
+			StartTime: s.createdAt,
+		})
+	}
+
+	slices.SortFunc(sessions, func(a, b SessionInfo) int {
+		if c := a.StartTime.Compare(b.StartTime); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return sessions
+}
+
+// RefreshSessionUsername updates the username of the session to the one canonicalized by its broker when it granted
+// the authentication, so that the session is listed, and restored after a restart, with it.
+func (m *Manager) RefreshSessionUsername(ctx context.Context, sessionID string) {
+	m.transactionsToBrokerMu.Lock()
+	defer m.transactionsToBrokerMu.Unlock()
+
+	s, ok := m.transactionsToBroker[sessionID]
+	if !ok {
+		return
+	}
+	username := s.broker.SessionUsername(sessionID)
+	if username == "" || username == s.username {
+		return
+	}
+	s.username = username
+	m.transactionsToBroker[sessionID] = s
+	m.persistSession(ctx, sessionID, s.broker.parseSessionID(sessionID), s)
+}
@@ -0,0 +1,219 @@
+package brokers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// sessionsBucketName is the bucket of the session store holding the ongoing sessions, keyed by session ID.
+const sessionsBucketName = "sessions"
+
+// storedSession is the struct stored in json format in the session store for each ongoing session.
+type storedSession struct {
+	ID              string
+	BrokerID        string
+	BrokerSessionID string
+	Username        string
+	StartTime       time.Time
+	ExpiresAt       time.Time
+	// Resumption is the resumption token of the session not used yet, if any, so that the session can be resumed after
+	// a restart.
+	Resumption *storedResumption `json:",omitempty"`
+}
+
+// storedResumption is a resumption token stored with its session.
+type storedResumption struct {
+	Token     string
+	Key       KeyInfo
+	ExpiresAt time.Time
+}
+
+// sessionStore persists the ongoing sessions, so that they survive a restart of the daemon.
+// A nil store persists nothing.
+type sessionStore struct {
+	db *bbolt.DB
+}
+
+// WithSessionStore persists the ongoing sessions in the database file at path, which is created if needed, so that
+// they can be resumed by a new manager after a restart of the daemon. By default, sessions are only kept in memory.
+func WithSessionStore(path string) Option {
+	return func(o *options) {
+		o.sessionStorePath = path
+	}
+}
+
+// openSessionStore opens the session store at path, creating it if needed.
+func openSessionStore(path string) (s *sessionStore, err error) {
+	defer decorate.OnError(&err, "can't open session store %q", path)
+
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists([]byte(sessionsBucketName))
+		return err
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return &sessionStore{db: db}, nil
+}
+
+// put stores the session, replacing any session with the same ID.
+func (s *sessionStore) put(session storedSession) error {
+	if s == nil {
+		return nil
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("can't marshal session %q: %v", session.ID, err)
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(sessionsBucketName)).Put([]byte(session.ID), data)
+	})
+}
+
+// delete removes the sessions matching ids. Unknown sessions are ignored.
+func (s *sessionStore) delete(ids ...string) error {
+	if s == nil || len(ids) == 0 {
+		return nil
+	}
+
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket([]byte(sessionsBucketName))
+		for _, id := range ids {
+			if err := bucket.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// all returns all the stored sessions.
+func (s *sessionStore) all() (sessions []storedSession, err error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	err = s.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket([]byte(sessionsBucketName)).ForEach(func(key, value []byte) error {
+			var session storedSession
+			if err := json.Unmarshal(value, &session); err != nil {
+				return fmt.Errorf("can't unmarshal session %q: %v", key, err)
+			}
+			sessions = append(sessions, session)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// close closes the session store.
+func (s *sessionStore) close() error {
+	if s == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// closeSessionStore closes the session store, if any.
+func (m *Manager) closeSessionStore() {
+	if err := m.sessionStore.close(); err != nil {
+		log.Warningf(context.Background(), "Could not close the session store: %v", err)
+	}
+}
+
+// persistSession stores the session, with its resumption token not used yet, if any. Failures are only logged, as the
+// session can still be used until the daemon stops.
+func (m *Manager) persistSession(ctx context.Context, sessionID, brokerSessionID string, s session) {
+	stored := storedSession{
+		ID:              sessionID,
+		BrokerID:        s.broker.ID,
+		BrokerSessionID: brokerSessionID,
+		Username:        s.username,
+		StartTime:       s.createdAt,
+		ExpiresAt:       s.expiresAt,
+	}
+	if token, r, ok := m.resumptionOfSession(sessionID); ok {
+		stored.Resumption = &storedResumption{Token: token, Key: r.key, ExpiresAt: r.expiresAt}
+	}
+	if err := m.sessionStore.put(stored); err != nil {
+		log.Warningf(ctx, "%s: Could not persist session, it won't survive a restart: %v", sessionID, err)
+	}
+}
+
+// updatePersistedSession stores the session again, after its username was canonicalized or its resumption token was
+// used. Sessions which ended meanwhile are not stored again.
+func (m *Manager) updatePersistedSession(ctx context.Context, sessionID string) {
+	if m.sessionStore == nil {
+		return
+	}
+
+	m.transactionsToBrokerMu.Lock()
+	defer m.transactionsToBrokerMu.Unlock()
+	s, ok := m.transactionsToBroker[sessionID]
+	if !ok {
+		return
+	}
+	m.persistSession(ctx, sessionID, s.broker.parseSessionID(sessionID), s)
+}
+
+// unpersistSessions removes the sessions matching ids from the session store. Failures are only logged, as the
+// sessions are dropped when restored if their broker is gone, or ended by the session reaper once expired.
+func (m *Manager) unpersistSessions(ctx context.Context, ids ...string) {
+	if err := m.sessionStore.delete(ids...); err != nil {
+		log.Warningf(ctx, "Could not remove sessions %v from the session store: %v", ids, err)
+	}
+}
+
+// restoreSessions binds the sessions of the session store to their broker again, with their resumption token. The
+// sessions of brokers which are not loaded anymore are dropped.
+func (m *Manager) restoreSessions(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, "can't restore sessions")
+
+	stored, err := m.sessionStore.all()
+	if err != nil {
+		return err
+	}
+
+	var dropped []string
+	for _, s := range stored {
+		b, exists := m.brokers[s.BrokerID]
+		if !exists {
+			log.Warningf(ctx, "%s: Dropping session of %q, its broker %q is not loaded anymore", s.ID, s.Username, s.BrokerID)
+			dropped = append(dropped, s.ID)
+			continue
+		}
+
+		log.Debugf(ctx, "%s: Restored session for %q (broker session %q)", s.ID, s.Username, s.BrokerSessionID)
+		b.restoreSession(s.ID, s.BrokerSessionID, s.Username)
+		// Expired sessions are restored too, so that the session reaper ends them on their broker.
+		m.transactionsToBroker[s.ID] = session{
+			broker:    b,
+			username:  s.Username,
+			createdAt: s.StartTime,
+			expiresAt: s.ExpiresAt,
+		}
+		// Expired tokens are not restored, as they can't be used anymore.
+		if r := s.Resumption; r != nil && time.Now().Before(r.ExpiresAt) {
+			m.resumptions[r.Token] = resumption{sessionID: s.ID, key: r.Key, expiresAt: r.ExpiresAt}
+		}
+	}
+
+	return m.sessionStore.delete(dropped...)
+}
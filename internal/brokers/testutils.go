@@ -0,0 +1,48 @@
+package brokers
+
+// All those functions and methods are only for tests.
+// They are guarded by testing assertions.
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/ubuntu/authd/internal/testsdetection"
+)
+
+// RegisterTestBroker adds a broker with the given ID to the manager, after the existing ones in preference order.
+// Its calls go to the object at objPath exported on conn, so that tests can run sessions against a stub broker
+// without configuration file. The connection is not reestablished if it's dropped.
+// It must be called before the manager is used, and panics outside of tests.
+func (m *Manager) RegisterTestBroker(id string, conn *dbus.Conn, objPath dbus.ObjectPath) {
+	testsdetection.MustBeTesting()
+
+	m.brokersMu.Lock()
+	defer m.brokersMu.Unlock()
+
+	if _, exists := m.brokers[id]; exists {
+		panic(fmt.Sprintf("broker %q is already registered", id))
+	}
+
+	b := Broker{
+		ID:          id,
+		Name:        id,
+		DisplayName: id,
+		brokerer: dbusBroker{
+			name:               id,
+			maxSessionIDLength: defaultMaxSessionIDLength,
+			bus:                &busConn{conn: conn},
+			// The first name of a connection is its unique name, to which the objects it exports are reachable.
+			dbusName:   conn.Names()[0],
+			objectPath: objPath,
+			calls:      m.calls,
+
+			sessionContext: &sessionContextSupport{},
+		},
+		maxSessionIDLength: defaultMaxSessionIDLength,
+	}.withInternalState()
+	b.queries = m.queries
+
+	m.brokers[id] = &b
+	m.brokersOrder = append(m.brokersOrder, id)
+}
@@ -0,0 +1,96 @@
+package brokers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+)
+
+// slowNewSessionThreshold is the duration above which a session creation is logged as slow.
+const slowNewSessionThreshold = 2 * time.Second
+
+// Tracer starts spans around the steps of broker calls. It can wrap an OpenTelemetry tracer.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a traced step started by a Tracer.
+type Span interface {
+	End()
+}
+
+// WithTracer sets the tracer receiving the spans of the steps of session creations.
+func WithTracer(t Tracer) Option {
+	return func(o *options) {
+		o.tracer = t
+	}
+}
+
+// noopTracer is the Tracer used when none is set, which doesn't trace anything.
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End() {}
+
+// stepTimer records the duration of each step of an operation, and traces them as children of the operation span.
+type stepTimer struct {
+	tracer Tracer
+	span   Span
+	start  time.Time
+	steps  []timedStep
+}
+
+type timedStep struct {
+	name     string
+	duration time.Duration
+}
+
+// newStepTimer starts timing and tracing the operation name. The returned context must be passed to step.
+func newStepTimer(ctx context.Context, tracer Tracer, name string) (context.Context, *stepTimer) {
+	ctx, span := tracer.Start(ctx, name)
+	return ctx, &stepTimer{tracer: tracer, span: span, start: time.Now()}
+}
+
+// step starts timing and tracing the step name. The returned function ends it.
+func (t *stepTimer) step(ctx context.Context, name string) (context.Context, func()) {
+	ctx, span := t.tracer.Start(ctx, name)
+	start := time.Now()
+	return ctx, func() {
+		span.End()
+		t.steps = append(t.steps, timedStep{name: name, duration: time.Since(start)})
+	}
+}
+
+// end ends the operation and returns its total duration.
+func (t *stepTimer) end() time.Duration {
+	t.span.End()
+	return time.Since(t.start)
+}
+
+// String returns the duration of each step, in order.
+func (t *stepTimer) String() string {
+	var s []string
+	for _, step := range t.steps {
+		s = append(s, fmt.Sprintf("%s: %v", step.name, step.duration))
+	}
+	return strings.Join(s, ", ")
+}
+
+// logNewSessionTiming logs where the time went when creating the session, as a warning if it was slow.
+func logNewSessionTiming(ctx context.Context, t *stepTimer, brokerID, username string) {
+	total := t.end()
+	msg := fmt.Sprintf("New session for %q with broker %q took %v (%s)", username, brokerID, total, t)
+	if total >= slowNewSessionThreshold {
+		log.Warning(ctx, msg)
+		return
+	}
+	log.Debug(ctx, msg)
+}
@@ -44,7 +44,8 @@ func useExampleBrokers() (string, func(), error) {
 	}, nil
 }
 
-// Stop calls the function responsible for cleaning up the examplebrokers.
+// Stop closes the session store, if any, and calls the function responsible for cleaning up the examplebrokers.
 func (m *Manager) Stop() {
+	m.closeSessionStore()
 	m.cleanup()
 }
@@ -7,5 +7,7 @@ func useExampleBrokers() (string, func(), error) {
 	return "", nil, nil
 }
 
-// Stop is a no-op in production code.
-func (m *Manager) Stop() {}
+// Stop closes the session store, if any.
+func (m *Manager) Stop() {
+	m.closeSessionStore()
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net"
 	"os"
+	"time"
 
 	"github.com/coreos/go-systemd/activation"
 	"github.com/coreos/go-systemd/daemon"
@@ -20,6 +21,8 @@ type Daemon struct {
 	lis        net.Listener
 
 	systemdSdNotifier systemdSdNotifier
+
+	maintenance *maintenance
 }
 
 type options struct {
@@ -28,6 +31,8 @@ type options struct {
 	// private member that we export for tests.
 	systemdActivationListener func() ([]net.Listener, error)
 	systemdSdNotifier         func(unsetEnvironment bool, state string) (bool, error)
+	maintenanceTick           time.Duration
+	now                       func() time.Time
 }
 
 type systemdSdNotifier func(unsetEnvironment bool, state string) (bool, error)
@@ -58,6 +63,8 @@ func New(ctx context.Context, registerGRPCService GRPCServiceRegisterer, args ..
 
 		systemdActivationListener: activation.Listeners,
 		systemdSdNotifier:         daemon.SdNotify,
+		maintenanceTick:           defaultMaintenanceTick,
+		now:                       time.Now,
 	}
 	// Apply given args.
 	for _, f := range args {
@@ -106,6 +113,11 @@ func New(ctx context.Context, registerGRPCService GRPCServiceRegisterer, args ..
 		lis:        lis,
 
 		systemdSdNotifier: opts.systemdSdNotifier,
+
+		maintenance: &maintenance{
+			tick: opts.maintenanceTick,
+			now:  opts.now,
+		},
 	}, nil
 }
 
@@ -133,6 +145,7 @@ func (d *Daemon) Serve(ctx context.Context) (err error) {
 // It can drops any existing connexion is force is true.
 func (d Daemon) Quit(ctx context.Context, force bool) {
 	log.Info(ctx, "Stopping daemon requested.")
+	d.maintenance.stop()
 	if force {
 		d.grpcServer.Stop()
 		return
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -286,6 +287,109 @@ func TestQuit(t *testing.T) {
 	}
 }
 
+func TestScheduleMaintenance(t *testing.T) {
+	t.Parallel()
+
+	midnight := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+
+	testCases := map[string]struct {
+		window   daemon.MaintenanceWindow
+		interval time.Duration
+		disabled bool
+		noRun    bool
+		at       time.Duration
+
+		wantRuns int
+		wantErr  bool
+	}{
+		"Runs tasks at any time without window":      {at: 12 * time.Hour, wantRuns: 1},
+		"Runs tasks within window":                   {window: daemon.MaintenanceWindow{Start: time.Hour, End: 3 * time.Hour}, at: 2 * time.Hour, wantRuns: 1},
+		"Runs tasks within window wrapping midnight": {window: daemon.MaintenanceWindow{Start: 23 * time.Hour, End: time.Hour}, at: 30 * time.Minute, wantRuns: 1},
+		"Runs tasks again once interval elapsed":     {interval: time.Nanosecond, at: 12 * time.Hour, wantRuns: 2},
+
+		"Does not run tasks outside window":                   {window: daemon.MaintenanceWindow{Start: time.Hour, End: 3 * time.Hour}, at: 12 * time.Hour},
+		"Does not run tasks before interval elapsed":          {interval: time.Hour, at: 12 * time.Hour, wantRuns: 1},
+		"Does not run disabled tasks":                         {disabled: true, at: 12 * time.Hour},
+		"Does not require anything to run for disabled tasks": {disabled: true, noRun: true, at: 12 * time.Hour},
+
+		"Error on invalid window":           {window: daemon.MaintenanceWindow{Start: 25 * time.Hour}, wantErr: true},
+		"Error on invalid interval":         {interval: -1, wantErr: true},
+		"Error on enabled task without run": {noRun: true, wantErr: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			registerGRPC := func(context.Context) *grpc.Server {
+				return grpc.NewServer(grpc.UnaryInterceptor(errmessages.RedactErrorInterceptor))
+			}
+
+			// The clock moves forward by a second each time it's read.
+			var ticks atomic.Int64
+			now := func() time.Time {
+				return midnight.Add(tc.at).Add(time.Duration(ticks.Add(1)) * time.Second)
+			}
+
+			d, err := daemon.New(context.Background(), registerGRPC,
+				daemon.WithSocketPath(filepath.Join(t.TempDir(), "manual.socket")),
+				daemon.WithMaintenanceTick(time.Millisecond),
+				daemon.WithNow(now))
+			require.NoError(t, err, "Setup: New() should not return an error")
+
+			if tc.interval == 0 {
+				tc.interval = time.Hour
+			}
+			var runs, running, concurrentRuns atomic.Int32
+			run := func(context.Context) error {
+				if running.Add(1) > 1 {
+					concurrentRuns.Add(1)
+				}
+				defer running.Add(-1)
+				runs.Add(1)
+				time.Sleep(time.Millisecond)
+				return nil
+			}
+			if tc.noRun {
+				run = nil
+			}
+
+			var tasks []daemon.MaintenanceTask
+			for _, name := range []string{"first", "second"} {
+				tasks = append(tasks, daemon.MaintenanceTask{Name: name, Interval: tc.interval, Enabled: !tc.disabled, Run: run})
+			}
+			// This task never runs in parallel with the others, whatever their interval.
+			tasks = append(tasks, daemon.MaintenanceTask{Name: "failing", Interval: time.Hour, Enabled: true,
+				Run: func(context.Context) error { return errors.New("failure") }})
+
+			err = d.ScheduleMaintenance(daemon.MaintenanceSpec{Window: tc.window, Tasks: tasks})
+			if tc.wantErr {
+				require.Error(t, err, "ScheduleMaintenance should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "ScheduleMaintenance should not return an error, but did")
+
+			err = d.ScheduleMaintenance(daemon.MaintenanceSpec{Window: tc.window, Tasks: tasks})
+			require.Error(t, err, "ScheduleMaintenance should return an error when maintenance is already scheduled")
+
+			// Let the scheduler check for tasks to run a few times.
+			require.Eventually(t, func() bool { return ticks.Load() > 20 }, time.Second, time.Millisecond,
+				"Scheduler should keep checking for tasks to run")
+			d.Quit(context.Background(), false)
+
+			got := int(runs.Load())
+			if tc.wantRuns < 2 {
+				require.Equal(t, 2*tc.wantRuns, got, "Each task should run the expected number of times")
+			} else {
+				require.GreaterOrEqual(t, got, 2*tc.wantRuns, "Each task should run at least the expected number of times")
+			}
+			require.Zero(t, concurrentRuns.Load(), "Tasks should never run concurrently")
+
+			err = d.ScheduleMaintenance(daemon.MaintenanceSpec{Tasks: tasks})
+			require.Error(t, err, "ScheduleMaintenance should return an error once the daemon has quit")
+		})
+	}
+}
+
 func createClientConnection(t *testing.T, socketPath string) (success bool, disconnect func()) {
 	t.Helper()
 
@@ -1,6 +1,9 @@
 package daemon
 
-import "net"
+import (
+	"net"
+	"time"
+)
 
 func WithSystemdActivationListener(f func() ([]net.Listener, error)) func(o *options) {
 	return func(o *options) {
@@ -17,3 +20,15 @@ func WithSystemdSdNotifier(f func(unsetEnvironment bool, state string) (bool, er
 func (d Daemon) SelectedSocketAddr() string {
 	return d.lis.Addr().String()
 }
+
+func WithMaintenanceTick(tick time.Duration) func(o *options) {
+	return func(o *options) {
+		o.maintenanceTick = tick
+	}
+}
+
+func WithNow(now func() time.Time) func(o *options) {
+	return func(o *options) {
+		o.now = now
+	}
+}
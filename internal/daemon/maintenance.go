@@ -0,0 +1,198 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/decorate"
+)
+
+// MaintenanceTask is a background task run periodically by the maintenance scheduler.
+type MaintenanceTask struct {
+	Name string
+	// Interval is the minimum time between two runs of the task.
+	Interval time.Duration
+	// Enabled must be set for the task to run.
+	Enabled bool
+	Run     func(context.Context) error
+}
+
+// MaintenanceWindow is the daily off-peak time range, in local time, during which maintenance tasks can run.
+// Start and End are offsets from midnight. The window wraps around midnight if End is before Start.
+// The zero value allows the tasks to run at any time.
+type MaintenanceWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// MaintenanceSpec is the configuration of the maintenance scheduler.
+type MaintenanceSpec struct {
+	Window MaintenanceWindow
+	Tasks  []MaintenanceTask
+}
+
+// contains returns whether t is within the window.
+func (w MaintenanceWindow) contains(t time.Time) bool {
+	if w.Start == w.End {
+		return true
+	}
+
+	y, m, d := t.Date()
+	sinceMidnight := t.Sub(time.Date(y, m, d, 0, 0, 0, 0, t.Location()))
+	if w.Start < w.End {
+		return sinceMidnight >= w.Start && sinceMidnight < w.End
+	}
+	return sinceMidnight >= w.Start || sinceMidnight < w.End
+}
+
+// validate returns an error if the spec can't be scheduled.
+func (s MaintenanceSpec) validate() error {
+	for _, o := range []time.Duration{s.Window.Start, s.Window.End} {
+		if o < 0 || o >= 24*time.Hour {
+			return fmt.Errorf("invalid maintenance window offset: %v", o)
+		}
+	}
+
+	names := make(map[string]struct{})
+	for _, task := range s.Tasks {
+		if task.Name == "" {
+			return errors.New("maintenance task without a name")
+		}
+		if _, ok := names[task.Name]; ok {
+			return fmt.Errorf("maintenance task %q is registered more than once", task.Name)
+		}
+		names[task.Name] = struct{}{}
+
+		if !task.Enabled {
+			continue
+		}
+		if task.Run == nil {
+			return fmt.Errorf("maintenance task %q has nothing to run", task.Name)
+		}
+		if task.Interval <= 0 {
+			return fmt.Errorf("invalid interval for maintenance task %q: %v", task.Name, task.Interval)
+		}
+	}
+	return nil
+}
+
+// defaultMaintenanceTick is how often the scheduler checks for maintenance tasks to run.
+const defaultMaintenanceTick = time.Minute
+
+// maintenance holds the maintenance scheduler of the daemon, once started.
+type maintenance struct {
+	scheduler *maintenanceScheduler
+	stopped   bool
+	mu        sync.Mutex
+
+	tick time.Duration
+	now  func() time.Time
+}
+
+// maintenanceScheduler runs the maintenance tasks one at a time, so that they don't contend with each other.
+type maintenanceScheduler struct {
+	spec    MaintenanceSpec
+	lastRun map[string]time.Time
+
+	now func() time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// ScheduleMaintenance starts running the enabled maintenance tasks of spec in the background, each one at most once
+// per interval, and only during the maintenance window. Tasks are run one after the other, never concurrently.
+// The scheduler is stopped when the daemon quits.
+func (d *Daemon) ScheduleMaintenance(spec MaintenanceSpec) (err error) {
+	defer decorate.OnError(&err /*i18n.G(*/, "can't schedule maintenance") //)
+
+	if err := spec.validate(); err != nil {
+		return err
+	}
+
+	d.maintenance.mu.Lock()
+	defer d.maintenance.mu.Unlock()
+	if d.maintenance.stopped {
+		return errors.New("daemon has quit")
+	}
+	if d.maintenance.scheduler != nil {
+		return errors.New("maintenance is already scheduled")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &maintenanceScheduler{
+		spec:    spec,
+		lastRun: make(map[string]time.Time),
+		now:     d.maintenance.now,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	d.maintenance.scheduler = s
+
+	go s.run(ctx, d.maintenance.tick)
+	return nil
+}
+
+// run checks every tick for tasks to run, until ctx is done.
+func (s *maintenanceScheduler) run(ctx context.Context, tick time.Duration) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+
+	for {
+		s.runDueTasks(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDueTasks runs, in order, the enabled tasks whose interval has elapsed since their last run, as long as we are
+// within the maintenance window.
+func (s *maintenanceScheduler) runDueTasks(ctx context.Context) {
+	for _, task := range s.spec.Tasks {
+		if !task.Enabled {
+			continue
+		}
+		// A task can take long enough for the window to end, so we check it before each one.
+		now := s.now()
+		if ctx.Err() != nil || !s.spec.Window.contains(now) {
+			return
+		}
+		if last, ok := s.lastRun[task.Name]; ok && now.Sub(last) < task.Interval {
+			continue
+		}
+
+		log.Debugf(ctx, "Running maintenance task %q", task.Name)
+		if err := task.Run(ctx); err != nil {
+			log.Warningf(ctx, "Maintenance task %q failed: %v", task.Name, err)
+		}
+		s.lastRun[task.Name] = now
+	}
+}
+
+// stop stops the scheduler, waiting for any running task to return.
+func (s *maintenanceScheduler) stop() {
+	s.cancel()
+	<-s.done
+}
+
+// stop stops the maintenance scheduler, if any, and prevents scheduling a new one.
+func (m *maintenance) stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stopped = true
+	if m.scheduler == nil {
+		return
+	}
+	m.scheduler.stop()
+}
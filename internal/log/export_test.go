@@ -0,0 +1,28 @@
+package log
+
+import "time"
+
+// ResetOutput forgets the custom output set with SetOutput, so that logs are written to stderr.
+func ResetOutput() {
+	hasCustomOutput.Store(nil)
+}
+
+// SetRateLimitInterval sets the interval over which repeated warnings are collapsed, and returns a function restoring
+// the previous one. The intervals in progress are stopped, both when setting and restoring it, so that tests don't
+// depend on the warnings logged before.
+func SetRateLimitInterval(d time.Duration) (restore func()) {
+	warningsLimiter.stop()
+
+	warningsLimiter.mu.Lock()
+	defer warningsLimiter.mu.Unlock()
+
+	previous := warningsLimiter.interval
+	warningsLimiter.interval = d
+	return func() {
+		warningsLimiter.stop()
+
+		warningsLimiter.mu.Lock()
+		defer warningsLimiter.mu.Unlock()
+		warningsLimiter.interval = previous
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"log/slog"
 	"maps"
+	"os"
+	"slices"
 	"sync"
 	"sync/atomic"
 )
@@ -24,6 +26,34 @@ var logLevel slog.Level
 
 var hasCustomOutput atomic.Pointer[io.Writer]
 
+// Format is the output format of the logs.
+type Format int
+
+const (
+	// TextFormat renders each log entry as a human readable line. This is the default.
+	TextFormat Format = iota
+	// JSONFormat renders each log entry as a JSON object, with its level, message, time and attributes.
+	JSONFormat
+)
+
+// ParseFormat returns the format named name, which is either "text" or "json".
+func ParseFormat(name string) (Format, error) {
+	switch name {
+	case "text":
+		return TextFormat, nil
+	case "json":
+		return JSONFormat, nil
+	default:
+		return TextFormat, fmt.Errorf("unknown log format %q", name)
+	}
+}
+
+var logFormatMu = sync.RWMutex{}
+var logFormat = TextFormat
+
+// hasCustomFormat is set once the format was set, as the default slog logger is then replaced by ours.
+var hasCustomFormat atomic.Bool
+
 const (
 	// ErrorLevel level. Logs. Used for errors that should definitely be noted.
 	// Commonly used for hooks to send errors to an error tracking service.
@@ -38,10 +68,28 @@ const (
 
 func logFuncAdapter(slogFunc func(ctx context.Context, msg string, args ...interface{})) Handler {
 	return func(ctx context.Context, _ Level, format string, args ...interface{}) {
-		slogFunc(ctx, fmt.Sprintf(format, args...))
+		slogFunc(ctx, fmt.Sprintf(format, args...), attrs(ctx)...)
 	}
 }
 
+type attrsKey struct{}
+
+// WithAttrs returns a copy of ctx whose log entries carry the key-value pairs of args, as passed to slog, in addition
+// to the ones already attached to ctx. They are rendered as fields of the entries, like the session or the broker the
+// entries are about.
+func WithAttrs(ctx context.Context, args ...interface{}) context.Context {
+	return context.WithValue(ctx, attrsKey{}, append(slices.Clip(attrs(ctx)), args...))
+}
+
+// attrs returns the key-value pairs attached to ctx with WithAttrs.
+func attrs(ctx context.Context) []interface{} {
+	if ctx == nil {
+		return nil
+	}
+	a, _ := ctx.Value(attrsKey{}).([]interface{})
+	return a
+}
+
 var allLevels = []slog.Level{
 	slog.LevelDebug,
 	slog.LevelInfo,
@@ -81,6 +129,8 @@ func SetLevel(level Level) (oldLevel Level) {
 		logLevelMu.Unlock()
 		if outPtr := hasCustomOutput.Load(); outPtr != nil {
 			SetOutput(*outPtr)
+		} else if hasCustomFormat.Load() {
+			setDefaultLogger(os.Stderr)
 		}
 	}()
 	logLevel = level
@@ -90,9 +140,41 @@ func SetLevel(level Level) (oldLevel Level) {
 // SetOutput sets the log output.
 func SetOutput(out io.Writer) {
 	hasCustomOutput.Store(&out)
-	slog.SetDefault(slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{
-		Level: GetLevel(),
-	})))
+	setDefaultLogger(out)
+}
+
+// GetFormat gets the standard logger output format.
+func GetFormat() Format {
+	logFormatMu.RLock()
+	defer logFormatMu.RUnlock()
+	return logFormat
+}
+
+// SetFormat sets the standard logger output format. Without any custom output, logs are written to stderr.
+func SetFormat(format Format) {
+	logFormatMu.Lock()
+	logFormat = format
+	logFormatMu.Unlock()
+	hasCustomFormat.Store(true)
+
+	// The initial default logger can't be restored: it writes through the standard log package, which
+	// slog.SetDefault redirected to our logger.
+	out := io.Writer(os.Stderr)
+	if outPtr := hasCustomOutput.Load(); outPtr != nil {
+		out = *outPtr
+	}
+	setDefaultLogger(out)
+}
+
+// setDefaultLogger sets the default slog logger to write to out in the current format and level.
+func setDefaultLogger(out io.Writer) {
+	opts := &slog.HandlerOptions{Level: GetLevel()}
+
+	var h slog.Handler = slog.NewTextHandler(out, opts)
+	if GetFormat() == JSONFormat {
+		h = slog.NewJSONHandler(out, opts)
+	}
+	slog.SetDefault(slog.New(h))
 }
 
 // SetLevelHandler allows to define the default handler function for a given level.
@@ -1,9 +1,17 @@
 package log_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/authd/internal/log"
@@ -128,6 +136,48 @@ func TestSetLevelHandler(t *testing.T) {
 	log.SetLevelHandler(log.Level(99999999), nil)
 }
 
+func TestRateLimitedWarningf(t *testing.T) {
+	defaultLevel := log.GetLevel()
+	t.Cleanup(func() {
+		log.SetLevel(defaultLevel)
+		log.SetLevelHandler(log.WarnLevel, nil)
+	})
+	t.Cleanup(log.SetRateLimitInterval(50 * time.Millisecond))
+
+	var logged []string
+	var loggedMu sync.Mutex
+	log.SetLevel(log.WarnLevel)
+	log.SetLevelHandler(log.WarnLevel, func(_ context.Context, _ log.Level, format string, args ...interface{}) {
+		loggedMu.Lock()
+		defer loggedMu.Unlock()
+		logged = append(logged, fmt.Sprintf(format, args...))
+	})
+	getLogged := func() []string {
+		loggedMu.Lock()
+		defer loggedMu.Unlock()
+		return slices.Clone(logged)
+	}
+
+	for i := range 5 {
+		log.RateLimitedWarningf(context.Background(), "Broker %q is down for session %d", "broker", i)
+	}
+	log.RateLimitedWarningf(context.Background(), "Another warning")
+	require.Equal(t, []string{`Broker "broker" is down for session 0`, "Another warning"}, getLogged(),
+		"Only the first of the warnings with the same format should be logged")
+
+	want := []string{
+		`Broker "broker" is down for session 0`,
+		"Another warning",
+		`4 more occurrences of "Broker %q is down for session %d" in the last 50ms`,
+	}
+	require.Eventually(t, func() bool { return slices.Equal(want, getLogged()) }, time.Second, time.Millisecond,
+		"Suppressed warnings should be summarized at the end of the interval, got %v", getLogged())
+
+	log.RateLimitedWarningf(context.Background(), "Broker %q is down for session %d", "broker", 5)
+	require.Equal(t, `Broker "broker" is down for session 5`, getLogged()[len(want)],
+		"Warnings should be logged again after the interval")
+}
+
 func TestSetHandler(t *testing.T) {
 	defaultLevel := log.GetLevel()
 	t.Cleanup(func() {
@@ -215,3 +265,127 @@ func TestSetHandler(t *testing.T) {
 		require.False(t, handlerCalled, "Handler should not have been called")
 	}
 }
+
+func TestSetFormat(t *testing.T) {
+	// This can't be parallel.
+	defaultLevel := log.GetLevel()
+	t.Cleanup(func() {
+		log.SetFormat(log.TextFormat)
+		log.SetOutput(os.Stderr)
+		log.SetLevel(defaultLevel)
+	})
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	log.SetLevel(log.InfoLevel)
+	require.Equal(t, log.TextFormat, log.GetFormat(), "Default format should be text")
+
+	log.SetFormat(log.JSONFormat)
+	require.Equal(t, log.JSONFormat, log.GetFormat(), "Format should be JSON once set")
+	log.Infof(context.Background(), "Some %s message", "JSON")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry), "Log entry should be a JSON object")
+	require.Equal(t, "INFO", entry["level"], "Log entry should have the expected level")
+	require.Equal(t, "Some JSON message", entry["msg"], "Log entry should have the expected message")
+	require.Contains(t, entry, "time", "Log entry should have a timestamp")
+
+	// Changing the level keeps the format.
+	out.Reset()
+	log.SetLevel(log.WarnLevel)
+	log.Info(context.Background(), "Filtered out message")
+	require.Empty(t, out.String(), "Log entries below the level should be filtered out")
+	log.Warning(context.Background(), "Some warning")
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry), "Log entry should still be a JSON object")
+	require.Equal(t, "WARN", entry["level"], "Log entry should have the expected level")
+
+	out.Reset()
+	log.SetFormat(log.TextFormat)
+	log.Warning(context.Background(), "Some text message")
+	require.Contains(t, out.String(), `level=WARN msg="Some text message"`, "Log entry should be text again")
+}
+
+func TestWithAttrs(t *testing.T) {
+	// This can't be parallel.
+	defaultLevel := log.GetLevel()
+	t.Cleanup(func() {
+		log.SetFormat(log.TextFormat)
+		log.SetOutput(os.Stderr)
+		log.SetLevel(defaultLevel)
+	})
+
+	var out bytes.Buffer
+	log.SetOutput(&out)
+	log.SetLevel(log.InfoLevel)
+	log.SetFormat(log.JSONFormat)
+
+	ctx := log.WithAttrs(context.Background(), "broker_id", "some-broker")
+	sessionCtx := log.WithAttrs(ctx, "session_id", "some-session")
+	log.Infof(sessionCtx, "Some %s message", "session")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry), "Log entry should be a JSON object")
+	require.Equal(t, "Some session message", entry["msg"], "Log entry should have the expected message")
+	require.Equal(t, "some-broker", entry["broker_id"], "Log entry should have the attributes of the parent context")
+	require.Equal(t, "some-session", entry["session_id"], "Log entry should have the attributes of the context")
+
+	out.Reset()
+	log.Info(ctx, "Some broker message")
+	var parentEntry map[string]any
+	require.NoError(t, json.Unmarshal(out.Bytes(), &parentEntry), "Log entry should be a JSON object")
+	require.NotContains(t, parentEntry, "session_id", "Attributes of a child context should not leak to its parent")
+
+	out.Reset()
+	log.SetFormat(log.TextFormat)
+	log.Info(sessionCtx, "Some text message")
+	require.Contains(t, out.String(), `msg="Some text message" broker_id=some-broker session_id=some-session`,
+		"Log entry should have the attributes as text")
+}
+
+func TestParseFormat(t *testing.T) {
+	t.Parallel()
+
+	for name, want := range map[string]log.Format{"text": log.TextFormat, "json": log.JSONFormat} {
+		got, err := log.ParseFormat(name)
+		require.NoError(t, err, "ParseFormat should not return an error for %q", name)
+		require.Equal(t, want, got, "ParseFormat should return the format named %q", name)
+	}
+
+	_, err := log.ParseFormat("xml")
+	require.Error(t, err, "ParseFormat should return an error for an unknown format")
+}
+
+func TestSetFormatWithoutCustomOutput(t *testing.T) {
+	// This can't be parallel.
+	defaultLevel := log.GetLevel()
+	r, w, err := os.Pipe()
+	require.NoError(t, err, "Setup: could not create pipe")
+	stderr := os.Stderr
+	os.Stderr = w
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		log.SetFormat(log.TextFormat)
+		log.SetLevel(defaultLevel)
+		_ = r.Close()
+	})
+
+	log.ResetOutput()
+	log.SetLevel(log.InfoLevel)
+	log.SetFormat(log.JSONFormat)
+	log.Info(context.Background(), "JSON message")
+	log.SetFormat(log.TextFormat)
+	log.Warning(context.Background(), "WARN text again")
+	log.SetLevel(log.ErrorLevel)
+	log.Warning(context.Background(), "Filtered out message")
+	require.NoError(t, w.Close(), "Setup: could not close pipe")
+
+	out, err := io.ReadAll(r)
+	require.NoError(t, err, "Setup: could not read logs")
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	require.Len(t, lines, 2, "Only the entries above the level should be logged")
+
+	var entry map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry), "First log entry should be a JSON object")
+	require.Equal(t, "JSON message", entry["msg"], "First log entry should have the expected message")
+	require.Contains(t, lines[1], `level=WARN msg="WARN text again"`, "Log entry should be text again")
+}
@@ -0,0 +1,90 @@
+package log
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultRateLimitInterval is the interval over which repeated warnings are collapsed.
+const defaultRateLimitInterval = time.Minute
+
+// rateLimiter collapses the repeated messages logged with the same format within an interval.
+type rateLimiter struct {
+	interval time.Duration
+
+	// formats are the formats logged in their current interval.
+	formats map[string]*limitedFormat
+	mu      sync.Mutex
+}
+
+// limitedFormat is a format logged in its current interval.
+type limitedFormat struct {
+	// suppressed is the number of messages with the format suppressed in the interval.
+	suppressed int
+	// end summarizes the interval once it's over.
+	end *time.Timer
+}
+
+var warningsLimiter = &rateLimiter{
+	interval: defaultRateLimitInterval,
+	formats:  make(map[string]*limitedFormat),
+}
+
+// RateLimitedWarningf outputs messages with the level [WarnLevel] like Warningf, but only the first of the messages
+// with the same format within a minute is logged. The others are counted and summarized at the end of the minute.
+// Messages are grouped by format, so that the ones only differing by their arguments are collapsed too.
+func RateLimitedWarningf(context context.Context, format string, args ...interface{}) {
+	warningsLimiter.logf(context, WarnLevel, format, args...)
+}
+
+// logf logs the message if it's the first one with this format in the interval, and counts it otherwise.
+func (r *rateLimiter) logf(ctx context.Context, level Level, format string, args ...interface{}) {
+	if !isLevelEnabled(ctx, level) {
+		return
+	}
+
+	r.mu.Lock()
+	if f, limited := r.formats[format]; limited {
+		f.suppressed++
+		r.mu.Unlock()
+		return
+	}
+	f := &limitedFormat{}
+	interval := r.interval
+	f.end = time.AfterFunc(interval, func() { r.summarize(level, format, f, interval) })
+	r.formats[format] = f
+	r.mu.Unlock()
+
+	logf(ctx, level, format, args...)
+}
+
+// summarize logs how many messages with the format were suppressed during the interval, if any, and starts a new
+// interval for it.
+func (r *rateLimiter) summarize(level Level, format string, f *limitedFormat, interval time.Duration) {
+	r.mu.Lock()
+	// The limiter was stopped while the interval was ending.
+	if r.formats[format] != f {
+		r.mu.Unlock()
+		return
+	}
+	n := f.suppressed
+	delete(r.formats, format)
+	r.mu.Unlock()
+
+	if n == 0 {
+		return
+	}
+	logf(context.Background(), level, "%d more occurrences of %q in the last %v", n, format, interval)
+}
+
+// stop ends the current intervals without summarizing them, so that no timer is left running.
+func (r *rateLimiter) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, f := range r.formats {
+		f.end.Stop()
+	}
+	r.formats = make(map[string]*limitedFormat)
+}
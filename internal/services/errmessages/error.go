@@ -9,3 +9,6 @@ type ErrToDisplay struct {
 func NewErrorToDisplay(err error) error {
 	return ErrToDisplay{err}
 }
+
+// Unwrap returns the error to display, so that it can still be matched.
+func (e ErrToDisplay) Unwrap() error { return e.error }
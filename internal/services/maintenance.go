@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/ubuntu/authd/internal/daemon"
+)
+
+// MaintenanceTaskConfig is the configuration of a maintenance task.
+type MaintenanceTaskConfig struct {
+	Enabled  bool
+	Interval time.Duration
+}
+
+// MaintenanceConfig is the configuration of the maintenance of the cache.
+type MaintenanceConfig struct {
+	// WindowStart and WindowEnd are the offsets from midnight of the daily time range during which the tasks can
+	// run. The tasks can run at any time if they are equal.
+	WindowStart time.Duration `mapstructure:"window_start"`
+	WindowEnd   time.Duration `mapstructure:"window_end"`
+
+	Verify      MaintenanceTaskConfig
+	Prune       MaintenanceTaskConfig
+	Compact     MaintenanceTaskConfig
+	ExpireUsers MaintenanceTaskConfig `mapstructure:"expire_users"`
+	// UserMaxAge is how long users can go without logging in before being expired.
+	UserMaxAge time.Duration `mapstructure:"user_max_age"`
+}
+
+// DefaultMaintenanceConfig is the default configuration of the maintenance, which only checks the cache, as the
+// other tasks modify it.
+var DefaultMaintenanceConfig = MaintenanceConfig{
+	Verify:      MaintenanceTaskConfig{Enabled: true, Interval: 24 * time.Hour},
+	Prune:       MaintenanceTaskConfig{Interval: 24 * time.Hour},
+	Compact:     MaintenanceTaskConfig{Interval: 7 * 24 * time.Hour},
+	ExpireUsers: MaintenanceTaskConfig{Interval: 24 * time.Hour},
+}
+
+// MaintenanceSpec returns the maintenance tasks of the cache, configured by config, to be scheduled by the daemon.
+// Pruning runs before compacting, so that the space freed by the removed records is reclaimed in the same window.
+func (m Manager) MaintenanceSpec(config MaintenanceConfig) daemon.MaintenanceSpec {
+	task := func(name string, c MaintenanceTaskConfig, run func() error) daemon.MaintenanceTask {
+		return daemon.MaintenanceTask{
+			Name:     name,
+			Interval: c.Interval,
+			Enabled:  c.Enabled,
+			Run:      func(context.Context) error { return run() },
+		}
+	}
+
+	return daemon.MaintenanceSpec{
+		Window: daemon.MaintenanceWindow{Start: config.WindowStart, End: config.WindowEnd},
+		Tasks: []daemon.MaintenanceTask{
+			task("verify", config.Verify, m.userManager.VerifyCache),
+			task("expire_users", config.ExpireUsers, func() error {
+				_, err := m.userManager.ExpireStaleUsers(config.UserMaxAge)
+				return err
+			}),
+			task("prune", config.Prune, m.userManager.PruneCache),
+			task("compact", config.Compact, m.userManager.CompactCache),
+		},
+	}
+}
@@ -3,6 +3,8 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/ubuntu/authd"
 	"github.com/ubuntu/authd/internal/brokers"
@@ -22,37 +24,201 @@ type Manager struct {
 	brokerManager *brokers.Manager
 	pamService    pam.Service
 	nssService    nss.Service
+
+	stopBackgroundTasks func()
+}
+
+// sessionReaperInterval is how often the sessions past their maximum lifetime are ended.
+const sessionReaperInterval = time.Minute
+
+// healthCheckInterval is how often the health of the brokers is checked.
+const healthCheckInterval = 30 * time.Second
+
+type options struct {
+	brokerOptions   []brokers.Option
+	brokerPolicy    brokers.BrokerPolicy
+	domainBrokerMap map[string]string
+	nssOptions      []nss.Option
+	userOptions     []users.Option
+}
+
+// Option is the function signature used to tweak the manager creation.
+type Option func(*options)
+
+// WithBrokerOptions passes opts to the broker manager.
+func WithBrokerOptions(opts ...brokers.Option) Option {
+	return func(o *options) {
+		o.brokerOptions = append(o.brokerOptions, opts...)
+	}
+}
+
+// WithBrokerPolicy sets the policy restricting the brokers users can authenticate with. Its rules refer to the brokers
+// by name instead of ID, and all of them must be loaded.
+func WithBrokerPolicy(policy brokers.BrokerPolicy) Option {
+	return func(o *options) {
+		o.brokerPolicy = policy
+	}
+}
+
+// WithDomainBrokerMap sets, by domain, the broker to route users named user@domain to when they have no previous
+// broker. The brokers are referred to by name instead of ID, and all of them must be loaded.
+func WithDomainBrokerMap(domainBrokers map[string]string) Option {
+	return func(o *options) {
+		o.domainBrokerMap = domainBrokers
+	}
+}
+
+// WithUserOptions passes opts to the user manager.
+func WithUserOptions(opts ...users.Option) Option {
+	return func(o *options) {
+		o.userOptions = append(o.userOptions, opts...)
+	}
+}
+
+// WithNSSOptions passes opts to the NSS service.
+func WithNSSOptions(opts ...nss.Option) Option {
+	return func(o *options) {
+		o.nssOptions = append(o.nssOptions, opts...)
+	}
 }
 
 // NewManager returns a new manager after creating all necessary items for our business logic.
-func NewManager(ctx context.Context, cacheDir, brokersConfPath string, configuredBrokers []string, usersConfig users.Config) (m Manager, err error) {
+func NewManager(ctx context.Context, cacheDir, brokersConfPath string, configuredBrokers []string, usersConfig users.Config, args ...Option) (m Manager, err error) {
 	defer decorate.OnError(&err /*i18n.G(*/, "can't create authd object") //)
 
 	log.Debug(ctx, "Building authd object")
 
-	brokerManager, err := brokers.NewManager(ctx, brokersConfPath, configuredBrokers)
+	opts := options{}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	brokerManager, err := brokers.NewManager(ctx, brokersConfPath, configuredBrokers, opts.brokerOptions...)
 	if err != nil {
 		return m, err
 	}
+	if err := setBrokerPolicy(brokerManager, opts.brokerPolicy); err != nil {
+		return m, err
+	}
+	if err := setDomainBrokerMap(brokerManager, opts.domainBrokerMap); err != nil {
+		return m, err
+	}
 
-	userManager, err := users.NewManager(usersConfig, cacheDir)
+	// The NSS lookups made while the cache is migrated are retried by the NSS module.
+	userOpts := append([]users.Option{users.WithBackgroundMigrations()}, opts.userOptions...)
+	userManager, err := users.NewManager(usersConfig, cacheDir, userOpts...)
 	if err != nil {
 		return m, err
 	}
 
 	permissionManager := permissions.New()
 
-	nssService := nss.NewService(ctx, userManager, brokerManager, &permissionManager)
+	nssService := nss.NewService(ctx, userManager, brokerManager, &permissionManager, opts.nssOptions...)
 	pamService := pam.NewService(ctx, userManager, brokerManager, &permissionManager)
 
+	backgroundCtx, cancel := context.WithCancel(context.Background())
+	reaperDone := brokerManager.StartSessionReaper(backgroundCtx, sessionReaperInterval)
+	healthChecksDone := brokerManager.StartHealthChecks(backgroundCtx, healthCheckInterval)
+
 	return Manager{
 		userManager:   userManager,
 		brokerManager: brokerManager,
 		nssService:    nssService,
 		pamService:    pamService,
+
+		stopBackgroundTasks: func() {
+			cancel()
+			<-reaperDone
+			<-healthChecksDone
+		},
 	}, nil
 }
 
+// setBrokerPolicy sets the broker policy of the broker manager, after replacing the broker names of its rules with
+// their IDs.
+func setBrokerPolicy(brokerManager *brokers.Manager, policy brokers.BrokerPolicy) error {
+	ids := brokerIDsByName(brokerManager)
+	toIDs := func(names []string) ([]string, error) {
+		var r []string
+		for _, name := range names {
+			id, ok := ids[name]
+			if !ok {
+				return nil, fmt.Errorf("broker policy refers to broker %q, which is not loaded", name)
+			}
+			r = append(r, id)
+		}
+		return r, nil
+	}
+
+	var byID brokers.BrokerPolicy
+	for _, rule := range policy {
+		allow, err := toIDs(rule.Allow)
+		if err != nil {
+			return err
+		}
+		deny, err := toIDs(rule.Deny)
+		if err != nil {
+			return err
+		}
+		byID = append(byID, brokers.BrokerPolicyRule{Pattern: rule.Pattern, Allow: allow, Deny: deny})
+	}
+	return brokerManager.SetBrokerPolicy(byID)
+}
+
+// setDomainBrokerMap sets the domain broker map of the broker manager, after replacing the broker names with their IDs.
+func setDomainBrokerMap(brokerManager *brokers.Manager, domainBrokers map[string]string) error {
+	ids := brokerIDsByName(brokerManager)
+	byID := make(map[string]string, len(domainBrokers))
+	for domain, name := range domainBrokers {
+		id, ok := ids[name]
+		if !ok {
+			return fmt.Errorf("domain %q is mapped to broker %q, which is not loaded", domain, name)
+		}
+		byID[domain] = id
+	}
+	return brokerManager.SetDomainBrokerMap(byID)
+}
+
+// brokerIDsByName returns the IDs of the available brokers, indexed by name.
+func brokerIDsByName(brokerManager *brokers.Manager) map[string]string {
+	ids := make(map[string]string)
+	for _, b := range brokerManager.AvailableBrokers() {
+		ids[b.Name] = b.ID
+	}
+	return ids
+}
+
+// InFlightBrokerCalls returns the number of broker calls currently in flight, for debugging purposes.
+func (m Manager) InFlightBrokerCalls() int {
+	return m.brokerManager.InFlightBrokerCalls()
+}
+
+// BusConnected returns whether authd is currently connected to the system bus, for debugging purposes.
+func (m Manager) BusConnected() bool {
+	return m.brokerManager.BusConnected()
+}
+
+// BrokerQueryCacheStats returns the statistics of the cache of idempotent broker queries, for debugging purposes.
+func (m Manager) BrokerQueryCacheStats() brokers.QueryCacheStats {
+	return m.brokerManager.BrokerQueryCacheStats()
+}
+
+// ReloadBrokers loads the brokers whose configuration file was added and drops the ones whose configuration file was
+// removed.
+func (m Manager) ReloadBrokers(ctx context.Context) error {
+	return m.brokerManager.Reload(ctx)
+}
+
+// SetBreakGlassUsers replaces the users which are always routed to the local broker.
+func (m Manager) SetBreakGlassUsers(users []string) {
+	m.brokerManager.SetBreakGlassUsers(users)
+}
+
+// LoadedBrokers returns the information of the currently loaded brokers in preference order, for debugging purposes.
+func (m Manager) LoadedBrokers() []brokers.BrokerInfo {
+	return m.brokerManager.LoadedBrokers()
+}
+
 // RegisterGRPCServices returns a new grpc Server after registering both NSS and PAM services.
 func (m Manager) RegisterGRPCServices(ctx context.Context) *grpc.Server {
 	log.Debug(ctx, "Registering GRPC services")
@@ -66,9 +232,10 @@ func (m Manager) RegisterGRPCServices(ctx context.Context) *grpc.Server {
 	return grpcServer
 }
 
-// stop stops the underlying cache.
+// stop stops the session reaper and the underlying cache.
 func (m *Manager) stop() error {
 	log.Debug(context.TODO(), "Closing grpc manager and cache")
 
+	m.stopBackgroundTasks()
 	return m.userManager.Stop()
 }
@@ -9,9 +9,11 @@ import (
 	"path/filepath"
 	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/authd"
+	"github.com/ubuntu/authd/internal/brokers"
 	"github.com/ubuntu/authd/internal/services"
 	"github.com/ubuntu/authd/internal/services/errmessages"
 	"github.com/ubuntu/authd/internal/testutils"
@@ -22,16 +24,23 @@ import (
 
 func TestNewManager(t *testing.T) {
 	tests := map[string]struct {
-		cacheDir string
+		cacheDir        string
+		brokerPolicy    brokers.BrokerPolicy
+		domainBrokerMap map[string]string
 
 		systemBusSocket string
 
 		wantErr bool
 	}{
-		"Successfully create the manager": {},
-
-		"Error when can not create cache":          {cacheDir: "doesnotexist", wantErr: true},
-		"Error when can not create broker manager": {systemBusSocket: "doesnotexist", wantErr: true},
+		"Successfully create the manager":                        {},
+		"Successfully create the manager with broker policy":     {brokerPolicy: brokers.BrokerPolicy{{Pattern: "*", Deny: []string{brokers.LocalBrokerName}}}},
+		"Successfully create the manager with domain broker map": {domainBrokerMap: map[string]string{"example.com": brokers.LocalBrokerName}},
+
+		"Error when can not create cache":                          {cacheDir: "doesnotexist", wantErr: true},
+		"Error when can not create broker manager":                 {systemBusSocket: "doesnotexist", wantErr: true},
+		"Error when broker policy refers to an unknown broker":     {brokerPolicy: brokers.BrokerPolicy{{Pattern: "*", Allow: []string{"unknown"}}}, wantErr: true},
+		"Error when broker policy has a malformed pattern":         {brokerPolicy: brokers.BrokerPolicy{{Pattern: "["}}, wantErr: true},
+		"Error when domain broker map refers to an unknown broker": {domainBrokerMap: map[string]string{"example.com": "unknown"}, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -42,7 +51,8 @@ func TestNewManager(t *testing.T) {
 				t.Setenv("DBUS_SYSTEM_BUS_ADDRESS", tc.systemBusSocket)
 			}
 
-			m, err := services.NewManager(context.Background(), tc.cacheDir, t.TempDir(), nil, users.DefaultConfig)
+			m, err := services.NewManager(context.Background(), tc.cacheDir, t.TempDir(), nil, users.DefaultConfig,
+				services.WithBrokerPolicy(tc.brokerPolicy), services.WithDomainBrokerMap(tc.domainBrokerMap))
 			if tc.wantErr {
 				require.Error(t, err, "NewManager should have returned an error, but did not")
 				return
@@ -117,6 +127,36 @@ func TestAccessAuthorization(t *testing.T) {
 // requireEqualServices asserts that the grpc services were registered as expected.
 //
 // This is needed because the order of the methods and the services is not guaranteed.
+func TestMaintenanceSpec(t *testing.T) {
+	t.Parallel()
+
+	m, err := services.NewManager(context.Background(), t.TempDir(), t.TempDir(), nil, users.DefaultConfig)
+	require.NoError(t, err, "Setup: could not create manager for the test")
+	t.Cleanup(func() { require.NoError(t, m.Stop(), "Teardown: Stop should not have returned an error, but did") })
+
+	enabled := services.MaintenanceTaskConfig{Enabled: true, Interval: time.Hour}
+	spec := m.MaintenanceSpec(services.MaintenanceConfig{
+		Verify:      enabled,
+		Prune:       enabled,
+		Compact:     enabled,
+		ExpireUsers: enabled,
+		UserMaxAge:  time.Hour,
+	})
+
+	var names []string
+	for _, task := range spec.Tasks {
+		names = append(names, task.Name)
+		require.True(t, task.Enabled, "Task %q should be enabled", task.Name)
+		require.Equal(t, time.Hour, task.Interval, "Task %q should have the configured interval", task.Name)
+		require.NoError(t, task.Run(context.Background()), "Task %q should not return an error on an empty cache", task.Name)
+	}
+	require.Equal(t, []string{"verify", "expire_users", "prune", "compact"}, names, "Tasks should be run in order")
+
+	for _, task := range m.MaintenanceSpec(services.DefaultMaintenanceConfig).Tasks {
+		require.Equal(t, task.Name == "verify", task.Enabled, "Only verify should be enabled by default, not %q", task.Name)
+	}
+}
+
 func requireEqualServices(t *testing.T, want, got map[string]grpc.ServiceInfo) {
 	t.Helper()
 
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 
 	"github.com/ubuntu/authd"
 	"github.com/ubuntu/authd/internal/brokers"
@@ -17,26 +18,129 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// groupMembersBatchSize is the maximum number of members sent in a single message by StreamGroupMembers.
+const groupMembersBatchSize = 1000
+
+// defaultInlineGroupMembersLimit is the maximum number of members returned in a group entry if the service isn't
+// created with WithInlineGroupMembersLimit. Larger groups are returned truncated, without members.
+const defaultInlineGroupMembersLimit = 1000
+
+// defaultEnumerationPageSize is the number of entries sent in a single message by the streaming enumerations if the
+// request doesn't set one, and maxEnumerationPageSize is the highest one a request can set.
+const (
+	defaultEnumerationPageSize = 1000
+	maxEnumerationPageSize     = 10000
+)
+
 // Service is the implementation of the NSS module service.
 type Service struct {
 	userManager       *users.Manager
 	brokerManager     *brokers.Manager
 	permissionManager *permissions.Manager
 
+	authoritativeRange    *idRange
+	snapshotEnumeration   bool
+	uidOffset, gidOffset  int64
+	flattenedGroupMembers bool
+	inlineMembersLimit    int
+
 	authd.UnimplementedNSSServer
 }
 
+// idRange is an inclusive range of UIDs and GIDs.
+type idRange struct {
+	min, max uint32
+}
+
+type options struct {
+	authoritativeRange    *idRange
+	snapshotEnumeration   bool
+	uidOffset, gidOffset  int64
+	flattenedGroupMembers bool
+	inlineMembersLimit    int
+}
+
+// Option is the function signature used to tweak the service creation.
+type Option func(*options)
+
+// WithAuthoritativeRange restricts the IDs authd answers for to the ones between min and max, inclusive.
+// Queries for other IDs return a distinct error so that the NSS module lets the next source answer.
+func WithAuthoritativeRange(min, max uint32) Option {
+	return func(o *options) {
+		o.authoritativeRange = &idRange{min: min, max: max}
+	}
+}
+
+// WithSnapshotEnumeration serves the enumerations of all groups from a snapshot taken at the start of each call, with
+// the members of their nested groups when group members are flattened, so that they reflect a single point in time
+// even with concurrent updates. Only the groups and, if needed, their nested members are read for each enumeration.
+// The enumerations of users are always read in a single transaction.
+func WithSnapshotEnumeration() Option {
+	return func(o *options) {
+		o.snapshotEnumeration = true
+	}
+}
+
+// WithIDOffset shifts the UIDs and GIDs returned by the service by uidOffset and gidOffset, like for containers
+// whose user namespace maps the IDs stored by authd to another range. Queries by ID take the shifted IDs.
+// Entries whose shifted IDs don't fit in an ID are not returned.
+func WithIDOffset(uidOffset, gidOffset int64) Option {
+	return func(o *options) {
+		o.uidOffset = uidOffset
+		o.gidOffset = gidOffset
+	}
+}
+
+// WithFlattenedGroupMembers adds the members of the groups nested in a group, directly or through other nested groups,
+// to the members of the group entries returned by the service.
+func WithFlattenedGroupMembers() Option {
+	return func(o *options) {
+		o.flattenedGroupMembers = true
+	}
+}
+
+// WithInlineGroupMembersLimit sets the maximum number of members returned in a group entry. The entries of larger
+// groups are marked as truncated and have no members: they are to be read with StreamGroupMembers instead.
+func WithInlineGroupMembersLimit(limit int) Option {
+	return func(o *options) {
+		o.inlineMembersLimit = limit
+	}
+}
+
 // NewService returns a new NSS GRPC service.
-func NewService(ctx context.Context, userManager *users.Manager, brokerManager *brokers.Manager, permissionManager *permissions.Manager) Service {
+func NewService(ctx context.Context, userManager *users.Manager, brokerManager *brokers.Manager, permissionManager *permissions.Manager, args ...Option) Service {
 	log.Debug(ctx, "Building new GRPC NSS service")
 
+	opts := options{
+		inlineMembersLimit: defaultInlineGroupMembersLimit,
+	}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
 	return Service{
-		userManager:       userManager,
-		brokerManager:     brokerManager,
-		permissionManager: permissionManager,
+		userManager:         userManager,
+		brokerManager:       brokerManager,
+		permissionManager:   permissionManager,
+		authoritativeRange:  opts.authoritativeRange,
+		snapshotEnumeration: opts.snapshotEnumeration,
+		uidOffset:           opts.uidOffset,
+		gidOffset:           opts.gidOffset,
+
+		flattenedGroupMembers: opts.flattenedGroupMembers,
+		inlineMembersLimit:    opts.inlineMembersLimit,
 	}
 }
 
+// checkAuthoritativeID returns an OutOfRange error if authd is not authoritative for the ID, so that the query is
+// answered by the next NSS source.
+func (s Service) checkAuthoritativeID(id uint32) error {
+	if s.authoritativeRange == nil || (id >= s.authoritativeRange.min && id <= s.authoritativeRange.max) {
+		return nil
+	}
+	return status.Errorf(codes.OutOfRange, "ID %d is not managed by authd", id)
+}
+
 // GetPasswdByName returns the passwd entry for the given username.
 func (s Service) GetPasswdByName(ctx context.Context, req *authd.GetPasswdByNameRequest) (*authd.PasswdEntry, error) {
 	if req.GetName() == "" {
@@ -45,10 +149,10 @@ func (s Service) GetPasswdByName(ctx context.Context, req *authd.GetPasswdByName
 
 	u, err := s.userManager.UserByName(req.GetName())
 	if err == nil {
-		return nssPasswdFromUsersPasswd(u), nil
+		return s.passwdEntry(ctx, u)
 	}
 
-	if !errors.Is(err, users.ErrNoDataFound{}) || !req.GetShouldPreCheck() {
+	if !errors.Is(err, users.ErrNoEntry) || !req.GetShouldPreCheck() {
 		return nil, noDataFoundErrorToGRPCError(err)
 	}
 
@@ -63,12 +167,21 @@ func (s Service) GetPasswdByName(ctx context.Context, req *authd.GetPasswdByName
 
 // GetPasswdByUID returns the passwd entry for the given UID.
 func (s Service) GetPasswdByUID(ctx context.Context, req *authd.GetByIDRequest) (*authd.PasswdEntry, error) {
-	u, err := s.userManager.UserByID(req.GetId())
+	if err := s.checkAuthoritativeID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	uid, err := shiftID(req.GetId(), -s.uidOffset)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "")
+	}
+
+	u, err := s.userManager.UserByID(uid)
 	if err != nil {
 		return nil, noDataFoundErrorToGRPCError(err)
 	}
 
-	return nssPasswdFromUsersPasswd(u), nil
+	return s.passwdEntry(ctx, u)
 }
 
 // GetPasswdEntries returns all passwd entries.
@@ -80,12 +193,37 @@ func (s Service) GetPasswdEntries(ctx context.Context, req *authd.Empty) (*authd
 
 	var r authd.PasswdEntries
 	for _, u := range allUsers {
-		r.Entries = append(r.Entries, nssPasswdFromUsersPasswd(u))
+		entry, err := s.nssPasswdFromUsersPasswd(u)
+		if err != nil {
+			log.Warningf(ctx, "Ignoring passwd entry: %v", err)
+			continue
+		}
+		r.Entries = append(r.Entries, entry)
 	}
 
 	return &r, nil
 }
 
+// GetPasswdEntriesStream streams all passwd entries in pages, for directories too large to be returned in a single
+// message. Only one page is read from the database at a time, so the entries are not a snapshot, even with
+// WithSnapshotEnumeration.
+func (s Service) GetPasswdEntriesStream(req *authd.PageRequest, stream authd.NSS_GetPasswdEntriesStreamServer) error {
+	ctx := stream.Context()
+	err := s.userManager.UsersInBatches(ctx, pageSize(req), func(usrs []users.UserEntry) error {
+		var r authd.PasswdEntries
+		for _, u := range usrs {
+			entry, err := s.nssPasswdFromUsersPasswd(u)
+			if err != nil {
+				log.Warningf(ctx, "Ignoring passwd entry: %v", err)
+				continue
+			}
+			r.Entries = append(r.Entries, entry)
+		}
+		return stream.Send(&r)
+	})
+	return noDataFoundErrorToGRPCError(err)
+}
+
 // GetGroupByName returns the group entry for the given group name.
 func (s Service) GetGroupByName(ctx context.Context, req *authd.GetGroupByNameRequest) (*authd.GroupEntry, error) {
 	if req.GetName() == "" {
@@ -96,38 +234,142 @@ func (s Service) GetGroupByName(ctx context.Context, req *authd.GetGroupByNameRe
 		return nil, noDataFoundErrorToGRPCError(err)
 	}
 
-	return nssGroupFromUsersGroup(g), nil
+	return s.groupEntry(ctx, g)
 }
 
 // GetGroupByGID returns the group entry for the given GID.
 func (s Service) GetGroupByGID(ctx context.Context, req *authd.GetByIDRequest) (*authd.GroupEntry, error) {
-	g, err := s.userManager.GroupByID(req.GetId())
+	if err := s.checkAuthoritativeID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	gid, err := shiftID(req.GetId(), -s.gidOffset)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "")
+	}
+
+	g, err := s.userManager.GroupByID(gid)
 	if err != nil {
 		return nil, noDataFoundErrorToGRPCError(err)
 	}
 
-	return nssGroupFromUsersGroup(g), nil
+	return s.groupEntry(ctx, g)
 }
 
 // GetGroupEntries returns all group entries.
 func (s Service) GetGroupEntries(ctx context.Context, req *authd.Empty) (*authd.GroupEntries, error) {
-	allGroups, err := s.userManager.AllGroups()
+	allGroups, err := s.allGroups(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	var r authd.GroupEntries
 	for _, g := range allGroups {
-		r.Entries = append(r.Entries, nssGroupFromUsersGroup(g))
+		entry, err := s.nssGroupFromUsersGroup(g)
+		if err != nil {
+			log.Warningf(ctx, "Ignoring group entry: %v", err)
+			continue
+		}
+		r.Entries = append(r.Entries, entry)
+	}
+
+	return &r, nil
+}
+
+// GetGroupEntriesStream streams all group entries in pages, like GetPasswdEntriesStream.
+func (s Service) GetGroupEntriesStream(req *authd.PageRequest, stream authd.NSS_GetGroupEntriesStreamServer) error {
+	ctx := stream.Context()
+	err := s.userManager.GroupsInBatches(ctx, pageSize(req), func(grps []users.GroupEntry) error {
+		var r authd.GroupEntries
+		for _, g := range grps {
+			g, err := s.withNestedMembers(g)
+			if err != nil {
+				log.Warningf(ctx, "Ignoring group entry: %v", err)
+				continue
+			}
+			entry, err := s.nssGroupFromUsersGroup(g)
+			if err != nil {
+				log.Warningf(ctx, "Ignoring group entry: %v", err)
+				continue
+			}
+			r.Entries = append(r.Entries, entry)
+		}
+		return stream.Send(&r)
+	})
+	return noDataFoundErrorToGRPCError(err)
+}
+
+// StreamGroupMembers streams the members of the given group in batches, for groups too large to be returned in
+// a single GroupEntry, like the ones whose entries are truncated. The members of the nested groups are included if the
+// service flattens group membership.
+func (s Service) StreamGroupMembers(req *authd.GetGroupByNameRequest, stream authd.NSS_StreamGroupMembersServer) error {
+	if req.GetName() == "" {
+		return status.Error(codes.InvalidArgument, "no group name provided")
+	}
+
+	if s.flattenedGroupMembers {
+		g, err := s.userManager.GroupByName(req.GetName())
+		if err != nil {
+			return noDataFoundErrorToGRPCError(err)
+		}
+		g, err = s.userManager.GroupWithNestedMembers(g)
+		if err != nil {
+			return noDataFoundErrorToGRPCError(err)
+		}
+		for members := range slices.Chunk(g.Users, groupMembersBatchSize) {
+			if err := stream.Send(&authd.GroupMembers{Members: members}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := s.userManager.GroupMembersByName(req.GetName(), groupMembersBatchSize, func(members []string) error {
+		return stream.Send(&authd.GroupMembers{Members: members})
+	})
+	return noDataFoundErrorToGRPCError(err)
+}
+
+// GetGroupsByUID returns the group entries of the groups the user matching the given UID belongs to, including their
+// primary group, as needed by initgroups. Only the groups of the user are read, not all the groups.
+func (s Service) GetGroupsByUID(ctx context.Context, req *authd.GetByIDRequest) (*authd.GroupEntries, error) {
+	if err := s.checkAuthoritativeID(req.GetId()); err != nil {
+		return nil, err
+	}
+
+	uid, err := shiftID(req.GetId(), -s.uidOffset)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, "")
+	}
+
+	groups, err := s.userManager.GroupsForUser(uid)
+	if err != nil {
+		return nil, noDataFoundErrorToGRPCError(err)
+	}
+
+	var r authd.GroupEntries
+	for _, g := range groups {
+		g, err := s.withNestedMembers(g)
+		if err != nil {
+			log.Warningf(ctx, "Ignoring group entry: %v", err)
+			continue
+		}
+		entry, err := s.nssGroupFromUsersGroup(g)
+		if err != nil {
+			log.Warningf(ctx, "Ignoring group entry: %v", err)
+			continue
+		}
+		r.Entries = append(r.Entries, entry)
 	}
 
 	return &r, nil
 }
 
 // GetShadowByName returns the shadow entry for the given username.
+// Only root and the members of the shadow group can read it.
 func (s Service) GetShadowByName(ctx context.Context, req *authd.GetShadowByNameRequest) (*authd.ShadowEntry, error) {
-	if err := s.permissionManager.IsRequestFromRoot(ctx); err != nil {
-		return nil, err
+	if err := s.permissionManager.IsRequestFromShadowReader(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
 	if req.GetName() == "" {
@@ -138,13 +380,21 @@ func (s Service) GetShadowByName(ctx context.Context, req *authd.GetShadowByName
 		return nil, noDataFoundErrorToGRPCError(err)
 	}
 
-	return nssShadowFromUsersShadow(u), nil
+	entry, err := nssShadowFromUsersShadow(u)
+	if err != nil {
+		// Returning a truncated value could lock the user out, or worse, so we act as if the entry was not there.
+		log.Warningf(ctx, "Ignoring shadow entry: %v", err)
+		return nil, status.Error(codes.NotFound, "")
+	}
+
+	return entry, nil
 }
 
 // GetShadowEntries returns all shadow entries.
+// Only root and the members of the shadow group can read them.
 func (s Service) GetShadowEntries(ctx context.Context, req *authd.Empty) (*authd.ShadowEntries, error) {
-	if err := s.permissionManager.IsRequestFromRoot(ctx); err != nil {
-		return nil, err
+	if err := s.permissionManager.IsRequestFromShadowReader(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
 	}
 
 	allUsers, err := s.userManager.AllShadows()
@@ -154,12 +404,118 @@ func (s Service) GetShadowEntries(ctx context.Context, req *authd.Empty) (*authd
 
 	var r authd.ShadowEntries
 	for _, u := range allUsers {
-		r.Entries = append(r.Entries, nssShadowFromUsersShadow(u))
+		entry, err := nssShadowFromUsersShadow(u)
+		if err != nil {
+			log.Warningf(ctx, "Ignoring shadow entry: %v", err)
+			continue
+		}
+		r.Entries = append(r.Entries, entry)
 	}
 
 	return &r, nil
 }
 
+// GetShadowEntriesStream streams all shadow entries in pages, like GetPasswdEntriesStream.
+// Only root and the members of the shadow group can read them.
+func (s Service) GetShadowEntriesStream(req *authd.PageRequest, stream authd.NSS_GetShadowEntriesStreamServer) error {
+	ctx := stream.Context()
+	if err := s.permissionManager.IsRequestFromShadowReader(ctx); err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	err := s.userManager.ShadowsInBatches(ctx, pageSize(req), func(shadows []users.ShadowEntry) error {
+		var r authd.ShadowEntries
+		for _, u := range shadows {
+			entry, err := nssShadowFromUsersShadow(u)
+			if err != nil {
+				log.Warningf(ctx, "Ignoring shadow entry: %v", err)
+				continue
+			}
+			r.Entries = append(r.Entries, entry)
+		}
+		return stream.Send(&r)
+	})
+	return noDataFoundErrorToGRPCError(err)
+}
+
+// GetGShadowByName returns the gshadow entry for the given group name.
+func (s Service) GetGShadowByName(ctx context.Context, req *authd.GetGShadowByNameRequest) (*authd.GShadowEntry, error) {
+	if err := s.permissionManager.IsRequestFromShadowReader(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	if req.GetName() == "" {
+		return nil, status.Error(codes.InvalidArgument, "no gshadow name provided")
+	}
+	g, err := s.userManager.GroupByName(req.GetName())
+	if err != nil {
+		return nil, noDataFoundErrorToGRPCError(err)
+	}
+	// The members of the gshadow entry must match the ones of the group entry.
+	if g, err = s.withNestedMembers(g); err != nil {
+		return nil, noDataFoundErrorToGRPCError(err)
+	}
+
+	return nssGShadowFromUsersGroup(g), nil
+}
+
+// GetGShadowEntries returns all gshadow entries.
+func (s Service) GetGShadowEntries(ctx context.Context, req *authd.Empty) (*authd.GShadowEntries, error) {
+	if err := s.permissionManager.IsRequestFromShadowReader(ctx); err != nil {
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	// The members of the gshadow entries must match the ones of the group entries.
+	allGroups, err := s.allGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var r authd.GShadowEntries
+	for _, g := range allGroups {
+		r.Entries = append(r.Entries, nssGShadowFromUsersGroup(g))
+	}
+
+	return &r, nil
+}
+
+// allGroups returns all groups, with the members of their nested groups if group members are flattened. With
+// WithSnapshotEnumeration, the groups and their nested members are read at a single point in time. Otherwise, the
+// groups whose nested members can't be read are skipped.
+func (s Service) allGroups(ctx context.Context) ([]users.GroupEntry, error) {
+	if s.snapshotEnumeration {
+		return s.userManager.GroupsSnapshot(s.flattenedGroupMembers)
+	}
+
+	allGroups, err := s.userManager.AllGroups()
+	if err != nil {
+		return nil, err
+	}
+	if !s.flattenedGroupMembers {
+		return allGroups, nil
+	}
+
+	var groups []users.GroupEntry
+	for _, g := range allGroups {
+		g, err := s.userManager.GroupWithNestedMembers(g)
+		if err != nil {
+			log.Warningf(ctx, "Ignoring group entry: %v", err)
+			continue
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// pageSize returns the number of entries to send in each message of a streaming enumeration.
+func pageSize(req *authd.PageRequest) int {
+	size := req.GetPageSize()
+	if size == 0 {
+		return defaultEnumerationPageSize
+	}
+	return int(min(size, maxEnumerationPageSize))
+}
+
 // userPreCheck checks if the user exists in at least one broker.
 func (s Service) userPreCheck(ctx context.Context, username string) (pwent *authd.PasswdEntry, err error) {
 	// Check if the user exists in at least one broker.
@@ -188,64 +544,174 @@ func (s Service) userPreCheck(ctx context.Context, username string) (pwent *auth
 	u.UID = s.userManager.GenerateUID(u.Name)
 	u.GID = s.userManager.GenerateGID(u.Name)
 
-	return nssPasswdFromUsersPasswd(u), nil
+	return s.nssPasswdFromUsersPasswd(u)
+}
+
+// passwdEntry returns the PasswdEntry of a user found by a query, or a NotFound error if its IDs can't be shifted.
+func (s Service) passwdEntry(ctx context.Context, u users.UserEntry) (*authd.PasswdEntry, error) {
+	entry, err := s.nssPasswdFromUsersPasswd(u)
+	if err != nil {
+		log.Warningf(ctx, "Ignoring passwd entry: %v", err)
+		return nil, status.Error(codes.NotFound, "")
+	}
+	return entry, nil
 }
 
-// nssPasswdFromUsersPasswd returns a PasswdEntry from users.UserEntry.
-func nssPasswdFromUsersPasswd(u users.UserEntry) *authd.PasswdEntry {
+// groupEntry returns the GroupEntry of a group found by a query, or a NotFound error if its GID can't be shifted.
+func (s Service) groupEntry(ctx context.Context, g users.GroupEntry) (*authd.GroupEntry, error) {
+	g, err := s.withNestedMembers(g)
+	if err != nil {
+		return nil, noDataFoundErrorToGRPCError(err)
+	}
+
+	entry, err := s.nssGroupFromUsersGroup(g)
+	if err != nil {
+		log.Warningf(ctx, "Ignoring group entry: %v", err)
+		return nil, status.Error(codes.NotFound, "")
+	}
+	return entry, nil
+}
+
+// withNestedMembers returns the group with the members of its nested groups, if the service flattens group membership.
+func (s Service) withNestedMembers(g users.GroupEntry) (users.GroupEntry, error) {
+	if !s.flattenedGroupMembers {
+		return g, nil
+	}
+	return s.userManager.GroupWithNestedMembers(g)
+}
+
+// nssPasswdFromUsersPasswd returns a PasswdEntry from users.UserEntry, with its IDs shifted by the offsets of the
+// service. It returns an error if any of the shifted IDs does not fit in the entry.
+func (s Service) nssPasswdFromUsersPasswd(u users.UserEntry) (*authd.PasswdEntry, error) {
+	uid, err := shiftID(u.UID, s.uidOffset)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UID for user %q: %v", u.Name, err)
+	}
+	gid, err := shiftID(u.GID, s.gidOffset)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GID for user %q: %v", u.Name, err)
+	}
+
 	return &authd.PasswdEntry{
 		Name:    u.Name,
 		Passwd:  "x",
-		Uid:     u.UID,
-		Gid:     u.GID,
+		Uid:     uid,
+		Gid:     gid,
 		Gecos:   u.Gecos,
 		Homedir: u.Dir,
 		Shell:   u.Shell,
-	}
+	}, nil
 }
 
-// nssGroupFromUsersGroup returns a GroupEntry from users.GroupEntry.
-func nssGroupFromUsersGroup(g users.GroupEntry) *authd.GroupEntry {
+// nssGroupFromUsersGroup returns a GroupEntry from users.GroupEntry, with its GID shifted by the offset of the
+// service. It returns an error if the shifted GID does not fit in the entry.
+// Groups with more members than the inline limit of the service are returned truncated, without members.
+func (s Service) nssGroupFromUsersGroup(g users.GroupEntry) (*authd.GroupEntry, error) {
+	gid, err := shiftID(g.GID, s.gidOffset)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GID for group %q: %v", g.Name, err)
+	}
+
+	if len(g.Users) > s.inlineMembersLimit {
+		return &authd.GroupEntry{
+			Name:      g.Name,
+			Passwd:    "x",
+			Gid:       gid,
+			Truncated: true,
+		}, nil
+	}
+
 	return &authd.GroupEntry{
 		Name:    g.Name,
 		Passwd:  "x",
-		Gid:     g.GID,
+		Gid:     gid,
 		Members: g.Users,
+	}, nil
+}
+
+// shiftID returns id shifted by offset, or an error if the result does not fit in an ID.
+func shiftID(id uint32, offset int64) (uint32, error) {
+	shifted := int64(id) + offset
+	if shifted < 0 || shifted > math.MaxUint32 {
+		return 0, fmt.Errorf("ID %d shifted by %d is out of range", id, offset)
 	}
+	//nolint:gosec // We checked that the value fits in an uint32.
+	return uint32(shifted), nil
 }
 
 // nssShadowFromUsersShadow returns a ShadowEntry from users.ShadowEntry.
-func nssShadowFromUsersShadow(u users.ShadowEntry) *authd.ShadowEntry {
-	return &authd.ShadowEntry{
-		Name:               u.Name,
-		Passwd:             "x",
-		LastChange:         convertToNumberOfDays(u.LastPwdChange),
-		ChangeMinDays:      convertToNumberOfDays(u.MinPwdAge),
-		ChangeMaxDays:      convertToNumberOfDays(u.MaxPwdAge),
-		ChangeWarnDays:     convertToNumberOfDays(u.PwdWarnPeriod),
-		ChangeInactiveDays: convertToNumberOfDays(u.PwdInactivity),
-		ExpireDate:         convertToNumberOfDays(u.ExpirationDate),
+// The password of locked users starts with "!", like the ones locked by passwd, so that pam_unix rejects them too.
+// It returns an error if any of the number of days does not fit in the entry.
+func nssShadowFromUsersShadow(u users.ShadowEntry) (*authd.ShadowEntry, error) {
+	entry := &authd.ShadowEntry{
+		Name:   u.Name,
+		Passwd: "x",
+	}
+	if u.Locked {
+		entry.Passwd = "!x"
+	}
+
+	for _, f := range []struct {
+		name string
+		days int
+		dst  *int32
+	}{
+		{"last password change", u.LastPwdChange, &entry.LastChange},
+		{"minimum password age", u.MinPwdAge, &entry.ChangeMinDays},
+		{"maximum password age", u.MaxPwdAge, &entry.ChangeMaxDays},
+		{"password warning period", u.PwdWarnPeriod, &entry.ChangeWarnDays},
+		{"password inactivity period", u.PwdInactivity, &entry.ChangeInactiveDays},
+		{"expiration date", u.ExpirationDate, &entry.ExpireDate},
+	} {
+		days, err := convertToNumberOfDays(f.days)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s for user %q: %v", f.name, u.Name, err)
+		}
+		*f.dst = days
 	}
+
+	return entry, nil
 }
 
-// noDataFoundErrorToGRPCError converts a data not found to proper GRPC status code.
-// This code is picked up by the NSS module to return corresponding NSS status.
+// nssGShadowFromUsersGroup returns a GShadowEntry from users.GroupEntry.
+// Groups have no password nor administrators, so the password is locked and only the members are set.
+func nssGShadowFromUsersGroup(g users.GroupEntry) *authd.GShadowEntry {
+	return &authd.GShadowEntry{
+		Name:    g.Name,
+		Passwd:  "!",
+		Members: g.Users,
+	}
+}
+
+// noDataFoundErrorToGRPCError converts a lookup without any matching entry, a migration in progress or any other
+// error from the database to proper GRPC status code. Errors which already are GRPC ones, like the ones of streams,
+// are returned as is.
+// This code is picked up by the NSS module to return corresponding NSS status: the next source is queried if there
+// is no entry, while the lookup is retried later during a migration, and fails when the database can't be read.
+// Aborted is reserved for transient errors, as it's the only code the NSS module retries.
 func noDataFoundErrorToGRPCError(err error) error {
-	if !errors.Is(err, users.ErrNoDataFound{}) {
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, users.ErrMigrationInProgress) {
+		return status.Error(codes.Aborted, err.Error())
+	}
+	if errors.Is(err, users.ErrNoEntry) {
+		return status.Error(codes.NotFound, "")
+	}
+	if _, ok := status.FromError(err); ok {
 		return err
 	}
 
-	return status.Error(codes.NotFound, "")
+	return status.Error(codes.Unavailable, err.Error())
 }
 
-// convertToNumberOfDays returns an int32 from an int. This should be only use for safe conversions where
-// we know the numbers can’t be overflow like number of days in shadow.
-// We print a warning if the number overflows and replaced it with max int32.
-func convertToNumberOfDays(i int) int32 {
-	if i > math.MaxInt32 {
-		log.Warningf(context.Background(), "Number of days overflows an int32: %d, replaced with max of int32", i)
-		return math.MaxInt32
+// convertToNumberOfDays returns an int32 from an int, as used for the number of days in shadow.
+// It returns an error if the number does not fit in an int32, instead of silently truncating it.
+func convertToNumberOfDays(i int) (int32, error) {
+	if i > math.MaxInt32 || i < math.MinInt32 {
+		return 0, fmt.Errorf("number of days overflows an int32: %d", i)
 	}
 	//nolint:gosec // we did check the conversion beforehand.
-	return int32(i)
+	return int32(i), nil
 }
@@ -2,7 +2,9 @@ package nss_test
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
@@ -52,7 +54,8 @@ func TestGetPasswdByName(t *testing.T) {
 		wantErr          bool
 		wantErrNotExists bool
 	}{
-		"Return existing user": {username: "user1"},
+		"Return existing user":          {username: "user1"},
+		"Return existing disabled user": {username: "user1", sourceDB: "disabled_user.db.yaml"},
 
 		"Precheck user if not in cache":                                          {username: "user-pre-check", shouldPreCheck: true},
 		"Prechecked user with upper cases in username has same id as lower case": {username: "User-Pre-Check", shouldPreCheck: true},
@@ -82,15 +85,23 @@ func TestGetPasswdByUID(t *testing.T) {
 	tests := map[string]struct {
 		uid uint32
 
-		sourceDB string
+		sourceDB           string
+		authoritativeRange []uint32
+		idOffset           []int64
 
-		wantErr          bool
-		wantErrNotExists bool
+		wantErr           bool
+		wantErrNotExists  bool
+		wantErrOutOfRange bool
 	}{
-		"Return existing user": {uid: 1111},
+		"Return existing user":                        {uid: 1111},
+		"Return existing user in authoritative range": {uid: 1111, authoritativeRange: []uint32{1000, 2000}},
+		"Return existing user by its shifted uid":     {uid: 101111, idOffset: []int64{100000, 200000}},
+
+		"Error with typed GRPC outofrange code on uid not managed by authd": {uid: 1111, authoritativeRange: []uint32{2000, 3000}, wantErr: true, wantErrOutOfRange: true},
 
 		"Error in database fetched content":                      {uid: 1111, sourceDB: "invalid.db.yaml", wantErr: true},
 		"Error with typed GRPC notfound code on unexisting user": {uid: 4242, wantErr: true, wantErrNotExists: true},
+		"Error with typed GRPC notfound code on unshifted uid":   {uid: 1111, idOffset: []int64{100000, 200000}, wantErr: true, wantErrNotExists: true},
 		"Error on missing uid":                                   {wantErr: true},
 	}
 	for name, tc := range tests {
@@ -98,9 +109,20 @@ func TestGetPasswdByUID(t *testing.T) {
 			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
 			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
 
-			client := newNSSClient(t, tc.sourceDB, false)
+			var args []nss.Option
+			if tc.authoritativeRange != nil {
+				args = append(args, nss.WithAuthoritativeRange(tc.authoritativeRange[0], tc.authoritativeRange[1]))
+			}
+			if tc.idOffset != nil {
+				args = append(args, nss.WithIDOffset(tc.idOffset[0], tc.idOffset[1]))
+			}
+			client := newNSSClient(t, tc.sourceDB, false, args...)
 
 			got, err := client.GetPasswdByUID(context.Background(), &authd.GetByIDRequest{Id: tc.uid})
+			if tc.wantErrOutOfRange {
+				require.Equal(t, codes.OutOfRange, status.Code(err), "GetPasswdByUID should return OutOfRange error")
+				return
+			}
 			requireExpectedResult(t, "GetPasswdByUID", got, err, tc.wantErr, tc.wantErrNotExists)
 		})
 	}
@@ -108,21 +130,33 @@ func TestGetPasswdByUID(t *testing.T) {
 
 func TestGetPasswdEntries(t *testing.T) {
 	tests := map[string]struct {
-		sourceDB string
+		sourceDB            string
+		snapshotEnumeration bool
+		idOffset            []int64
 
 		wantErr bool
 	}{
-		"Return all users": {},
-		"Return no users":  {sourceDB: "empty.db.yaml"},
+		"Return all users":                  {},
+		"Return all users from a snapshot":  {snapshotEnumeration: true},
+		"Return all users with shifted ids": {idOffset: []int64{100000, 200000}},
+		"Return no users":                   {sourceDB: "empty.db.yaml"},
 
-		"Error in database fetched content": {sourceDB: "invalid.db.yaml", wantErr: true},
+		"Error in database fetched content":                 {sourceDB: "invalid.db.yaml", wantErr: true},
+		"Error in database fetched content from a snapshot": {sourceDB: "invalid.db.yaml", snapshotEnumeration: true, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
 			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
 
-			client := newNSSClient(t, tc.sourceDB, false)
+			var opts []nss.Option
+			if tc.snapshotEnumeration {
+				opts = append(opts, nss.WithSnapshotEnumeration())
+			}
+			if tc.idOffset != nil {
+				opts = append(opts, nss.WithIDOffset(tc.idOffset[0], tc.idOffset[1]))
+			}
+			client := newNSSClient(t, tc.sourceDB, false, opts...)
 
 			got, err := client.GetPasswdEntries(context.Background(), &authd.Empty{})
 			requireExpectedEntriesResult(t, "GetPasswdEntries", got.GetEntries(), err, tc.wantErr)
@@ -130,16 +164,60 @@ func TestGetPasswdEntries(t *testing.T) {
 	}
 }
 
+func TestGetPasswdEntriesStream(t *testing.T) {
+	tests := map[string]struct {
+		pageSize uint32
+		sourceDB string
+		idOffset []int64
+
+		wantPages int
+		wantErr   bool
+	}{
+		"Return all users in a single page":      {wantPages: 1},
+		"Return all users in multiple pages":     {pageSize: 2, wantPages: 2},
+		"Return all users in pages of one entry": {pageSize: 1, wantPages: 3},
+		"Return all users with shifted ids":      {pageSize: 2, idOffset: []int64{100000, 200000}, wantPages: 2},
+		"Return no users":                        {sourceDB: "empty.db.yaml"},
+
+		"Error in database fetched content": {sourceDB: "invalid.db.yaml", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
+			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
+
+			var opts []nss.Option
+			if tc.idOffset != nil {
+				opts = append(opts, nss.WithIDOffset(tc.idOffset[0], tc.idOffset[1]))
+			}
+			client := newNSSClient(t, tc.sourceDB, false, opts...)
+
+			stream, err := client.GetPasswdEntriesStream(context.Background(), &authd.PageRequest{PageSize: tc.pageSize})
+			require.NoError(t, err, "Setup: could not start stream")
+
+			got, pages, err := recvAllPages[authd.PasswdEntry](stream.Recv)
+			requireExpectedEntriesResult(t, "GetPasswdEntriesStream", got, err, tc.wantErr)
+			require.Equal(t, tc.wantPages, pages, "GetPasswdEntriesStream should return the expected number of pages")
+		})
+	}
+}
+
 func TestGetGroupByName(t *testing.T) {
 	tests := map[string]struct {
 		groupname string
 
-		sourceDB string
+		sourceDB              string
+		flattenedGroupMembers bool
+		inlineMembersLimit    int
 
 		wantErr          bool
 		wantErrNotExists bool
 	}{
-		"Return existing group": {groupname: "group1"},
+		"Return existing group":                        {groupname: "group1"},
+		"Return existing group without nested members": {groupname: "group1", sourceDB: "nested_groups.db.yaml"},
+		"Return existing group with nested members":    {groupname: "group1", sourceDB: "nested_groups.db.yaml", flattenedGroupMembers: true},
+		"Return truncated group above inline limit":    {groupname: "commongroup", inlineMembersLimit: 1},
+		"Return group at inline limit":                 {groupname: "commongroup", inlineMembersLimit: 2},
 
 		"Error in database fetched content":                      {groupname: "group1", sourceDB: "invalid.db.yaml", wantErr: true},
 		"Error with typed GRPC notfound code on unexisting user": {groupname: "does-not-exists", wantErr: true, wantErrNotExists: true},
@@ -150,7 +228,14 @@ func TestGetGroupByName(t *testing.T) {
 			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
 			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
 
-			client := newNSSClient(t, tc.sourceDB, false)
+			var opts []nss.Option
+			if tc.flattenedGroupMembers {
+				opts = append(opts, nss.WithFlattenedGroupMembers())
+			}
+			if tc.inlineMembersLimit != 0 {
+				opts = append(opts, nss.WithInlineGroupMembersLimit(tc.inlineMembersLimit))
+			}
+			client := newNSSClient(t, tc.sourceDB, false, opts...)
 
 			got, err := client.GetGroupByName(context.Background(), &authd.GetGroupByNameRequest{Name: tc.groupname})
 			requireExpectedResult(t, "GetGroupByName", got, err, tc.wantErr, tc.wantErrNotExists)
@@ -162,25 +247,49 @@ func TestGetGroupByGID(t *testing.T) {
 	tests := map[string]struct {
 		gid uint32
 
-		sourceDB string
+		sourceDB           string
+		authoritativeRange []uint32
+		idOffset           []int64
 
-		wantErr          bool
-		wantErrNotExists bool
+		wantErr            bool
+		wantErrNotExists   bool
+		wantErrOutOfRange  bool
+		wantErrUnavailable bool
 	}{
-		"Return existing group": {gid: 11111},
+		"Return existing group":                        {gid: 11111},
+		"Return existing group in authoritative range": {gid: 11111, authoritativeRange: []uint32{10000, 20000}},
+		"Return existing group by its shifted gid":     {gid: 211111, idOffset: []int64{100000, 200000}},
 
-		"Error in database fetched content":                      {gid: 1111, sourceDB: "invalid.db.yaml", wantErr: true},
-		"Error with typed GRPC notfound code on unexisting user": {gid: 4242, wantErr: true, wantErrNotExists: true},
-		"Error on missing uid":                                   {wantErr: true},
+		"Error with typed GRPC outofrange code on gid not managed by authd": {gid: 11111, authoritativeRange: []uint32{20000, 30000}, wantErr: true, wantErrOutOfRange: true},
+
+		"Error in database fetched content":                               {gid: 1111, sourceDB: "invalid.db.yaml", wantErr: true},
+		"Error with typed GRPC unavailable code on inconsistent database": {gid: 11111, sourceDB: "group_without_members_record.db.yaml", wantErr: true, wantErrUnavailable: true},
+		"Error with typed GRPC notfound code on unexisting user":          {gid: 4242, wantErr: true, wantErrNotExists: true},
+		"Error with typed GRPC notfound code on unshifted gid":            {gid: 11111, idOffset: []int64{100000, 200000}, wantErr: true, wantErrNotExists: true},
+		"Error on missing uid":                                            {wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
 			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
 
-			client := newNSSClient(t, tc.sourceDB, false)
+			var args []nss.Option
+			if tc.authoritativeRange != nil {
+				args = append(args, nss.WithAuthoritativeRange(tc.authoritativeRange[0], tc.authoritativeRange[1]))
+			}
+			if tc.idOffset != nil {
+				args = append(args, nss.WithIDOffset(tc.idOffset[0], tc.idOffset[1]))
+			}
+			client := newNSSClient(t, tc.sourceDB, false, args...)
 
 			got, err := client.GetGroupByGID(context.Background(), &authd.GetByIDRequest{Id: tc.gid})
+			if tc.wantErrOutOfRange {
+				require.Equal(t, codes.OutOfRange, status.Code(err), "GetGroupByGID should return OutOfRange error")
+				return
+			}
+			if tc.wantErrUnavailable {
+				require.Equal(t, codes.Unavailable, status.Code(err), "GetGroupByGID should return Unavailable error")
+			}
 			requireExpectedResult(t, "GetGroupByGID", got, err, tc.wantErr, tc.wantErrNotExists)
 		})
 	}
@@ -188,12 +297,19 @@ func TestGetGroupByGID(t *testing.T) {
 
 func TestGetGroupEntries(t *testing.T) {
 	tests := map[string]struct {
-		sourceDB string
+		sourceDB              string
+		snapshotEnumeration   bool
+		flattenedGroupMembers bool
 
 		wantErr bool
 	}{
-		"Return all groups": {},
-		"Return no groups":  {sourceDB: "empty.db.yaml"},
+		"Return all groups":                     {},
+		"Return all groups from a snapshot":     {snapshotEnumeration: true},
+		"Return all groups with nested members": {sourceDB: "nested_groups.db.yaml", flattenedGroupMembers: true},
+		"Return no groups":                      {sourceDB: "empty.db.yaml"},
+		"Return all groups with nested members from a snapshot": {
+			sourceDB: "nested_groups.db.yaml", snapshotEnumeration: true, flattenedGroupMembers: true,
+		},
 
 		"Error in database fetched content": {sourceDB: "invalid.db.yaml", wantErr: true},
 	}
@@ -202,7 +318,14 @@ func TestGetGroupEntries(t *testing.T) {
 			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
 			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
 
-			client := newNSSClient(t, tc.sourceDB, false)
+			var opts []nss.Option
+			if tc.snapshotEnumeration {
+				opts = append(opts, nss.WithSnapshotEnumeration())
+			}
+			if tc.flattenedGroupMembers {
+				opts = append(opts, nss.WithFlattenedGroupMembers())
+			}
+			client := newNSSClient(t, tc.sourceDB, false, opts...)
 
 			got, err := client.GetGroupEntries(context.Background(), &authd.Empty{})
 			requireExpectedEntriesResult(t, "GetGroupEntries", got.GetEntries(), err, tc.wantErr)
@@ -210,31 +333,193 @@ func TestGetGroupEntries(t *testing.T) {
 	}
 }
 
-func TestGetShadowByName(t *testing.T) {
+func TestGetGroupEntriesStream(t *testing.T) {
 	tests := map[string]struct {
-		username string
+		pageSize              uint32
+		sourceDB              string
+		flattenedGroupMembers bool
 
-		sourceDB           string
-		currentUserNotRoot bool
+		wantPages int
+		wantErr   bool
+	}{
+		"Return all groups in a single page":  {wantPages: 1},
+		"Return all groups in multiple pages": {pageSize: 3, wantPages: 2},
+		"Return all groups with nested members": {
+			sourceDB: "nested_groups.db.yaml", flattenedGroupMembers: true, wantPages: 1,
+		},
+		"Return no groups": {sourceDB: "empty.db.yaml"},
+
+		"Error in database fetched content": {sourceDB: "invalid.db.yaml", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
+			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
+
+			var opts []nss.Option
+			if tc.flattenedGroupMembers {
+				opts = append(opts, nss.WithFlattenedGroupMembers())
+			}
+			client := newNSSClient(t, tc.sourceDB, false, opts...)
+
+			stream, err := client.GetGroupEntriesStream(context.Background(), &authd.PageRequest{PageSize: tc.pageSize})
+			require.NoError(t, err, "Setup: could not start stream")
+
+			got, pages, err := recvAllPages[authd.GroupEntry](stream.Recv)
+			requireExpectedEntriesResult(t, "GetGroupEntriesStream", got, err, tc.wantErr)
+			require.Equal(t, tc.wantPages, pages, "GetGroupEntriesStream should return the expected number of pages")
+		})
+	}
+}
+
+func TestStreamGroupMembers(t *testing.T) {
+	tests := map[string]struct {
+		groupname string
+
+		sourceDB              string
+		flattenedGroupMembers bool
 
 		wantErr          bool
 		wantErrNotExists bool
 	}{
-		"Return existing user": {username: "user1"},
+		"Return members of existing group":                     {groupname: "commongroup"},
+		"Return members of existing group with nested members": {groupname: "group1", sourceDB: "nested_groups.db.yaml", flattenedGroupMembers: true},
 
-		"Error when not root":                                    {currentUserNotRoot: true, username: "user1", wantErr: true},
-		"Error in database fetched content":                      {username: "user1", sourceDB: "invalid.db.yaml", wantErr: true},
-		"Error with typed GRPC notfound code on unexisting user": {username: "does-not-exists", wantErr: true, wantErrNotExists: true},
-		"Error on missing name":                                  {wantErr: true},
+		"Error in database fetched content":                       {groupname: "group1", sourceDB: "invalid.db.yaml", wantErr: true},
+		"Error with typed GRPC notfound code on unexisting group": {groupname: "does-not-exists", wantErr: true, wantErrNotExists: true},
+		"Error on missing name":                                   {wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
 			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
 
-			client := newNSSClient(t, tc.sourceDB, tc.currentUserNotRoot)
+			var opts []nss.Option
+			if tc.flattenedGroupMembers {
+				opts = append(opts, nss.WithFlattenedGroupMembers())
+			}
+			client := newNSSClient(t, tc.sourceDB, false, opts...)
+
+			stream, err := client.StreamGroupMembers(context.Background(), &authd.GetGroupByNameRequest{Name: tc.groupname})
+			require.NoError(t, err, "Setup: could not start stream")
+
+			var got []string
+			for {
+				var m *authd.GroupMembers
+				m, err = stream.Recv()
+				if err != nil {
+					break
+				}
+				got = append(got, m.GetMembers()...)
+			}
+			if tc.wantErr {
+				require.NotErrorIs(t, err, io.EOF, "StreamGroupMembers should return an error but did not")
+				s, ok := status.FromError(err)
+				require.True(t, ok, "The error is always a GRPC error")
+				if tc.wantErrNotExists {
+					require.Equal(t, codes.NotFound, s.Code(), "StreamGroupMembers should return NotFound error")
+				}
+				return
+			}
+			require.ErrorIs(t, err, io.EOF, "StreamGroupMembers should end without error")
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "StreamGroupMembers should return the expected members, but did not")
+		})
+	}
+}
+
+func TestGetGroupsByUID(t *testing.T) {
+	tests := map[string]struct {
+		uid uint32
+
+		sourceDB              string
+		authoritativeRange    []uint32
+		idOffset              []int64
+		flattenedGroupMembers bool
+
+		wantErr           bool
+		wantErrNotExists  bool
+		wantErrOutOfRange bool
+	}{
+		"Return groups of user":                        {uid: 2222},
+		"Return only primary group of user":            {uid: 1111},
+		"Return groups of user in authoritative range": {uid: 2222, authoritativeRange: []uint32{1000, 3000}},
+		"Return groups of user by its shifted uid":     {uid: 102222, idOffset: []int64{100000, 200000}},
+		"Return groups of user with nested members":    {uid: 2222, sourceDB: "nested_groups.db.yaml", flattenedGroupMembers: true},
+
+		"Error with typed GRPC outofrange code on uid not managed by authd": {uid: 2222, authoritativeRange: []uint32{3000, 4000}, wantErr: true, wantErrOutOfRange: true},
+
+		"Error in database fetched content":                      {uid: 1111, sourceDB: "invalid.db.yaml", wantErr: true},
+		"Error with typed GRPC notfound code on unexisting user": {uid: 4242, wantErr: true, wantErrNotExists: true},
+		"Error with typed GRPC notfound code on unshifted uid":   {uid: 2222, idOffset: []int64{100000, 200000}, wantErr: true, wantErrNotExists: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
+			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
+
+			var args []nss.Option
+			if tc.authoritativeRange != nil {
+				args = append(args, nss.WithAuthoritativeRange(tc.authoritativeRange[0], tc.authoritativeRange[1]))
+			}
+			if tc.idOffset != nil {
+				args = append(args, nss.WithIDOffset(tc.idOffset[0], tc.idOffset[1]))
+			}
+			if tc.flattenedGroupMembers {
+				args = append(args, nss.WithFlattenedGroupMembers())
+			}
+			client := newNSSClient(t, tc.sourceDB, false, args...)
+
+			got, err := client.GetGroupsByUID(context.Background(), &authd.GetByIDRequest{Id: tc.uid})
+			if tc.wantErrOutOfRange {
+				require.Equal(t, codes.OutOfRange, status.Code(err), "GetGroupsByUID should return OutOfRange error")
+				return
+			}
+			if tc.wantErrNotExists {
+				require.Equal(t, codes.NotFound, status.Code(err), "GetGroupsByUID should return NotFound error")
+				return
+			}
+			requireExpectedEntriesResult(t, "GetGroupsByUID", got.GetEntries(), err, tc.wantErr)
+		})
+	}
+}
+
+func TestGetShadowByName(t *testing.T) {
+	tests := map[string]struct {
+		username string
+
+		sourceDB            string
+		currentUserNotRoot  bool
+		currentUserInShadow bool
+
+		wantErr              bool
+		wantErrNotExists     bool
+		wantPermissionDenied bool
+	}{
+		"Return existing user":                                 {username: "user1"},
+		"Return existing user with maximum of days":            {username: "user1", sourceDB: "out_of_range_shadow.db.yaml"},
+		"Return locked password of disabled user":              {username: "user1", sourceDB: "disabled_user.db.yaml"},
+		"Return existing user to a member of the shadow group": {currentUserNotRoot: true, currentUserInShadow: true, username: "user1"},
+
+		"Error with typed GRPC permission denied code when not root": {currentUserNotRoot: true, username: "user1", wantErr: true, wantPermissionDenied: true},
+		"Error in database fetched content":                          {username: "user1", sourceDB: "invalid.db.yaml", wantErr: true},
+		"Error with typed GRPC notfound code on unexisting user":     {username: "does-not-exists", wantErr: true, wantErrNotExists: true},
+		"Error with typed GRPC notfound code on too many days":       {username: "user2", sourceDB: "out_of_range_shadow.db.yaml", wantErr: true, wantErrNotExists: true},
+		"Error with typed GRPC notfound code on too few days":        {username: "user3", sourceDB: "out_of_range_shadow.db.yaml", wantErr: true, wantErrNotExists: true},
+		"Error on missing name":                                      {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
+			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
+
+			client := newNSSClientWithPermissions(t, tc.sourceDB, shadowPermissionOptions(tc.currentUserNotRoot, tc.currentUserInShadow))
 
 			got, err := client.GetShadowByName(context.Background(), &authd.GetShadowByNameRequest{Name: tc.username})
+			if tc.wantPermissionDenied {
+				require.Equal(t, codes.PermissionDenied, status.Code(err), "GetShadowByName should return PermissionDenied error")
+			}
 			requireExpectedResult(t, "GetShadowByName", got, err, tc.wantErr, tc.wantErrNotExists)
 		})
 	}
@@ -242,36 +527,173 @@ func TestGetShadowByName(t *testing.T) {
 
 func TestGetShadowEntries(t *testing.T) {
 	tests := map[string]struct {
-		sourceDB           string
-		currentUserNotRoot bool
+		sourceDB            string
+		currentUserNotRoot  bool
+		currentUserInShadow bool
 
-		wantErr bool
+		wantErr              bool
+		wantPermissionDenied bool
 	}{
 		"Return all users": {},
 		"Return no users":  {sourceDB: "empty.db.yaml"},
+		"Return only users with valid number of days":      {sourceDB: "out_of_range_shadow.db.yaml"},
+		"Return all users to a member of the shadow group": {currentUserNotRoot: true, currentUserInShadow: true},
 
-		"Error when not root":               {currentUserNotRoot: true, wantErr: true},
-		"Error in database fetched content": {sourceDB: "invalid.db.yaml", wantErr: true},
+		"Error with typed GRPC permission denied code when not root": {currentUserNotRoot: true, wantErr: true, wantPermissionDenied: true},
+		"Error in database fetched content":                          {sourceDB: "invalid.db.yaml", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
 			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
 
-			client := newNSSClient(t, tc.sourceDB, tc.currentUserNotRoot)
+			client := newNSSClientWithPermissions(t, tc.sourceDB, shadowPermissionOptions(tc.currentUserNotRoot, tc.currentUserInShadow))
 
 			got, err := client.GetShadowEntries(context.Background(), &authd.Empty{})
+			if tc.wantPermissionDenied {
+				require.Equal(t, codes.PermissionDenied, status.Code(err), "GetShadowEntries should return PermissionDenied error")
+			}
 			requireExpectedEntriesResult(t, "GetShadowEntries", got.GetEntries(), err, tc.wantErr)
 		})
 	}
 }
 
+func TestGetShadowEntriesStream(t *testing.T) {
+	tests := map[string]struct {
+		pageSize            uint32
+		sourceDB            string
+		currentUserNotRoot  bool
+		currentUserInShadow bool
+
+		wantPages            int
+		wantErr              bool
+		wantPermissionDenied bool
+	}{
+		"Return all users in a single page":                {wantPages: 1},
+		"Return all users in multiple pages":               {pageSize: 2, wantPages: 2},
+		"Return only users with valid number of days":      {sourceDB: "out_of_range_shadow.db.yaml", wantPages: 1},
+		"Return all users to a member of the shadow group": {currentUserNotRoot: true, currentUserInShadow: true, wantPages: 1},
+
+		"Error with typed GRPC permission denied code when not root": {currentUserNotRoot: true, wantErr: true, wantPermissionDenied: true},
+		"Error in database fetched content":                          {sourceDB: "invalid.db.yaml", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
+			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
+
+			client := newNSSClientWithPermissions(t, tc.sourceDB, shadowPermissionOptions(tc.currentUserNotRoot, tc.currentUserInShadow))
+
+			stream, err := client.GetShadowEntriesStream(context.Background(), &authd.PageRequest{PageSize: tc.pageSize})
+			require.NoError(t, err, "Setup: could not start stream")
+
+			got, pages, err := recvAllPages[authd.ShadowEntry](stream.Recv)
+			if tc.wantPermissionDenied {
+				require.Equal(t, codes.PermissionDenied, status.Code(err), "GetShadowEntriesStream should return PermissionDenied error")
+			}
+			requireExpectedEntriesResult(t, "GetShadowEntriesStream", got, err, tc.wantErr)
+			require.Equal(t, tc.wantPages, pages, "GetShadowEntriesStream should return the expected number of pages")
+		})
+	}
+}
+
+func TestGetGShadowByName(t *testing.T) {
+	tests := map[string]struct {
+		groupname string
+
+		sourceDB              string
+		currentUserNotRoot    bool
+		currentUserInShadow   bool
+		flattenedGroupMembers bool
+
+		wantErr              bool
+		wantErrNotExists     bool
+		wantPermissionDenied bool
+	}{
+		"Return existing group":                                 {groupname: "group1"},
+		"Return existing group to a member of the shadow group": {currentUserNotRoot: true, currentUserInShadow: true, groupname: "group1"},
+		"Return existing group with nested members":             {groupname: "group1", sourceDB: "nested_groups.db.yaml", flattenedGroupMembers: true},
+
+		"Error with typed GRPC permission denied code when not root": {currentUserNotRoot: true, groupname: "group1", wantErr: true, wantPermissionDenied: true},
+		"Error in database fetched content":                          {groupname: "group1", sourceDB: "invalid.db.yaml", wantErr: true},
+		"Error with typed GRPC notfound code on unexisting group":    {groupname: "does-not-exists", wantErr: true, wantErrNotExists: true},
+		"Error on missing name":                                      {wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
+			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
+
+			var opts []nss.Option
+			if tc.flattenedGroupMembers {
+				opts = append(opts, nss.WithFlattenedGroupMembers())
+			}
+			client := newNSSClientWithPermissions(t, tc.sourceDB, shadowPermissionOptions(tc.currentUserNotRoot, tc.currentUserInShadow), opts...)
+
+			got, err := client.GetGShadowByName(context.Background(), &authd.GetGShadowByNameRequest{Name: tc.groupname})
+			if tc.wantPermissionDenied {
+				require.Equal(t, codes.PermissionDenied, status.Code(err), "GetGShadowByName should return PermissionDenied error")
+			}
+			requireExpectedResult(t, "GetGShadowByName", got, err, tc.wantErr, tc.wantErrNotExists)
+		})
+	}
+}
+
+func TestGetGShadowEntries(t *testing.T) {
+	tests := map[string]struct {
+		sourceDB              string
+		currentUserNotRoot    bool
+		currentUserInShadow   bool
+		flattenedGroupMembers bool
+
+		wantErr              bool
+		wantPermissionDenied bool
+	}{
+		"Return all groups": {},
+		"Return no groups":  {sourceDB: "empty.db.yaml"},
+		"Return all groups to a member of the shadow group": {currentUserNotRoot: true, currentUserInShadow: true},
+		"Return all groups with nested members":             {sourceDB: "nested_groups.db.yaml", flattenedGroupMembers: true},
+
+		"Error with typed GRPC permission denied code when not root": {currentUserNotRoot: true, wantErr: true, wantPermissionDenied: true},
+		"Error in database fetched content":                          {sourceDB: "invalid.db.yaml", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about gpasswd output here as it's already covered in the cache unit tests.
+			_ = localgroupstestutils.SetupGPasswdMock(t, filepath.Join("testdata", "empty.group"))
+
+			var opts []nss.Option
+			if tc.flattenedGroupMembers {
+				opts = append(opts, nss.WithFlattenedGroupMembers())
+			}
+			client := newNSSClientWithPermissions(t, tc.sourceDB, shadowPermissionOptions(tc.currentUserNotRoot, tc.currentUserInShadow), opts...)
+
+			got, err := client.GetGShadowEntries(context.Background(), &authd.Empty{})
+			if tc.wantPermissionDenied {
+				require.Equal(t, codes.PermissionDenied, status.Code(err), "GetGShadowEntries should return PermissionDenied error")
+			}
+			requireExpectedEntriesResult(t, "GetGShadowEntries", got.GetEntries(), err, tc.wantErr)
+		})
+	}
+}
+
 func TestMockgpasswd(t *testing.T) {
 	localgroupstestutils.Mockgpasswd(t)
 }
 
 // newNSSClient returns a new GRPC PAM client for tests with the provided sourceDB as its initial cache.
-func newNSSClient(t *testing.T, sourceDB string, currentUserNotRoot bool) (client authd.NSSClient) {
+func newNSSClient(t *testing.T, sourceDB string, currentUserNotRoot bool, args ...nss.Option) (client authd.NSSClient) {
+	t.Helper()
+
+	var opts []permissions.Option
+	if !currentUserNotRoot {
+		opts = append(opts, permissionstestutils.WithCurrentUserAsRoot())
+	}
+	return newNSSClientWithPermissions(t, sourceDB, opts, args...)
+}
+
+// newNSSClientWithPermissions returns a NSS client to a service whose permission manager is created with pmOpts.
+func newNSSClientWithPermissions(t *testing.T, sourceDB string, pmOpts []permissions.Option, args ...nss.Option) (client authd.NSSClient) {
 	t.Helper()
 
 	// socket path is limited in length.
@@ -283,13 +705,9 @@ func newNSSClient(t *testing.T, sourceDB string, currentUserNotRoot bool) (clien
 	lis, err := net.Listen("unix", socketPath)
 	require.NoError(t, err, "Setup: could not create unix socket")
 
-	var opts []permissions.Option
-	if !currentUserNotRoot {
-		opts = append(opts, permissionstestutils.WithCurrentUserAsRoot())
-	}
-	pm := permissions.New(opts...)
+	pm := permissions.New(pmOpts...)
 
-	service := nss.NewService(context.Background(), newUserManagerForTests(t, sourceDB), newBrokersManagerForTests(t), &pm)
+	service := nss.NewService(context.Background(), newUserManagerForTests(t, sourceDB), newBrokersManagerForTests(t), &pm, args...)
 
 	grpcServer := grpc.NewServer(permissions.WithUnixPeerCreds(), grpc.ChainUnaryInterceptor(enableCheckGlobalAccess(service), errmessages.RedactErrorInterceptor))
 	authd.RegisterNSSServer(grpcServer, service)
@@ -311,6 +729,18 @@ func newNSSClient(t *testing.T, sourceDB string, currentUserNotRoot bool) (clien
 	return authd.NewNSSClient(conn)
 }
 
+// shadowPermissionOptions returns the options of a permission manager for which the current user is root, unless
+// currentUserNotRoot is set, and in the shadow group if currentUserInShadow is set.
+func shadowPermissionOptions(currentUserNotRoot, currentUserInShadow bool) (opts []permissions.Option) {
+	if !currentUserNotRoot {
+		opts = append(opts, permissionstestutils.WithCurrentUserAsRoot())
+	}
+	if currentUserInShadow {
+		opts = append(opts, permissionstestutils.WithCurrentUserInShadowGroup())
+	}
+	return opts
+}
+
 func enableCheckGlobalAccess(s nss.Service) grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		if err := s.CheckGlobalAccess(ctx, info.FullMethod); err != nil {
@@ -354,7 +784,7 @@ func newBrokersManagerForTests(t *testing.T) *brokers.Manager {
 }
 
 // requireExpectedResult asserts expected behaviour from any get* NSS requests and can update them from golden content.
-func requireExpectedResult[T authd.PasswdEntry | authd.GroupEntry | authd.ShadowEntry](t *testing.T, funcName string, got *T, err error, wantErr, wantErrNotExists bool) {
+func requireExpectedResult[T authd.PasswdEntry | authd.GroupEntry | authd.ShadowEntry | authd.GShadowEntry](t *testing.T, funcName string, got *T, err error, wantErr, wantErrNotExists bool) {
 	t.Helper()
 
 	if wantErr {
@@ -373,7 +803,7 @@ func requireExpectedResult[T authd.PasswdEntry | authd.GroupEntry | authd.Shadow
 }
 
 // requireExpectedEntriesResult asserts expected behaviour from any get* NSS request returning a list and can update them from golden content.
-func requireExpectedEntriesResult[T authd.PasswdEntry | authd.GroupEntry | authd.ShadowEntry](t *testing.T, funcName string, got []*T, err error, wantErr bool) {
+func requireExpectedEntriesResult[T authd.PasswdEntry | authd.GroupEntry | authd.ShadowEntry | authd.GShadowEntry](t *testing.T, funcName string, got []*T, err error, wantErr bool) {
 	t.Helper()
 
 	if wantErr {
@@ -394,10 +824,26 @@ func requireExpectedEntriesResult[T authd.PasswdEntry | authd.GroupEntry | authd
 	}
 }
 
+// recvAllPages receives the pages of a streaming enumeration until it ends, and returns their entries and the number
+// of pages received.
+func recvAllPages[T any, P interface{ GetEntries() []*T }](recv func() (P, error)) (entries []*T, pages int, err error) {
+	for {
+		p, err := recv()
+		if errors.Is(err, io.EOF) {
+			return entries, pages, nil
+		}
+		if err != nil {
+			return nil, pages, err
+		}
+		pages++
+		entries = append(entries, p.GetEntries()...)
+	}
+}
+
 // requireExportedEquals compare *want to *got, only using the exported fields.
 // It helps ensuring that we don’t end up in a lockcopy vetting warning when we directly
 // compare the exported fields with require.EqualExportedValues.
-func requireExportedEquals[T authd.PasswdEntry | authd.GroupEntry | authd.ShadowEntry](t *testing.T, want *T, got *T, msg string) {
+func requireExportedEquals[T authd.PasswdEntry | authd.GroupEntry | authd.ShadowEntry | authd.GShadowEntry](t *testing.T, want *T, got *T, msg string) {
 	t.Helper()
 
 	data, err := yaml.Marshal(got)
@@ -41,11 +41,17 @@ func NewService(ctx context.Context, userManager *users.Manager, brokerManager *
 	}
 }
 
-// AvailableBrokers returns the list of all brokers with their details.
+// AvailableBrokers returns the list of all healthy brokers with their details.
 func (s Service) AvailableBrokers(ctx context.Context, _ *authd.Empty) (*authd.ABResponse, error) {
 	var r authd.ABResponse
 
-	for _, b := range s.brokerManager.AvailableBrokers() {
+	for _, status := range s.brokerManager.AvailableBrokersWithStatus() {
+		// Brokers which don't answer the health checks are hidden until they answer again.
+		if !status.Healthy {
+			log.Debugf(ctx, "Hiding unhealthy broker %q", status.Broker.Name)
+			continue
+		}
+		b := status.Broker
 		r.BrokersInfos = append(r.BrokersInfos, &authd.ABResponse_BrokerInfo{
 			Id:        b.ID,
 			Name:      b.Name,
@@ -118,9 +124,6 @@ func (s Service) SelectBroker(ctx context.Context, req *authd.SBRequest) (resp *
 	if username == "" {
 		return nil, status.Error(codes.InvalidArgument, "no user name provided")
 	}
-	if brokerID == "" {
-		return nil, status.Error(codes.InvalidArgument, "no broker selected")
-	}
 	if lang == "" {
 		lang = "C"
 	}
@@ -135,15 +138,25 @@ func (s Service) SelectBroker(ctx context.Context, req *authd.SBRequest) (resp *
 		return nil, status.Error(codes.InvalidArgument, "invalid session mode")
 	}
 
-	// Create a session and Memorize selected broker for it.
-	sessionID, encryptionKey, err := s.brokerManager.NewSession(brokerID, username, lang, mode)
+	// Create a session and Memorize selected broker for it. If no broker is selected, authd picks the one of the user.
+	// Break-glass users are routed to the local broker, for which no session is created.
+	// The resumption token can be passed once to ResumeSession to reconnect to the session.
+	// The default broker is remembered per terminal, so that the selection of a user doesn't become the default of
+	// the next one on another terminal.
+	// The session context, like the remote host of the client, is passed to the broker.
+	broker, sessionID, key, resumptionToken, err := s.brokerManager.NewSessionForUser(ctx, brokerID, username, lang, mode,
+		brokers.WithContextKey(req.GetTty()), brokers.WithSessionContext(req.GetSessionContext()))
 	if err != nil {
 		return nil, err
 	}
 
 	return &authd.SBResponse{
-		SessionId:     sessionID,
-		EncryptionKey: encryptionKey,
+		BrokerId:        broker.ID,
+		SessionId:       sessionID,
+		EncryptionKey:   key.Key,
+		Algorithm:       key.Algorithm,
+		Encoding:        key.Encoding,
+		ResumptionToken: resumptionToken,
 	}, err
 }
 
@@ -155,6 +168,7 @@ func (s Service) GetAuthenticationModes(ctx context.Context, req *authd.GAMReque
 	if sessionID == "" {
 		return nil, status.Error(codes.InvalidArgument, "no session ID provided")
 	}
+	ctx = log.WithAttrs(ctx, "session_id", sessionID)
 
 	broker, err := s.brokerManager.BrokerFromSessionID(sessionID)
 	if err != nil {
@@ -201,6 +215,7 @@ func (s Service) SelectAuthenticationMode(ctx context.Context, req *authd.SAMReq
 	if authenticationModeID == "" {
 		return nil, status.Error(codes.InvalidArgument, "no authentication mode provided")
 	}
+	ctx = log.WithAttrs(ctx, "session_id", sessionID)
 
 	broker, err := s.brokerManager.BrokerFromSessionID(sessionID)
 	if err != nil {
@@ -225,6 +240,11 @@ func (s Service) IsAuthenticated(ctx context.Context, req *authd.IARequest) (res
 	if sessionID == "" {
 		return nil, status.Error(codes.InvalidArgument, "no session ID provided")
 	}
+	ctx = log.WithAttrs(ctx, "session_id", sessionID)
+	// The authentication results are logged with the context of the session, like where the user logs in from.
+	if sessionContext := s.brokerManager.SessionContext(sessionID); sessionContext != nil {
+		ctx = log.WithAttrs(ctx, "session_context", sessionContext)
+	}
 
 	broker, err := s.brokerManager.BrokerFromSessionID(sessionID)
 	if err != nil {
@@ -245,24 +265,63 @@ func (s Service) IsAuthenticated(ctx context.Context, req *authd.IARequest) (res
 
 	if access != brokers.AuthGranted {
 		return &authd.IAResponse{
-			Access: access,
-			Msg:    data,
+			Access:   access,
+			Msg:      data,
+			Username: broker.SessionUsername(sessionID),
 		}, nil
 	}
 
+	// The broker may have canonicalized the username of the session.
+	s.brokerManager.RefreshSessionUsername(ctx, sessionID)
+
 	var uInfo users.UserInfo
 	if err := json.Unmarshal([]byte(data), &uInfo); err != nil {
 		return nil, fmt.Errorf("user data from broker invalid: %v", err)
 	}
 
+	// Brokers don't know whether the user was disabled by an administrator.
+	if err := s.userManager.CheckAccountEnabled(uInfo.Name); errors.Is(err, users.ErrAccountDisabled) {
+		log.Infof(ctx, "%s: Denying login: %v", sessionID, err)
+		return &authd.IAResponse{
+			Access:   brokers.AuthDenied,
+			Msg:      `{"message": "Your account is disabled, please contact your system administrator"}`,
+			Username: broker.SessionUsername(sessionID),
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// Brokers don't know the shadow information of the user, which can expire their account.
+	if err := s.userManager.CheckAccountExpiration(uInfo.Name); errors.Is(err, users.ErrAccountExpired) {
+		log.Infof(ctx, "%s: Denying login: %v", sessionID, err)
+		return &authd.IAResponse{
+			Access:   brokers.AuthDenied,
+			Msg:      `{"message": "Your account has expired, please contact your system administrator"}`,
+			Username: broker.SessionUsername(sessionID),
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// New users of brokers declaring the UID range they are authoritative for get their UID in it. Brokers opting in
+	// to a strict range can't write any user outside of it.
+	var updateOpts []users.UpdateUserOption
+	if r, ok := broker.AuthoritativeUIDRange(); ok && broker.StrictUIDRange() {
+		updateOpts = append(updateOpts, users.WithAuthoritativeUIDRange(r.Min, r.Max))
+	} else if ok {
+		updateOpts = append(updateOpts, users.WithUIDRange(r.Min, r.Max))
+	}
+
 	// Update database and local groups on granted auth.
-	if err := s.userManager.UpdateUser(uInfo); err != nil {
+	if err := s.userManager.UpdateUser(uInfo, updateOpts...); err != nil {
 		return nil, err
 	}
 
+	// The caller should use the username canonicalized by the broker from now on, like we did for the user entry.
 	return &authd.IAResponse{
-		Access: access,
-		Msg:    "",
+		Access:   access,
+		Msg:      "",
+		Username: broker.SessionUsername(sessionID),
 	}, nil
 }
 
@@ -297,8 +356,32 @@ func (s Service) EndSession(ctx context.Context, req *authd.ESRequest) (empty *a
 	if sessionID == "" {
 		return nil, status.Error(codes.InvalidArgument, "no session id given")
 	}
+	ctx = log.WithAttrs(ctx, "session_id", sessionID)
 
-	return &authd.Empty{}, s.brokerManager.EndSession(sessionID)
+	return &authd.Empty{}, s.brokerManager.EndSession(ctx, sessionID)
+}
+
+// ResumeSession returns the session the resumption token was issued for by SelectBroker, so that a client can
+// reconnect to it. A token can only be used once.
+func (s Service) ResumeSession(ctx context.Context, req *authd.RSRequest) (resp *authd.RSResponse, err error) {
+	defer decorate.OnError(&err, "could not resume session")
+
+	token := req.GetResumptionToken()
+	if token == "" {
+		return nil, status.Error(codes.InvalidArgument, "no resumption token given")
+	}
+
+	sessionID, key, err := s.brokerManager.ResumeSession(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authd.RSResponse{
+		SessionId:     sessionID,
+		EncryptionKey: key.Key,
+		Algorithm:     key.Algorithm,
+		Encoding:      key.Encoding,
+	}, nil
 }
 
 func uiLayoutToMap(layout *authd.UILayout) (mapLayout map[string]string, err error) {
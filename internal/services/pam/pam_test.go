@@ -24,6 +24,7 @@ import (
 	permissionstestutils "github.com/ubuntu/authd/internal/services/permissions/testutils"
 	"github.com/ubuntu/authd/internal/testutils"
 	"github.com/ubuntu/authd/internal/users"
+	"github.com/ubuntu/authd/internal/users/cache"
 	cachetestutils "github.com/ubuntu/authd/internal/users/cache/testutils"
 	localgroupstestutils "github.com/ubuntu/authd/internal/users/localgroups/testutils"
 	userstestutils "github.com/ubuntu/authd/internal/users/testutils"
@@ -34,6 +35,8 @@ import (
 var (
 	globalBrokerManager   *brokers.Manager
 	mockBrokerGeneratedID string
+	// mockBrokerConfigPath is the configuration file of the mock broker.
+	mockBrokerConfigPath string
 )
 
 // Used for TestGetAuthenticationModes and TestSelectAuthenticationMode.
@@ -82,11 +85,13 @@ func TestAvailableBrokers(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		currentUserNotRoot bool
+		currentUserNotRoot  bool
+		withUnhealthyBroker bool
 
 		wantErr bool
 	}{
-		"Success getting available brokers": {},
+		"Success getting available brokers":          {},
+		"Unhealthy brokers are hidden from the list": {withUnhealthyBroker: true},
 
 		"Error when not root": {currentUserNotRoot: true, wantErr: true},
 	}
@@ -94,8 +99,25 @@ func TestAvailableBrokers(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			brokerManager := globalBrokerManager
+			if tc.withUnhealthyBroker {
+				brokersConfPath := t.TempDir()
+				mockConfig, err := os.ReadFile(mockBrokerConfigPath)
+				require.NoError(t, err, "Setup: could not read the configuration of the mock broker")
+				err = os.WriteFile(filepath.Join(brokersConfPath, filepath.Base(mockBrokerConfigPath)), mockConfig, 0600)
+				require.NoError(t, err, "Setup: could not write the configuration of the mock broker")
+				cfg := "[authd]\nname = NotRunning\nbrand_icon = icon.png\n" +
+					"dbus_name = com.ubuntu.authd.NotRunning\ndbus_object = /com/ubuntu/authd/NotRunning\n"
+				err = os.WriteFile(filepath.Join(brokersConfPath, "NotRunning.conf"), []byte(cfg), 0600)
+				require.NoError(t, err, "Setup: could not write the configuration of the broker which is not running")
+
+				brokerManager, err = brokers.NewManager(context.Background(), brokersConfPath, nil)
+				require.NoError(t, err, "Setup: could not create the broker manager")
+				brokerManager.HealthCheck(context.Background())
+			}
+
 			pm := newPermissionManager(t, tc.currentUserNotRoot)
-			client := newPamClient(t, nil, globalBrokerManager, &pm)
+			client := newPamClient(t, nil, brokerManager, &pm)
 
 			abResp, err := client.AvailableBrokers(context.Background(), &authd.Empty{})
 
@@ -187,25 +209,34 @@ func TestSelectBroker(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		brokerID    string
-		username    string
-		sessionMode string
+		brokerID       string
+		username       string
+		sessionMode    string
+		sessionContext map[string]string
 
+		previousBroker     bool
+		breakGlassUser     bool
 		currentUserNotRoot bool
 
 		wantErr bool
 	}{
-		"Successfully select a broker and creates auth session":   {username: "success"},
-		"Successfully select a broker and creates passwd session": {username: "success", sessionMode: "passwd"},
-
-		"Error when not root":                             {username: "success", currentUserNotRoot: true, wantErr: true},
-		"Error when username is empty":                    {wantErr: true},
-		"Error when mode is empty":                        {sessionMode: "-", wantErr: true},
-		"Error when mode does not exist":                  {sessionMode: "does not exist", wantErr: true},
-		"Error when brokerID is empty":                    {username: "empty broker", brokerID: "-", wantErr: true},
-		"Error when broker does not exist":                {username: "no broker", brokerID: "does not exist", wantErr: true},
-		"Error when broker does not provide a session ID": {username: "NS_no_id", wantErr: true},
-		"Error when starting the session":                 {username: "NS_error", wantErr: true},
+		"Successfully select a broker and creates auth session":                      {username: "success"},
+		"Successfully select a broker and creates passwd session":                    {username: "success", sessionMode: "passwd"},
+		"Successfully select the previous broker of the user when brokerID is empty": {username: "success", brokerID: "-", previousBroker: true},
+		"Successfully route break-glass users to the local broker":                   {username: "success", breakGlassUser: true},
+		"Successfully keep the session context of the client": {
+			username:       "success",
+			sessionContext: map[string]string{"rhost": "192.0.2.1"},
+		},
+
+		"Error when not root":                                              {username: "success", currentUserNotRoot: true, wantErr: true},
+		"Error when username is empty":                                     {wantErr: true},
+		"Error when mode is empty":                                         {sessionMode: "-", wantErr: true},
+		"Error when mode does not exist":                                   {sessionMode: "does not exist", wantErr: true},
+		"Error when brokerID is empty and the user has no previous broker": {username: "empty broker", brokerID: "-", wantErr: true},
+		"Error when broker does not exist":                                 {username: "no broker", brokerID: "does not exist", wantErr: true},
+		"Error when broker does not provide a session ID":                  {username: "NS_no_id", wantErr: true},
+		"Error when starting the session":                                  {username: "NS_error", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -225,6 +256,15 @@ func TestSelectBroker(t *testing.T) {
 				tc.username = t.Name() + testutils.IDSeparator + tc.username
 			}
 
+			if tc.previousBroker {
+				err := globalBrokerManager.SetDefaultBrokerForUser(mockBrokerGeneratedID, tc.username)
+				require.NoError(t, err, "Setup: could not set the default broker of the user")
+			}
+			if tc.breakGlassUser {
+				globalBrokerManager.SetBreakGlassUsers([]string{tc.username})
+				t.Cleanup(func() { globalBrokerManager.SetBreakGlassUsers(nil) })
+			}
+
 			var sessionMode authd.SessionMode
 			switch tc.sessionMode {
 			case "":
@@ -236,9 +276,11 @@ func TestSelectBroker(t *testing.T) {
 			}
 
 			sbRequest := &authd.SBRequest{
-				BrokerId: tc.brokerID,
-				Username: tc.username,
-				Mode:     sessionMode,
+				BrokerId:       tc.brokerID,
+				Username:       tc.username,
+				Mode:           sessionMode,
+				Tty:            t.Name(),
+				SessionContext: tc.sessionContext,
 			}
 			sbResp, err := client.SelectBroker(context.Background(), sbRequest)
 			if tc.wantErr {
@@ -246,10 +288,22 @@ func TestSelectBroker(t *testing.T) {
 				return
 			}
 			require.NoError(t, err, "SelectBroker should not return an error, but did")
-
-			got := fmt.Sprintf("ID: %s\nEncryption Key: %s\n",
-				strings.ReplaceAll(sbResp.GetSessionId(), tc.brokerID, "BROKER_ID"),
-				sbResp.GetEncryptionKey())
+			if tc.breakGlassUser {
+				require.Equal(t, brokers.LocalBrokerName, sbResp.GetBrokerId(), "SelectBroker should route break-glass users to the local broker")
+				require.Empty(t, sbResp.GetSessionId(), "SelectBroker should not create a session with the local broker")
+				return
+			}
+			endSessionOnCleanup(t, sbResp.GetSessionId())
+			require.Equal(t, mockBrokerGeneratedID, sbResp.GetBrokerId(), "SelectBroker should return the broker of the session")
+			require.Equal(t, mockBrokerGeneratedID, globalBrokerManager.DefaultBroker(t.Name()).ID,
+				"SelectBroker should remember the broker as the default one of the tty")
+			require.Equal(t, tc.sessionContext, globalBrokerManager.SessionContext(sbResp.GetSessionId()),
+				"SelectBroker should keep the session context of the client")
+
+			// The session ID is randomly generated.
+			require.NotEmpty(t, sbResp.GetSessionId(), "SelectBroker should return a session ID")
+			got := fmt.Sprintf("Encryption Key: %s\nAlgorithm: %s\nEncoding: %s\n",
+				sbResp.GetEncryptionKey(), sbResp.GetAlgorithm(), sbResp.GetEncoding())
 			want := testutils.LoadWithUpdateFromGolden(t, got)
 			require.Equal(t, want, got, "SelectBroker returned an unexpected response")
 		})
@@ -429,6 +483,7 @@ func TestIsAuthenticated(t *testing.T) {
 		"Denies authentication when broker times out":         {username: "IA_timeout"},
 		"Update existing DB on success":                       {username: "success", existingDB: "cache-with-user.db"},
 		"Update local groups":                                 {username: "success_with_local_groups", localGroupsFile: "valid.group"},
+		"Denies authentication of expired account":            {username: "success", existingDB: "cache-with-expired-user.db"},
 
 		// service errors
 		"Error when not root": {username: "success", currentUserNotRoot: true},
@@ -496,9 +551,10 @@ func TestIsAuthenticated(t *testing.T) {
 					AuthenticationData: &authd.IARequest_AuthenticationData{},
 				}
 				iaResp, err := client.IsAuthenticated(ctx, iaReq)
-				firstCall = fmt.Sprintf("FIRST CALL:\n\taccess: %s\n\tmsg: %s\n\terr: %v\n",
+				firstCall = fmt.Sprintf("FIRST CALL:\n\taccess: %s\n\tmsg: %s\n\tusername: %s\n\terr: %v\n",
 					iaResp.GetAccess(),
 					iaResp.GetMsg(),
+					iaResp.GetUsername(),
 					err,
 				)
 			}()
@@ -516,9 +572,10 @@ func TestIsAuthenticated(t *testing.T) {
 					AuthenticationData: &authd.IARequest_AuthenticationData{},
 				}
 				iaResp, err := client.IsAuthenticated(context.Background(), iaReq)
-				secondCall = fmt.Sprintf("SECOND CALL:\n\taccess: %s\n\tmsg: %s\n\terr: %v\n",
+				secondCall = fmt.Sprintf("SECOND CALL:\n\taccess: %s\n\tmsg: %s\n\tusername: %s\n\terr: %v\n",
 					iaResp.GetAccess(),
 					iaResp.GetMsg(),
+					iaResp.GetUsername(),
 					err,
 				)
 			}
@@ -566,6 +623,7 @@ func TestIDGeneration(t *testing.T) {
 				Mode:     authd.SessionMode_AUTH,
 			})
 			require.NoError(t, err, "Setup: failed to create session for tests")
+			endSessionOnCleanup(t, sbResp.GetSessionId())
 
 			resp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{SessionId: sbResp.GetSessionId()})
 			require.NoError(t, err, "Setup: could not authenticate user")
@@ -579,6 +637,80 @@ func TestIDGeneration(t *testing.T) {
 	}
 }
 
+func TestIsAuthenticatedWithUIDRange(t *testing.T) {
+	t.Parallel()
+
+	const minUID, maxUID = 100000, 199999
+
+	tests := map[string]struct {
+		existingUID uint32
+		strict      bool
+
+		wantErr bool
+	}{
+		"Generate the UID of new users in the range":                       {},
+		"Generate the UID of new users in the strict range":                {strict: true},
+		"Keep the UID of existing users outside of the range":              {existingUID: 1111},
+		"Keep the UID of existing users in the strict range":               {existingUID: 111111, strict: true},
+		"Error when the UID of existing users is outside the strict range": {existingUID: 1111, strict: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// A broker with the mock broker bus name, declaring an authoritative UID range.
+			cfg, err := os.ReadFile(mockBrokerConfigPath)
+			require.NoError(t, err, "Setup: could not read mock broker configuration")
+			cfg = append(cfg, fmt.Sprintf("\nauthoritative_uid_range = %d-%d\nauthoritative_uid_range_strict = %t\n", minUID, maxUID, tc.strict)...)
+			brokersConfPath := t.TempDir()
+			err = os.WriteFile(filepath.Join(brokersConfPath, filepath.Base(mockBrokerConfigPath)), cfg, 0600)
+			require.NoError(t, err, "Setup: could not write broker configuration")
+			brokerManager, err := brokers.NewManager(context.Background(), brokersConfPath, nil)
+			require.NoError(t, err, "Setup: could not create broker manager")
+			brokerID, err := getMockBrokerGeneratedID(brokerManager)
+			require.NoError(t, err, "Setup: could not get broker ID")
+
+			m, err := users.NewManager(users.DefaultConfig, t.TempDir())
+			require.NoError(t, err, "Setup: could not create user manager")
+			t.Cleanup(func() { _ = m.Stop() })
+			pm := newPermissionManager(t, false) // Allow starting the session (current user considered root)
+			client := newPamClient(t, m, brokerManager, &pm)
+
+			username := t.Name() + testutils.IDSeparator + "success"
+			if tc.existingUID != 0 {
+				err = userstestutils.GetManagerCache(m).UpdateUserEntry(
+					cache.NewUserDB(username, tc.existingUID, tc.existingUID, "", "/home/success", "/bin/sh"),
+					[]cache.GroupDB{cache.NewGroupDB(username, tc.existingUID, nil)})
+				require.NoError(t, err, "Setup: could not add existing user")
+			}
+
+			sbResp, err := client.SelectBroker(context.Background(), &authd.SBRequest{
+				BrokerId: brokerID,
+				Username: username,
+				Mode:     authd.SessionMode_AUTH,
+			})
+			require.NoError(t, err, "Setup: failed to create session for tests")
+
+			resp, err := client.IsAuthenticated(context.Background(), &authd.IARequest{SessionId: sbResp.GetSessionId()})
+			if tc.wantErr {
+				require.Error(t, err, "IsAuthenticated should return an error")
+				return
+			}
+			require.NoError(t, err, "IsAuthenticated should not return an error")
+			require.Equal(t, "granted", resp.GetAccess(), "Authentication should be granted")
+
+			u, err := m.UserByName(username)
+			require.NoError(t, err, "User should be in the cache after authentication")
+			if tc.existingUID != 0 {
+				require.Equal(t, tc.existingUID, u.UID, "Existing user should keep their UID")
+				return
+			}
+			require.GreaterOrEqual(t, u.UID, uint32(minUID), "New user should get a UID in the authoritative range")
+			require.LessOrEqual(t, u.UID, uint32(maxUID), "New user should get a UID in the authoritative range")
+		})
+	}
+}
+
 func TestSetDefaultBrokerForUser(t *testing.T) {
 	t.Parallel()
 
@@ -691,6 +823,67 @@ func TestEndSession(t *testing.T) {
 	}
 }
 
+func TestResumeSession(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		token string
+
+		username           string
+		currentUserNotRoot bool
+
+		wantErr bool
+	}{
+		"Successfully resume session": {username: "success"},
+
+		"Error when not root":                     {username: "success", currentUserNotRoot: true, wantErr: true},
+		"Error when token is empty":               {token: "-", wantErr: true},
+		"Error when token is invalid":             {token: "invalid-token", wantErr: true},
+		"Error when broker does not hold session": {username: "HS_lost", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			pm := newPermissionManager(t, false) // Allow starting the session (current user considered root)
+			client := newPamClient(t, nil, globalBrokerManager, &pm)
+
+			var sbResp *authd.SBResponse
+			switch tc.token {
+			case "invalid-token":
+			case "-":
+				tc.token = ""
+			default:
+				var err error
+				sbResp, err = client.SelectBroker(context.Background(), &authd.SBRequest{
+					BrokerId: mockBrokerGeneratedID,
+					Username: t.Name() + testutils.IDSeparator + tc.username,
+					Mode:     authd.SessionMode_AUTH,
+				})
+				require.NoError(t, err, "Setup: failed to create session for tests")
+				endSessionOnCleanup(t, sbResp.GetSessionId())
+				require.NotEmpty(t, sbResp.GetResumptionToken(), "SelectBroker should return a resumption token")
+				tc.token = sbResp.GetResumptionToken()
+			}
+
+			// Now, set tests permissions for this use case
+			permissionstestutils.SetCurrentUserAsRoot(&pm, !tc.currentUserNotRoot)
+
+			rsResp, err := client.ResumeSession(context.Background(), &authd.RSRequest{ResumptionToken: tc.token})
+			if tc.wantErr {
+				require.Error(t, err, "ResumeSession should return an error, but did not")
+				return
+			}
+			require.NoError(t, err, "ResumeSession should not return an error, but did")
+			require.Equal(t, sbResp.GetSessionId(), rsResp.GetSessionId(), "ResumeSession should return the session of the token")
+			require.Equal(t, sbResp.GetEncryptionKey(), rsResp.GetEncryptionKey(), "ResumeSession should return the encryption key of the session")
+
+			_, err = client.ResumeSession(context.Background(), &authd.RSRequest{ResumptionToken: tc.token})
+			require.Error(t, err, "ResumeSession should not accept a token twice")
+		})
+	}
+}
+
 func TestMockgpasswd(t *testing.T) {
 	localgroupstestutils.Mockgpasswd(t)
 }
@@ -707,7 +900,8 @@ func initBrokers() (brokerConfigPath string, cleanup func(), err error) {
 		_ = os.RemoveAll(tmpDir)
 		return "", nil, err
 	}
-	_, brokerCleanup, err := testutils.StartBusBrokerMock(brokersConfPath, "BrokerMock")
+	var brokerCleanup func()
+	mockBrokerConfigPath, brokerCleanup, err = testutils.StartBusBrokerMock(brokersConfPath, "BrokerMock")
 	if err != nil {
 		_ = os.RemoveAll(tmpDir)
 		return "", nil, err
@@ -809,9 +1003,19 @@ func startSession(t *testing.T, client authd.PAMClient, username string) string
 		Mode:     authd.SessionMode_AUTH,
 	})
 	require.NoError(t, err, "Setup: failed to create session for tests")
+	endSessionOnCleanup(t, sbResp.GetSessionId())
 	return sbResp.GetSessionId()
 }
 
+// endSessionOnCleanup ends the session on the global broker manager once the test is done, if the test didn't. The
+// mock broker generates the session ID from the username, so the session could not be started again otherwise when
+// running the tests multiple times.
+func endSessionOnCleanup(t *testing.T, sessionID string) {
+	t.Helper()
+
+	t.Cleanup(func() { _ = globalBrokerManager.EndSession(context.Background(), sessionID) })
+}
+
 func TestMain(m *testing.M) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "" {
 		os.Exit(m.Run())
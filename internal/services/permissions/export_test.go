@@ -1,13 +1,16 @@
 package permissions
 
+import "os"
+
 type PeerCredsInfo = peerCredsInfo
 
 //nolint:revive // This is a false positive as we returned a typed alias and not the private type.
 func NewTestPeerCredsInfo(uid uint32, pid int32) PeerCredsInfo {
-	return PeerCredsInfo{uid: uid, pid: pid}
+	return PeerCredsInfo{uid: uid, gid: uint32(os.Getgid()), pid: pid}
 }
 
 var (
-	CurrentUserUID        = currentUserUID
-	WithCurrentUserAsRoot = withCurrentUserAsRoot
+	CurrentUserUID               = currentUserUID
+	WithCurrentUserAsRoot        = withCurrentUserAsRoot
+	WithCurrentUserInShadowGroup = withCurrentUserInShadowGroup
 )
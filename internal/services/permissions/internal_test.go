@@ -63,6 +63,14 @@ func TestServerPeerCredsHandshake(t *testing.T) {
 	uid := currentUserUID()
 	require.Equal(t, fmt.Sprintf("uid: %d, pid: %d", uid, os.Getpid()),
 		i.AuthType(), "uid or pid received doesn't match what we expected")
+	gids, err := os.Getgroups()
+	require.NoError(t, err, "Setup: could not get supplementary groups")
+	wantGroups := make([]uint32, 0, len(gids))
+	for _, gid := range gids {
+		//nolint:gosec // GIDs are uint32 on Linux.
+		wantGroups = append(wantGroups, uint32(gid))
+	}
+	require.ElementsMatch(t, wantGroups, i.(peerCredsInfo).groups, "supplementary groups received don't match what we expected")
 
 	// ClientHandshake status check.
 	c, i, err = s.ClientHandshake(context.Background(), "unused", conn)
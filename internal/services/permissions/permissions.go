@@ -5,24 +5,35 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os/user"
+	"slices"
+	"strconv"
 
+	"github.com/ubuntu/authd/internal/log"
 	"github.com/ubuntu/decorate"
 	"google.golang.org/grpc/peer"
 )
 
 var permErrorFmt = "this action is only allowed for root users. Current user is %d"
 
+var shadowPermErrorFmt = "this action is only allowed for root users and members of the shadow group. Current user is %d"
+
 // Manager is an abstraction of permission process.
 type Manager struct {
 	rootUID uint32
+
+	shadowGID      uint32
+	hasShadowGroup bool
 }
 
 type options struct {
-	rootUID uint32
+	rootUID     uint32
+	shadowGroup string
 }
 
 var defaultOptions = options{
-	rootUID: 0,
+	rootUID:     0,
+	shadowGroup: "shadow",
 }
 
 // Option represents an optional function to override Manager default values.
@@ -35,10 +46,25 @@ func New(args ...Option) Manager {
 		arg(&opts)
 	}
 
-	//nolint:gosimple // S1016 Those structs are not the same conceptually.
-	return Manager{
+	m := Manager{
 		rootUID: opts.rootUID,
 	}
+
+	// Only root can read shadow entries if there is no shadow group.
+	g, err := user.LookupGroup(opts.shadowGroup)
+	if err != nil {
+		log.Debugf(context.Background(), "No shadow group %q, only root can read shadow entries: %v", opts.shadowGroup, err)
+		return m
+	}
+	gid, err := strconv.ParseUint(g.Gid, 10, 32)
+	if err != nil {
+		log.Warningf(context.Background(), "Invalid GID %q of shadow group %q: %v", g.Gid, opts.shadowGroup, err)
+		return m
+	}
+	//nolint:gosec // ParseUint checked that the value fits in an uint32.
+	m.shadowGID, m.hasShadowGroup = uint32(gid), true
+
+	return m
 }
 
 // IsRequestFromRoot returns nil if the request was performed by a root user.
@@ -46,13 +72,9 @@ func New(args ...Option) Manager {
 func (m Manager) IsRequestFromRoot(ctx context.Context) (err error) {
 	defer decorate.OnError(&err, "permission denied")
 
-	p, ok := peer.FromContext(ctx)
-	if !ok {
-		return errors.New("context request doesn't have grpc peer information")
-	}
-	pci, ok := p.AuthInfo.(peerCredsInfo)
-	if !ok {
-		return errors.New("context request doesn't have valid grpc peer credential information")
+	pci, err := peerCreds(ctx)
+	if err != nil {
+		return err
 	}
 
 	if pci.uid != m.rootUID {
@@ -61,3 +83,36 @@ func (m Manager) IsRequestFromRoot(ctx context.Context) (err error) {
 
 	return nil
 }
+
+// IsRequestFromShadowReader returns nil if the request was performed by a root user, or by a member of the shadow
+// group, which are the users allowed to read shadow entries.
+func (m Manager) IsRequestFromShadowReader(ctx context.Context) (err error) {
+	defer decorate.OnError(&err, "permission denied")
+
+	pci, err := peerCreds(ctx)
+	if err != nil {
+		return err
+	}
+
+	if pci.uid == m.rootUID {
+		return nil
+	}
+	if m.hasShadowGroup && (pci.gid == m.shadowGID || slices.Contains(pci.groups, m.shadowGID)) {
+		return nil
+	}
+
+	return fmt.Errorf(shadowPermErrorFmt, pci.uid)
+}
+
+// peerCreds returns the credentials of the peer of the request.
+func peerCreds(ctx context.Context) (peerCredsInfo, error) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return peerCredsInfo{}, errors.New("context request doesn't have grpc peer information")
+	}
+	pci, ok := p.AuthInfo.(peerCredsInfo)
+	if !ok {
+		return peerCredsInfo{}, errors.New("context request doesn't have valid grpc peer credential information")
+	}
+	return pci, nil
+}
@@ -78,6 +78,58 @@ func TestIsRequestFromRoot(t *testing.T) {
 	}
 }
 
+func TestIsRequestFromShadowReader(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		currentUserAsRoot   bool
+		currentUserInShadow bool
+		noPeerCredsInfo     bool
+
+		wantErr bool
+	}{
+		"Granted if current user considered as root":          {currentUserAsRoot: true},
+		"Granted if current user is in the shadow group":      {currentUserInShadow: true},
+		"Granted if current user is root and in shadow group": {currentUserAsRoot: true, currentUserInShadow: true},
+
+		"Error as deny when current user is neither root nor in shadow group": {wantErr: true},
+		"Error as deny when missing peer creds Info":                          {currentUserInShadow: true, noPeerCredsInfo: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			if !tc.noPeerCredsInfo {
+				pid := os.Getpid()
+				if pid > math.MaxInt32 {
+					t.Fatalf("Setup: pid is too large to be converted to int32: %d", pid)
+				}
+				//nolint:gosec // we did check the conversion check beforehand.
+				authInfo := permissions.NewTestPeerCredsInfo(permissions.CurrentUserUID(), int32(pid))
+				ctx = peer.NewContext(ctx, &peer.Peer{AuthInfo: authInfo})
+			}
+
+			var opts []permissions.Option
+			if tc.currentUserAsRoot {
+				opts = append(opts, permissionstestutils.WithCurrentUserAsRoot())
+			}
+			if tc.currentUserInShadow {
+				opts = append(opts, permissionstestutils.WithCurrentUserInShadowGroup())
+			}
+			pm := permissions.New(opts...)
+
+			err := pm.IsRequestFromShadowReader(ctx)
+
+			if tc.wantErr {
+				require.Error(t, err, "IsRequestFromShadowReader should deny access but didn't")
+				return
+			}
+			require.NoError(t, err, "IsRequestFromShadowReader should allow access but didn't")
+		})
+	}
+}
+
 func TestWithUnixPeerCreds(t *testing.T) {
 	t.Parallel()
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"math"
 	"net"
+	"unsafe"
 
 	"github.com/ubuntu/decorate"
 	"golang.org/x/sys/unix"
@@ -25,6 +26,7 @@ func (serverPeerCreds) ServerHandshake(conn net.Conn) (n net.Conn, c credentials
 	defer decorate.OnError(&err, "server handshake failed")
 
 	var cred *unix.Ucred
+	var groups []uint32
 	// net.Conn is an interface. Expect only *net.UnixConn types
 	uc, ok := conn.(*net.UnixConn)
 	if !ok {
@@ -49,15 +51,40 @@ func (serverPeerCreds) ServerHandshake(conn net.Conn) (n net.Conn, c credentials
 		cred, errClosure = unix.GetsockoptUcred(int(fd),
 			unix.SOL_SOCKET,
 			unix.SO_PEERCRED)
+		if errClosure != nil {
+			return
+		}
+		groups, errClosure = getsockoptPeerGroups(int(fd))
 	})
 	if errClosure != nil {
-		return nil, nil, fmt.Errorf("GetsockoptUcred() error: %v", errClosure)
+		return nil, nil, fmt.Errorf("getsockopt() error: %v", errClosure)
 	}
 	if err != nil {
 		return nil, nil, fmt.Errorf("Control() error: %v", err)
 	}
 
-	return conn, peerCredsInfo{uid: cred.Uid, pid: cred.Pid}, nil
+	return conn, peerCredsInfo{uid: cred.Uid, gid: cred.Gid, pid: cred.Pid, groups: groups}, nil
+}
+
+// getsockoptPeerGroups returns the supplementary groups of the peer of the socket, as they were when it connected,
+// via SO_PEERGROUPS. There is no wrapper for it, as the size of the list is only known once the kernel is asked for it.
+func getsockoptPeerGroups(fd int) ([]uint32, error) {
+	groups := make([]uint32, 64)
+	for {
+		//nolint:gosec // The length of the slice can't overflow an uint32, as the kernel limits the number of groups.
+		size := uint32(len(groups) * 4)
+		_, _, errno := unix.Syscall6(unix.SYS_GETSOCKOPT, uintptr(fd), unix.SOL_SOCKET, unix.SO_PEERGROUPS,
+			uintptr(unsafe.Pointer(&groups[0])), uintptr(unsafe.Pointer(&size)), 0)
+		// The kernel sets the size needed if the list doesn't fit.
+		if errno == unix.ERANGE {
+			groups = make([]uint32, size/4)
+			continue
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		return groups[:size/4], nil
+	}
 }
 func (serverPeerCreds) ClientHandshake(_ context.Context, _ string, conn net.Conn) (net.Conn, credentials.AuthInfo, error) {
 	return conn, nil, nil
@@ -68,7 +95,10 @@ func (serverPeerCreds) OverrideServerName(_ string) error       { return nil }
 
 type peerCredsInfo struct {
 	uid uint32
+	gid uint32
 	pid int32
+	// groups are the supplementary groups of the peer when it connected.
+	groups []uint32
 }
 
 // AuthType returns a string encrypting uid and pid of caller.
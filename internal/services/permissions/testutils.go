@@ -6,6 +6,7 @@ package permissions
 import (
 	"fmt"
 	"math"
+	"os"
 	"os/user"
 	"strconv"
 
@@ -22,6 +23,19 @@ func withCurrentUserAsRoot() Option {
 	}
 }
 
+// withCurrentUserInShadowGroup returns an Option that sets the shadow group to the primary group of the current user.
+func withCurrentUserInShadowGroup() Option {
+	testsdetection.MustBeTesting()
+
+	g, err := user.LookupGroupId(strconv.Itoa(os.Getgid()))
+	if err != nil {
+		panic(fmt.Sprintf("could not get primary group of current user: %v", err))
+	}
+	return func(o *options) {
+		o.shadowGroup = g.Name
+	}
+}
+
 // currentUserUID returns the current user UID or panics.
 func currentUserUID() uint32 {
 	testsdetection.MustBeTesting()
@@ -24,6 +24,11 @@ func init() {
 //go:linkname WithCurrentUserAsRoot github.com/ubuntu/authd/internal/services/permissions.withCurrentUserAsRoot
 func WithCurrentUserAsRoot() permissions.Option
 
+// WithCurrentUserInShadowGroup returns an Option that sets the shadow group to the primary group of the current user.
+//
+//go:linkname WithCurrentUserInShadowGroup github.com/ubuntu/authd/internal/services/permissions.withCurrentUserInShadowGroup
+func WithCurrentUserInShadowGroup() permissions.Option
+
 // SetCurrentUserAsRoot mutates a default permission to the current user's UID if currentUserAsRoot is true.
 //
 //go:linkname SetCurrentUserAsRoot github.com/ubuntu/authd/internal/services/permissions.(*Manager).setCurrentUserAsRoot
@@ -35,7 +40,8 @@ func SetCurrentUserAsRoot(m *permissions.Manager, currentUserAsRoot bool)
 
 //go:linkname defaultOptions github.com/ubuntu/authd/internal/services/permissions.defaultOptions
 var defaultOptions struct {
-	rootUID uint32
+	rootUID     uint32
+	shadowGroup string
 }
 
 //go:linkname currentUserUID github.com/ubuntu/authd/internal/services/permissions.currentUserUID
@@ -22,6 +22,9 @@ const (
 
 	// IDSeparator is the value used to append values to the sessionID in the broker mock.
 	IDSeparator = "_separator_"
+
+	// BrokerSlowCallDuration is how long the broker mock takes to answer the calls requested to be slow.
+	BrokerSlowCallDuration = 5 * time.Second
 )
 
 const (
@@ -54,6 +57,12 @@ type BrokerBusMock struct {
 	name                   string
 	isAuthenticatedCalls   map[string]isAuthenticatedCtx
 	isAuthenticatedCallsMu sync.RWMutex
+
+	// ongoingSessions are the sessions of the calls requested to be slow only once which were not ended yet, and
+	// slowCallsDone the sessions whose slow call was already made.
+	ongoingSessions map[string]bool
+	slowCallsDone   map[string]bool
+	sessionsMu      sync.Mutex
 }
 
 // StartBusBrokerMock starts the D-Bus service and exports it on the system bus.
@@ -71,6 +80,8 @@ func StartBusBrokerMock(cfgDir string, brokerName string) (string, func(), error
 		name:                   brokerName,
 		isAuthenticatedCalls:   map[string]isAuthenticatedCtx{},
 		isAuthenticatedCallsMu: sync.RWMutex{},
+		ongoingSessions:        map[string]bool{},
+		slowCallsDone:          map[string]bool{},
 	}
 
 	if err = conn.Export(&bus, dbus.ObjectPath(busObjectPath), dbusInterface); err != nil {
@@ -129,6 +140,29 @@ func (b *BrokerBusMock) NewSession(username, lang, mode string) (sessionID, encr
 	if parsedUsername == "NS_no_id" {
 		return "", username + "_key", nil
 	}
+	if parsedUsername == "NS_slow" {
+		time.Sleep(BrokerSlowCallDuration)
+	}
+	if parsedUsername == "NS_slow_once" {
+		// The session of the user must be ended before another one can be created.
+		sessionID := GenerateSessionID(username)
+		if !b.startSession(sessionID) {
+			return "", "", dbus.MakeFailedError(fmt.Errorf("broker %q: session %q is already ongoing", b.name, sessionID))
+		}
+		return sessionID, GenerateEncryptionKey(b.name), nil
+	}
+	if parsedUsername == "NS_invalid_id" {
+		return "invalid\x1b[0m-session_id", username + "_key", nil
+	}
+	if parsedUsername == "NS_auth_denied" {
+		return "", "", dbus.NewError(dbusInterface+".Error.AuthDenied", []interface{}{fmt.Sprintf("broker %q: authentication denied", b.name)})
+	}
+	if parsedUsername == "NS_user_unknown" {
+		return "", "", dbus.NewError(dbusInterface+".Error.UserUnknown", []interface{}{fmt.Sprintf("broker %q: user unknown", b.name)})
+	}
+	if parsedUsername == "NS_unknown_error" {
+		return "", "", dbus.NewError(dbusInterface+".Error.SomethingElse", []interface{}{fmt.Sprintf("broker %q: unknown error", b.name)})
+	}
 	return GenerateSessionID(username), GenerateEncryptionKey(b.name), nil
 }
 
@@ -305,13 +339,48 @@ func (b *BrokerBusMock) IsAuthenticated(sessionID, authenticationData string) (a
 
 // EndSession returns default values to be used in tests or an error if requested.
 func (b *BrokerBusMock) EndSession(sessionID string) (dbusErr *dbus.Error) {
-	sessionID = parseSessionID(sessionID)
-	if sessionID == "ES_error" {
+	b.sessionsMu.Lock()
+	delete(b.ongoingSessions, sessionID)
+	b.sessionsMu.Unlock()
+
+	switch parseSessionID(sessionID) {
+	case "ES_error":
 		return dbus.MakeFailedError(fmt.Errorf("broker %q: EndSession errored out", b.name))
+	case "ES_slow":
+		time.Sleep(BrokerSlowCallDuration)
+	case "ES_slow_once":
+		b.slowOnce(sessionID)
 	}
 	return nil
 }
 
+// startSession marks the session as ongoing, taking BrokerSlowCallDuration the first time it's started. It returns
+// false if the session is already ongoing.
+func (b *BrokerBusMock) startSession(sessionID string) bool {
+	b.sessionsMu.Lock()
+	if b.ongoingSessions[sessionID] {
+		b.sessionsMu.Unlock()
+		return false
+	}
+	b.ongoingSessions[sessionID] = true
+	b.sessionsMu.Unlock()
+
+	b.slowOnce(sessionID)
+	return true
+}
+
+// slowOnce takes BrokerSlowCallDuration the first time it's called for the session.
+func (b *BrokerBusMock) slowOnce(sessionID string) {
+	b.sessionsMu.Lock()
+	done := b.slowCallsDone[sessionID]
+	b.slowCallsDone[sessionID] = true
+	b.sessionsMu.Unlock()
+
+	if !done {
+		time.Sleep(BrokerSlowCallDuration)
+	}
+}
+
 // CancelIsAuthenticated cancels an ongoing IsAuthenticated call if it exists.
 func (b *BrokerBusMock) CancelIsAuthenticated(sessionID string) (dbusErr *dbus.Error) {
 	b.isAuthenticatedCallsMu.Lock()
@@ -324,6 +393,17 @@ func (b *BrokerBusMock) CancelIsAuthenticated(sessionID string) (dbusErr *dbus.E
 	return nil
 }
 
+// HasSession returns that the session is ongoing, unless the broker is requested to have lost it or to error out.
+func (b *BrokerBusMock) HasSession(sessionID string) (ongoing bool, dbusErr *dbus.Error) {
+	switch parseSessionID(sessionID) {
+	case "HS_lost":
+		return false, nil
+	case "HS_error":
+		return false, dbus.MakeFailedError(fmt.Errorf("broker %q: HasSession errored out", b.name))
+	}
+	return true, nil
+}
+
 // UserPreCheck returns default values to be used in tests or an error if requested.
 func (b *BrokerBusMock) UserPreCheck(username string) (userinfo string, dbusErr *dbus.Error) {
 	if strings.ToLower(username) != "user-pre-check" {
@@ -0,0 +1,287 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// backupFormatVersion is the version of the format written by Export. Import rejects backups of other versions.
+const backupFormatVersion = 1
+
+var (
+	// ErrIncompatibleBackup is returned by Import if the backup was written in another format version.
+	ErrIncompatibleBackup = errors.New("incompatible backup format")
+	// ErrCacheNotEmpty is returned by Import if the cache already has records and WithOverwrite is not passed.
+	ErrCacheNotEmpty = errors.New("cache is not empty")
+)
+
+// backupHeader is the part of the backup which is read before its content, so that backups of other versions are
+// rejected whatever their content is.
+type backupHeader struct {
+	Version int
+}
+
+// backup is the content of the cache written by Export, in JSON format.
+type backup struct {
+	Version int
+	// Buckets maps the name of each bucket to its records, with their values decoded. The optional buckets are only
+	// written if they exist. The members of large groups are in the GroupMembers bucket, as the list of their UIDs by
+	// GID.
+	Buckets map[string]map[string]json.RawMessage
+}
+
+// plainBuckets are the optional buckets whose values are written as plain JSON, without the encoding options of the
+// cache.
+var plainBuckets = []string{quarantineBucketName, tombstonesBucketName, failedLoginsBucketName}
+
+type importOptions struct {
+	overwrite bool
+}
+
+// ImportOption represents an optional function to override Import default values.
+type ImportOption func(*importOptions)
+
+// WithOverwrite makes Import replace the content of the cache if it's not empty.
+func WithOverwrite() ImportOption {
+	return func(o *importOptions) {
+		o.overwrite = true
+	}
+}
+
+// Export writes all users and groups, their memberships and the brokers assigned to the users to w, with the nested
+// groups, the tombstones, the failed logins and the quarantined records, in a versioned JSON format which can be
+// restored with Import. The values are written decoded, so that they can be imported in a cache with different
+// encoding options.
+func (c *Cache) Export(w io.Writer) (err error) {
+	defer decorate.OnError(&err, "could not export cache")
+
+	b := backup{
+		Version: backupFormatVersion,
+		Buckets: make(map[string]map[string]json.RawMessage),
+	}
+
+	c.mu.RLock()
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range optionalBuckets {
+			if string(name) == groupMembersBucketName {
+				continue
+			}
+			if bucket := tx.Bucket(name); bucket != nil {
+				buckets[string(name)] = c.newBucketWithName(string(name), bucket)
+			}
+		}
+
+		for name, bucket := range buckets {
+			records := make(map[string]json.RawMessage)
+			err := bucket.ForEach(func(key, value []byte) error {
+				data, err := decodeValue(value)
+				if err != nil {
+					return fmt.Errorf("can't decode record in bucket %q for key %s: %v", name, key, err)
+				}
+				if !json.Valid(data) {
+					return fmt.Errorf("invalid record in bucket %q for key %s", name, key)
+				}
+				records[string(key)] = data
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			b.Buckets[name] = records
+		}
+
+		largeGroups, err := exportLargeGroups(tx)
+		if err != nil {
+			return err
+		}
+		if len(largeGroups) > 0 {
+			b.Buckets[groupMembersBucketName] = largeGroups
+		}
+		return nil
+	})
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(b)
+}
+
+// exportLargeGroups returns the UIDs of the members of the large groups, in JSON format, by GID.
+func exportLargeGroups(tx *bbolt.Tx) (map[string]json.RawMessage, error) {
+	groupMembers := tx.Bucket([]byte(groupMembersBucketName))
+	if groupMembers == nil {
+		return nil, nil
+	}
+
+	largeGroups := make(map[string]json.RawMessage)
+	err := groupMembers.ForEachBucket(func(gid []byte) error {
+		uids, err := uidsOfLargeGroup(groupMembers.Bucket(gid))
+		if err != nil {
+			return fmt.Errorf("can't read members of group %s in bucket %q: %v", gid, groupMembersBucketName, err)
+		}
+		data, err := json.Marshal(uids)
+		if err != nil {
+			return err
+		}
+		largeGroups[string(gid)] = data
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return largeGroups, nil
+}
+
+// importLargeGroup creates the bucket of the members of the large group matching gid, from their UIDs in JSON format.
+func importLargeGroup(tx *bbolt.Tx, gid string, value json.RawMessage) error {
+	var uids []uint32
+	if err := json.Unmarshal(value, &uids); err != nil {
+		return fmt.Errorf("can't unmarshal members of group %s: %v", gid, err)
+	}
+	if _, err := strconv.ParseUint(gid, 10, 32); err != nil {
+		return fmt.Errorf("invalid GID %q: %v", gid, err)
+	}
+
+	groupMembers, err := tx.CreateBucketIfNotExists([]byte(groupMembersBucketName))
+	if err != nil {
+		return err
+	}
+	members, err := groupMembers.CreateBucket([]byte(gid))
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		if err := members.Put([]byte(strconv.FormatUint(uint64(uid), 10)), []byte{}); err != nil {
+			panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+		}
+	}
+	return nil
+}
+
+// Import restores the content of the cache from a backup written by Export, in a single transaction. The values are
+// written with the encoding options of the cache.
+// It returns ErrIncompatibleBackup if the backup was written in another format version, and ErrCacheNotEmpty if the
+// cache already has records, including tombstones, unless WithOverwrite is passed. In that case, all the content of
+// the cache is replaced by the one of the backup, including the nested groups, the tombstones, the failed logins and
+// the quarantined records.
+func (c *Cache) Import(r io.Reader, args ...ImportOption) (err error) {
+	defer decorate.OnError(&err, "could not import cache")
+
+	opts := importOptions{}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	var content json.RawMessage
+	if err := json.NewDecoder(r).Decode(&content); err != nil {
+		return fmt.Errorf("can't read backup: %v", err)
+	}
+	var h backupHeader
+	if err := json.Unmarshal(content, &h); err != nil {
+		return fmt.Errorf("can't read backup header: %v", err)
+	}
+	if h.Version != backupFormatVersion {
+		return fmt.Errorf("%w: version %d, expected version %d", ErrIncompatibleBackup, h.Version, backupFormatVersion)
+	}
+	var b backup
+	if err := json.Unmarshal(content, &b); err != nil {
+		return fmt.Errorf("can't read backup content: %v", err)
+	}
+	for name := range b.Buckets {
+		isBucket := func(n []byte) bool { return string(n) == name }
+		if !slices.ContainsFunc(allBuckets, isBucket) && !slices.ContainsFunc(optionalBuckets, isBucket) {
+			return fmt.Errorf("unknown bucket %q in backup", name)
+		}
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		empty := true
+		for _, name := range append(slices.Clone(allBuckets), optionalBuckets...) {
+			bucket := tx.Bucket(name)
+			if bucket == nil {
+				continue
+			}
+			if k, _ := bucket.Cursor().First(); k != nil {
+				empty = false
+				break
+			}
+		}
+		if !empty && !opts.overwrite {
+			return ErrCacheNotEmpty
+		}
+		if !empty {
+			if buckets, err = c.clearAllBuckets(tx); err != nil {
+				return err
+			}
+		}
+
+		for name, records := range b.Buckets {
+			if _, ok := buckets[name]; !ok && name != groupMembersBucketName && len(records) > 0 {
+				bucket, err := tx.CreateBucketIfNotExists([]byte(name))
+				if err != nil {
+					return err
+				}
+				buckets[name] = c.newBucketWithName(name, bucket)
+			}
+
+			for key, value := range records {
+				switch {
+				case name == groupMembersBucketName:
+					if err := importLargeGroup(tx, key, value); err != nil {
+						return err
+					}
+				case slices.Contains(plainBuckets, name):
+					if err := buckets[name].Put([]byte(key), value); err != nil {
+						panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+					}
+				default:
+					updateBucket(buckets[name], key, value)
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// clearAllBuckets deletes all records of the database, including the ones of the optional buckets, and returns the
+// recreated buckets.
+func (c *Cache) clearAllBuckets(tx *bbolt.Tx) (map[string]bucketWithName, error) {
+	for _, name := range optionalBuckets {
+		if tx.Bucket(name) == nil {
+			continue
+		}
+		if err := tx.DeleteBucket(name); err != nil {
+			return nil, fmt.Errorf("can't delete bucket %q: %v", name, err)
+		}
+	}
+	for _, name := range allBuckets {
+		if err := tx.DeleteBucket(name); err != nil {
+			return nil, fmt.Errorf("can't delete bucket %q: %v", name, err)
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return nil, fmt.Errorf("can't create bucket %q: %v", name, err)
+		}
+	}
+	return c.getAllBuckets(tx)
+}
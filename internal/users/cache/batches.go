@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// UsersInBatches calls fn with all users, in batches of at most batchSize users. Each batch is read in its own
+// transaction, so that only one batch is held in memory and writes are not blocked while fn runs: users added or
+// removed concurrently may or may not be returned, but no user is returned twice.
+// It stops at the first error returned by fn, or if ctx is cancelled.
+func (c *Cache) UsersInBatches(ctx context.Context, batchSize int, fn func(users []UserDB) error) error {
+	return inBatches(ctx, c, userByIDBucketName, batchSize, func(_ map[string]bucketWithName, key, value []byte) (UserDB, error) {
+		var u userDB
+		if err := unmarshalValue(value, &u); err != nil {
+			return UserDB{}, fmt.Errorf("can't unmarshal user in bucket %q for key %s: %v", userByIDBucketName, key, err)
+		}
+		return u.UserDB, nil
+	}, fn)
+}
+
+// GroupsInBatches calls fn with all groups and their members, in batches of at most batchSize groups. As with
+// UsersInBatches, each batch is read in its own transaction.
+// It stops at the first error returned by fn, or if ctx is cancelled.
+func (c *Cache) GroupsInBatches(ctx context.Context, batchSize int, fn func(groups []GroupDB) error) error {
+	return inBatches(ctx, c, groupByIDBucketName, batchSize, func(buckets map[string]bucketWithName, key, value []byte) (GroupDB, error) {
+		var g groupDB
+		if err := unmarshalValue(value, &g); err != nil {
+			return GroupDB{}, fmt.Errorf("can't unmarshal group in bucket %q for key %s: %v", groupByIDBucketName, key, err)
+		}
+
+		users, err := getUsersInGroup(buckets, g.GID)
+		if err != nil {
+			return GroupDB{}, err
+		}
+		return NewGroupDB(g.Name, g.GID, users), nil
+	}, fn)
+}
+
+// inBatches calls fn with the records of the bucket decoded by decode, in batches of at most batchSize records read
+// in their own transaction. Each batch resumes after the key of the last record of the previous one.
+func inBatches[T any](ctx context.Context, c *Cache, bucketName string, batchSize int,
+	decode func(buckets map[string]bucketWithName, key, value []byte) (T, error), fn func([]T) error) error {
+	if batchSize < 1 {
+		return fmt.Errorf("invalid batch size: %d", batchSize)
+	}
+
+	var after []byte
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var batch []T
+		c.mu.RLock()
+		err := c.view(func(tx *bbolt.Tx) error {
+			buckets, err := c.getAllBuckets(tx)
+			if err != nil {
+				return err
+			}
+
+			cursor := buckets[bucketName].Cursor()
+			k, v := cursor.First()
+			if after != nil {
+				k, v = cursor.Seek(after)
+				if bytes.Equal(k, after) {
+					k, v = cursor.Next()
+				}
+			}
+			for ; k != nil && len(batch) < batchSize; k, v = cursor.Next() {
+				e, err := decode(buckets, k, v)
+				if err != nil {
+					return err
+				}
+				batch = append(batch, e)
+				// Keys are only valid during the transaction.
+				after = bytes.Clone(k)
+			}
+			return nil
+		})
+		c.mu.RUnlock()
+		if err != nil {
+			return err
+		}
+
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := fn(batch); err != nil {
+			return err
+		}
+		if len(batch) < batchSize {
+			return nil
+		}
+	}
+}
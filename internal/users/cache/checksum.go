@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/ubuntu/authd/internal/log"
+	"go.etcd.io/bbolt"
+)
+
+// ErrCorrupted is returned when reading a record whose checksum doesn't match its content.
+var ErrCorrupted = errors.New("corrupted database record")
+
+// checksumValueVersion is the version byte prefixing values followed by the CRC-32 of the rest of the value, which is
+// itself a plain or compressed value.
+const checksumValueVersion byte = 2
+
+// checksumLen is the length of the checksum following checksumValueVersion.
+const checksumLen = 4
+
+// WithRecordChecksums sets whether a checksum is stored with each record, and added to the existing records when
+// opening the database, so that corrupted records are detected when read instead of being served.
+// Records are verified when read whether this is enabled or not.
+func WithRecordChecksums(checksums bool) Option {
+	return func(o *options) {
+		o.recordChecksums = checksums
+	}
+}
+
+// withChecksum returns the value prefixed with its checksum.
+func withChecksum(value []byte) []byte {
+	v := make([]byte, 1+checksumLen, 1+checksumLen+len(value))
+	v[0] = checksumValueVersion
+	binary.BigEndian.PutUint32(v[1:], crc32.ChecksumIEEE(value))
+	return append(v, value...)
+}
+
+// verifyChecksum returns the value without its checksum, or ErrCorrupted if the checksum doesn't match.
+// Values stored without checksum are returned as is.
+func verifyChecksum(value []byte) ([]byte, error) {
+	if len(value) == 0 || value[0] != checksumValueVersion {
+		return value, nil
+	}
+	if len(value) < 1+checksumLen {
+		return nil, fmt.Errorf("%w: truncated checksum", ErrCorrupted)
+	}
+
+	data := value[1+checksumLen:]
+	if crc32.ChecksumIEEE(data) != binary.BigEndian.Uint32(value[1:]) {
+		return nil, ErrCorrupted
+	}
+	return data, nil
+}
+
+// addRecordChecksums adds a checksum to the records stored without one.
+func (c *Cache) addRecordChecksums() error {
+	var added int
+	err := c.migrate(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, bucket := range buckets {
+			// The bucket can't be modified while iterating over it, so we collect the records first.
+			records := make(map[string][]byte)
+			// The iteration can't fail, as we never return an error.
+			_ = bucket.ForEach(func(key, value []byte) error {
+				if len(value) > 0 && value[0] == checksumValueVersion {
+					return nil
+				}
+				records[string(key)] = withChecksum(value)
+				return nil
+			})
+
+			for key, value := range records {
+				if err := bucket.Put([]byte(key), value); err != nil {
+					panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+				}
+			}
+			added += len(records)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not add checksums to records: %v", err)
+	}
+
+	if added > 0 {
+		log.Infof(context.TODO(), "Added checksums to %d database records", added)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// compactSuffix is the suffix of the temporary file the database is compacted into.
+const compactSuffix = ".compact"
+
+// Compact rewrites the database into a new file without its free pages, and atomically replaces the database file
+// with it, so that the space freed by deleted records is reclaimed. It returns the size of the database file before and
+// after the compaction.
+// It takes the write lock of the cache for the duration of the compaction: concurrent reads and writes wait for it to
+// complete. If the compaction fails, the database is left untouched.
+func (c *Cache) Compact() (sizeBefore, sizeAfter int64, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	path := c.db.Path()
+	defer decorate.OnError(&err, "could not compact database %q", path)
+
+	c.stateMu.Lock()
+	closed := c.closed
+	c.stateMu.Unlock()
+	if closed {
+		return 0, 0, errors.New("cache is closed")
+	}
+	if c.db.IsReadOnly() {
+		return 0, 0, ErrReadOnlyCache
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	sizeBefore = fi.Size()
+
+	tmpPath := path + compactSuffix
+	// A leftover of a previous compaction which was interrupted can't be trusted.
+	if err := os.Remove(tmpPath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return 0, 0, fmt.Errorf("can't remove previous compacted database: %v", err)
+	}
+	if sizeAfter, err = compactInto(c.db, tmpPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, 0, err
+	}
+
+	if err := c.db.Close(); err != nil {
+		_ = os.Remove(tmpPath)
+		return 0, 0, fmt.Errorf("can't close database: %v", err)
+	}
+	// The database file is replaced only once the compacted one is complete, so that the rename is atomic.
+	renameErr := os.Rename(tmpPath, path)
+	if renameErr != nil {
+		_ = os.Remove(tmpPath)
+	}
+
+	db, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		err = fmt.Errorf("can't reopen database: %v", err)
+		// There is no database to run transactions on anymore.
+		c.stateMu.Lock()
+		c.lastErr = err
+		c.stateMu.Unlock()
+		return 0, 0, err
+	}
+	c.stateMu.Lock()
+	c.db = db
+	c.stateMu.Unlock()
+
+	if renameErr != nil {
+		return 0, 0, fmt.Errorf("can't replace database file: %v", renameErr)
+	}
+
+	return sizeBefore, sizeAfter, nil
+}
+
+// compactInto copies src into a new database at path, and returns the size of the new database file.
+func compactInto(src *bbolt.DB, path string) (size int64, err error) {
+	dst, err := bbolt.Open(path, 0600, nil)
+	if err != nil {
+		return 0, fmt.Errorf("can't create compacted database: %v", err)
+	}
+	if err := bbolt.Compact(dst, src, 0); err != nil {
+		_ = dst.Close()
+		return 0, fmt.Errorf("can't copy database: %v", err)
+	}
+	if err := dst.Close(); err != nil {
+		return 0, fmt.Errorf("can't close compacted database: %v", err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
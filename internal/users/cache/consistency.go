@@ -0,0 +1,261 @@
+package cache
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"go.etcd.io/bbolt"
+)
+
+// inconsistencies returns a description of each inconsistency between the buckets of the database: records which
+// can't be parsed, users or groups whose ID and name records don't match, and mappings between users, groups and
+// brokers referring to missing or unrelated entries.
+// It returns an error only if the buckets can't be read.
+func (c *Cache) inconsistencies() (problems []string, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		problems = bucketsInconsistencies(buckets)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return problems, nil
+}
+
+// inconsistencyKind is the kind of an inconsistency found by checkBuckets.
+type inconsistencyKind int
+
+const (
+	// otherInconsistency is an inconsistency which RepairIntegrity doesn't fix.
+	otherInconsistency inconsistencyKind = iota
+	// unparsableRecord is a record which can't be parsed.
+	unparsableRecord
+	// orphanedName is a name record without a matching ID record.
+	orphanedName
+	// missingGroupMember is a member of a group which is not a user of the database.
+	missingGroupMember
+	// missingUserGroup is a group of a user which is not a group of the database.
+	missingUserGroup
+)
+
+// inconsistency is an inconsistency between the buckets of the database.
+type inconsistency struct {
+	kind inconsistencyKind
+	// bucket and key identify the orphaned name record.
+	bucket string
+	key    string
+	// id and ref are the GID of the group and the UID of its missing member, or the UID of the user and the GID of
+	// its missing group.
+	id  uint32
+	ref uint32
+
+	description string
+}
+
+// reportFunc is called by checkBuckets with each inconsistency it finds, described by format and args.
+type reportFunc func(i inconsistency, format string, args ...any)
+
+// bucketsInconsistencies returns a description of each inconsistency between the buckets, sorted.
+func bucketsInconsistencies(buckets map[string]bucketWithName) (problems []string) {
+	checkBuckets(buckets, func(i inconsistency) {
+		problems = append(problems, i.description)
+	})
+
+	slices.Sort(problems)
+	return problems
+}
+
+// checkBuckets calls found with each inconsistency between the buckets.
+func checkBuckets(buckets map[string]bucketWithName, found func(inconsistency)) {
+	report := func(i inconsistency, format string, args ...any) {
+		i.description = fmt.Sprintf(format, args...)
+		found(i)
+	}
+	unparsable := inconsistency{kind: unparsableRecord}
+	other := inconsistency{kind: otherInconsistency}
+	hasRecord := func(b bucketWithName, id uint32) bool {
+		return b.Get([]byte(strconv.FormatUint(uint64(id), 10))) != nil
+	}
+
+	users := make(map[uint32]userDB)
+	checkPivot(buckets[userByIDBucketName], buckets[userByNameBucketName], report,
+		func(u userDB) (uint32, string) { return u.UID, u.Name },
+		func(u userDB) { users[u.UID] = u })
+
+	groups := make(map[uint32]groupDB)
+	checkPivot(buckets[groupByIDBucketName], buckets[groupByNameBucketName], report,
+		func(g groupDB) (uint32, string) { return g.GID, g.Name },
+		func(g groupDB) { groups[g.GID] = g })
+
+	userToGroups := make(map[uint32][]uint32)
+	_ = buckets[userToGroupsBucketName].ForEach(func(key, value []byte) error {
+		var u userToGroupsDB
+		if err := unmarshalValue(value, &u); err != nil {
+			report(unparsable, "%s: can't parse record %s: %v", userToGroupsBucketName, key, err)
+			return nil
+		}
+		if string(key) != strconv.FormatUint(uint64(u.UID), 10) {
+			report(other, "%s: record %s is for UID %d", userToGroupsBucketName, key, u.UID)
+		}
+		userToGroups[u.UID] = u.GIDs
+		return nil
+	})
+
+	groupToUsers := make(map[uint32][]uint32)
+	_ = buckets[groupToUsersBucketName].ForEach(func(key, value []byte) error {
+		var g groupToUsersDB
+		if err := unmarshalValue(value, &g); err != nil {
+			report(unparsable, "%s: can't parse record %s: %v", groupToUsersBucketName, key, err)
+			return nil
+		}
+		if string(key) != strconv.FormatUint(uint64(g.GID), 10) {
+			report(other, "%s: record %s is for GID %d", groupToUsersBucketName, key, g.GID)
+		}
+		if members := largeGroupMembersBucket(buckets, g.GID); members != nil {
+			uids, err := uidsOfLargeGroup(members)
+			if err != nil {
+				report(unparsable, "%s: can't read members of group %d: %v", groupMembersBucketName, g.GID, err)
+			}
+			g.UIDs = append(g.UIDs, uids...)
+		}
+		groupToUsers[g.GID] = g.UIDs
+		return nil
+	})
+	if groupMembers := buckets[groupToUsersBucketName].Tx().Bucket([]byte(groupMembersBucketName)); groupMembers != nil {
+		_ = groupMembers.ForEach(func(key, _ []byte) error {
+			if buckets[groupToUsersBucketName].Get(key) == nil {
+				report(other, "%s: members of group %s, which has no %s record", groupMembersBucketName, key, groupToUsersBucketName)
+			}
+			return nil
+		})
+	}
+
+	for uid, u := range users {
+		gids, ok := userToGroups[uid]
+		if !ok {
+			report(other, "user %q (%d) has no %s record", u.Name, uid, userToGroupsBucketName)
+			continue
+		}
+		if !slices.Contains(gids, u.GID) {
+			report(other, "user %q (%d) is not a member of its primary group %d", u.Name, uid, u.GID)
+		}
+	}
+	for uid, gids := range userToGroups {
+		if _, ok := users[uid]; !ok {
+			report(other, "%s: record for missing user %d", userToGroupsBucketName, uid)
+		}
+		for _, gid := range gids {
+			if !hasRecord(buckets[groupByIDBucketName], gid) {
+				report(inconsistency{kind: missingUserGroup, id: uid, ref: gid}, "%s: user %d is a member of missing group %d", userToGroupsBucketName, uid, gid)
+			}
+			if !slices.Contains(groupToUsers[gid], uid) {
+				report(other, "%s: user %d is a member of group %d, which doesn't list it", userToGroupsBucketName, uid, gid)
+			}
+		}
+	}
+	for gid := range groups {
+		if _, ok := groupToUsers[gid]; !ok {
+			report(other, "group %d has no %s record", gid, groupToUsersBucketName)
+		}
+	}
+	for gid, uids := range groupToUsers {
+		if _, ok := groups[gid]; !ok {
+			report(other, "%s: record for missing group %d", groupToUsersBucketName, gid)
+		}
+		for _, uid := range uids {
+			if !hasRecord(buckets[userByIDBucketName], uid) {
+				report(inconsistency{kind: missingGroupMember, id: gid, ref: uid}, "%s: group %d lists missing user %d",
+					groupToUsersBucketName, gid, uid)
+				continue
+			}
+			if !slices.Contains(userToGroups[uid], gid) {
+				report(other, "%s: group %d lists user %d, which is not a member of it", groupToUsersBucketName, gid, uid)
+			}
+		}
+	}
+
+	_ = buckets[userToBrokerBucketName].ForEach(func(key, _ []byte) error {
+		uid, err := strconv.ParseUint(string(key), 10, 32)
+		//nolint:gosec // ParseUint checked that the value fits in an uint32.
+		if _, ok := users[uint32(uid)]; err != nil || !ok {
+			report(other, "%s: record for missing user %s", userToBrokerBucketName, key)
+		}
+		return nil
+	})
+}
+
+// checkPivot reports the records of the byID and byName buckets which can't be parsed or which don't have a matching
+// record in the other bucket, and calls found with each valid record of byID.
+func checkPivot[T any](byID, byName bucketWithName, report reportFunc, keys func(T) (uint32, string), found func(T)) {
+	unparsable := inconsistency{kind: unparsableRecord}
+	other := inconsistency{kind: otherInconsistency}
+	orphaned := func(key []byte) inconsistency {
+		return inconsistency{kind: orphanedName, bucket: byName.name, key: string(key)}
+	}
+
+	_ = byID.ForEach(func(key, value []byte) error {
+		var v T
+		if err := unmarshalValue(value, &v); err != nil {
+			report(unparsable, "%s: can't parse record %s: %v", byID.name, key, err)
+			return nil
+		}
+		id, name := keys(v)
+		if string(key) != strconv.FormatUint(uint64(id), 10) {
+			report(other, "%s: record %s is for ID %d", byID.name, key, id)
+			return nil
+		}
+
+		record := byName.Get(byName.nameKey(name))
+		if record == nil {
+			// Records written before keys were folded are keyed by their exact name.
+			record = byName.Get([]byte(name))
+		}
+		var match T
+		if err := unmarshalValue(record, &match); err != nil {
+			report(other, "%s: no valid record for %q (%d)", byName.name, name, id)
+		} else if matchID, _ := keys(match); matchID != id {
+			report(other, "%s: record for %q is for ID %d instead of %d", byName.name, name, matchID, id)
+		}
+
+		found(v)
+		return nil
+	})
+
+	_ = byName.ForEach(func(key, value []byte) error {
+		var v T
+		if err := unmarshalValue(value, &v); err != nil {
+			report(unparsable, "%s: can't parse record %s: %v", byName.name, key, err)
+			return nil
+		}
+		id, name := keys(v)
+		if string(key) != name && string(key) != string(byName.nameKey(name)) {
+			report(other, "%s: record %s is for %q", byName.name, key, name)
+			return nil
+		}
+
+		record := byID.Get([]byte(strconv.FormatUint(uint64(id), 10)))
+		if record == nil {
+			report(orphaned(key), "%s: no record for %q (%d)", byID.name, name, id)
+			return nil
+		}
+		var match T
+		if err := unmarshalValue(record, &match); err != nil {
+			// The record of byID is already reported as unparsable.
+			return nil
+		}
+		if _, matchName := keys(match); string(byName.nameKey(matchName)) != string(byName.nameKey(name)) {
+			report(orphaned(key), "%s: record %d is for %q instead of %q", byID.name, id, matchName, name)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// CreateUserIfAbsent creates the user name from template, unless a user with this name already exists.
+// The user gets the lowest ID in [minUID, maxUID] used neither as a UID nor as a GID, nor reserved by the tombstone of
+// a deleted user, and a primary group with the same name and ID. The UID, GID and name of the template are ignored.
+// It returns the user as stored in the database and whether it was created. Concurrent calls for the same name
+// result in a single creation, and all of them return the same user.
+func (c *Cache) CreateUserIfAbsent(name string, template UserDB, minUID, maxUID uint32) (u UserDB, created bool, err error) {
+	defer decorate.OnError(&err, "could not create user %q", name)
+
+	if name == "" {
+		return UserDB{}, false, errors.New("empty user name")
+	}
+	if minUID > maxUID {
+		return UserDB{}, false, fmt.Errorf("invalid UID range %d-%d", minUID, maxUID)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.update(func(tx *bbolt.Tx) error {
+		created = false
+
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		existing, err := getFromBucket[userDB](buckets[userByNameBucketName], name)
+		if err == nil {
+			u = existing.UserDB
+			return nil
+		}
+		if !errors.Is(err, NoDataFoundError{}) {
+			return err
+		}
+
+		id, err := allocateID(buckets, minUID, maxUID, func(id uint32) bool { return c.isTombstoned(tx, id, name) })
+		if err != nil {
+			return err
+		}
+
+		u = template
+		u.Name = name
+		u.UID = id
+		u.GID = id
+
+		now := c.now()
+		if err := c.updateUserEntry(buckets, userDB{UserDB: u, ModifiedAt: now}, []GroupDB{{Name: name, GID: id}}, now); err != nil {
+			return err
+		}
+
+		created = true
+		return nil
+	})
+	if err != nil {
+		return UserDB{}, false, err
+	}
+
+	if created {
+		log.Infof(context.Background(), "Created user %q with UID %d", u.Name, u.UID)
+	}
+	return u, created, nil
+}
+
+// allocateID returns the lowest ID in [minID, maxID] which is used neither as a UID nor as a GID, and not reserved.
+func allocateID(buckets map[string]bucketWithName, minID, maxID uint32, reserved func(uint32) bool) (uint32, error) {
+	for id := minID; ; id++ {
+		key := []byte(strconv.FormatUint(uint64(id), 10))
+		if buckets[userByIDBucketName].Get(key) == nil && buckets[groupByIDBucketName].Get(key) == nil && !reserved(id) {
+			return id, nil
+		}
+		// Checked before incrementing, so that we don't overflow when maxID is the highest uint32.
+		if id == maxID {
+			return 0, fmt.Errorf("no free ID in range %d-%d", minID, maxID)
+		}
+	}
+}
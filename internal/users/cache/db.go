@@ -2,14 +2,23 @@
 package cache
 
 import (
+	"bytes"
+	"compress/flate"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ubuntu/authd/internal/log"
 	"github.com/ubuntu/decorate"
 	"go.etcd.io/bbolt"
 )
@@ -18,6 +27,20 @@ var (
 	dbName = "authd.db"
 )
 
+// ErrMigrationInProgress is returned by reads while a database migration is running.
+var ErrMigrationInProgress = errors.New("database migration in progress")
+
+// ErrReadOnlyCache is returned by writes to a cache opened with WithReadOnly.
+var ErrReadOnlyCache = errors.New("cache is opened read-only")
+
+// ErrDatabaseInUse is returned by New when the database is opened read-only while another process, like the daemon,
+// holds it open read-write.
+var ErrDatabaseInUse = errors.New("database is in use by another process")
+
+// readOnlyOpenTimeout is how long opening the database read-only waits for the process holding it read-write to
+// release it.
+const readOnlyOpenTimeout = time.Second
+
 const (
 	userByNameBucketName   = "UserByName"
 	userByIDBucketName     = "UserByID"
@@ -26,6 +49,18 @@ const (
 	userToGroupsBucketName = "UserToGroups"
 	groupToUsersBucketName = "GroupToUsers"
 	userToBrokerBucketName = "UserToBroker"
+
+	// quarantineBucketName is only created when a record is first quarantined.
+	quarantineBucketName = "Quarantine"
+	// tombstonesBucketName is only created when a user is first deleted with UIDs reuse delayed.
+	tombstonesBucketName = "Tombstones"
+	// groupToGroupsBucketName is only created when a group is first nested in another one.
+	groupToGroupsBucketName = "GroupToGroups"
+	// groupMembersBucketName is only created when a group first gets more members than the large group threshold.
+	// It holds a bucket per large group, with the UIDs of its members as keys.
+	groupMembersBucketName = "GroupMembers"
+	// failedLoginsBucketName is only created when a failed login is first recorded.
+	failedLoginsBucketName = "FailedLogins"
 )
 
 var (
@@ -35,12 +70,93 @@ var (
 		[]byte(userToGroupsBucketName), []byte(groupToUsersBucketName),
 		[]byte(userToBrokerBucketName),
 	}
+
+	// optionalBuckets are the buckets which are not created when opening the database, but which are kept.
+	optionalBuckets = [][]byte{
+		[]byte(quarantineBucketName),
+		[]byte(tombstonesBucketName),
+		[]byte(groupToGroupsBucketName),
+		[]byte(groupMembersBucketName),
+		[]byte(failedLoginsBucketName),
+	}
 )
 
 // Cache is our database API.
 type Cache struct {
 	db *bbolt.DB
+	// mu protects the database handle, not its content: methods running transactions, whether they read or write,
+	// take the read lock, while Close and Compact, which close or replace the handle, take the write lock.
+	// Concurrent writers don't need to be serialized here, as bbolt only runs one read-write transaction at a time.
+	// Any other field written after New must be protected by its own lock, like stateMu and subscribersMu.
 	mu sync.RWMutex
+
+	// stateMu protects the state of the cache, which can be updated by concurrent transactions.
+	stateMu sync.Mutex
+	closed  bool
+	lastErr error
+
+	// migrating is the number of migrations running, so that reads don't return partially migrated data.
+	migrating atomic.Int32
+	// migrationsDone is closed once the migrations run when opening the database are done.
+	migrationsDone chan struct{}
+
+	// subscribersMu protects the subscribers to the events of the cache, and orders the deliveries of the events.
+	subscribersMu sync.Mutex
+	subscribers   map[*subscriber]struct{}
+
+	groupConflictPolicy  GroupConflictPolicy
+	compressValues       bool
+	uniqueHomedirs       bool
+	keepExistingShell    bool
+	caseInsensitiveNames bool
+	uidReuseDelay        time.Duration
+	recordChecksums      bool
+	largeGroupThreshold  int
+	now                  func() time.Time
+}
+
+// GroupConflictPolicy defines how to handle a group whose name is already used by a group with a different GID.
+type GroupConflictPolicy int
+
+const (
+	// GroupConflictFail fails the update.
+	GroupConflictFail GroupConflictPolicy = iota
+	// GroupConflictPreferNew moves the existing group, with all its memberships, to the new GID.
+	GroupConflictPreferNew
+	// GroupConflictPreferExisting keeps the existing GID and ignores the new one.
+	GroupConflictPreferExisting
+)
+
+type options struct {
+	groupConflictPolicy  GroupConflictPolicy
+	compressValues       bool
+	uniqueHomedirs       bool
+	keepExistingShell    bool
+	caseInsensitiveNames bool
+	uidReuseDelay        time.Duration
+	recordChecksums      bool
+	readOnly             bool
+	backgroundMigrations bool
+
+	// private members that we export for tests.
+	largeGroupThreshold int
+	now                 func() time.Time
+}
+
+var defaultOptions = options{
+	groupConflictPolicy: GroupConflictFail,
+	largeGroupThreshold: defaultLargeGroupThreshold,
+	now:                 time.Now,
+}
+
+// Option represents an optional function to override New default values.
+type Option func(*options)
+
+// WithGroupConflictPolicy sets how updates handle a group name already used by a group with a different GID.
+func WithGroupConflictPolicy(policy GroupConflictPolicy) Option {
+	return func(o *options) {
+		o.groupConflictPolicy = policy
+	}
 }
 
 // UserDB is the public type that is shared to external packages.
@@ -59,6 +175,10 @@ type UserDB struct {
 	PwdInactivity  int
 	MinPwdAge      int
 	ExpirationDate int
+
+	// Disabled users can't log in, but are still returned by lookups, so that their files keep their owner. It's only
+	// changed by SetUserEnabled.
+	Disabled bool
 }
 
 // GroupDB is the struct stored in json format in the bucket.
@@ -80,12 +200,74 @@ type groupToUsersDB struct {
 	UIDs []uint32
 }
 
+// WithValueCompression sets whether the values are compressed when written to the database. Values are read
+// correctly whether they were compressed or not, so that this can be changed on an existing database.
+func WithValueCompression(compress bool) Option {
+	return func(o *options) {
+		o.compressValues = compress
+	}
+}
+
+// WithUniqueHomedirs sets whether an update giving a user the homedir of a user with a different UID is rejected.
+// Users keeping their existing homedir are not affected.
+func WithUniqueHomedirs(unique bool) Option {
+	return func(o *options) {
+		o.uniqueHomedirs = unique
+	}
+}
+
+// WithKeepExistingShell sets whether updates keep the shell users already have, instead of the one of the update, so
+// that a shell customized locally is not overwritten on the next login. Users without any shell get the one of the
+// update.
+func WithKeepExistingShell(keep bool) Option {
+	return func(o *options) {
+		o.keepExistingShell = keep
+	}
+}
+
+// WithCaseInsensitiveNames sets whether user names are looked up case-insensitively, for identity providers returning
+// names with inconsistent casing. The users keep the name of their last update, but are keyed by their lower-cased
+// name, which is lossy: an update of a user whose name only differs by case from the one of a user with a different
+// UID is rejected, like an update of a user with the UID of another one.
+// Users written without this option are rekeyed when opening the database read-write, unless their lower-cased name
+// is already used by another user.
+func WithCaseInsensitiveNames(caseInsensitive bool) Option {
+	return func(o *options) {
+		o.caseInsensitiveNames = caseInsensitive
+	}
+}
+
+// WithReadOnly opens the database read-only, for processes which only read from the cache, so that they don't take
+// the exclusive lock of the database. Writes return ErrReadOnlyCache, and the database must have been created by a
+// process opening it read-write.
+// Read-only processes share the database with each other, but not with a process holding it read-write, like the
+// daemon: New returns ErrDatabaseInUse if such a process doesn't close the database within a second.
+func WithReadOnly() Option {
+	return func(o *options) {
+		o.readOnly = true
+	}
+}
+
+// WithBackgroundMigrations makes New return as soon as the database is opened, with the migrations running in the
+// background, so that a large database doesn't delay the start of the process. Reads return ErrMigrationInProgress
+// until they are done, while writes wait for them. Migrations failing in the background are only logged.
+func WithBackgroundMigrations() Option {
+	return func(o *options) {
+		o.backgroundMigrations = true
+	}
+}
+
 // New creates a new database cache by creating or opening the underlying db.
-func New(cacheDir string) (cache *Cache, err error) {
+func New(cacheDir string, args ...Option) (cache *Cache, err error) {
 	dbPath := filepath.Join(cacheDir, dbName)
 	defer decorate.OnError(&err, "could not create new database object at %q", dbPath)
 
-	db, err := openAndInitDB(dbPath)
+	opts := defaultOptions
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	db, err := openAndInitDB(dbPath, opts.readOnly)
 	if err != nil {
 		return nil, err
 	}
@@ -94,16 +276,92 @@ func New(cacheDir string) (cache *Cache, err error) {
 	// user with the same name but different UID to the UserByID bucket, and overwriting the existing user with the same
 	// name in the UserByName bucket. To clean this up, we remove users from the UserByID bucket that are not in the
 	// UserByName bucket.
-	if err = deleteOrphanedUsers(db); err != nil {
-		return nil, err
+	c := &Cache{
+		db:                   db,
+		mu:                   sync.RWMutex{},
+		groupConflictPolicy:  opts.groupConflictPolicy,
+		compressValues:       opts.compressValues,
+		uniqueHomedirs:       opts.uniqueHomedirs,
+		keepExistingShell:    opts.keepExistingShell,
+		caseInsensitiveNames: opts.caseInsensitiveNames,
+		uidReuseDelay:        opts.uidReuseDelay,
+		recordChecksums:      opts.recordChecksums,
+		largeGroupThreshold:  opts.largeGroupThreshold,
+		now:                  opts.now,
+		migrationsDone:       make(chan struct{}),
+	}
+
+	// The migrations are run by the next process opening the database read-write.
+	if opts.readOnly {
+		close(c.migrationsDone)
+		return c, nil
+	}
+
+	if !opts.backgroundMigrations {
+		defer close(c.migrationsDone)
+		if err = c.runMigrations(); err != nil {
+			return nil, err
+		}
+		return c, nil
 	}
 
-	return &Cache{db: db, mu: sync.RWMutex{}}, nil
+	// Reads are rejected until all the migrations are done, not only while each of them runs. The read lock keeps
+	// Close and Compact from replacing the database handle under the migrations.
+	c.migrating.Add(1)
+	c.mu.RLock()
+	go func() {
+		defer close(c.migrationsDone)
+		defer c.mu.RUnlock()
+		defer c.migrating.Add(-1)
+
+		if err := c.runMigrations(); err != nil {
+			log.Warningf(context.TODO(), "Could not migrate database %q: %v", dbPath, err)
+		}
+	}()
+
+	return c, nil
 }
 
-// openAndInitDB open a pre-existing database and potentially initializes its buckets.
-func openAndInitDB(path string) (*bbolt.DB, error) {
-	db, err := bbolt.Open(path, 0600, nil)
+// runMigrations runs the migrations of the database opened read-write.
+func (c *Cache) runMigrations() error {
+	// Users must be found by their name to not be considered orphaned.
+	if c.caseInsensitiveNames {
+		if err := c.foldUserNames(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.deleteOrphanedUsers(); err != nil {
+		return err
+	}
+
+	if c.recordChecksums {
+		if err := c.addRecordChecksums(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WaitForMigrations waits for the migrations run when opening the database to be done, for callers which need to read
+// from the cache right after opening it with WithBackgroundMigrations.
+func (c *Cache) WaitForMigrations() {
+	<-c.migrationsDone
+}
+
+// openAndInitDB open a pre-existing database and potentially initializes its buckets, unless it's opened read-only.
+func openAndInitDB(path string, readOnly bool) (*bbolt.DB, error) {
+	opts := *bbolt.DefaultOptions
+	opts.ReadOnly = readOnly
+	if readOnly {
+		// Readers don't wait forever for the exclusive lock of a read-write process to be released.
+		opts.Timeout = readOnlyOpenTimeout
+	}
+	db, err := bbolt.Open(path, 0600, &opts)
+	if errors.Is(err, bbolt.ErrTimeout) {
+		return nil, ErrDatabaseInUse
+	}
 	if err != nil {
 		return nil, fmt.Errorf("can't open database file: %v", err)
 	}
@@ -117,6 +375,10 @@ func openAndInitDB(path string) (*bbolt.DB, error) {
 		return nil, fmt.Errorf("wrong file permission for %s: %o", path, perm)
 	}
 
+	if readOnly {
+		return db, nil
+	}
+
 	// Create buckets
 	err = db.Update(func(tx *bbolt.Tx) error {
 		var allBucketsNames []string
@@ -126,6 +388,9 @@ func openAndInitDB(path string) (*bbolt.DB, error) {
 				return err
 			}
 		}
+		for _, bucket := range optionalBuckets {
+			allBucketsNames = append(allBucketsNames, string(bucket))
+		}
 
 		// Clear up any unknown buckets
 		var bucketNamesToDelete [][]byte
@@ -157,6 +422,13 @@ func openAndInitDB(path string) (*bbolt.DB, error) {
 func (c *Cache) Close() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+
+	c.stateMu.Lock()
+	c.closed = true
+	c.stateMu.Unlock()
+
+	c.unsubscribeAll()
+
 	return c.db.Close()
 }
 
@@ -165,37 +437,61 @@ func RemoveDb(cacheDir string) error {
 	return os.Remove(filepath.Join(cacheDir, dbName))
 }
 
-// bucketWithName is a wrapper adding the name on top of a bbolt Bucket.
+// bucketWithName is a wrapper adding the name and how values are written on top of a bbolt Bucket.
 type bucketWithName struct {
-	name string
+	name     string
+	compress bool
+	checksum bool
+	// foldKeys is set if the string keys of the bucket are lower-cased.
+	foldKeys bool
 	*bbolt.Bucket
 }
 
+// nameKey returns the key of name in the bucket, lower-cased if the bucket folds its keys.
+func (b bucketWithName) nameKey(name string) []byte {
+	if b.foldKeys {
+		return []byte(strings.ToLower(name))
+	}
+	return []byte(name)
+}
+
 // getAllBuckets returns all buckets that should be stored in the database.
-func getAllBuckets(tx *bbolt.Tx) (map[string]bucketWithName, error) {
+func (c *Cache) getAllBuckets(tx *bbolt.Tx) (map[string]bucketWithName, error) {
 	buckets := make(map[string]bucketWithName)
 	for _, name := range allBuckets {
 		b := tx.Bucket(name)
 		if b == nil {
 			return nil, fmt.Errorf("bucket %v not found", name)
 		}
-		buckets[string(name)] = bucketWithName{name: string(name), Bucket: b}
+		buckets[string(name)] = c.newBucketWithName(string(name), b)
 	}
 
 	return buckets, nil
 }
 
 // getBucket returns one bucket for a given name.
-func getBucket(tx *bbolt.Tx, name string) (bucketWithName, error) {
+func (c *Cache) getBucket(tx *bbolt.Tx, name string) (bucketWithName, error) {
 	b := tx.Bucket([]byte(name))
 	if b == nil {
 		return bucketWithName{}, fmt.Errorf("bucket %v not found", name)
 	}
-	return bucketWithName{name: name, Bucket: b}, nil
+	return c.newBucketWithName(name, b), nil
+}
+
+// newBucketWithName wraps the bucket matching name, with the encoding of the cache.
+func (c *Cache) newBucketWithName(name string, b *bbolt.Bucket) bucketWithName {
+	return bucketWithName{
+		name:     name,
+		compress: c.compressValues,
+		checksum: c.recordChecksums,
+		foldKeys: c.caseInsensitiveNames && name == userByNameBucketName,
+		Bucket:   b,
+	}
 }
 
 // getFromBucket is a generic function to get any value of given type from a bucket. It returns an error if
-// the returned value (json) could not be unmarshalled to the returned struct.
+// the returned value (json) could not be unmarshalled to the returned struct, matching ErrCorrupted if its checksum
+// doesn't match.
 func getFromBucket[T any, K uint32 | string](bucket bucketWithName, key K) (T, error) {
 	// TODO: switch to https://github.com/golang/go/issues/45380 if accepted.
 	var k []byte
@@ -203,7 +499,7 @@ func getFromBucket[T any, K uint32 | string](bucket bucketWithName, key K) (T, e
 	case uint32:
 		k = []byte(strconv.FormatUint(uint64(v), 10))
 	case string:
-		k = []byte(v)
+		k = bucket.nameKey(v)
 	default:
 		panic(fmt.Sprintf("unhandled type: %T", key))
 	}
@@ -215,13 +511,97 @@ func getFromBucket[T any, K uint32 | string](bucket bucketWithName, key K) (T, e
 		return r, NoDataFoundError{key: string(k), bucketName: bucket.name}
 	}
 
-	if err := json.Unmarshal(data, &r); err != nil {
-		return r, fmt.Errorf("can't unmarshal {%s: %s} in bucket %q: %v", string(k), string(data), bucket.name, err)
+	if err := unmarshalValue(data, &r); err != nil {
+		return r, fmt.Errorf("can't unmarshal {%s: %s} in bucket %q: %w", string(k), string(data), bucket.name, err)
 	}
 
 	return r, nil
 }
 
+// deflateValueVersion is the version byte prefixing values compressed with deflate. Plain JSON values have no prefix,
+// as they can't start with this byte.
+const deflateValueVersion byte = 1
+
+// encodeValue returns the value to store for the JSON data, compressing it if requested and worth it, and prefixed
+// with its checksum if requested.
+func encodeValue(data []byte, compress, checksum bool) []byte {
+	v := data
+	if compress {
+		v = compressValue(data)
+	}
+	if checksum {
+		return withChecksum(v)
+	}
+	return v
+}
+
+// compressValue returns the compressed value of the JSON data, or the data itself if compressing it isn't worth it.
+func compressValue(data []byte) []byte {
+	var b bytes.Buffer
+	b.WriteByte(deflateValueVersion)
+	w := deflateWriters.Get().(*flate.Writer)
+	defer deflateWriters.Put(w)
+	w.Reset(&b)
+	// Writing to a bytes.Buffer can't fail.
+	_, _ = w.Write(data)
+	_ = w.Close()
+
+	// Small values don't compress well, keep them as is.
+	if b.Len() >= len(data) {
+		return data
+	}
+	return b.Bytes()
+}
+
+// deflateWriters caches the deflate writers, which are expensive to allocate.
+var deflateWriters = sync.Pool{
+	New: func() any {
+		// The compression level is valid, so this can't fail.
+		w, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return w
+	},
+}
+
+// decodeValue returns the JSON data of a stored value, whether it was compressed or not. It returns ErrCorrupted if
+// the value was stored with a checksum which doesn't match.
+func decodeValue(value []byte) ([]byte, error) {
+	value, err := verifyChecksum(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 || value[0] != deflateValueVersion {
+		return value, nil
+	}
+
+	data, err := io.ReadAll(flate.NewReader(bytes.NewReader(value[1:])))
+	if err != nil {
+		return nil, fmt.Errorf("can't decompress value: %v", err)
+	}
+	return data, nil
+}
+
+// unmarshalValue decodes a stored value into v.
+func unmarshalValue(value []byte, v any) error {
+	data, err := decodeValue(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// ErrNoEntry is returned by the lookups of a single user or group when no entry matches them. It wraps the
+// NoDataFoundError of the lookup, so that it can be told apart from other missing records, which mean that the
+// database is inconsistent.
+var ErrNoEntry = errors.New("no entry found")
+
+// noEntryError wraps err with ErrNoEntry if it's a NoDataFoundError, and returns it unchanged otherwise.
+func noEntryError(err error) error {
+	if !errors.Is(err, NoDataFoundError{}) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrNoEntry, err)
+}
+
 // NoDataFoundError is returned when we didn’t find a matching entry.
 type NoDataFoundError struct {
 	key        string
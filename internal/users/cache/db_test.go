@@ -1,16 +1,27 @@
 package cache_test
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"io/fs"
+	"math"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/ubuntu/authd/internal/testutils"
 	"github.com/ubuntu/authd/internal/users/cache"
 	cachetestutils "github.com/ubuntu/authd/internal/users/cache/testutils"
+	"go.etcd.io/bbolt"
 )
 
 func TestNew(t *testing.T) {
@@ -20,9 +31,10 @@ func TestNew(t *testing.T) {
 	perm0000 := os.FileMode(0000)
 
 	tests := map[string]struct {
-		dbFile          string
-		perm            *fs.FileMode
-		corruptedDbFile bool
+		dbFile               string
+		perm                 *fs.FileMode
+		corruptedDbFile      bool
+		backgroundMigrations bool
 
 		wantErr bool
 	}{
@@ -30,6 +42,7 @@ func TestNew(t *testing.T) {
 		"New with already existing database":                     {dbFile: "multiple_users_and_groups"},
 		"New recreates any missing buckets and delete unknowns":  {dbFile: "database_with_unknown_bucket"},
 		"New removes orphaned user records from UserByID bucket": {dbFile: "orphaned_user_record"},
+		"New removes orphaned user records in the background":    {dbFile: "orphaned_user_record", backgroundMigrations: true},
 
 		"Error on cacheDir non existent cacheDir":      {dbFile: "-", wantErr: true},
 		"Error on corrupted db file":                   {corruptedDbFile: true, wantErr: true},
@@ -67,13 +80,18 @@ func TestNew(t *testing.T) {
 				}
 			}
 
-			c, err := cache.New(cacheDir)
+			var opts []cache.Option
+			if tc.backgroundMigrations {
+				opts = append(opts, cache.WithBackgroundMigrations())
+			}
+			c, err := cache.New(cacheDir, opts...)
 			if tc.wantErr {
 				require.Error(t, err, "New should return an error but didn't")
 				return
 			}
 			require.NoError(t, err)
 			defer c.Close()
+			c.WaitForMigrations()
 
 			got, err := cachetestutils.DumpToYaml(c)
 			require.NoError(t, err, "Created database should be valid yaml content")
@@ -90,6 +108,167 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestValueCompression(t *testing.T) {
+	t.Parallel()
+
+	cacheDir := t.TempDir()
+	user1 := cache.NewUserDB("user1", 1111, 11111, "User1 gecos", "/home/user1", "/bin/bash")
+	user2 := cache.NewUserDB("user2", 2222, 11111, "User2 gecos", "/home/user2", "/bin/bash")
+	group := cache.NewGroupDB("group1", 11111, nil)
+
+	// Write a first user compressed.
+	c, err := cache.New(cacheDir, cache.WithValueCompression(true))
+	require.NoError(t, err, "Setup: could not create cache")
+	err = c.UpdateUserEntry(user1, []cache.GroupDB{group})
+	require.NoError(t, err, "UpdateUserEntry should not return an error with compression")
+	require.NoError(t, c.Close(), "Setup: could not close cache")
+
+	db, err := bbolt.Open(filepath.Join(cacheDir, cachetestutils.DbName), 0600, nil)
+	require.NoError(t, err, "Setup: could not open database")
+	err = db.View(func(tx *bbolt.Tx) error {
+		v := tx.Bucket([]byte("UserByName")).Get([]byte("user1"))
+		require.NotEmpty(t, v, "User should be stored")
+		require.NotEqual(t, byte('{'), v[0], "User should not be stored as plain JSON")
+		return nil
+	})
+	require.NoError(t, err, "Setup: could not read database")
+	require.NoError(t, db.Close(), "Setup: could not close database")
+
+	// Reopen without compression, and mix both formats.
+	c = initCacheFromDir(t, cacheDir)
+	got, err := c.UserByName("user1")
+	require.NoError(t, err, "Compressed user should be readable without compression enabled")
+	require.Equal(t, user1, got, "Compressed user should be read as written")
+
+	err = c.UpdateUserEntry(user2, []cache.GroupDB{group})
+	require.NoError(t, err, "UpdateUserEntry should not return an error without compression")
+
+	dump, err := cachetestutils.DumpToYaml(c)
+	require.NoError(t, err, "Database with mixed values should be dumped")
+	want := testutils.LoadWithUpdateFromGolden(t, dump)
+	require.Equal(t, want, dump, "Did not get expected database content")
+}
+
+func TestRecordChecksums(t *testing.T) {
+	t.Parallel()
+
+	cacheDir := t.TempDir()
+	cachetestutils.CreateDBFromYAML(t, filepath.Join("testdata", "one_user_and_group.db.yaml"), cacheDir)
+
+	// Opening the database with checksums adds them to the existing records.
+	c, err := cache.New(cacheDir, cache.WithRecordChecksums(true))
+	require.NoError(t, err, "New should add checksums to the existing records")
+	want, err := c.UserByName("user1")
+	require.NoError(t, err, "UserByName should return the user with its checksum verified")
+	require.NoError(t, c.Close(), "Setup: could not close cache")
+
+	// Corrupt the user record, keeping it valid JSON.
+	db, err := bbolt.Open(filepath.Join(cacheDir, cachetestutils.DbName), 0600, nil)
+	require.NoError(t, err, "Setup: could not open database")
+	err = db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket([]byte("UserByName"))
+		v := b.Get([]byte("user1"))
+		require.Equal(t, byte(2), v[0], "User should be stored with a checksum")
+		corrupted := []byte(strings.Replace(string(v), `"UID":1111`, `"UID":1011`, 1))
+		require.NotEqual(t, v, corrupted, "Setup: user record should be stored as plain JSON")
+		return b.Put([]byte("user1"), corrupted)
+	})
+	require.NoError(t, err, "Setup: could not corrupt database")
+	require.NoError(t, db.Close(), "Setup: could not close database")
+
+	// Corrupted records are detected whether checksums are enabled or not.
+	for _, enabled := range []bool{true, false} {
+		c = initCacheFromDir(t, cacheDir, cache.WithRecordChecksums(enabled))
+		_, err = c.UserByName("user1")
+		require.ErrorIs(t, err, cache.ErrCorrupted, "UserByName should detect the corrupted record")
+		s := c.State()
+		require.Equal(t, cache.CacheInError, s.Status, "State should report the cache in error after reading a corrupted record")
+		require.ErrorIs(t, s.LastError, cache.ErrCorrupted, "State should report the corrupted record")
+
+		got, err := c.UserByID(want.UID)
+		require.NoError(t, err, "UserByID should return the record which was not corrupted")
+		require.Equal(t, want, got, "UserByID should return the user as written")
+		require.Equal(t, cache.CacheHealthy, c.State().Status, "State should be healthy again after a successful transaction")
+		require.NoError(t, c.Close(), "Setup: could not close cache")
+	}
+}
+
+func BenchmarkUpdateUserEntry(b *testing.B) {
+	groups := []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)}
+	for i := range 50 {
+		groups = append(groups, cache.NewGroupDB(fmt.Sprintf("supplementary-group-%d", i), uint32(20000+i), nil))
+	}
+
+	for name, compress := range map[string]bool{"Uncompressed": false, "Compressed": true} {
+		b.Run(name, func(b *testing.B) {
+			cacheDir := b.TempDir()
+			c, err := cache.New(cacheDir, cache.WithValueCompression(compress))
+			require.NoError(b, err, "Setup: could not create cache")
+			defer c.Close()
+
+			b.ResetTimer()
+			for i := range b.N {
+				uid := uint32(100000 + i%1000)
+				u := cache.NewUserDB(fmt.Sprintf("user%d", uid), uid, 11111,
+					fmt.Sprintf("User %d,Room 42,+1 555 0100,+1 555 0101,Some other information", uid),
+					fmt.Sprintf("/home/user%d", uid), "/bin/bash")
+				if err := c.UpdateUserEntry(u, groups); err != nil {
+					b.Fatalf("UpdateUserEntry should not return an error: %v", err)
+				}
+			}
+			b.StopTimer()
+
+			info, err := os.Stat(filepath.Join(cacheDir, cachetestutils.DbName))
+			require.NoError(b, err, "Could not stat database file")
+			b.ReportMetric(float64(info.Size()), "db-bytes")
+		})
+	}
+}
+
+func BenchmarkUpdateUserEntryLargeGroup(b *testing.B) {
+	groups := []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)}
+
+	for _, members := range []int{1000, 10000, 100000} {
+		for name, threshold := range map[string]int{"InRecord": math.MaxInt, "InBucket": 100} {
+			b.Run(fmt.Sprintf("%s/%d_members", name, members), func(b *testing.B) {
+				// The group is created with all its members at once, as adding them one by one is what we measure.
+				uids := make([]string, members)
+				for i := range uids {
+					uids[i] = strconv.Itoa(100000 + i)
+				}
+				db := fmt.Sprintf(`GroupByID:
+  "11111": '{"Name":"group1","GID":11111}'
+GroupByName:
+  group1: '{"Name":"group1","GID":11111}'
+GroupToUsers:
+  "11111": '{"GID":11111,"UIDs":[%s]}'
+`, strings.Join(uids, ","))
+
+				cacheDir := b.TempDir()
+				err := cachetestutils.DbfromYAML(strings.NewReader(db), cacheDir)
+				require.NoError(b, err, "Setup: could not create database")
+				c, err := cache.New(cacheDir, cache.WithLargeGroupThreshold(threshold))
+				require.NoError(b, err, "Setup: could not create cache")
+				defer c.Close()
+
+				newUser := func(uid uint32) cache.UserDB {
+					return cache.NewUserDB(fmt.Sprintf("user%d", uid), uid, 11111, "", fmt.Sprintf("/home/user%d", uid), "/bin/bash")
+				}
+				// The first member added moves the existing ones to the bucket of the group, if needed.
+				err = c.UpdateUserEntry(newUser(uint32(100000+members)), groups)
+				require.NoError(b, err, "Setup: could not add first member")
+
+				b.ResetTimer()
+				for i := range b.N {
+					if err := c.UpdateUserEntry(newUser(uint32(100001+members+i)), groups); err != nil {
+						b.Fatalf("UpdateUserEntry should not return an error: %v", err)
+					}
+				}
+			})
+		}
+	}
+}
+
 func TestUpdateUserEntry(t *testing.T) {
 	t.Parallel()
 
@@ -138,6 +317,23 @@ func TestUpdateUserEntry(t *testing.T) {
 			// These values don't matter. We just want to make sure they are the same as the ones provided by the manager.
 			LastPwdChange: -1, MaxPwdAge: -1, PwdWarnPeriod: -1, PwdInactivity: -1, MinPwdAge: -1, ExpirationDate: -1,
 		},
+		"user1-without-shell": {
+			Name:  "user1",
+			UID:   1111,
+			Gecos: "New user1 gecos",
+			Dir:   "/home/user1",
+			// These values don't matter. We just want to make sure they are the same as the ones provided by the manager.
+			LastPwdChange: -1, MaxPwdAge: -1, PwdWarnPeriod: -1, PwdInactivity: -1, MinPwdAge: -1, ExpirationDate: -1,
+		},
+		"newuser-homedir-of-user1": {
+			Name:  "newuser",
+			UID:   5555,
+			Gecos: "Newuser gecos",
+			Dir:   "/home/user1",
+			Shell: "/bin/bash",
+			// These values don't matter. We just want to make sure they are the same as the ones provided by the manager.
+			LastPwdChange: -1, MaxPwdAge: -1, PwdWarnPeriod: -1, PwdInactivity: -1, MinPwdAge: -1, ExpirationDate: -1,
+		},
 		"user3": {
 			Name:  "user3",
 			UID:   3333,
@@ -153,12 +349,20 @@ func TestUpdateUserEntry(t *testing.T) {
 		"newgroup1": cache.NewGroupDB("newgroup1", 11111, nil),
 		"group2":    cache.NewGroupDB("group2", 22222, nil),
 		"group3":    cache.NewGroupDB("group3", 33333, nil),
+
+		"group1-new-gid":       cache.NewGroupDB("group1", 12345, nil),
+		"group1-gid-of-group2": cache.NewGroupDB("group1", 22222, nil),
+		"commongroup-new-gid":  cache.NewGroupDB("commongroup", 88888, nil),
 	}
 
 	tests := map[string]struct {
-		userCase   string
-		groupCases []string
-		dbFile     string
+		userCase            string
+		groupCases          []string
+		dbFile              string
+		groupConflictPolicy cache.GroupConflictPolicy
+		uniqueHomedirs      bool
+		keepExistingShell   bool
+		uidRange            *[2]uint32
 
 		wantErr bool
 	}{
@@ -168,9 +372,12 @@ func TestUpdateUserEntry(t *testing.T) {
 		"Insert new user without optional gecos field": {userCase: "user1-without-gecos"},
 
 		// User and Group updates
-		"Update user by changing attributes":                      {userCase: "user1-new-attributes", dbFile: "one_user_and_group"},
-		"Update user does not change homedir if it exists":        {userCase: "user1-new-homedir", dbFile: "one_user_and_group"},
-		"Update user by removing optional gecos field if not set": {userCase: "user1-without-gecos", dbFile: "one_user_and_group"},
+		"Update user by changing attributes":                          {userCase: "user1-new-attributes", dbFile: "one_user_and_group"},
+		"Update user does not change homedir if it exists":            {userCase: "user1-new-homedir", dbFile: "one_user_and_group"},
+		"Update user does not remove optional gecos field if not set": {userCase: "user1-without-gecos", dbFile: "one_user_and_group"},
+		"Update user does not remove shell if not set":                {userCase: "user1-without-shell", dbFile: "one_user_and_group"},
+		"Update user does not change shell if kept":                   {userCase: "user1-new-attributes", dbFile: "one_user_and_group", keepExistingShell: true},
+		"Insert new user with its shell even if shell is kept":        {keepExistingShell: true},
 
 		// Group updates
 		"Update user by adding a new group":         {groupCases: []string{"group1", "group2"}, dbFile: "one_user_and_group"},
@@ -187,9 +394,39 @@ func TestUpdateUserEntry(t *testing.T) {
 		"Invalid value entry in userByName recreates entries":                         {dbFile: "invalid_entry_in_userByName"},
 		"Invalid value entries in other user and groups don't impact current request": {dbFile: "invalid_entries_but_user_and_group1"},
 
+		// Homedir conflicts
+		"Insert new user with the homedir of another user":                         {userCase: "newuser-homedir-of-user1", dbFile: "one_user_and_group"},
+		"Update user with unique homedirs":                                         {dbFile: "multiple_users_and_groups", uniqueHomedirs: true},
+		"Update user does not change homedir if it exists with unique homedirs":    {userCase: "user1-new-homedir", dbFile: "one_user_and_group", uniqueHomedirs: true},
+		"Error when new user has the homedir of another user with unique homedirs": {userCase: "newuser-homedir-of-user1", dbFile: "one_user_and_group", uniqueHomedirs: true, wantErr: true},
+
+		// Authoritative UID range
+		"Insert new user in the authoritative UID range":             {uidRange: &[2]uint32{1000, 1999}},
+		"Error when new user is outside the authoritative UID range": {uidRange: &[2]uint32{2000, 2999}, wantErr: true},
+
+		// Group name conflicts
+		"Move group to new gid with prefer new policy": {
+			userCase: "user3", groupCases: []string{"group3", "commongroup-new-gid"}, dbFile: "multiple_users_and_groups",
+			groupConflictPolicy: cache.GroupConflictPreferNew,
+		},
+		"Move primary group to new gid with prefer new policy": {
+			groupCases: []string{"group1-new-gid"}, dbFile: "one_user_and_group",
+			groupConflictPolicy: cache.GroupConflictPreferNew,
+		},
+		"Keep existing gid with prefer existing policy": {
+			userCase: "user3", groupCases: []string{"group3", "commongroup-new-gid"}, dbFile: "multiple_users_and_groups",
+			groupConflictPolicy: cache.GroupConflictPreferExisting,
+		},
+		"Keep existing primary group gid with prefer existing policy": {
+			groupCases: []string{"group1-new-gid"}, dbFile: "one_user_and_group",
+			groupConflictPolicy: cache.GroupConflictPreferExisting,
+		},
+
 		// Renaming errors
-		"Error when user has conflicting uid":  {userCase: "user1-new-name", dbFile: "one_user_and_group", wantErr: true},
-		"Error when group has conflicting gid": {groupCases: []string{"newgroup1"}, dbFile: "one_user_and_group", wantErr: true},
+		"Error when user has conflicting uid":        {userCase: "user1-new-name", dbFile: "one_user_and_group", wantErr: true},
+		"Error when group has conflicting gid":       {groupCases: []string{"newgroup1"}, dbFile: "one_user_and_group", wantErr: true},
+		"Error when group has conflicting name":      {groupCases: []string{"group1-new-gid"}, dbFile: "one_user_and_group", wantErr: true},
+		"Error when moved group has conflicting gid": {groupCases: []string{"group1-gid-of-group2"}, dbFile: "multiple_users_and_groups", groupConflictPolicy: cache.GroupConflictPreferNew, wantErr: true},
 
 		// Error cases
 		"Error on invalid value entry in groupByID":                                 {dbFile: "invalid_entry_in_groupByID", wantErr: true},
@@ -203,7 +440,8 @@ func TestUpdateUserEntry(t *testing.T) {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			c := initCache(t, tc.dbFile)
+			c := initCache(t, tc.dbFile, cache.WithGroupConflictPolicy(tc.groupConflictPolicy), cache.WithUniqueHomedirs(tc.uniqueHomedirs),
+				cache.WithKeepExistingShell(tc.keepExistingShell))
 
 			if tc.userCase == "" {
 				tc.userCase = "user1"
@@ -219,7 +457,12 @@ func TestUpdateUserEntry(t *testing.T) {
 			}
 			user.GID = groups[0].GID
 
-			err := c.UpdateUserEntry(user, groups)
+			var opts []cache.UpdateUserEntryOption
+			if tc.uidRange != nil {
+				opts = append(opts, cache.WithAuthoritativeUIDRange(tc.uidRange[0], tc.uidRange[1]))
+			}
+
+			err := c.UpdateUserEntry(user, groups, opts...)
 			if tc.wantErr {
 				require.Error(t, err, "UpdateFromUserInfo should return an error but didn't")
 				return
@@ -235,233 +478,2213 @@ func TestUpdateUserEntry(t *testing.T) {
 	}
 }
 
-func TestUserByID(t *testing.T) {
+func TestValidateUserEntry(t *testing.T) {
 	t.Parallel()
 
+	newUser := func(name string, uid uint32, dir string) cache.UserDB {
+		return cache.NewUserDB(name, uid, 0, "", dir, "/bin/bash")
+	}
+	user1 := newUser("user1", 1111, "/home/user1")
+
 	tests := map[string]struct {
-		dbFile string
+		user                cache.UserDB
+		groups              []cache.GroupDB
+		dbFile              string
+		groupConflictPolicy cache.GroupConflictPolicy
+		uniqueHomedirs      bool
+		deletedUID          uint32
 
-		wantErr     bool
-		wantErrType error
+		wantErr bool
 	}{
-		"Get existing user": {dbFile: "one_user_and_group"},
+		"Validate new user":      {},
+		"Validate existing user": {dbFile: "one_user_and_group"},
+		"Validate existing user with a new homedir with warning": {user: newUser("user1", 1111, "/new/home/user1"), dbFile: "one_user_and_group"},
+		"Validate group kept with prefer existing policy with warning": {
+			groups: []cache.GroupDB{cache.NewGroupDB("group1", 12345, nil)}, dbFile: "one_user_and_group",
+			groupConflictPolicy: cache.GroupConflictPreferExisting,
+		},
+		"Validate group moved with prefer new policy with warning": {
+			groups: []cache.GroupDB{cache.NewGroupDB("group1", 12345, nil)}, dbFile: "one_user_and_group",
+			groupConflictPolicy: cache.GroupConflictPreferNew,
+		},
+		"Validate group taking the GID a moved group frees": {
+			groups:              []cache.GroupDB{cache.NewGroupDB("newgroup1", 11111, nil), cache.NewGroupDB("group1", 12345, nil)},
+			dbFile:              "one_user_and_group",
+			groupConflictPolicy: cache.GroupConflictPreferNew,
+		},
 
-		"Error on missing user":           {wantErrType: cache.NoDataFoundError{}},
-		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByID", wantErr: true},
+		"Error when user has conflicting uid":   {user: newUser("newuser1", 1111, "/home/newuser1"), dbFile: "one_user_and_group", wantErr: true},
+		"Error when group has conflicting gid":  {groups: []cache.GroupDB{cache.NewGroupDB("newgroup1", 11111, nil)}, dbFile: "one_user_and_group", wantErr: true},
+		"Error when group has conflicting name": {groups: []cache.GroupDB{cache.NewGroupDB("group1", 12345, nil)}, dbFile: "one_user_and_group", wantErr: true},
+		"Error when moved group has conflicting gid": {
+			groups: []cache.GroupDB{cache.NewGroupDB("group1", 22222, nil)}, dbFile: "multiple_users_and_groups",
+			groupConflictPolicy: cache.GroupConflictPreferNew, wantErr: true,
+		},
+		"Error when groups of the entry have the same gid": {
+			groups: []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil), cache.NewGroupDB("group2", 11111, nil)}, wantErr: true,
+		},
+		"Error when new user has the homedir of another user with unique homedirs": {
+			user: newUser("newuser", 2222, "/home/user1"), dbFile: "one_user_and_group", uniqueHomedirs: true, wantErr: true,
+		},
+		"Error when new user has the UID of a deleted user": {
+			user: newUser("newuser1", 1111, "/home/newuser1"), dbFile: "one_user_and_group", deletedUID: 1111, wantErr: true,
+		},
+		"Error on invalid value entry in userByID": {dbFile: "invalid_entry_in_userByID", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			c := initCache(t, tc.dbFile)
+			c := initCache(t, tc.dbFile, cache.WithGroupConflictPolicy(tc.groupConflictPolicy), cache.WithUniqueHomedirs(tc.uniqueHomedirs),
+				cache.WithUIDReuseDelay(time.Hour))
+			if tc.deletedUID != 0 {
+				require.NoError(t, c.DeleteUser(tc.deletedUID), "Setup: DeleteUser should not return an error")
+			}
 
-			got, err := c.UserByID(1111)
-			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+			if tc.user.Name == "" {
+				tc.user = user1
+			}
+			if tc.groups == nil {
+				tc.groups = []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)}
+			}
+			tc.user.GID = tc.groups[0].GID
+
+			before, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Setup: could not dump database")
+
+			warnings, err := c.ValidateUserEntry(tc.user, tc.groups)
+			after, dumpErr := cachetestutils.DumpToYaml(c)
+			require.NoError(t, dumpErr, "Setup: could not dump database")
+			require.Equal(t, before, after, "ValidateUserEntry should not change the database")
+
+			// The update should fail exactly when the validation does.
+			updateErr := c.UpdateUserEntry(tc.user, tc.groups)
+			if tc.wantErr {
+				require.Error(t, err, "ValidateUserEntry should return an error but didn't")
+				require.Error(t, updateErr, "UpdateUserEntry should fail like ValidateUserEntry")
+				return
+			}
+			require.NoError(t, err, "ValidateUserEntry should not return an error")
+			require.NoError(t, updateErr, "UpdateUserEntry should succeed like ValidateUserEntry")
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, warnings)
+			require.Equal(t, want, warnings, "ValidateUserEntry should return the expected warnings")
 		})
 	}
 }
 
-func TestUserByName(t *testing.T) {
+func TestCopyGroupMembership(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		dbFile string
+		dbFile       string
+		fromUID      uint32
+		toUID        uint32
+		primaryGroup bool
 
-		wantErrType error
 		wantErr     bool
+		wantErrType error
 	}{
-		"Get existing user": {dbFile: "one_user_and_group"},
-
-		"Error on missing user":           {wantErrType: cache.NoDataFoundError{}},
-		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByName", wantErr: true},
+		"Copy supplementary groups":                       {fromUID: 2222, toUID: 1111},
+		"Copy supplementary groups and the primary group": {fromUID: 2222, toUID: 1111, primaryGroup: true},
+		"Remove supplementary groups not in the source":   {fromUID: 1111, toUID: 2222},
+		"Copy from a user to themselves":                  {fromUID: 2222, toUID: 2222},
+
+		"Error on missing source user":      {fromUID: 5555, toUID: 1111, wantErrType: cache.NoDataFoundError{}},
+		"Error on missing destination user": {fromUID: 2222, toUID: 5555, wantErrType: cache.NoDataFoundError{}},
+		"Error on invalid database entry":   {dbFile: "invalid_entries_but_user_and_group1", fromUID: 2222, toUID: 1111, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			if tc.dbFile == "" {
+				tc.dbFile = "multiple_users_and_groups"
+			}
 			c := initCache(t, tc.dbFile)
 
-			got, err := c.UserByName("user1")
-			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+			var args []cache.CopyGroupMembershipOption
+			if tc.primaryGroup {
+				args = append(args, cache.WithPrimaryGroup())
+			}
+
+			err := c.CopyGroupMembership(tc.fromUID, tc.toUID, args...)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "CopyGroupMembership should return expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "CopyGroupMembership should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
 		})
 	}
 }
 
-func TestAllUsers(t *testing.T) {
+func TestUpdateUserEntryConcurrently(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "")
+
+	// Writers only take the read lock of the cache, so this runs with readers and writers all at once.
+	const calls = 50
+	shared := cache.NewGroupDB("shared", 50000, nil)
+	errs := make(chan error, 2*calls)
+	var wg sync.WaitGroup
+	for i := range uint32(calls) {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			name := fmt.Sprintf("user%d", i)
+			u := cache.NewUserDB(name, 10000+i, 20000+i, "", "/home/"+name, "/bin/bash")
+			errs <- c.UpdateUserEntry(u, []cache.GroupDB{cache.NewGroupDB(name, 20000+i, nil), shared})
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := c.AllUsers()
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err, "Concurrent calls should not return an error")
+	}
+	users, err := c.AllUsers()
+	require.NoError(t, err, "AllUsers should not return an error")
+	require.Len(t, users, calls, "All concurrently updated users should be in the cache")
+	g, err := c.GroupByID(shared.GID)
+	require.NoError(t, err, "GroupByID should return the shared group")
+	require.Len(t, g.Users, calls, "All concurrently updated users should be members of the shared group")
+	c.AssertConsistent(t)
+}
+
+func TestUpdateUserEntryWithDeterministicUID(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		dbFile string
+		name     string
+		uid      uint32
+		hash     uint32
+		uidRange *[2]uint32
 
+		wantUID uint32
 		wantErr bool
 	}{
-		"Get one user":       {dbFile: "one_user_and_group"},
-		"Get multiple users": {dbFile: "multiple_users_and_groups"},
-
-		"Get users only rely on valid userByID": {dbFile: "partially_valid_multiple_users_and_groups_only_userByID"},
-
-		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", wantErr: true},
+		"Compute UID of new user":                         {name: "newuser", hash: 5555, wantUID: 5555},
+		"Keep UID of existing user":                       {name: "user1", hash: 5555, wantUID: 1111},
+		"Keep UID passed for new user":                    {name: "newuser", uid: 3333, hash: 5555, wantUID: 3333},
+		"Compute UID in the authoritative UID range":      {name: "newuser", hash: 2500, uidRange: &[2]uint32{1000, 1999}, wantUID: 1500},
+		"Compute UID in the full range":                   {name: "newuser", hash: 0, wantUID: 1},
+		"Probe next UID on collision":                     {name: "newuser", hash: 1111, wantUID: 1112},
+		"Probe from the start of the range on collision":  {name: "newuser", hash: 1111, uidRange: &[2]uint32{1110, 1111}, wantUID: 1110},
+		"Error when there is no free UID left in range":   {name: "newuser", hash: 1111, uidRange: &[2]uint32{1111, 1111}, wantErr: true},
+		"Error when the UID passed is outside of a range": {name: "newuser", uid: 3333, uidRange: &[2]uint32{1000, 1999}, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			c := initCache(t, tc.dbFile)
+			c := initCache(t, "one_user_and_group")
 
-			got, err := c.AllUsers()
-			requireGetAssertions(t, got, tc.wantErr, nil, err)
+			opts := []cache.UpdateUserEntryOption{cache.WithDeterministicUID(func(string) uint32 { return tc.hash })}
+			if tc.uidRange != nil {
+				opts = append(opts, cache.WithAuthoritativeUIDRange(tc.uidRange[0], tc.uidRange[1]))
+			}
+
+			usr := cache.NewUserDB(tc.name, tc.uid, 11111, "", "/home/"+tc.name, "/bin/bash")
+			err := c.UpdateUserEntry(usr, []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)}, opts...)
+			if tc.wantErr {
+				require.Error(t, err, "UpdateUserEntry should return an error")
+				return
+			}
+			require.NoError(t, err, "UpdateUserEntry should not return an error")
+
+			got, err := c.UserByName(tc.name)
+			require.NoError(t, err, "UserByName should not return an error")
+			require.Equal(t, tc.wantUID, got.UID, "UpdateUserEntry should have stored the user with the expected UID")
 		})
 	}
 }
 
-func TestGroupByID(t *testing.T) {
+func TestUpdateUserEntryCAS(t *testing.T) {
 	t.Parallel()
 
+	user1 := cache.NewUserDB("user1", 1111, 11111, "New user1 gecos", "/home/user1", "/bin/dash")
+	newUser := cache.NewUserDB("newuser", 5555, 11111, "Newuser gecos", "/home/newuser", "/bin/bash")
+	groups := []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)}
+
 	tests := map[string]struct {
-		dbFile string
+		user            cache.UserDB
+		expectedVersion uint64
+		writeBefore     bool
 
-		wantErr     bool
+		wantVersion uint64
 		wantErrType error
 	}{
-		"Get existing group": {dbFile: "one_user_and_group"},
+		"Update user stored without a version":              {user: user1, wantVersion: 1},
+		"Update user written since the database was loaded": {user: user1, expectedVersion: 1, writeBefore: true, wantVersion: 2},
+		"Insert new user with version 0":                    {user: newUser, wantVersion: 1},
 
-		"Error on missing group":          {wantErrType: cache.NoDataFoundError{}},
-		"Error on invalid database entry": {dbFile: "invalid_entry_in_groupByID", wantErr: true},
-		"Error as missing userByID":       {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers", wantErr: true},
+		"Error when the user was written since it was read": {user: user1, writeBefore: true, wantErrType: cache.ErrVersionConflict},
+		"Error when the expected version is ahead":          {user: user1, expectedVersion: 1, wantErrType: cache.ErrVersionConflict},
+		"Error when the new user is expected to exist":      {user: newUser, expectedVersion: 1, wantErrType: cache.ErrVersionConflict},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			c := initCache(t, tc.dbFile)
+			c := initCache(t, "one_user_and_group")
+			if tc.writeBefore {
+				err := c.UpdateUserEntry(user1, groups)
+				require.NoError(t, err, "Setup: UpdateUserEntry should not fail")
+			}
 
-			got, err := c.GroupByID(11111)
-			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+			err := c.UpdateUserEntryCAS(tc.user, groups, tc.expectedVersion)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "UpdateUserEntryCAS should return expected error")
+				return
+			}
+			require.NoError(t, err, "UpdateUserEntryCAS should not return an error")
+
+			got, version, err := c.UserByIDWithVersion(tc.user.UID)
+			require.NoError(t, err, "UserByIDWithVersion should not return an error")
+			require.Equal(t, tc.user, got, "UpdateUserEntryCAS should have stored the user")
+			require.Equal(t, tc.wantVersion, version, "UpdateUserEntryCAS should have incremented the version")
 		})
 	}
 }
 
-func TestGroupByName(t *testing.T) {
+func TestUpdateUserEntries(t *testing.T) {
 	t.Parallel()
 
+	user1 := cache.NewUserDB("user1", 1111, 11111, "New user1 gecos", "/home/user1", "/bin/dash")
+	user2 := cache.NewUserDB("user2", 2222, 22222, "User2 gecos", "/home/user2", "/bin/bash")
+	user3 := cache.NewUserDB("user3", 3333, 22222, "User3 gecos", "/home/user3", "/bin/bash")
+	group1 := cache.NewGroupDB("group1", 11111, nil)
+	group2 := cache.NewGroupDB("group2", 22222, nil)
+
 	tests := map[string]struct {
-		dbFile string
+		entries []cache.UserEntryUpdate
 
-		wantErr     bool
-		wantErrType error
+		wantErr bool
 	}{
-		"Get existing group": {dbFile: "one_user_and_group"},
-
-		"Error on missing group":          {wantErrType: cache.NoDataFoundError{}},
-		"Error on invalid database entry": {dbFile: "invalid_entry_in_groupByName", wantErr: true},
-		"Error as missing userByID":       {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers", wantErr: true},
+		"Insert new users sharing a group": {entries: []cache.UserEntryUpdate{
+			{User: user2, Groups: []cache.GroupDB{group2, group1}},
+			{User: user3, Groups: []cache.GroupDB{group2}},
+		}},
+		"Update existing user and insert new one": {entries: []cache.UserEntryUpdate{
+			{User: user1, Groups: []cache.GroupDB{group1, group2}},
+			{User: user2, Groups: []cache.GroupDB{group2}},
+		}},
+		"Update broker of users": {entries: []cache.UserEntryUpdate{
+			{User: user1, Groups: []cache.GroupDB{group1}, BrokerID: "other-broker-id"},
+			{User: user2, Groups: []cache.GroupDB{group2}, BrokerID: "other-broker-id"},
+		}},
+		"Insert nothing without entries": {},
+
+		"Error and update nothing on UID used by a different user": {entries: []cache.UserEntryUpdate{
+			{User: user2, Groups: []cache.GroupDB{group2}},
+			{User: cache.NewUserDB("user3", 1111, 22222, "", "/home/user3", "/bin/bash"), Groups: []cache.GroupDB{group2}},
+		}, wantErr: true},
+		"Error and update nothing on GID used by a different group": {entries: []cache.UserEntryUpdate{
+			{User: user2, Groups: []cache.GroupDB{group2}},
+			{User: user3, Groups: []cache.GroupDB{cache.NewGroupDB("group3", 11111, nil)}},
+		}, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
-			c := initCache(t, tc.dbFile)
+			c := initCache(t, "one_user_and_group")
 
-			got, err := c.GroupByName("group1")
-			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+			err := c.UpdateUserEntries(tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "UpdateUserEntries should return an error but didn't")
+			} else {
+				require.NoError(t, err, "UpdateUserEntries should not return an error")
+			}
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
 		})
 	}
 }
 
-func TestAllGroups(t *testing.T) {
+func TestMergeUsers(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		dbFile string
+		dbFile        string
+		keepUID       uint32
+		mergeUID      uint32
+		homedirPolicy cache.HomedirMergePolicy
 
 		wantErr     bool
 		wantErrType error
 	}{
-		"Get one group":       {dbFile: "one_user_and_group"},
-		"Get multiple groups": {dbFile: "multiple_users_and_groups"},
-
-		"Get groups rely on groupByID, groupToUsers, UserByID": {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers_UserByID"},
-
-		"Error on some invalid groups entry":     {dbFile: "invalid_entries_but_user_and_group1", wantErr: true},
-		"Error as not only relying on groupByID": {dbFile: "partially_valid_multiple_users_and_groups_only_groupByID", wantErr: true},
-		"Error as missing userByID":              {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers", wantErr: true},
+		"Merge users with the same homedir":                            {dbFile: "users_with_case_drift", keepUID: 1111, mergeUID: 2222},
+		"Merge users keeping the broker of the kept user":              {keepUID: 1111, mergeUID: 4444, homedirPolicy: cache.HomedirMergeKeepExisting},
+		"Merge users keeping the homedir of the kept user":             {keepUID: 1111, mergeUID: 2222, homedirPolicy: cache.HomedirMergeKeepExisting},
+		"Merge users preferring the homedir of the merged user":        {keepUID: 1111, mergeUID: 2222, homedirPolicy: cache.HomedirMergePreferMerged},
+		"Merge users sharing a group keeps it for the kept user":       {keepUID: 2222, mergeUID: 3333, homedirPolicy: cache.HomedirMergeKeepExisting},
+		"Merge users with the same homedir ignores the homedir policy": {dbFile: "users_with_case_drift", keepUID: 1111, mergeUID: 2222, homedirPolicy: cache.HomedirMergePreferMerged},
+
+		"Error on users with different homedirs":  {keepUID: 1111, mergeUID: 2222, wantErr: true},
+		"Error on merging a user into themselves": {keepUID: 1111, mergeUID: 1111, wantErr: true},
+		"Error on missing kept user":              {keepUID: 5555, mergeUID: 1111, wantErrType: cache.NoDataFoundError{}},
+		"Error on missing merged user":            {keepUID: 1111, mergeUID: 5555, wantErrType: cache.NoDataFoundError{}},
+		"Error on invalid database entry":         {dbFile: "invalid_entries_but_user_and_group1", keepUID: 1111, mergeUID: 2222, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
 
+			if tc.dbFile == "" {
+				tc.dbFile = "multiple_users_and_groups"
+			}
 			c := initCache(t, tc.dbFile)
 
-			got, err := c.AllGroups()
-			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+			err := c.MergeUsers(tc.keepUID, tc.mergeUID, cache.WithHomedirMergePolicy(tc.homedirPolicy))
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "MergeUsers should return expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "MergeUsers should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
 		})
 	}
 }
 
-func TestUpdateBrokerForUser(t *testing.T) {
-	t.Parallel()
-
-	c := initCache(t, "one_user_and_group")
-
-	// Update broker for existent user
-	err := c.UpdateBrokerForUser("user1", "ExampleBrokerID")
-	require.NoError(t, err, "UpdateBrokerForUser for an existent user should not return an error")
-
-	// Error when updating broker for nonexistent user
-	err = c.UpdateBrokerForUser("nonexistent", "ExampleBrokerID")
-	require.Error(t, err, "UpdateBrokerForUser for a nonexistent user should return an error")
-}
-
-func TestBrokerForUser(t *testing.T) {
+func TestUsersModifiedSince(t *testing.T) {
 	t.Parallel()
 
-	c := initCache(t, "multiple_users_and_groups")
-
-	// Get existing BrokerForUser entry
-	gotID, err := c.BrokerForUser("user1")
-	require.NoError(t, err, "BrokerForUser for an existent user should not return an error")
-	wantID := testutils.LoadWithUpdateFromGolden(t, gotID)
-	require.Equal(t, wantID, gotID, "BrokerForUser should return expected broker ID")
-
-	// Get unassigned broker to existent user
-	gotID, err = c.BrokerForUser("userwithoutbroker")
-	require.NoError(t, err, "BrokerForUser for an existent user should not return an error")
-	require.Empty(t, gotID, "BrokerForUser should return empty broker ID for unassigned broker to existent user")
+	tests := map[string]struct {
+		dbFile           string
+		since            time.Time
+		cancelledContext bool
 
-	// Error when user does not exist
-	gotID, err = c.BrokerForUser("nonexistent")
-	require.Error(t, err, "BrokerForUser for a nonexistent user should return an error")
-	require.Empty(t, gotID, "BrokerForUser should return empty broker ID when user entry does not exist")
-}
+		wantErr bool
+	}{
+		"Get users modified since a given time":      {since: time.Date(2005, 1, 1, 0, 0, 0, 0, time.UTC)},
+		"Get all users with a modification time":     {},
+		"Get users modified strictly after the time": {since: time.Date(2006, 6, 1, 10, 8, 4, 0, time.UTC)},
 
-func TestRemoveDb(t *testing.T) {
-	t.Parallel()
+		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", wantErr: true},
+		"Error on cancelled context":        {cancelledContext: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
 
-	c := initCache(t, "multiple_users_and_groups")
-	cacheDir := filepath.Dir(c.DbPath())
+			if tc.dbFile == "" {
+				tc.dbFile = "users_with_modification_times"
+			}
+			c := initCache(t, tc.dbFile)
 
-	// First call should return with no error.
-	require.NoError(t, cache.RemoveDb(cacheDir), "RemoveDb should not return an error on the first call")
-	require.NoFileExists(t, cacheDir, "RemoveDb should remove the database file")
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelledContext {
+				cancel()
+			}
 
-	// Second call should return ErrNotExist as the database file was already removed.
-	require.ErrorIs(t, cache.RemoveDb(cacheDir), fs.ErrNotExist, "RemoveDb should return os.ErrNotExist on the second call")
+			got, err := c.UsersModifiedSince(ctx, tc.since)
+			requireGetAssertions(t, got, tc.wantErr, nil, err)
+		})
+	}
 }
 
-func TestDeleteUser(t *testing.T) {
+func TestLastLogin(t *testing.T) {
 	t.Parallel()
 
 	tests := map[string]struct {
-		dbFile string
+		dbFile   string
+		username string
 
-		wantErr     bool
 		wantErrType error
+		wantErr     bool
 	}{
-		"Delete existing user":                            {dbFile: "one_user_and_group"},
-		"Delete existing user keeping other users intact": {dbFile: "multiple_users_and_groups"},
+		"Get last login of existing user":           {dbFile: "users_with_last_logins"},
+		"Get zero time if the user never logged in": {dbFile: "users_with_last_logins", username: "userneverloggedin"},
 
 		"Error on missing user":           {wantErrType: cache.NoDataFoundError{}},
-		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByID", wantErr: true},
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByName", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.username == "" {
+				tc.username = "user2"
+			}
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.LastLogin(tc.username)
+			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+		})
+	}
+}
+
+func TestAllLastLogins(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErr bool
+	}{
+		"Get last logins of all users": {dbFile: "users_with_last_logins"},
+		"Get no last login if empty":   {},
+
+		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.AllLastLogins()
+			requireGetAssertions(t, got, tc.wantErr, nil, err)
+		})
+	}
+}
+
+func TestExpiredPasswordUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+		now    time.Time
+
+		wantErr bool
+	}{
+		"Get users with expired passwords at the start of the day": {now: time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)},
+		"Get users with expired passwords at the end of the day":   {now: time.Date(2024, 1, 10, 23, 59, 59, 0, time.UTC)},
+		"Get users with expired passwords using UTC days":          {now: time.Date(2024, 1, 11, 0, 30, 0, 0, time.FixedZone("UTC+1", 3600))},
+		"Get users with expired passwords the next day":            {now: time.Date(2024, 1, 11, 0, 0, 0, 0, time.UTC)},
+
+		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.dbFile == "" {
+				tc.dbFile = "users_with_password_ages"
+			}
+
+			c := initCache(t, tc.dbFile, cache.WithNow(func() time.Time { return tc.now }))
+
+			got, err := c.ExpiredPasswordUsers()
+			requireGetAssertions(t, got, tc.wantErr, nil, err)
+		})
+	}
+}
+
+func TestUserIsExpired(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	today := int(now.Unix() / (24 * 60 * 60))
+
+	tests := map[string]struct {
+		lastPwdChange  int
+		maxPwdAge      int
+		expirationDate int
+
+		want bool
+	}{
+		"Not expired without expiration date nor maximum password age": {lastPwdChange: -1, maxPwdAge: -1, expirationDate: -1},
+		"Not expired before expiration date":                           {lastPwdChange: -1, maxPwdAge: -1, expirationDate: today + 1},
+		"Not expired with expiration date 0":                           {lastPwdChange: -1, maxPwdAge: -1, expirationDate: 0},
+		"Not expired before maximum password age":                      {lastPwdChange: today - 10, maxPwdAge: 30, expirationDate: -1},
+
+		"Expired on expiration date":         {lastPwdChange: -1, maxPwdAge: -1, expirationDate: today, want: true},
+		"Expired after expiration date":      {lastPwdChange: -1, maxPwdAge: -1, expirationDate: today - 1, want: true},
+		"Expired after maximum password age": {lastPwdChange: today - 31, maxPwdAge: 30, expirationDate: -1, want: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			u := cache.NewUserDB("user1", 1111, 11111, "", "/home/user1", "/bin/bash")
+			u.LastPwdChange = tc.lastPwdChange
+			u.MaxPwdAge = tc.maxPwdAge
+			u.ExpirationDate = tc.expirationDate
+
+			require.Equal(t, tc.want, u.IsExpired(now), "IsExpired should return the expected result")
+		})
+	}
+}
+
+func TestHealthReport(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile        string
+		idleThreshold time.Duration
+		cancelCtx     bool
+
+		wantErr bool
+	}{
+		"Report health of the cache":                        {},
+		"Report health of the cache with an idle threshold": {idleThreshold: 365 * 24 * time.Hour},
+		"Report health of a healthy cache":                  {dbFile: "one_user_and_group"},
+
+		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", wantErr: true},
+		"Error on cancelled context":        {cancelCtx: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.dbFile == "" {
+				tc.dbFile = "users_with_health_issues"
+			}
+
+			now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+			c := initCache(t, tc.dbFile, cache.WithUIDReuseDelay(-1), cache.WithNow(func() time.Time { return now }))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelCtx {
+				cancel()
+			}
+
+			var opts []cache.HealthReportOption
+			if tc.idleThreshold != 0 {
+				opts = append(opts, cache.WithIdleThreshold(tc.idleThreshold))
+			}
+
+			got, err := c.HealthReport(ctx, opts...)
+			if tc.wantErr {
+				require.Error(t, err, "HealthReport should return an error")
+				return
+			}
+			require.NoError(t, err, "HealthReport should not return an error")
+
+			// The fragmentation depends on how the database file was written, so it's only checked to be a ratio.
+			require.GreaterOrEqual(t, got.FragmentationRatio, 0.0, "FragmentationRatio should not be negative")
+			require.LessOrEqual(t, got.FragmentationRatio, 1.0, "FragmentationRatio should not be greater than 1")
+			got.FragmentationRatio = 0
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "HealthReport should return the expected report")
+		})
+	}
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErr bool
+	}{
+		"Report discrepancies between buckets": {dbFile: "integrity_issues"},
+		"Report nothing on a consistent cache": {dbFile: "multiple_users_and_groups"},
+		"Report nothing on a large group":      {dbFile: "large_group"},
+
+		"Error on invalid entry in userByName":   {dbFile: "invalid_entry_in_userByName", wantErr: true},
+		"Error on invalid entry in groupToUsers": {dbFile: "invalid_entry_in_groupToUsers", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.CheckIntegrity()
+			if tc.wantErr {
+				require.Error(t, err, "CheckIntegrity should return an error")
+				return
+			}
+			require.NoError(t, err, "CheckIntegrity should not return an error")
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "CheckIntegrity should return the expected report")
+		})
+	}
+}
+
+func TestRepairIntegrity(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantReport cache.IntegrityReport
+		wantErr    bool
+	}{
+		"Repair discrepancies between buckets": {
+			dbFile: "integrity_issues",
+			wantReport: cache.IntegrityReport{
+				OrphanedUserNames:   []string{"ghost"},
+				OrphanedGroupNames:  []string{"ghostgroup"},
+				MissingGroupMembers: map[uint32][]uint32{11111: {9999}, 22222: {7777, 9999}},
+				MissingUserGroups:   map[uint32][]uint32{1111: {88888}},
+			},
+		},
+		"Repair nothing on a consistent cache": {
+			dbFile: "multiple_users_and_groups",
+			wantReport: cache.IntegrityReport{
+				OrphanedUserNames:   []string{},
+				OrphanedGroupNames:  []string{},
+				MissingGroupMembers: map[uint32][]uint32{},
+				MissingUserGroups:   map[uint32][]uint32{},
+			},
+		},
+
+		"Error on invalid entry in userToGroups": {dbFile: "invalid_entry_in_userToGroups", wantErr: true},
+		"Error on invalid entry in groupByName":  {dbFile: "invalid_entry_in_groupByName", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.RepairIntegrity()
+			if tc.wantErr {
+				require.Error(t, err, "RepairIntegrity should return an error")
+				return
+			}
+			require.NoError(t, err, "RepairIntegrity should not return an error")
+
+			require.Equal(t, tc.wantReport, *got, "RepairIntegrity should report the discrepancies it fixed")
+
+			after, err := c.CheckIntegrity()
+			require.NoError(t, err, "CheckIntegrity should not return an error after the repair")
+			require.True(t, after.IsEmpty(), "CheckIntegrity should not report any discrepancy after the repair")
+
+			dump, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, dump)
+			require.Equal(t, want, dump, "Did not get expected database content")
+		})
+	}
+}
+
+func TestDumpUser(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile   string
+		username string
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Dump user with one group":          {dbFile: "one_user_and_group", username: "user1"},
+		"Dump user with multiple groups":    {dbFile: "multiple_users_and_groups", username: "user2"},
+		"Dump user without assigned broker": {dbFile: "one_user_and_group_without_broker", username: "user1"},
+
+		"Error on missing name":           {dbFile: "one_user_and_group", username: "doesnotexist", wantErrType: cache.NoDataFoundError{}},
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByName", username: "user1", wantErr: true},
+		"Error on invalid group entry":    {dbFile: "invalid_entry_in_groupByID", username: "user1", wantErr: true},
+		"Error on invalid user to groups": {dbFile: "invalid_entry_in_userToGroups", username: "user1", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.DumpUser(tc.username)
+			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+		})
+	}
+}
+
+func TestExportNSSFiles(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile       string
+		addedUser    *cache.UserDB
+		disabledUser uint32
+		withShadow   bool
+
+		wantErr bool
+	}{
+		"Export users and groups with shadow entries":    {dbFile: "multiple_users_and_groups", withShadow: true},
+		"Export disabled users with a locked password":   {dbFile: "multiple_users_and_groups", disabledUser: 2222, withShadow: true},
+		"Export users and groups without shadow entries": {dbFile: "multiple_users_and_groups"},
+		"Export users with password ages":                {dbFile: "users_with_password_ages", withShadow: true},
+		"Export nothing from empty database":             {withShadow: true},
+		"Replace colons and newlines in GECOS": {
+			addedUser:  &cache.UserDB{Name: "newuser", UID: 1000, GID: 1000, Gecos: "Name:Office\nPhone", Dir: "/home/newuser", Shell: "/bin/bash"},
+			withShadow: true,
+		},
+
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByID", wantErr: true},
+		"Error on colon in a field other than GECOS": {
+			addedUser: &cache.UserDB{Name: "newuser", UID: 1000, GID: 1000, Dir: "/home/new:user", Shell: "/bin/bash"},
+			wantErr:   true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+			if tc.addedUser != nil {
+				err := c.UpdateUserEntry(*tc.addedUser, []cache.GroupDB{{Name: tc.addedUser.Name, GID: tc.addedUser.GID}})
+				require.NoError(t, err, "Setup: UpdateUserEntry should not return an error")
+			}
+			if tc.disabledUser != 0 {
+				err := c.SetUserEnabled(tc.disabledUser, false)
+				require.NoError(t, err, "Setup: SetUserEnabled should not return an error")
+			}
+
+			var passwd, group, shadow strings.Builder
+			var shadowW io.Writer
+			if tc.withShadow {
+				shadowW = &shadow
+			}
+			err := c.ExportNSSFiles(&passwd, &group, shadowW)
+			if tc.wantErr {
+				require.Error(t, err, "ExportNSSFiles should return an error but didn't")
+				return
+			}
+			require.NoError(t, err, "ExportNSSFiles should not return an error but did")
+
+			got := fmt.Sprintf("passwd:\n%s\ngroup:\n%s\nshadow:\n%s", passwd.String(), group.String(), shadow.String())
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "ExportNSSFiles should write the expected entries")
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile          string
+		optionalRecords bool
+		closeCache      bool
+
+		wantErr bool
+	}{
+		"Export users and groups":                {dbFile: "multiple_users_and_groups"},
+		"Export members of large groups":         {dbFile: "large_group"},
+		"Export users with case drifted names":   {dbFile: "users_with_case_drift"},
+		"Export nothing from empty database":     {},
+		"Export records of the optional buckets": {dbFile: "nested_groups", optionalRecords: true},
+
+		"Error on closed cache": {dbFile: "multiple_users_and_groups", closeCache: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile, cache.WithUIDReuseDelay(time.Hour))
+			if tc.optionalRecords {
+				// The nested groups are in the database, add a tombstone, a failed login and a quarantined user.
+				require.NoError(t, c.DeleteUser(4444), "Setup: could not delete user")
+				_, err := c.RecordFailedLogin("user1")
+				require.NoError(t, err, "Setup: could not record failed login")
+				require.NoError(t, c.Quarantine(3333, "test"), "Setup: could not quarantine user")
+			}
+			if tc.closeCache {
+				require.NoError(t, c.Close(), "Setup: could not close cache")
+			}
+
+			var backup strings.Builder
+			err := c.Export(&backup)
+			if tc.wantErr {
+				require.Error(t, err, "Export should return an error but didn't")
+				return
+			}
+			require.NoError(t, err, "Export should not return an error but did")
+			require.True(t, strings.HasPrefix(backup.String(), `{"Version":1,`), "Export should write the format version first")
+
+			// The backup is checked by restoring it in a cache with other encoding options and other records, which are
+			// all replaced.
+			restored := initCache(t, "users_with_health_issues", cache.WithValueCompression(true), cache.WithRecordChecksums(true))
+			err = restored.Import(strings.NewReader(backup.String()), cache.WithOverwrite())
+			require.NoError(t, err, "Import should not return an error")
+
+			want, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Setup: could not dump exported database")
+			got, err := cachetestutils.DumpToYaml(restored)
+			require.NoError(t, err, "Setup: could not dump imported database")
+			require.Equal(t, want, got, "Import should restore the exported content")
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile       string
+		backupDBFile string
+		backup       string
+		overwrite    bool
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Import backup in empty database":                 {backupDBFile: "multiple_users_and_groups"},
+		"Import backup of large groups in empty database": {backupDBFile: "large_group"},
+		"Import empty backup in empty database":           {backupDBFile: "empty"},
+		"Import backup replacing database content":        {dbFile: "nested_groups", backupDBFile: "one_user_and_group", overwrite: true},
+
+		"Error on non-empty database without overwrite":            {dbFile: "one_user_and_group", backupDBFile: "multiple_users_and_groups", wantErrType: cache.ErrCacheNotEmpty},
+		"Error on database with only tombstones without overwrite": {dbFile: "only_tombstones", backupDBFile: "multiple_users_and_groups", wantErrType: cache.ErrCacheNotEmpty},
+		"Error on backup of another version":                       {backup: `{"Version":2,"Buckets":["new format"]}`, wantErrType: cache.ErrIncompatibleBackup},
+		"Error on backup without version":                          {backup: `{"Buckets":{}}`, wantErrType: cache.ErrIncompatibleBackup},
+		"Error on invalid backup":                                  {backup: `{"Version":1,`, wantErr: true},
+		"Error on unknown bucket":                                  {backup: `{"Version":1,"Buckets":{"Unknown":{}}}`, wantErr: true},
+		"Error on invalid members of large group":                  {backup: `{"Version":1,"Buckets":{"GroupMembers":{"99999":{}}}}`, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.backupDBFile != "" {
+				var backup strings.Builder
+				dbFile := tc.backupDBFile
+				if dbFile == "empty" {
+					dbFile = ""
+				}
+				err := initCache(t, dbFile).Export(&backup)
+				require.NoError(t, err, "Setup: Export should not return an error")
+				tc.backup = backup.String()
+			}
+
+			c := initCache(t, tc.dbFile)
+			wantDump, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Setup: could not dump database")
+
+			var opts []cache.ImportOption
+			if tc.overwrite {
+				opts = append(opts, cache.WithOverwrite())
+			}
+			err = c.Import(strings.NewReader(tc.backup), opts...)
+			if tc.wantErr || tc.wantErrType != nil {
+				require.Error(t, err, "Import should return an error but didn't")
+				if tc.wantErrType != nil {
+					require.ErrorIs(t, err, tc.wantErrType, "Import should return the expected error")
+				}
+
+				gotDump, err := cachetestutils.DumpToYaml(c)
+				require.NoError(t, err, "Setup: could not dump database")
+				require.Equal(t, wantDump, gotDump, "Import should not change the database on error")
+				return
+			}
+			require.NoError(t, err, "Import should not return an error but did")
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
+		})
+	}
+}
+
+func TestUserByID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Get existing user": {dbFile: "one_user_and_group"},
+
+		"Error on missing user":           {wantErrType: cache.ErrNoEntry},
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByID", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.UserByID(1111)
+			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+		})
+	}
+}
+
+func TestUserByName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErrType error
+		wantErr     bool
+	}{
+		"Get existing user": {dbFile: "one_user_and_group"},
+
+		"Error on missing user":           {wantErrType: cache.ErrNoEntry},
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByName", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.UserByName("user1")
+			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+		})
+	}
+}
+
+func TestAllUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErr bool
+	}{
+		"Get one user":       {dbFile: "one_user_and_group"},
+		"Get multiple users": {dbFile: "multiple_users_and_groups"},
+
+		"Get users only rely on valid userByID":      {dbFile: "partially_valid_multiple_users_and_groups_only_userByID"},
+		"Get users ignores users only in userByName": {dbFile: "stale_user_only_in_userByName"},
+
+		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.AllUsers()
+			requireGetAssertions(t, got, tc.wantErr, nil, err)
+		})
+	}
+}
+
+func TestGroupByID(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Get existing group": {dbFile: "one_user_and_group"},
+
+		"Error on missing group":          {wantErrType: cache.ErrNoEntry},
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_groupByID", wantErr: true},
+		"Error as missing userByID":       {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.GroupByID(11111)
+			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+		})
+	}
+}
+
+func TestGroupByName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Get existing group": {dbFile: "one_user_and_group"},
+
+		"Error on missing group":          {wantErrType: cache.ErrNoEntry},
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_groupByName", wantErr: true},
+		"Error as missing userByID":       {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.GroupByName("group1")
+			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+		})
+	}
+}
+
+func TestGroupMeta(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+		byName bool
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Get existing group by ID":                    {dbFile: "one_user_and_group"},
+		"Get existing group by name":                  {dbFile: "one_user_and_group", byName: true},
+		"Get existing group with missing users by ID": {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers"},
+
+		"Error on missing group by ID":                   {wantErrType: cache.ErrNoEntry},
+		"Error on missing group by name":                 {byName: true, wantErrType: cache.ErrNoEntry},
+		"Error on invalid database entry in groupByID":   {dbFile: "invalid_entry_in_groupByID", wantErr: true},
+		"Error on invalid database entry in groupByName": {dbFile: "invalid_entry_in_groupByName", byName: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			var got cache.GroupDB
+			var err error
+			if tc.byName {
+				got, err = c.GroupMetaByName("group1")
+			} else {
+				got, err = c.GroupMetaByID(11111)
+			}
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "GroupMeta should return expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "GroupMeta should return an error")
+				return
+			}
+			require.NoError(t, err, "GroupMeta should not return an error")
+			require.Equal(t, cache.NewGroupDB("group1", 11111, nil), got, "GroupMeta should return the group without its members")
+		})
+	}
+}
+
+func TestAllGroups(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Get one group":       {dbFile: "one_user_and_group"},
+		"Get multiple groups": {dbFile: "multiple_users_and_groups"},
+
+		"Get groups rely on groupByID, groupToUsers, UserByID": {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers_UserByID"},
+
+		"Error on some invalid groups entry":     {dbFile: "invalid_entries_but_user_and_group1", wantErr: true},
+		"Error as not only relying on groupByID": {dbFile: "partially_valid_multiple_users_and_groups_only_groupByID", wantErr: true},
+		"Error as missing userByID":              {dbFile: "partially_valid_multiple_users_and_groups_groupByID_groupToUsers", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.AllGroups()
+			requireGetAssertions(t, got, tc.wantErr, tc.wantErrType, err)
+		})
+	}
+}
+
+func TestGroupMembersByName(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile    string
+		groupName string
+		batchSize int
+		fnErr     bool
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Get members in a single batch":    {dbFile: "multiple_users_and_groups", batchSize: 10},
+		"Get members in multiple batches":  {dbFile: "multiple_users_and_groups", batchSize: 1},
+		"Get members of single user group": {dbFile: "multiple_users_and_groups", groupName: "group1", batchSize: 1},
+
+		"Error on missing group":          {dbFile: "multiple_users_and_groups", groupName: "doesnotexist", batchSize: 1, wantErrType: cache.ErrNoEntry},
+		"Error on invalid batch size":     {dbFile: "multiple_users_and_groups", wantErr: true},
+		"Error when batch callback fails": {dbFile: "multiple_users_and_groups", batchSize: 1, fnErr: true, wantErr: true},
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_groupByName", groupName: "group1", batchSize: 1, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			if tc.groupName == "" {
+				tc.groupName = "commongroup"
+			}
+
+			var got [][]string
+			err := c.GroupMembersByName(tc.groupName, tc.batchSize, func(members []string) error {
+				if tc.fnErr {
+					return errors.New("requested error")
+				}
+				got = append(got, members)
+				return nil
+			})
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "GroupMembersByName should return expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "GroupMembersByName should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "GroupMembersByName should return the expected batches")
+		})
+	}
+}
+
+func TestGroupsAndMembersOrder(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "")
+
+	// Users and groups are added in descending ID order, so that their records are not sorted.
+	shared := cache.NewGroupDB("shared", 50000, nil)
+	for _, u := range []struct {
+		name string
+		uid  uint32
+	}{{"charlie", 3333}, {"alice", 2222}, {"bob", 1111}} {
+		usr := cache.NewUserDB(u.name, u.uid, u.uid, "", "/home/"+u.name, "/bin/bash")
+		groups := []cache.GroupDB{cache.NewGroupDB(u.name, u.uid, nil), shared, cache.NewGroupDB("other", 40000, nil)}
+		require.NoError(t, c.UpdateUserEntry(usr, groups), "Setup: could not add user %q", u.name)
+	}
+
+	wantMembers := []string{"bob", "alice", "charlie"}
+	g, err := c.GroupByID(shared.GID)
+	require.NoError(t, err, "GroupByID should not return an error")
+	require.Equal(t, wantMembers, g.Users, "GroupByID should return the members in ascending UID order")
+
+	all, err := c.AllGroups()
+	require.NoError(t, err, "AllGroups should not return an error")
+	for _, g := range all {
+		if g.GID == shared.GID {
+			require.Equal(t, wantMembers, g.Users, "AllGroups should return the members in ascending UID order")
+		}
+	}
+
+	var members []string
+	err = c.GroupMembersByName(shared.Name, 1, func(batch []string) error {
+		members = append(members, batch...)
+		return nil
+	})
+	require.NoError(t, err, "GroupMembersByName should not return an error")
+	require.Equal(t, wantMembers, members, "GroupMembersByName should return the members in ascending UID order")
+
+	groups, err := c.GroupsForUser(3333)
+	require.NoError(t, err, "GroupsForUser should not return an error")
+	var gids []uint32
+	for _, g := range groups {
+		gids = append(gids, g.GID)
+	}
+	require.Equal(t, []uint32{3333, 40000, 50000}, gids, "GroupsForUser should return the groups in ascending GID order")
+}
+
+func TestUsersInBatches(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile       string
+		batchSize    int
+		fnErr        bool
+		cancelledCtx bool
+
+		wantErr bool
+	}{
+		"Get users in a single batch":                 {dbFile: "multiple_users_and_groups", batchSize: 10},
+		"Get users in multiple batches":               {dbFile: "multiple_users_and_groups", batchSize: 3},
+		"Get users in batches of the number of users": {dbFile: "multiple_users_and_groups", batchSize: 4},
+		"Get users in batches of one user":            {dbFile: "multiple_users_and_groups", batchSize: 1},
+
+		"Error on invalid batch size":       {dbFile: "multiple_users_and_groups", wantErr: true},
+		"Error when batch callback fails":   {dbFile: "multiple_users_and_groups", batchSize: 1, fnErr: true, wantErr: true},
+		"Error on cancelled context":        {dbFile: "multiple_users_and_groups", batchSize: 1, cancelledCtx: true, wantErr: true},
+		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", batchSize: 10, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelledCtx {
+				cancel()
+			}
+
+			var got [][]cache.UserDB
+			err := c.UsersInBatches(ctx, tc.batchSize, func(users []cache.UserDB) error {
+				if tc.fnErr {
+					return errors.New("requested error")
+				}
+				got = append(got, users)
+				return nil
+			})
+			if tc.wantErr {
+				require.Error(t, err, "UsersInBatches should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "UsersInBatches should return the expected batches")
+		})
+	}
+}
+
+func TestGroupsInBatches(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile       string
+		batchSize    int
+		fnErr        bool
+		cancelledCtx bool
+
+		wantErr bool
+	}{
+		"Get groups in a single batch":       {dbFile: "multiple_users_and_groups", batchSize: 10},
+		"Get groups in multiple batches":     {dbFile: "multiple_users_and_groups", batchSize: 3},
+		"Get groups in batches of one group": {dbFile: "multiple_users_and_groups", batchSize: 1},
+		"Get members of large groups":        {dbFile: "large_group", batchSize: 10},
+
+		"Error on invalid batch size":        {dbFile: "multiple_users_and_groups", wantErr: true},
+		"Error when batch callback fails":    {dbFile: "multiple_users_and_groups", batchSize: 1, fnErr: true, wantErr: true},
+		"Error on cancelled context":         {dbFile: "multiple_users_and_groups", batchSize: 1, cancelledCtx: true, wantErr: true},
+		"Error on some invalid groups entry": {dbFile: "invalid_entry_in_groupByID", batchSize: 10, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelledCtx {
+				cancel()
+			}
+
+			var got [][]cache.GroupDB
+			err := c.GroupsInBatches(ctx, tc.batchSize, func(groups []cache.GroupDB) error {
+				if tc.fnErr {
+					return errors.New("requested error")
+				}
+				got = append(got, groups)
+				return nil
+			})
+			if tc.wantErr {
+				require.Error(t, err, "GroupsInBatches should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "GroupsInBatches should return the expected batches")
+		})
+	}
+}
+
+func TestUpdateBrokerForUser(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "one_user_and_group")
+
+	// Update broker for existent user
+	err := c.UpdateBrokerForUser("user1", "ExampleBrokerID")
+	require.NoError(t, err, "UpdateBrokerForUser for an existent user should not return an error")
+
+	// Error when updating broker for nonexistent user
+	err = c.UpdateBrokerForUser("nonexistent", "ExampleBrokerID")
+	require.Error(t, err, "UpdateBrokerForUser for a nonexistent user should return an error")
+
+	// Error when the user found by name is gone, like when it's deleted concurrently
+	c = initCache(t, "integrity_issues")
+	err = c.UpdateBrokerForUser("ghost", "ExampleBrokerID")
+	require.ErrorIs(t, err, cache.NoDataFoundError{}, "UpdateBrokerForUser for a deleted user should return a NoDataFoundError")
+	brokerID, err := c.BrokerForUser("ghost")
+	require.NoError(t, err, "BrokerForUser should not return an error")
+	require.Empty(t, brokerID, "UpdateBrokerForUser should not record the broker of a deleted user")
+}
+
+func TestSetUserEnabled(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "one_user_and_group")
+
+	// Disable an existing user
+	err := c.SetUserEnabled(1111, false)
+	require.NoError(t, err, "SetUserEnabled for an existent user should not return an error")
+	u, err := c.UserByName("user1")
+	require.NoError(t, err, "Disabled users should still be returned by UserByName")
+	require.True(t, u.Disabled, "SetUserEnabled should disable the user")
+
+	// Updates of the user don't enable them again
+	u.Disabled = false
+	err = c.UpdateUserEntry(u, []cache.GroupDB{cache.NewGroupDB("group1", u.GID, nil)})
+	require.NoError(t, err, "Setup: UpdateUserEntry should not return an error")
+	u, err = c.UserByID(1111)
+	require.NoError(t, err, "UserByID should not return an error")
+	require.True(t, u.Disabled, "UpdateUserEntry should keep the user disabled")
+
+	// Enable the user again
+	err = c.SetUserEnabled(1111, true)
+	require.NoError(t, err, "SetUserEnabled for an existent user should not return an error")
+	u, err = c.UserByID(1111)
+	require.NoError(t, err, "UserByID should not return an error")
+	require.False(t, u.Disabled, "SetUserEnabled should enable the user")
+
+	// Error when the user does not exist
+	err = c.SetUserEnabled(4242, false)
+	require.ErrorIs(t, err, cache.ErrNoEntry, "SetUserEnabled for a nonexistent user should return ErrNoEntry")
+}
+
+func TestBrokerForUser(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "multiple_users_and_groups")
+
+	// Get existing BrokerForUser entry
+	gotID, err := c.BrokerForUser("user1")
+	require.NoError(t, err, "BrokerForUser for an existent user should not return an error")
+	wantID := testutils.LoadWithUpdateFromGolden(t, gotID)
+	require.Equal(t, wantID, gotID, "BrokerForUser should return expected broker ID")
+
+	// Get unassigned broker to existent user
+	gotID, err = c.BrokerForUser("userwithoutbroker")
+	require.NoError(t, err, "BrokerForUser for an existent user should not return an error")
+	require.Empty(t, gotID, "BrokerForUser should return empty broker ID for unassigned broker to existent user")
+
+	// Error when user does not exist
+	gotID, err = c.BrokerForUser("nonexistent")
+	require.Error(t, err, "BrokerForUser for a nonexistent user should return an error")
+	require.Empty(t, gotID, "BrokerForUser should return empty broker ID when user entry does not exist")
+}
+
+func TestAllBrokersForUser(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "multiple_users_and_groups")
+
+	wantID, err := c.BrokerForUser("user1")
+	require.NoError(t, err, "Setup: BrokerForUser for an existent user should not return an error")
+
+	// Get the brokers of a user with an assigned broker
+	got, err := c.AllBrokersForUser("user1")
+	require.NoError(t, err, "AllBrokersForUser for an existent user should not return an error")
+	require.Equal(t, []cache.BrokerUse{{BrokerID: wantID}}, got, "AllBrokersForUser should return the assigned broker")
+
+	// Get the brokers of a user without an assigned broker
+	got, err = c.AllBrokersForUser("userwithoutbroker")
+	require.NoError(t, err, "AllBrokersForUser for an existent user should not return an error")
+	require.Empty(t, got, "AllBrokersForUser should return no broker for a user without an assigned broker")
+
+	// Error when user does not exist
+	_, err = c.AllBrokersForUser("nonexistent")
+	require.Error(t, err, "AllBrokersForUser for a nonexistent user should return an error")
+}
+
+func TestUsersByBroker(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile   string
+		brokerID string
+
+		wantErr bool
+	}{
+		"Get users of broker":               {brokerID: "broker-id"},
+		"Get users of local broker":         {brokerID: "local"},
+		"Get no user for an unknown broker": {brokerID: "unknown-broker-id"},
+
+		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", brokerID: "broker-id", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.dbFile == "" {
+				tc.dbFile = "stale_users"
+			}
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.UsersByBroker(tc.brokerID)
+			requireGetAssertions(t, got, tc.wantErr, nil, err)
+		})
+	}
+}
+
+func TestCreateUserIfAbsent(t *testing.T) {
+	t.Parallel()
+
+	template := cache.UserDB{Gecos: "New user", Dir: "/home/newuser", Shell: "/bin/bash"}
+
+	tests := map[string]struct {
+		name           string
+		minUID, maxUID uint32
+
+		wantUID     uint32
+		wantCreated bool
+		wantErr     bool
+	}{
+		"Create user with the lowest free ID":   {name: "newuser", minUID: 1000, maxUID: 2000, wantUID: 1000, wantCreated: true},
+		"Skip IDs used by users":                {name: "newuser", minUID: 1111, maxUID: 2000, wantUID: 1112, wantCreated: true},
+		"Skip IDs used by groups":               {name: "newuser", minUID: 11111, maxUID: 12000, wantUID: 11112, wantCreated: true},
+		"Return existing user without creating": {name: "user1", minUID: 1000, maxUID: 2000, wantUID: 1111},
+
+		"Error on empty name":      {minUID: 1000, maxUID: 2000, wantErr: true},
+		"Error on invalid range":   {name: "newuser", minUID: 2000, maxUID: 1000, wantErr: true},
+		"Error when no ID is free": {name: "newuser", minUID: 1111, maxUID: 1111, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, "one_user_and_group")
+
+			got, created, err := c.CreateUserIfAbsent(tc.name, template, tc.minUID, tc.maxUID)
+			if tc.wantErr {
+				require.Error(t, err, "CreateUserIfAbsent should return an error but didn't")
+				return
+			}
+			require.NoError(t, err, "CreateUserIfAbsent should not return an error but did")
+			require.Equal(t, tc.wantCreated, created, "CreateUserIfAbsent should report whether the user was created")
+			require.Equal(t, tc.wantUID, got.UID, "CreateUserIfAbsent should return the UID of the user")
+
+			stored, err := c.UserByName(tc.name)
+			require.NoError(t, err, "UserByName should return the user")
+			require.Equal(t, got, stored, "CreateUserIfAbsent should return the stored user")
+			c.AssertConsistent(t)
+			if !tc.wantCreated {
+				return
+			}
+			require.Equal(t, tc.wantUID, got.GID, "Created user should have a primary group with the same ID")
+			require.Equal(t, template.Dir, got.Dir, "Created user should be filled from the template")
+
+			g, err := c.GroupByID(tc.wantUID)
+			require.NoError(t, err, "GroupByID should return the primary group of the created user")
+			require.Equal(t, cache.NewGroupDB(tc.name, tc.wantUID, []string{tc.name}), g, "Created user should be in its primary group")
+		})
+	}
+}
+
+func TestCreateUserIfAbsentConcurrently(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "")
+
+	const calls = 10
+	uids := make(chan uint32, calls)
+	errs := make(chan error, calls)
+	var creations atomic.Int32
+	var wg sync.WaitGroup
+	for range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			u, created, err := c.CreateUserIfAbsent("newuser", cache.UserDB{}, 1000, 2000)
+			errs <- err
+			if created {
+				creations.Add(1)
+			}
+			uids <- u.UID
+		}()
+	}
+	wg.Wait()
+	close(uids)
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err, "CreateUserIfAbsent should not return an error but did")
+	}
+	require.Equal(t, int32(1), creations.Load(), "Only one of the concurrent calls should create the user")
+	for uid := range uids {
+		require.Equal(t, uint32(1000), uid, "All concurrent calls should return the same UID")
+	}
+	c.AssertConsistent(t)
+}
+
+func TestCaseInsensitiveNames(t *testing.T) {
+	t.Parallel()
+
+	group := cache.NewGroupDB("group1", 11111, nil)
+	newUser := func(name string, uid uint32) cache.UserDB {
+		return cache.NewUserDB(name, uid, group.GID, "", "/home/"+strings.ToLower(name), "/bin/bash")
+	}
+
+	// Users written before names were case-insensitive are keyed by their exact name.
+	cacheDir := t.TempDir()
+	c, err := cache.New(cacheDir)
+	require.NoError(t, err, "Setup: could not create cache")
+	require.NoError(t, c.UpdateUserEntry(newUser("Alice", 1111), []cache.GroupDB{group}), "Setup: could not add user")
+	require.NoError(t, c.Close(), "Setup: could not close cache")
+
+	// They are rekeyed by the first process opening the database read-write, so that read-only ones find them too.
+	c, err = cache.New(cacheDir, cache.WithCaseInsensitiveNames(true))
+	require.NoError(t, err, "Setup: could not open cache with case-insensitive names")
+	require.NoError(t, c.Close(), "Setup: could not close cache")
+	c, err = cache.New(cacheDir, cache.WithCaseInsensitiveNames(true), cache.WithReadOnly())
+	require.NoError(t, err, "Setup: could not open cache read-only")
+	for _, name := range []string{"Alice", "alice", "ALICE"} {
+		u, err := c.UserByName(name)
+		require.NoError(t, err, "UserByName should find users written before names were case-insensitive")
+		require.Equal(t, "Alice", u.Name, "UserByName should return the name of the user as written")
+	}
+	require.NoError(t, c.Close(), "Setup: could not close read-only cache")
+
+	c = initCacheFromDir(t, cacheDir, cache.WithCaseInsensitiveNames(true))
+
+	require.NoError(t, c.UpdateUserEntry(newUser("alice", 1111), []cache.GroupDB{group}),
+		"UpdateUserEntry should accept a user renamed by case only")
+	require.NoError(t, c.UpdateUserEntry(newUser("Bob", 2222), []cache.GroupDB{group}), "Setup: could not add user")
+	for name, want := range map[string]string{"ALICE": "alice", "bob": "Bob", "BOB": "Bob"} {
+		u, err := c.UserByName(name)
+		require.NoError(t, err, "UserByName should find users case-insensitively")
+		require.Equal(t, want, u.Name, "UserByName should return the name of the last update of the user")
+	}
+
+	err = c.UpdateUserEntry(newUser("bob", 3333), []cache.GroupDB{group})
+	require.Error(t, err, "UpdateUserEntry should reject a user whose name only differs by case from another user")
+
+	c.AssertConsistent(t)
+
+	require.NoError(t, c.DeleteUser(1111), "DeleteUser should delete a user keyed case-insensitively")
+	_, err = c.UserByName("alice")
+	require.ErrorIs(t, err, cache.ErrNoEntry, "UserByName should not find deleted users")
+	c.AssertConsistent(t)
+}
+
+func TestAssertConsistent(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantFailure bool
+	}{
+		"Pass on empty database":                        {},
+		"Pass on consistent database":                   {dbFile: "one_user_and_group"},
+		"Pass on consistent database with ages":         {dbFile: "users_with_password_ages"},
+		"Pass on consistent large group":                {dbFile: "large_group"},
+		"Fail on user not in groupToUsers":              {dbFile: "user_not_in_groupToUsers", wantFailure: true},
+		"Fail on invalid entry in groupToUsers":         {dbFile: "invalid_entry_in_groupToUsers", wantFailure: true},
+		"Fail on invalid entry in userByID":             {dbFile: "invalid_entry_in_userByID", wantFailure: true},
+		"Fail on mismatching groupToUsers entry":        {dbFile: "multiple_users_and_groups", wantFailure: true},
+		"Fail on discrepancies found by CheckIntegrity": {dbFile: "integrity_issues", wantFailure: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			rec := &recordingTB{TB: t}
+			c.AssertConsistent(rec)
+			require.Equal(t, tc.wantFailure, rec.failed, "AssertConsistent should only fail on inconsistent databases: %s", rec.msg)
+		})
+	}
+}
+
+// recordingTB is a testing.TB recording failures instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	failed bool
+	msg    string
+}
+
+func (r *recordingTB) Errorf(format string, args ...any) {
+	r.failed = true
+	r.msg = fmt.Sprintf(format, args...)
+}
+
+func (r *recordingTB) Fatalf(format string, args ...any) {
+	r.Errorf(format, args...)
+}
+
+func TestRemoveDb(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "multiple_users_and_groups")
+	cacheDir := filepath.Dir(c.DbPath())
+
+	// First call should return with no error.
+	require.NoError(t, cache.RemoveDb(cacheDir), "RemoveDb should not return an error on the first call")
+	require.NoFileExists(t, cacheDir, "RemoveDb should remove the database file")
+
+	// Second call should return ErrNotExist as the database file was already removed.
+	require.ErrorIs(t, cache.RemoveDb(cacheDir), fs.ErrNotExist, "RemoveDb should return os.ErrNotExist on the second call")
+}
+
+func TestCompact(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		deleteUsers        bool
+		leftoverCompaction bool
+		closeCache         bool
+
+		wantErr bool
+	}{
+		"Compact database after users were deleted": {deleteUsers: true},
+		"Compact database without deleted users":    {},
+
+		"Error on leftover compacted database that can't be removed": {leftoverCompaction: true, wantErr: true},
+		"Error on closed cache": {closeCache: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, "multiple_users_and_groups")
+
+			if tc.deleteUsers {
+				var uids []uint32
+				for i := range 500 {
+					uid := uint32(100000 + i)
+					u := cache.NewUserDB(fmt.Sprintf("user%d", uid), uid, 11111, "", fmt.Sprintf("/home/user%d", uid), "/bin/bash")
+					err := c.UpdateUserEntry(u, []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)})
+					require.NoError(t, err, "Setup: UpdateUserEntry should not return an error")
+					uids = append(uids, uid)
+				}
+				_, err := c.DeleteUsers(uids)
+				require.NoError(t, err, "Setup: DeleteUsers should not return an error")
+			}
+			if tc.leftoverCompaction {
+				leftover := c.DbPath() + ".compact"
+				require.NoError(t, os.Mkdir(leftover, 0700), "Setup: could not create leftover directory")
+				require.NoError(t, os.WriteFile(filepath.Join(leftover, "file"), nil, 0600), "Setup: could not create file")
+			}
+			if tc.closeCache {
+				require.NoError(t, c.Close(), "Setup: could not close cache")
+			}
+
+			var wantDump string
+			if !tc.closeCache {
+				var err error
+				wantDump, err = cachetestutils.DumpToYaml(c)
+				require.NoError(t, err, "Setup: could not dump database")
+			}
+
+			sizeBefore, sizeAfter, err := c.Compact()
+			if tc.wantErr {
+				require.Error(t, err, "Compact should return an error")
+				if tc.closeCache {
+					return
+				}
+			} else {
+				require.NoError(t, err, "Compact should not return an error")
+				require.LessOrEqual(t, sizeAfter, sizeBefore, "Compact should not grow the database file")
+				if tc.deleteUsers {
+					require.Less(t, sizeAfter, sizeBefore, "Compact should shrink the database file")
+				}
+
+				fi, err := os.Stat(c.DbPath())
+				require.NoError(t, err, "Could not stat database file")
+				require.Equal(t, sizeAfter, fi.Size(), "Compact should return the size of the compacted database file")
+				require.NoFileExists(t, c.DbPath()+".compact", "Compact should not leave the compacted database behind")
+			}
+
+			// The database content is unchanged, and it can still be updated.
+			gotDump, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Database should be readable after Compact")
+			require.Equal(t, wantDump, gotDump, "Compact should not change the database content")
+			require.NoError(t, c.DeleteUser(1111), "Database should be writable after Compact")
+			require.Equal(t, cache.CacheHealthy, c.State().Status, "State should stay healthy after Compact")
+		})
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	t.Parallel()
+
+	user1 := cache.NewUserDB("user1", 1111, 11111, "User1 gecos", "/home/user1", "/bin/bash")
+	user2 := cache.NewUserDB("user2", 2222, 22222, "User2 gecos", "/home/user2", "/bin/bash")
+	group1 := cache.NewGroupDB("group1", 11111, nil)
+	group2 := cache.NewGroupDB("group2", 22222, nil)
+
+	userAdded := func(name string, uid uint32) cache.CacheEvent {
+		return cache.CacheEvent{Type: cache.UserAdded, Name: name, ID: uid}
+	}
+	userRemoved := func(name string, uid uint32) cache.CacheEvent {
+		return cache.CacheEvent{Type: cache.UserRemoved, Name: name, ID: uid}
+	}
+	groupAdded := func(name string, gid uint32) cache.CacheEvent {
+		return cache.CacheEvent{Type: cache.GroupAdded, Name: name, ID: gid}
+	}
+	groupRemoved := func(name string, gid uint32) cache.CacheEvent {
+		return cache.CacheEvent{Type: cache.GroupRemoved, Name: name, ID: gid}
+	}
+	memberAdded := func(group string, gid uint32, user string, uid uint32) cache.CacheEvent {
+		return cache.CacheEvent{Type: cache.MemberAdded, Name: group, ID: gid, MemberName: user, MemberUID: uid}
+	}
+	memberRemoved := func(group string, gid uint32, user string, uid uint32) cache.CacheEvent {
+		return cache.CacheEvent{Type: cache.MemberRemoved, Name: group, ID: gid, MemberName: user, MemberUID: uid}
+	}
+
+	tests := map[string]struct {
+		dbFile string
+		change func(c *cache.Cache) error
+
+		wantEvents []cache.CacheEvent
+		wantErr    bool
+	}{
+		"Notify addition of a user with their groups": {
+			change: func(c *cache.Cache) error { return c.UpdateUserEntry(user1, []cache.GroupDB{group1, group2}) },
+			wantEvents: []cache.CacheEvent{
+				userAdded("user1", 1111),
+				groupAdded("group1", 11111),
+				groupAdded("group2", 22222),
+				memberAdded("group1", 11111, "user1", 1111),
+				memberAdded("group2", 22222, "user1", 1111),
+			},
+		},
+		"Notify new membership of an existing user": {
+			dbFile: "one_user_and_group",
+			change: func(c *cache.Cache) error { return c.UpdateUserEntry(user1, []cache.GroupDB{group1, group2}) },
+			wantEvents: []cache.CacheEvent{
+				groupAdded("group2", 22222),
+				memberAdded("group2", 22222, "user1", 1111),
+			},
+		},
+		"Notify membership removal of an existing user": {
+			dbFile: "one_user_and_group",
+			change: func(c *cache.Cache) error {
+				u := cache.NewUserDB("user1", 1111, 22222, "", "/home/user1", "/bin/bash")
+				return c.UpdateUserEntryCAS(u, []cache.GroupDB{group2}, 0)
+			},
+			wantEvents: []cache.CacheEvent{
+				groupAdded("group2", 22222),
+				memberAdded("group2", 22222, "user1", 1111),
+				memberRemoved("group1", 11111, "user1", 1111),
+				groupRemoved("group1", 11111),
+			},
+		},
+		"Notify removal of a user and their groups": {
+			dbFile: "one_user_and_group",
+			change: func(c *cache.Cache) error { return c.DeleteUser(1111) },
+			wantEvents: []cache.CacheEvent{
+				memberRemoved("group1", 11111, "user1", 1111),
+				groupRemoved("group1", 11111),
+				userRemoved("user1", 1111),
+			},
+		},
+		"Notify batch updates": {
+			dbFile: "one_user_and_group",
+			change: func(c *cache.Cache) error {
+				return c.UpdateUserEntries([]cache.UserEntryUpdate{
+					{User: user2, Groups: []cache.GroupDB{group2, group1}},
+					{User: user1, Groups: []cache.GroupDB{group1, group2}},
+				})
+			},
+			wantEvents: []cache.CacheEvent{
+				userAdded("user2", 2222),
+				groupAdded("group2", 22222),
+				memberAdded("group2", 22222, "user2", 2222),
+				memberAdded("group1", 11111, "user2", 2222),
+				memberAdded("group2", 22222, "user1", 1111),
+			},
+		},
+		"Notify removal of multiple users": {
+			dbFile: "one_user_and_group",
+			change: func(c *cache.Cache) error {
+				if err := c.UpdateUserEntry(user2, []cache.GroupDB{group2, group1}); err != nil {
+					return err
+				}
+				_, err := c.DeleteUsers([]uint32{1111, 2222})
+				return err
+			},
+			wantEvents: []cache.CacheEvent{
+				userAdded("user2", 2222),
+				groupAdded("group2", 22222),
+				memberAdded("group2", 22222, "user2", 2222),
+				memberAdded("group1", 11111, "user2", 2222),
+
+				memberRemoved("group1", 11111, "user1", 1111),
+				memberRemoved("group2", 22222, "user2", 2222),
+				memberRemoved("group1", 11111, "user2", 2222),
+				groupRemoved("group1", 11111),
+				groupRemoved("group2", 22222),
+				userRemoved("user1", 1111),
+				userRemoved("user2", 2222),
+			},
+		},
+		"Notify removal of stale users": {
+			dbFile: "stale_users",
+			change: func(c *cache.Cache) error {
+				_, err := c.CleanupStaleUsers(time.Since(time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)))
+				return err
+			},
+			wantEvents: []cache.CacheEvent{
+				memberRemoved("stale", 22222, "stale", 2222),
+				memberRemoved("group1", 11111, "stale", 2222),
+				memberRemoved("group1", 11111, "stalewithoutbroker", 5555),
+				groupRemoved("stale", 22222),
+				userRemoved("stale", 2222),
+				userRemoved("stalewithoutbroker", 5555),
+			},
+		},
+		"Notify nothing on update without changes": {
+			dbFile: "one_user_and_group",
+			change: func(c *cache.Cache) error { return c.UpdateUserEntry(user1, []cache.GroupDB{group1}) },
+		},
+
+		"Notify nothing on failed update": {
+			dbFile: "one_user_and_group",
+			change: func(c *cache.Cache) error {
+				return c.UpdateUserEntries([]cache.UserEntryUpdate{
+					{User: user2, Groups: []cache.GroupDB{group2}},
+					{User: cache.NewUserDB("user3", 1111, 22222, "", "/home/user3", "/bin/bash"), Groups: []cache.GroupDB{group2}},
+				})
+			},
+			wantErr: true,
+		},
+		"Notify nothing on failed deletion": {
+			change:  func(c *cache.Cache) error { return c.DeleteUser(1111) },
+			wantErr: true,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+			events, unsubscribe := c.Subscribe()
+			defer unsubscribe()
+
+			err := tc.change(c)
+			if tc.wantErr {
+				require.Error(t, err, "Change should return an error")
+			} else {
+				require.NoError(t, err, "Change should not return an error")
+			}
+
+			// Events are delivered before the change returns.
+			var got []cache.CacheEvent
+			for len(events) > 0 {
+				got = append(got, <-events)
+			}
+			require.Equal(t, tc.wantEvents, got, "Subscriber should receive the expected events")
+		})
+	}
+}
+
+func TestSubscribeWithSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "")
+	slow, unsubscribeSlow := c.Subscribe()
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := c.Subscribe()
+	defer unsubscribeFast()
+
+	addUser := func(uid uint32) {
+		t.Helper()
+		u := cache.NewUserDB(fmt.Sprintf("user%d", uid), uid, 11111, "", fmt.Sprintf("/home/user%d", uid), "/bin/bash")
+		require.NoError(t, c.UpdateUserEntry(u, []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)}),
+			"Setup: UpdateUserEntry should not return an error")
+	}
+
+	// Each user after the first one adds 2 events: the user, and their membership.
+	var fastEvents int
+	for uid := uint32(1); cap(slow)-len(slow) >= 2; uid++ {
+		addUser(uid)
+		for len(fast) > 0 {
+			require.False(t, (<-fast).Missed, "Subscriber reading events should not miss any")
+			fastEvents++
+		}
+	}
+	require.Equal(t, len(slow), fastEvents, "Subscribers should receive the same events")
+	addUser(10000)
+
+	for len(slow) > 0 {
+		require.False(t, (<-slow).Missed, "Events delivered before the buffer was full should not be flagged")
+	}
+	addUser(10001)
+	e := <-slow
+	require.True(t, e.Missed, "First event delivered after some were dropped should be flagged")
+	require.Equal(t, "user10001", e.Name, "First event delivered after some were dropped should be the next one")
+	require.False(t, (<-slow).Missed, "Next events should not be flagged")
+}
+
+func TestUnsubscribe(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "")
+	events, unsubscribe := c.Subscribe()
+	other, _ := c.Subscribe()
+
+	unsubscribe()
+	_, ok := <-events
+	require.False(t, ok, "Unsubscribe should close the channel")
+	require.NotPanics(t, unsubscribe, "Unsubscribe should be idempotent")
+
+	require.NoError(t, c.UpdateUserEntry(cache.NewUserDB("user1", 1111, 11111, "", "/home/user1", "/bin/bash"),
+		[]cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)}), "Setup: UpdateUserEntry should not return an error")
+	require.NotEmpty(t, other, "Other subscribers should still receive events")
+
+	require.NoError(t, c.Close(), "Setup: could not close cache")
+	for len(other) > 0 {
+		<-other
+	}
+	_, ok = <-other
+	require.False(t, ok, "Close should close the channels of the subscribers")
+}
+
+func TestReadOnly(t *testing.T) {
+	t.Parallel()
+
+	cacheDir := t.TempDir()
+	cachetestutils.CreateDBFromYAML(t, filepath.Join("testdata", "multiple_users_and_groups.db.yaml"), cacheDir)
+
+	c := initCacheFromDir(t, cacheDir, cache.WithReadOnly())
+	// The database is only locked in shared mode, so that other read-only processes can open it.
+	other := initCacheFromDir(t, cacheDir, cache.WithReadOnly())
+
+	for _, c := range []*cache.Cache{c, other} {
+		u, err := c.UserByName("user1")
+		require.NoError(t, err, "UserByName should not return an error")
+		require.Equal(t, uint32(1111), u.UID, "UserByName should return the expected user")
+		_, err = c.GroupByID(11111)
+		require.NoError(t, err, "GroupByID should not return an error")
+	}
+
+	writes := map[string]func() error{
+		"UpdateUserEntry": func() error {
+			return c.UpdateUserEntry(cache.NewUserDB("user1", 1111, 11111, "", "/home/user1", "/bin/bash"),
+				[]cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)})
+		},
+		"UpdateBrokerForUser": func() error { return c.UpdateBrokerForUser("user1", "other-broker-id") },
+		"DeleteUser":          func() error { return c.DeleteUser(1111) },
+		"RecordFailedLogin": func() error {
+			_, err := c.RecordFailedLogin("user1")
+			return err
+		},
+		"Compact": func() error {
+			_, _, err := c.Compact()
+			return err
+		},
+	}
+	for name, write := range writes {
+		require.ErrorIs(t, write(), cache.ErrReadOnlyCache, "%s should return ErrReadOnlyCache", name)
+	}
+
+	s := c.State()
+	require.Equal(t, cache.CacheReadOnly, s.Status, "State should report the cache as read-only")
+	require.NoError(t, s.LastError, "Writes to a read-only cache should not be recorded as database errors")
+
+	_, err := cache.New(t.TempDir(), cache.WithReadOnly())
+	require.Error(t, err, "New should return an error when opening a missing database read-only")
+
+	// The exclusive lock of a read-write process, like the daemon, conflicts with the shared lock of the readers.
+	rwDir := t.TempDir()
+	cachetestutils.CreateDBFromYAML(t, filepath.Join("testdata", "multiple_users_and_groups.db.yaml"), rwDir)
+	initCacheFromDir(t, rwDir)
+	_, err = cache.New(rwDir, cache.WithReadOnly())
+	require.ErrorIs(t, err, cache.ErrDatabaseInUse, "New should time out opening read-only a database opened read-write")
+}
+
+func TestState(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "multiple_users_and_groups")
+
+	s := c.State()
+	require.Equal(t, cache.CacheHealthy, s.Status, "State should be healthy after opening the cache")
+	require.Equal(t, c.DbPath(), s.Path, "State should report the database path")
+	require.NoError(t, s.LastError, "State should not report an error after opening the cache")
+
+	// Missing entries are not database errors.
+	_, err := c.UserByName("nonexistent")
+	require.Error(t, err, "Setup: UserByName for a nonexistent user should return an error")
+	require.Equal(t, cache.CacheHealthy, c.State().Status, "State should stay healthy when no entry was found")
+
+	require.NoError(t, c.Close(), "Setup: could not close cache")
+	s = c.State()
+	require.Equal(t, cache.CacheClosed, s.Status, "State should be closed after closing the cache")
+	require.NoError(t, s.LastError, "State should not report an error after closing the cache")
+
+	_, err = c.UserByName("user1")
+	require.Error(t, err, "Setup: UserByName on a closed cache should return an error")
+	s = c.State()
+	require.Equal(t, cache.CacheClosed, s.Status, "State should stay closed after a failed transaction")
+	require.ErrorIs(t, s.LastError, bbolt.ErrDatabaseNotOpen, "State should report the last database error")
+}
+
+func TestReadsDuringMigration(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "multiple_users_and_groups")
+
+	migrating := make(chan struct{})
+	commit := make(chan struct{})
+	migrationDone := make(chan error)
+	go func() {
+		migrationDone <- c.RunMigration(func() {
+			close(migrating)
+			<-commit
+		})
+	}()
+	<-migrating
+
+	_, err := c.UserByName("user1")
+	require.ErrorIs(t, err, cache.ErrMigrationInProgress, "UserByName should fail while a migration is in progress")
+	_, err = c.GroupByID(11111)
+	require.ErrorIs(t, err, cache.ErrMigrationInProgress, "GroupByID should fail while a migration is in progress")
+	_, err = c.AllUsers()
+	require.ErrorIs(t, err, cache.ErrMigrationInProgress, "AllUsers should fail while a migration is in progress")
+	require.Equal(t, cache.CacheHealthy, c.State().Status, "State should stay healthy while a migration is in progress")
+
+	close(commit)
+	require.NoError(t, <-migrationDone, "Setup: migration should succeed")
+
+	u, err := c.UserByName("user1")
+	require.NoError(t, err, "UserByName should succeed once the migration is committed")
+	require.Equal(t, "user1", u.Name, "UserByName should return the expected user")
+}
+
+func TestSetNestedGroups(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile     string
+		gid        uint32
+		nestedGIDs []uint32
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Nest groups in a group":                      {gid: 11111, nestedGIDs: []uint32{33333, 22222, 33333}},
+		"Replace groups nested in a group":            {dbFile: "nested_groups", gid: 22222, nestedGIDs: []uint32{11111}},
+		"Remove groups nested in a group":             {dbFile: "nested_groups", gid: 22222},
+		"Remove groups nested in a group without any": {gid: 22222},
+		"Nest a group in itself":                      {gid: 11111, nestedGIDs: []uint32{11111}},
+
+		"Error on missing group":        {gid: 12345, nestedGIDs: []uint32{22222}, wantErrType: cache.NoDataFoundError{}},
+		"Error on missing nested group": {gid: 11111, nestedGIDs: []uint32{12345}, wantErrType: cache.NoDataFoundError{}},
+		"Error on invalid group entry":  {dbFile: "invalid_entry_in_groupByID", gid: 11111, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.dbFile == "" {
+				tc.dbFile = "multiple_users_and_groups"
+			}
+			c := initCache(t, tc.dbFile)
+
+			err := c.SetNestedGroups(tc.gid, tc.nestedGIDs)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "SetNestedGroups should return expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "SetNestedGroups should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
+		})
+	}
+}
+
+func TestFlattenedGroupMembers(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+		gid    uint32
+
+		want        []uint32
+		wantErr     bool
+		wantErrType error
+	}{
+		"Get members of a group without nested groups":     {dbFile: "multiple_users_and_groups", gid: 99999, want: []uint32{2222, 3333}},
+		"Get members of a group with nested groups":        {gid: 22222, want: []uint32{1111, 2222, 3333, 4444}},
+		"Get members of a group in a cycle of nesting":     {gid: 11111, want: []uint32{1111, 2222, 3333, 4444}},
+		"Get members of a group nested in itself":          {gid: 99999, want: []uint32{2222, 3333}},
+		"Get members of a group only nested in other ones": {gid: 33333, want: []uint32{3333}},
+
+		"Error on missing group":       {gid: 12345, wantErrType: cache.NoDataFoundError{}},
+		"Error on invalid group entry": {dbFile: "invalid_entry_in_groupByID", gid: 11111, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.dbFile == "" {
+				tc.dbFile = "nested_groups"
+			}
+			c := initCache(t, tc.dbFile)
+
+			got, err := c.FlattenedGroupMembers(tc.gid)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "FlattenedGroupMembers should return expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "FlattenedGroupMembers should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.want, got, "FlattenedGroupMembers should return the members of the group and its nested groups")
+		})
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Delete existing user":                                         {dbFile: "one_user_and_group"},
+		"Delete existing user keeping other users intact":              {dbFile: "multiple_users_and_groups"},
+		"Delete existing user removing their group from nested groups": {dbFile: "nested_groups"},
+
+		"Error on missing user":           {wantErrType: cache.NoDataFoundError{}},
+		"Error on invalid database entry": {dbFile: "invalid_entry_in_userByID", wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -471,15 +2694,507 @@ func TestDeleteUser(t *testing.T) {
 
 			err := c.DeleteUser(1111)
 			if tc.wantErr {
-				require.Error(t, err, "DeleteUser should return an error but didn't")
+				require.Error(t, err, "DeleteUser should return an error but didn't")
+				return
+			}
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "DeleteUser should return expected error")
+				return
+			}
+			require.NoError(t, err)
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
+		})
+	}
+}
+
+func TestCleanupStaleUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+		maxAge time.Duration
+
+		wantRemoved []string
+		wantErr     bool
+	}{
+		"Remove users who did not log in since max age": {maxAge: 30 * 24 * time.Hour, wantRemoved: []string{"stale", "stalewithoutbroker"}},
+		"Keep all users who logged in since max age":    {maxAge: 365 * 24 * time.Hour},
+		"Keep all users with no max age":                {},
+		"Keep all users with a negative max age":        {maxAge: -time.Hour},
+
+		"Error on some invalid users entry": {dbFile: "invalid_entries_but_user_and_group1", maxAge: time.Hour, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.dbFile == "" {
+				tc.dbFile = "stale_users"
+			}
+			now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+			c := initCache(t, tc.dbFile, cache.WithNow(func() time.Time { return now }))
+
+			removed, err := c.CleanupStaleUsers(tc.maxAge)
+			if tc.wantErr {
+				require.Error(t, err, "CleanupStaleUsers should return an error but didn't")
 				return
 			}
+			require.NoError(t, err, "CleanupStaleUsers should not return an error")
+			require.Equal(t, tc.wantRemoved, removed, "CleanupStaleUsers should return the names of the removed users")
+			c.AssertConsistent(t)
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
+		})
+	}
+}
+
+func TestTombstones(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		uidReuseDelay time.Duration
+		elapsed       time.Duration
+		release       bool
+
+		wantTombstones []uint32
+	}{
+		"Tombstone deleted UID until the delay elapsed": {uidReuseDelay: time.Hour, elapsed: time.Minute, wantTombstones: []uint32{1111}},
+		"Tombstone deleted UID forever":                 {uidReuseDelay: -1, elapsed: 1000 * time.Hour, wantTombstones: []uint32{1111}},
+		"Reuse UID once the delay elapsed":              {uidReuseDelay: time.Hour, elapsed: 2 * time.Hour},
+		"Reuse UID once the tombstone is released":      {uidReuseDelay: -1, release: true},
+		"Reuse UID right away by default":               {},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			c := initCache(t, "one_user_and_group", cache.WithUIDReuseDelay(tc.uidReuseDelay),
+				cache.WithNow(func() time.Time { return now }))
+
+			require.NoError(t, c.DeleteUser(1111), "Setup: DeleteUser should not return an error")
+			now = now.Add(tc.elapsed)
+			if tc.release {
+				require.NoError(t, c.ReleaseTombstone(1111), "ReleaseTombstone should not return an error")
+			}
+
+			got, err := c.Tombstones()
+			require.NoError(t, err, "Tombstones should not return an error")
+			require.Equal(t, tc.wantTombstones, got, "Tombstones should return the reserved UIDs")
+
+			if len(tc.wantTombstones) > 0 {
+				err = c.UpdateUserEntry(cache.NewUserDB("otheruser", 1111, 1111, "", "/home/otheruser", "/bin/bash"),
+					[]cache.GroupDB{cache.NewGroupDB("otheruser", 1111, nil)})
+				require.ErrorIs(t, err, cache.ErrUIDReserved, "UpdateUserEntry should not insert a user on a tombstoned UID")
+			}
+
+			u, _, err := c.CreateUserIfAbsent("newuser", cache.UserDB{}, 1111, 1112)
+			require.NoError(t, err, "CreateUserIfAbsent should not return an error")
+			wantUID := uint32(1111)
+			if len(tc.wantTombstones) > 0 {
+				wantUID = 1112
+			}
+			require.Equal(t, wantUID, u.UID, "CreateUserIfAbsent should only allocate UIDs which are not tombstoned")
+		})
+	}
+}
+
+func TestTombstonesReclaimedByTheSameUser(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name                 string
+		caseInsensitiveNames bool
+
+		wantUID uint32
+	}{
+		"Reclaim UID for the same name":                               {name: "user1", wantUID: 1111},
+		"Reclaim UID for the same name with different case if folded": {name: "USER1", caseInsensitiveNames: true, wantUID: 1111},
+		"Keep UID reserved for the same name with different case":     {name: "USER1", wantUID: 1112},
+		"Keep UID reserved for another name even if names are folded": {name: "user2", caseInsensitiveNames: true, wantUID: 1112},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, "one_user_and_group", cache.WithUIDReuseDelay(-1),
+				cache.WithCaseInsensitiveNames(tc.caseInsensitiveNames))
+			require.NoError(t, c.DeleteUser(1111), "Setup: DeleteUser should not return an error")
+
+			u, _, err := c.CreateUserIfAbsent(tc.name, cache.UserDB{}, 1111, 1112)
+			require.NoError(t, err, "CreateUserIfAbsent should not return an error")
+			require.Equal(t, tc.wantUID, u.UID, "CreateUserIfAbsent should only reclaim a tombstoned UID for the same user")
+		})
+	}
+}
+
+func TestRecordFailedLogin(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		username         string
+		previousFailures int
+		elapsed          time.Duration
+		args             []cache.FailedLoginOption
+		reset            bool
+		login            bool
+		recreate         bool
+
+		wantCount   int
+		wantErrType error
+	}{
+		"Count first failed login":                                  {wantCount: 1},
+		"Count failed logins since the last one":                    {previousFailures: 2, elapsed: time.Minute, wantCount: 3},
+		"Count failed logins within a custom window":                {previousFailures: 2, elapsed: 20 * time.Minute, args: []cache.FailedLoginOption{cache.WithFailedLoginWindow(time.Hour)}, wantCount: 3},
+		"Count failed logins forever with a negative window":        {previousFailures: 2, elapsed: 1000 * time.Hour, args: []cache.FailedLoginOption{cache.WithFailedLoginWindow(-1)}, wantCount: 3},
+		"Count failed logins from scratch after the window":         {previousFailures: 2, elapsed: 20 * time.Minute, wantCount: 1},
+		"Count failed logins from scratch after a reset":            {previousFailures: 2, reset: true, wantCount: 1},
+		"Count failed logins from scratch after a login":            {previousFailures: 2, login: true, wantCount: 1},
+		"Count failed logins from scratch after the user recreated": {previousFailures: 2, recreate: true, wantCount: 1},
+
+		"Error on missing user": {username: "doesnotexist", wantErrType: cache.NoDataFoundError{}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.username == "" {
+				tc.username = "user1"
+			}
+			now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			c := initCache(t, "one_user_and_group", cache.WithNow(func() time.Time { return now }))
+
+			for range tc.previousFailures {
+				_, err := c.RecordFailedLogin(tc.username, tc.args...)
+				require.NoError(t, err, "Setup: RecordFailedLogin should not return an error")
+			}
+			now = now.Add(tc.elapsed)
+			if tc.reset {
+				require.NoError(t, c.ResetFailedLogins(tc.username), "ResetFailedLogins should not return an error")
+			}
+			if tc.login {
+				u, err := c.UserByName(tc.username)
+				require.NoError(t, err, "Setup: UserByName should not return an error")
+				err = c.UpdateUserEntry(u, []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil)})
+				require.NoError(t, err, "Setup: UpdateUserEntry should not return an error")
+			}
+			if tc.recreate {
+				require.NoError(t, c.DeleteUser(1111), "Setup: DeleteUser should not return an error")
+				_, _, err := c.CreateUserIfAbsent(tc.username, cache.UserDB{}, 1111, 1111)
+				require.NoError(t, err, "Setup: CreateUserIfAbsent should not return an error")
+			}
+
+			got, err := c.RecordFailedLogin(tc.username, tc.args...)
 			if tc.wantErrType != nil {
-				require.ErrorIs(t, err, tc.wantErrType, "DeleteUser should return expected error")
+				require.ErrorIs(t, err, tc.wantErrType, "RecordFailedLogin should return expected error")
+				require.ErrorIs(t, c.ResetFailedLogins(tc.username), tc.wantErrType, "ResetFailedLogins should return expected error")
+				return
+			}
+			require.NoError(t, err, "RecordFailedLogin should not return an error")
+			require.Equal(t, tc.wantCount, got, "RecordFailedLogin should return the number of failed logins")
+		})
+	}
+}
+
+func TestReleaseTombstone(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "one_user_and_group", cache.WithUIDReuseDelay(-1))
+	require.ErrorIs(t, c.ReleaseTombstone(1111), cache.NoDataFoundError{}, "ReleaseTombstone should fail if no tombstone was ever added")
+
+	_, err := c.DeleteUsers([]uint32{1111})
+	require.NoError(t, err, "Setup: DeleteUsers should not return an error")
+	require.NoError(t, c.ReleaseTombstone(1111), "ReleaseTombstone should release the tombstone of the deleted user")
+	require.ErrorIs(t, c.ReleaseTombstone(1111), cache.NoDataFoundError{}, "ReleaseTombstone should fail on an already released tombstone")
+}
+
+func TestDeleteUsers(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+		uids   []uint32
+
+		wantDeleted []uint32
+		wantErr     bool
+	}{
+		"Delete existing users":                       {dbFile: "multiple_users_and_groups", uids: []uint32{1111, 3333}, wantDeleted: []uint32{1111, 3333}},
+		"Delete existing users skipping missing ones": {dbFile: "multiple_users_and_groups", uids: []uint32{1111, 5555, 2222}, wantDeleted: []uint32{1111, 2222}},
+		"Delete no user when none exists":             {dbFile: "multiple_users_and_groups", uids: []uint32{5555}},
+
+		"Error and delete nothing on invalid database entry": {dbFile: "invalid_entries_but_user_and_group1", uids: []uint32{1111, 2222}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			deleted, err := c.DeleteUsers(tc.uids)
+			if tc.wantErr {
+				require.Error(t, err, "DeleteUsers should return an error but didn't")
+				require.Empty(t, deleted, "DeleteUsers should not report deleted users on error")
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.wantDeleted, deleted, "DeleteUsers should return the deleted UIDs")
+			}
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
+		})
+	}
+}
+
+func TestValidateImportSet(t *testing.T) {
+	t.Parallel()
+
+	entry := func(name string, uid uint32, groups ...cache.GroupDB) cache.UserEntryUpdate {
+		return cache.UserEntryUpdate{User: cache.UserDB{Name: name, UID: uid}, Groups: groups}
+	}
+	group1 := cache.NewGroupDB("group1", 11111, nil)
+	group2 := cache.NewGroupDB("group2", 22222, nil)
+
+	tests := map[string]struct {
+		entries []cache.UserEntryUpdate
+
+		wantConflicts []cache.ImportConflict
+		wantErr       bool
+	}{
+		"No conflict in empty set":                  {},
+		"No conflict between users sharing a group": {entries: []cache.UserEntryUpdate{entry("user1", 1111, group1), entry("user2", 2222, group2, group1)}},
+
+		"Conflict on UID used by several users": {
+			entries: []cache.UserEntryUpdate{entry("user1", 1111, group1), entry("user2", 2222, group2), entry("user3", 1111, group1)},
+			wantConflicts: []cache.ImportConflict{
+				{Reason: "UID used by several users", Names: []string{"user1", "user3"}, IDs: []uint32{1111, 1111}},
+			},
+		},
+		"Conflict on name used by several users": {
+			entries: []cache.UserEntryUpdate{entry("user1", 1111, group1), entry("user1", 2222, group1)},
+			wantConflicts: []cache.ImportConflict{
+				{Reason: "name used by several users", Names: []string{"user1", "user1"}, IDs: []uint32{1111, 2222}},
+			},
+		},
+		"Conflicts on groups": {
+			entries: []cache.UserEntryUpdate{
+				entry("user1", 1111, group1),
+				entry("user2", 2222, cache.NewGroupDB("group2", 11111, nil)),
+				entry("user3", 3333, cache.NewGroupDB("group1", 33333, nil)),
+			},
+			wantConflicts: []cache.ImportConflict{
+				{Reason: "GID used by several groups", Names: []string{"group1", "group2"}, IDs: []uint32{11111, 11111}},
+				{Reason: "name used by several groups", Names: []string{"group1", "group1"}, IDs: []uint32{11111, 33333}},
+			},
+		},
+
+		"Error on user without name":   {entries: []cache.UserEntryUpdate{entry("", 1111, group1)}, wantErr: true},
+		"Error on user without groups": {entries: []cache.UserEntryUpdate{entry("user1", 1111)}, wantErr: true},
+		"Error on group without name":  {entries: []cache.UserEntryUpdate{entry("user1", 1111, cache.GroupDB{GID: 11111})}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := cache.ValidateImportSet(tc.entries)
+			if tc.wantErr {
+				require.Error(t, err, "ValidateImportSet should return an error but didn't")
+				return
+			}
+			require.NoError(t, err, "ValidateImportSet should not return an error but did")
+			require.Equal(t, tc.wantConflicts, got, "ValidateImportSet should return the expected conflicts")
+		})
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	t.Parallel()
+
+	user1 := cache.UserDB{Name: "user1", UID: 1111, Gecos: "New user1 gecos", Dir: "/home/user1", Shell: "/bin/dash"}
+	user2 := cache.UserDB{Name: "user2", UID: 2222, Gecos: "User2", Dir: "/home/user2", Shell: "/bin/dash"}
+	user5 := cache.UserDB{Name: "user5", UID: 5555, Gecos: "User5", Dir: "/home/user5", Shell: "/bin/sh"}
+
+	desiredCases := map[string]cache.UserEntryUpdate{
+		"user1": {User: user1, Groups: []cache.GroupDB{cache.NewGroupDB("group1", 11111, nil), cache.NewGroupDB("group2", 22222, nil)}},
+		"user2": {User: user2, Groups: []cache.GroupDB{cache.NewGroupDB("group2", 22222, nil)}},
+		"user5": {User: user5, Groups: []cache.GroupDB{cache.NewGroupDB("group5", 55555, nil)}, BrokerID: "broker-id"},
+
+		"user5-duplicated-uid": {User: cache.UserDB{Name: "otheruser5", UID: 5555}, Groups: []cache.GroupDB{cache.NewGroupDB("group5", 55555, nil)}},
+		"user5-without-groups": {User: user5},
+	}
+
+	tests := map[string]struct {
+		desired []string
+		opts    cache.ReconcileOptions
+
+		wantReport cache.ReconcileReport
+		wantErr    bool
+	}{
+		"Upsert users keeping the other ones": {desired: []string{"user1", "user5"}, wantReport: cache.ReconcileReport{Created: 1, Updated: 1}},
+		"Upsert users deleting the other ones": {
+			desired: []string{"user1", "user2", "user5"}, opts: cache.ReconcileOptions{AllowDeletion: true},
+			wantReport: cache.ReconcileReport{Created: 1, Updated: 2, Deleted: 2},
+		},
+		"Upsert users deleting the other ones of the source": {
+			desired: []string{"user1", "user5"}, opts: cache.ReconcileOptions{AllowDeletion: true, Source: "broker-id"},
+			wantReport: cache.ReconcileReport{Created: 1, Updated: 1, Deleted: 2},
+		},
+		"Delete all users when nothing is desired": {
+			opts:       cache.ReconcileOptions{AllowDeletion: true},
+			wantReport: cache.ReconcileReport{Deleted: 4},
+		},
+		"Delete nothing when nothing is desired without deletion allowed": {},
+
+		"Error and change nothing when an UID is desired twice":     {desired: []string{"user1", "user5", "user5-duplicated-uid"}, wantErr: true},
+		"Error and change nothing when a desired user has no group": {desired: []string{"user1", "user5-without-groups"}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, "multiple_users_and_groups")
+
+			var desired []cache.UserEntryUpdate
+			for _, d := range tc.desired {
+				desired = append(desired, desiredCases[d])
+			}
+
+			report, err := c.Reconcile(desired, tc.opts)
+			if tc.wantErr {
+				require.Error(t, err, "Reconcile should return an error but didn't")
+			} else {
+				require.NoError(t, err, "Reconcile should not return an error")
+			}
+			require.Equal(t, tc.wantReport, report, "Reconcile should return the expected report")
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
+		})
+	}
+}
+
+func TestQuarantine(t *testing.T) {
+	t.Parallel()
+
+	quarantinedAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		dbFile string
+		uid    uint32
+
+		alreadyQuarantined bool
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Quarantine existing user":                        {dbFile: "multiple_users_and_groups", uid: 2222},
+		"Quarantine user removing its groups left empty":  {dbFile: "one_user_and_group", uid: 1111},
+		"Quarantine user with invalid record in userByID": {dbFile: "invalid_entry_in_userByID", uid: 1111},
+
+		"Error on missing user":                  {dbFile: "multiple_users_and_groups", uid: 5555, wantErrType: cache.NoDataFoundError{}},
+		"Error when user is already quarantined": {dbFile: "multiple_users_and_groups", uid: 2222, alreadyQuarantined: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile, cache.WithNow(func() time.Time { return quarantinedAt }))
+
+			if tc.alreadyQuarantined {
+				require.NoError(t, c.Quarantine(tc.uid, "first quarantine"), "Setup: Quarantine should not return an error")
+			}
+
+			err := c.Quarantine(tc.uid, "conflicting record")
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "Quarantine should return expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "Quarantine should return an error but didn't")
+				return
+			}
+			require.NoError(t, err)
+
+			_, err = c.UserByID(tc.uid)
+			require.ErrorIs(t, err, cache.NoDataFoundError{}, "Quarantined user should not be served anymore")
+
+			records, err := c.QuarantinedRecords()
+			require.NoError(t, err, "QuarantinedRecords should not return an error")
+			require.Len(t, records, 1, "QuarantinedRecords should return the quarantined user")
+			require.Equal(t, tc.uid, records[0].UID, "QuarantinedRecords should return the quarantined UID")
+			require.Equal(t, "conflicting record", records[0].Reason, "QuarantinedRecords should return the reason")
+			require.Equal(t, quarantinedAt, records[0].QuarantinedAt.UTC(), "QuarantinedRecords should return the quarantine time")
+
+			got, err := cachetestutils.DumpToYaml(c)
+			require.NoError(t, err, "Created database should be valid yaml content")
+			want := testutils.LoadWithUpdateFromGolden(t, got)
+			require.Equal(t, want, got, "Did not get expected database content")
+		})
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		dbFile string
+		uid    uint32
+
+		notQuarantined bool
+		reuseUID       bool
+
+		wantErr     bool
+		wantErrType error
+	}{
+		"Restore quarantined user":                    {dbFile: "multiple_users_and_groups", uid: 2222},
+		"Restore quarantined user and removed groups": {dbFile: "one_user_and_group", uid: 1111},
+
+		"Error on user not quarantined":          {dbFile: "multiple_users_and_groups", uid: 2222, notQuarantined: true, wantErrType: cache.NoDataFoundError{}},
+		"Error when user record is invalid":      {dbFile: "invalid_entry_in_userByID", uid: 1111, wantErr: true},
+		"Error when UID is used by another user": {dbFile: "one_user_and_group", uid: 1111, reuseUID: true, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := initCache(t, tc.dbFile)
+
+			if !tc.notQuarantined {
+				require.NoError(t, c.Quarantine(tc.uid, "conflicting record"), "Setup: Quarantine should not return an error")
+			}
+			if tc.reuseUID {
+				err := c.UpdateUserEntry(cache.UserDB{Name: "otheruser", UID: tc.uid, GID: 55555}, []cache.GroupDB{cache.NewGroupDB("othergroup", 55555, nil)})
+				require.NoError(t, err, "Setup: UpdateUserEntry should not return an error")
+			}
+
+			err := c.Restore(tc.uid)
+			if tc.wantErrType != nil {
+				require.ErrorIs(t, err, tc.wantErrType, "Restore should return expected error")
+				return
+			}
+			if tc.wantErr {
+				require.Error(t, err, "Restore should return an error but didn't")
+				records, err := c.QuarantinedRecords()
+				require.NoError(t, err, "QuarantinedRecords should not return an error")
+				require.Len(t, records, 1, "Failed restore should keep the record in quarantine")
 				return
 			}
 			require.NoError(t, err)
 
+			records, err := c.QuarantinedRecords()
+			require.NoError(t, err, "QuarantinedRecords should not return an error")
+			require.Empty(t, records, "Restored user should not be quarantined anymore")
+
 			got, err := cachetestutils.DumpToYaml(c)
 			require.NoError(t, err, "Created database should be valid yaml content")
 			want := testutils.LoadWithUpdateFromGolden(t, got)
@@ -488,8 +3203,98 @@ func TestDeleteUser(t *testing.T) {
 	}
 }
 
+func TestUpdateThenDeleteUserLeavesNoOrphanGroup(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "")
+
+	sharedGroup := cache.NewGroupDB("sharedgroup", 99999, nil)
+	for _, u := range []cache.UserDB{
+		cache.NewUserDB("user1", 1111, 1111, "", "/home/user1", "/bin/bash"),
+		cache.NewUserDB("user2", 2222, 2222, "", "/home/user2", "/bin/bash"),
+	} {
+		privateGroup := cache.NewGroupDB(u.Name, u.GID, nil)
+		err := c.UpdateUserEntry(u, []cache.GroupDB{privateGroup, sharedGroup})
+		require.NoError(t, err, "Setup: UpdateUserEntry should not return an error")
+	}
+
+	err := c.DeleteUser(1111)
+	require.NoError(t, err, "DeleteUser should not return an error")
+
+	_, err = c.GroupByName("user1")
+	require.ErrorIs(t, err, cache.NoDataFoundError{}, "Private group of deleted user should be removed")
+	_, err = c.GroupByID(1111)
+	require.ErrorIs(t, err, cache.NoDataFoundError{}, "Private group of deleted user should be removed")
+
+	g, err := c.GroupByName("sharedgroup")
+	require.NoError(t, err, "Group with remaining members should be kept")
+	require.Equal(t, []string{"user2"}, g.Users, "Group with remaining members should only contain them")
+
+	g, err = c.GroupByName("user2")
+	require.NoError(t, err, "Private group of other user should be kept")
+	require.Equal(t, []string{"user2"}, g.Users, "Private group of other user should be unchanged")
+}
+
+func TestLargeGroupMembers(t *testing.T) {
+	t.Parallel()
+
+	c := initCache(t, "", cache.WithLargeGroupThreshold(2))
+
+	sharedGroup := cache.NewGroupDB("sharedgroup", 99999, nil)
+	users := []cache.UserDB{
+		cache.NewUserDB("user4", 4444, 4444, "", "/home/user4", "/bin/bash"),
+		cache.NewUserDB("user3", 3333, 3333, "", "/home/user3", "/bin/bash"),
+		cache.NewUserDB("user2", 2222, 2222, "", "/home/user2", "/bin/bash"),
+		cache.NewUserDB("user1", 1111, 1111, "", "/home/user1", "/bin/bash"),
+	}
+	groupsOf := func(u cache.UserDB) []cache.GroupDB {
+		return []cache.GroupDB{cache.NewGroupDB(u.Name, u.GID, nil), sharedGroup}
+	}
+	for _, u := range users {
+		err := c.UpdateUserEntry(u, groupsOf(u))
+		require.NoError(t, err, "Setup: UpdateUserEntry should not return an error")
+	}
+	// Updating a member of a large group doesn't add it twice.
+	err := c.UpdateUserEntry(users[0], groupsOf(users[0]))
+	require.NoError(t, err, "Setup: UpdateUserEntry should not return an error")
+	c.AssertConsistent(t)
+
+	g, err := c.GroupByName("sharedgroup")
+	require.NoError(t, err, "GroupByName should return the large group")
+	require.Equal(t, []string{"user1", "user2", "user3", "user4"}, g.Users, "Large group should list its members by UID")
+
+	var members []string
+	err = c.GroupMembersByName("sharedgroup", 3, func(batch []string) error {
+		members = append(members, batch...)
+		return nil
+	})
+	require.NoError(t, err, "GroupMembersByName should return the members of the large group")
+	require.Equal(t, g.Users, members, "GroupMembersByName should return all the members of the large group")
+
+	// Members leave the large group when their groups are updated or when they are deleted.
+	err = c.UpdateUserEntry(users[2], groupsOf(users[2])[:1])
+	require.NoError(t, err, "UpdateUserEntry should not return an error when leaving a large group")
+	err = c.DeleteUser(users[1].UID)
+	require.NoError(t, err, "DeleteUser should not return an error for a member of a large group")
+	c.AssertConsistent(t)
+
+	got, err := cachetestutils.DumpToYaml(c)
+	require.NoError(t, err, "Created database should be valid yaml content")
+	want := testutils.LoadWithUpdateFromGolden(t, got)
+	require.Equal(t, want, got, "Did not get expected database content")
+
+	// The large group is deleted with its last member.
+	for _, u := range []cache.UserDB{users[0], users[3]} {
+		err = c.DeleteUser(u.UID)
+		require.NoError(t, err, "DeleteUser should not return an error for a member of a large group")
+	}
+	_, err = c.GroupByName("sharedgroup")
+	require.ErrorIs(t, err, cache.NoDataFoundError{}, "Large group without members should be removed")
+	c.AssertConsistent(t)
+}
+
 // initCache returns a new cache ready to be used alongside its cache directory.
-func initCache(t *testing.T, dbFile string) (c *cache.Cache) {
+func initCache(t *testing.T, dbFile string, opts ...cache.Option) (c *cache.Cache) {
 	t.Helper()
 
 	cacheDir := t.TempDir()
@@ -497,7 +3302,13 @@ func initCache(t *testing.T, dbFile string) (c *cache.Cache) {
 		cachetestutils.CreateDBFromYAML(t, filepath.Join("testdata", dbFile+".db.yaml"), cacheDir)
 	}
 
-	c, err := cache.New(cacheDir)
+	return initCacheFromDir(t, cacheDir, opts...)
+}
+
+func initCacheFromDir(t *testing.T, cacheDir string, opts ...cache.Option) (c *cache.Cache) {
+	t.Helper()
+
+	c, err := cache.New(cacheDir, opts...)
 	require.NoError(t, err)
 	t.Cleanup(func() { c.Close() })
 
@@ -2,11 +2,10 @@ package cache
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"slices"
 	"strconv"
+	"time"
 
 	"github.com/ubuntu/authd/internal/log"
 	"github.com/ubuntu/decorate"
@@ -14,38 +13,140 @@ import (
 )
 
 // DeleteUser removes the user from the database.
+// Groups left without any member, like the private group of the user, are removed in the same transaction.
+// The UID is tombstoned if UIDs reuse is delayed.
 func (c *Cache) DeleteUser(uid uint32) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	return c.db.Update(func(tx *bbolt.Tx) error {
-		buckets, err := getAllBuckets(tx)
+	return c.updateWithEvents(func(tx *bbolt.Tx, t *changeTracker) error {
+		buckets, err := c.getAllBuckets(tx)
 		if err != nil {
 			return err
 		}
 
-		if err := deleteUser(buckets, uid); err != nil {
+		if err := t.trackUser(buckets, uid, nil); err != nil {
 			return err
 		}
+		return c.deleteUserWithTombstone(buckets, uid)
+	})
+}
+
+// DeleteUsers removes the users matching uids from the database in a single transaction, and returns the UIDs of the
+// ones which were removed. UIDs not in the database are skipped.
+// If any deletion fails, none of the users are removed.
+func (c *Cache) DeleteUsers(uids []uint32) (deleted []uint32, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.updateWithEvents(func(tx *bbolt.Tx, t *changeTracker) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, uid := range uids {
+			if err := t.trackUser(buckets, uid, nil); err != nil {
+				return err
+			}
+		}
+		for _, uid := range uids {
+			if buckets[userByIDBucketName].Get([]byte(strconv.FormatUint(uint64(uid), 10))) == nil {
+				continue
+			}
+			if err := c.deleteUserWithTombstone(buckets, uid); err != nil {
+				return err
+			}
+			deleted = append(deleted, uid)
+		}
 		return nil
 	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return deleted, nil
+}
+
+// localBrokerID is the ID of the local broker, whose users are never expired. It matches brokers.LocalBrokerName,
+// which can't be imported here.
+const localBrokerID = "local"
+
+// CleanupStaleUsers removes the users whose last login is older than maxAge, in a single transaction, and returns
+// their names. They are removed like with DeleteUser.
+// Users of the local broker and users who never logged in are kept. A maxAge of 0 or less never expires any user.
+func (c *Cache) CleanupStaleUsers(maxAge time.Duration) (removed []string, err error) {
+	defer decorate.OnError(&err, "could not clean up stale users")
+
+	if maxAge <= 0 {
+		return nil, nil
+	}
+	cutoff := c.now().Add(-maxAge)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.updateWithEvents(func(tx *bbolt.Tx, t *changeTracker) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		var stale []userDB
+		err = buckets[userByIDBucketName].ForEach(func(key, value []byte) error {
+			var u userDB
+			if err := unmarshalValue(value, &u); err != nil {
+				return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
+			}
+			if u.LastLogin.IsZero() || !u.LastLogin.Before(cutoff) {
+				return nil
+			}
+
+			brokerID, err := getFromBucket[string](buckets[userToBrokerBucketName], u.UID)
+			if err != nil && !errors.Is(err, NoDataFoundError{}) {
+				return err
+			}
+			if brokerID == localBrokerID {
+				return nil
+			}
+
+			stale = append(stale, u)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, u := range stale {
+			if err := t.trackUser(buckets, u.UID, nil); err != nil {
+				return err
+			}
+		}
+		// Buckets can't be modified while iterating over them.
+		for _, u := range stale {
+			if err := c.deleteUserWithTombstone(buckets, u.UID); err != nil {
+				return err
+			}
+			removed = append(removed, u.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
 }
 
 // deleteUserFromGroup removes the uid from the group.
 // If the group is empty after the uid gets removed, the group is deleted from the database.
 func deleteUserFromGroup(buckets map[string]bucketWithName, uid, gid uint32) error {
-	groupToUsers, err := getFromBucket[groupToUsersDB](buckets[groupToUsersBucketName], gid)
-	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+	empty, err := removeGroupMember(buckets, gid, uid)
+	if err != nil || !empty {
 		return err
 	}
 
-	groupToUsers.UIDs = slices.DeleteFunc(groupToUsers.UIDs, func(id uint32) bool { return id == uid })
-	if len(groupToUsers.UIDs) > 0 {
-		// Update the group entry with the new list of UIDs
-		updateBucket(buckets[groupToUsersBucketName], gid, groupToUsers)
-		return nil
-	}
-
 	// We now need to delete this group with no remaining user.
 	// We need the group.Name to delete from groupByName bucket.
 	group, err := getFromBucket[GroupDB](buckets[groupByIDBucketName], gid)
@@ -55,17 +156,15 @@ func deleteUserFromGroup(buckets map[string]bucketWithName, uid, gid uint32) err
 
 	gidKey := []byte(strconv.FormatUint(uint64(gid), 10))
 	// Delete group
+	deleteGroupMembers(buckets, gid)
 	// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
-	if err = buckets[groupToUsersBucketName].Delete(gidKey); err != nil {
-		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
-	}
 	if err = buckets[groupByIDBucketName].Delete(gidKey); err != nil {
 		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
 	}
 	if err = buckets[groupByNameBucketName].Delete([]byte(group.Name)); err != nil {
 		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
 	}
-	return nil
+	return deleteNestedGroup(buckets, gid)
 }
 
 // deleteUser removes the user from the database.
@@ -97,28 +196,30 @@ func deleteUser(buckets map[string]bucketWithName, uid uint32) (err error) {
 	if err = buckets[userByIDBucketName].Delete(uidKey); err != nil {
 		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
 	}
-	if err = buckets[userByNameBucketName].Delete([]byte(u.Name)); err != nil {
+	if err = buckets[userByNameBucketName].Delete(buckets[userByNameBucketName].nameKey(u.Name)); err != nil {
 		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
 	}
+	deleteUnfoldedName(buckets[userByNameBucketName], u.Name)
 	if err = buckets[userToBrokerBucketName].Delete(uidKey); err != nil {
 		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
 	}
+	clearFailedLogins(buckets[userByIDBucketName].Tx(), uid)
 	return nil
 }
 
 // deleteOrphanedUsers removes users from the UserByID bucket that are not in the UserByName bucket.
-func deleteOrphanedUsers(db *bbolt.DB) error {
+func (c *Cache) deleteOrphanedUsers() error {
 	log.Debug(context.TODO(), "Cleaning up orphaned user records")
 
-	err := db.Update(func(tx *bbolt.Tx) error {
-		buckets, err := getAllBuckets(tx)
+	err := c.migrate(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
 		if err != nil {
 			return err
 		}
 
 		return buckets[userByIDBucketName].ForEach(func(k, v []byte) error {
 			var user UserDB
-			if err := json.Unmarshal(v, &user); err != nil {
+			if err := unmarshalValue(v, &user); err != nil {
 				log.Warningf(context.TODO(), "Error loading user record {%s: %s}: %v", k, v, err)
 				return nil
 			}
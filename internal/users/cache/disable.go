@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// SetUserEnabled enables or disables the user matching uid. Disabled users keep their entry and group memberships,
+// but can't log in until they are enabled again.
+// It returns ErrNoEntry if no user matches uid.
+func (c *Cache) SetUserEnabled(uid uint32, enabled bool) (err error) {
+	defer decorate.OnError(&err, "could not set enabled state of user %d", uid)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		u, err := getFromBucket[userDB](buckets[userByIDBucketName], uid)
+		if err != nil {
+			return noEntryError(err)
+		}
+		if u.Disabled == !enabled {
+			return nil
+		}
+
+		u.Disabled = !enabled
+		u.ModifiedAt = c.now()
+		putUser(buckets, u)
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// UserDump is the complete cached state of a user.
+type UserDump struct {
+	User         UserDB
+	PrimaryGroup GroupDB
+	Groups       []GroupDB
+	BrokerID     string
+	LastLogin    time.Time
+}
+
+// DumpUser returns all the cached state of the user matching name, gathered in a single read transaction.
+// It returns an error if the database is corrupted or no entry was found.
+func (c *Cache) DumpUser(name string) (d UserDump, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		u, err := getFromBucket[userDB](buckets[userByNameBucketName], name)
+		if err != nil {
+			return err
+		}
+		d.User = u.UserDB
+		d.LastLogin = u.LastLogin
+
+		if d.PrimaryGroup, err = getGroupWithMembers(buckets, u.GID); err != nil {
+			return err
+		}
+
+		userToGroups, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], u.UID)
+		if err != nil {
+			return err
+		}
+		for _, gid := range userToGroups.GIDs {
+			g, err := getGroupWithMembers(buckets, gid)
+			if err != nil {
+				return err
+			}
+			d.Groups = append(d.Groups, g)
+		}
+
+		d.BrokerID, err = getFromBucket[string](buckets[userToBrokerBucketName], u.UID)
+		// Ignore the error if the user doesn't have an assigned broker yet.
+		if errors.Is(err, NoDataFoundError{}) {
+			err = nil
+		}
+		return err
+	})
+
+	if err != nil {
+		return UserDump{}, err
+	}
+
+	return d, nil
+}
+
+// getGroupWithMembers returns the group matching gid and its members. It returns an error if the database is
+// corrupted or no entry was found.
+func getGroupWithMembers(buckets map[string]bucketWithName, gid uint32) (GroupDB, error) {
+	g, err := getFromBucket[groupDB](buckets[groupByIDBucketName], gid)
+	if err != nil {
+		return GroupDB{}, err
+	}
+
+	users, err := getUsersInGroup(buckets, g.GID)
+	if err != nil {
+		return GroupDB{}, err
+	}
+
+	return NewGroupDB(g.Name, g.GID, users), nil
+}
@@ -0,0 +1,369 @@
+package cache
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+
+	"go.etcd.io/bbolt"
+)
+
+// eventsBufferSize is the number of events buffered for each subscriber.
+const eventsBufferSize = 256
+
+// CacheEventType is the type of change a CacheEvent describes.
+type CacheEventType int
+
+const (
+	// UserAdded means that a user was added.
+	UserAdded CacheEventType = iota
+	// UserRemoved means that a user was removed.
+	UserRemoved
+	// GroupAdded means that a group was added.
+	GroupAdded
+	// GroupRemoved means that a group was removed.
+	GroupRemoved
+	// MemberAdded means that a user became a member of a group.
+	MemberAdded
+	// MemberRemoved means that a user stopped being a member of a group.
+	MemberRemoved
+)
+
+// String returns a human readable representation of the event type.
+func (t CacheEventType) String() string {
+	switch t {
+	case UserAdded:
+		return "user added"
+	case UserRemoved:
+		return "user removed"
+	case GroupAdded:
+		return "group added"
+	case GroupRemoved:
+		return "group removed"
+	case MemberAdded:
+		return "member added"
+	case MemberRemoved:
+		return "member removed"
+	default:
+		return "unknown"
+	}
+}
+
+// CacheEvent is a change of the users or groups of the cache, delivered to the subscribers once the transaction
+// making it is committed.
+type CacheEvent struct {
+	Type CacheEventType
+	// Name and ID are the name and the UID or GID of the user or group the event is about. For MemberAdded and
+	// MemberRemoved events, they are the ones of the group.
+	Name string
+	ID   uint32
+	// MemberName and MemberUID are the name and UID of the user who joined or left the group, for MemberAdded and
+	// MemberRemoved events.
+	MemberName string
+	MemberUID  uint32
+
+	// Missed is set on the first event delivered after some were dropped because the buffer of the subscriber was
+	// full. The subscriber should then read the whole cache again.
+	Missed bool
+}
+
+// subscriber is a receiver of the events of the cache.
+type subscriber struct {
+	ch chan CacheEvent
+	// missed is set when an event was dropped, until the next one is delivered.
+	missed bool
+}
+
+// Subscribe returns a channel on which the changes made to the users and groups by UpdateUserEntry,
+// UpdateUserEntryCAS, UpdateUserEntries, DeleteUser, DeleteUsers and CleanupStaleUsers are delivered in order, once
+// committed, and a function to unsubscribe, which closes the channel.
+// Writers never wait for subscribers: when the buffer of the channel is full, events are dropped, and the next event
+// delivered has Missed set.
+// The channel is closed when the cache is closed.
+func (c *Cache) Subscribe() (<-chan CacheEvent, func()) {
+	s := &subscriber{ch: make(chan CacheEvent, eventsBufferSize)}
+
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+	if c.subscribers == nil {
+		c.subscribers = make(map[*subscriber]struct{})
+	}
+	c.subscribers[s] = struct{}{}
+
+	return s.ch, func() {
+		c.subscribersMu.Lock()
+		defer c.subscribersMu.Unlock()
+		c.unsubscribe(s)
+	}
+}
+
+// unsubscribe removes the subscriber and closes its channel, if it wasn't already. subscribersMu must be held.
+func (c *Cache) unsubscribe(s *subscriber) {
+	if _, ok := c.subscribers[s]; !ok {
+		return
+	}
+	delete(c.subscribers, s)
+	close(s.ch)
+}
+
+// unsubscribeAll removes all subscribers and closes their channel.
+func (c *Cache) unsubscribeAll() {
+	c.subscribersMu.Lock()
+	defer c.subscribersMu.Unlock()
+	for s := range c.subscribers {
+		c.unsubscribe(s)
+	}
+}
+
+// updateWithEvents runs fn in a read-write transaction like update, with a tracker of the changes it makes, and
+// delivers the events of the changes to the subscribers once the transaction is committed. The tracker is nil if there
+// is no subscriber.
+// The events of concurrent transactions are delivered in the order in which the transactions are committed.
+func (c *Cache) updateWithEvents(fn func(tx *bbolt.Tx, t *changeTracker) error) error {
+	var t *changeTracker
+	var events []CacheEvent
+	var locked bool
+	defer func() {
+		if locked {
+			c.subscribersMu.Unlock()
+		}
+	}()
+
+	err := c.update(func(tx *bbolt.Tx) error {
+		// Transactions are serialized, so taking the lock in the transaction orders the deliveries like the commits.
+		c.subscribersMu.Lock()
+		locked = true
+		if len(c.subscribers) > 0 {
+			t = newChangeTracker()
+		}
+
+		if err := fn(tx, t); err != nil {
+			return err
+		}
+
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+		events, err = t.events(buckets)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for s := range c.subscribers {
+		for _, e := range events {
+			e.Missed = s.missed
+			select {
+			case s.ch <- e:
+				s.missed = false
+			default:
+				s.missed = true
+			}
+		}
+	}
+	return nil
+}
+
+// membership is a user being a member of a group.
+type membership struct {
+	uid, gid uint32
+}
+
+// changeState is the state of the users and groups tracked by a changeTracker: the names of the ones which exist,
+// by ID, and whether the users are members of the groups.
+type changeState struct {
+	users   map[uint32]string
+	groups  map[uint32]string
+	members map[membership]bool
+}
+
+func newChangeState() changeState {
+	return changeState{
+		users:   make(map[uint32]string),
+		groups:  make(map[uint32]string),
+		members: make(map[membership]bool),
+	}
+}
+
+// changeTracker records the state of the users and groups which may be changed by a transaction, so that the events
+// of the changes can be computed once they are done. All its methods are no-ops on a nil tracker.
+type changeTracker struct {
+	// uids, gids and memberships are the tracked users, groups and memberships, in the order they were first tracked.
+	uids               []uint32
+	gids               []uint32
+	memberships        []membership
+	trackedUsers       map[uint32]struct{}
+	trackedGroups      map[uint32]struct{}
+	trackedMemberships map[membership]struct{}
+
+	before changeState
+}
+
+func newChangeTracker() *changeTracker {
+	return &changeTracker{
+		trackedUsers:       make(map[uint32]struct{}),
+		trackedGroups:      make(map[uint32]struct{}),
+		trackedMemberships: make(map[membership]struct{}),
+		before:             newChangeState(),
+	}
+}
+
+// trackUser records the state of the user matching uid, of their current groups, and of the groups of groupContents,
+// which the user may be added to, before they are changed.
+func (t *changeTracker) trackUser(buckets map[string]bucketWithName, uid uint32, groupContents []GroupDB) error {
+	if t == nil {
+		return nil
+	}
+
+	previous, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], uid)
+	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		return err
+	}
+	gids := previous.GIDs
+	for _, g := range groupContents {
+		gids = append(gids, g.GID)
+		// The group may be kept with another GID if its name is already used.
+		if existing, err := getFromBucket[groupDB](buckets[groupByNameBucketName], g.Name); err == nil {
+			gids = append(gids, existing.GID)
+		}
+	}
+
+	// The state before the transaction is the one when users and groups are first tracked.
+	var newUIDs, newGIDs []uint32
+	var newMemberships []membership
+	if _, ok := t.trackedUsers[uid]; !ok {
+		t.trackedUsers[uid] = struct{}{}
+		newUIDs = append(newUIDs, uid)
+	}
+	for _, gid := range gids {
+		if _, ok := t.trackedGroups[gid]; !ok {
+			t.trackedGroups[gid] = struct{}{}
+			newGIDs = append(newGIDs, gid)
+		}
+		m := membership{uid: uid, gid: gid}
+		if _, ok := t.trackedMemberships[m]; !ok {
+			t.trackedMemberships[m] = struct{}{}
+			newMemberships = append(newMemberships, m)
+		}
+	}
+	if err := readChangeState(buckets, t.before, newUIDs, newGIDs, newMemberships); err != nil {
+		return err
+	}
+
+	t.uids = append(t.uids, newUIDs...)
+	t.gids = append(t.gids, newGIDs...)
+	t.memberships = append(t.memberships, newMemberships...)
+	return nil
+}
+
+// events returns the events of the changes between the tracked state and the current one.
+func (t *changeTracker) events(buckets map[string]bucketWithName) ([]CacheEvent, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	after := newChangeState()
+	if err := readChangeState(buckets, after, t.uids, t.gids, t.memberships); err != nil {
+		return nil, err
+	}
+	before := t.before
+
+	var events []CacheEvent
+	for _, uid := range t.uids {
+		if _, existed := before.users[uid]; existed {
+			continue
+		}
+		if name, ok := after.users[uid]; ok {
+			events = append(events, CacheEvent{Type: UserAdded, Name: name, ID: uid})
+		}
+	}
+	for _, gid := range t.gids {
+		if _, existed := before.groups[gid]; existed {
+			continue
+		}
+		if name, ok := after.groups[gid]; ok {
+			events = append(events, CacheEvent{Type: GroupAdded, Name: name, ID: gid})
+		}
+	}
+	for _, m := range t.memberships {
+		if after.members[m] && !before.members[m] {
+			events = append(events, CacheEvent{Type: MemberAdded, Name: after.groups[m.gid], ID: m.gid,
+				MemberName: after.users[m.uid], MemberUID: m.uid})
+		}
+	}
+	for _, m := range t.memberships {
+		if before.members[m] && !after.members[m] {
+			events = append(events, CacheEvent{Type: MemberRemoved, Name: before.groups[m.gid], ID: m.gid,
+				MemberName: before.users[m.uid], MemberUID: m.uid})
+		}
+	}
+	for _, gid := range t.gids {
+		if _, exists := after.groups[gid]; exists {
+			continue
+		}
+		if name, ok := before.groups[gid]; ok {
+			events = append(events, CacheEvent{Type: GroupRemoved, Name: name, ID: gid})
+		}
+	}
+	for _, uid := range t.uids {
+		if _, exists := after.users[uid]; exists {
+			continue
+		}
+		if name, ok := before.users[uid]; ok {
+			events = append(events, CacheEvent{Type: UserRemoved, Name: name, ID: uid})
+		}
+	}
+	return events, nil
+}
+
+// readChangeState reads the state of the users, groups and memberships into s.
+func readChangeState(buckets map[string]bucketWithName, s changeState, uids, gids []uint32, memberships []membership) error {
+	for _, uid := range uids {
+		u, err := getFromBucket[userDB](buckets[userByIDBucketName], uid)
+		if errors.Is(err, NoDataFoundError{}) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.users[uid] = u.Name
+	}
+
+	for _, gid := range gids {
+		g, err := getFromBucket[groupDB](buckets[groupByIDBucketName], gid)
+		if errors.Is(err, NoDataFoundError{}) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		s.groups[gid] = g.Name
+	}
+
+	for _, m := range memberships {
+		isMember, err := isGroupMember(buckets, m.gid, m.uid)
+		if err != nil {
+			return err
+		}
+		s.members[m] = isMember
+	}
+	return nil
+}
+
+// isGroupMember returns true if uid is listed in the members of the group matching gid.
+func isGroupMember(buckets map[string]bucketWithName, gid, uid uint32) (bool, error) {
+	if members := largeGroupMembersBucket(buckets, gid); members != nil {
+		return members.Get([]byte(strconv.FormatUint(uint64(uid), 10))) != nil, nil
+	}
+
+	g, err := getFromBucket[groupToUsersDB](buckets[groupToUsersBucketName], gid)
+	if errors.Is(err, NoDataFoundError{}) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(g.UIDs, uid), nil
+}
@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// ExportNSSFiles writes all users and groups in the format of /etc/passwd, /etc/group and /etc/shadow to the given
+// writers, sorted by ID, with the same fields as the ones returned to NSS.
+// The shadow entries are only written if shadowW is not nil: callers must only pass it when the requester is allowed
+// to read shadow entries.
+// Colons and newlines in GECOS fields are replaced by spaces. An error is returned if any other field contains one
+// of them, as the entry can't be written without being malformed.
+func (c *Cache) ExportNSSFiles(passwdW, groupW, shadowW io.Writer) (err error) {
+	defer decorate.OnError(&err, "could not export NSS files")
+
+	var users []UserDB
+	var groups []GroupDB
+	c.mu.RLock()
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		err = buckets[userByIDBucketName].ForEach(func(key, value []byte) error {
+			var u userDB
+			if err := unmarshalValue(value, &u); err != nil {
+				return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
+			}
+			users = append(users, u.UserDB)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return buckets[groupByIDBucketName].ForEach(func(key, value []byte) error {
+			var g groupDB
+			if err := unmarshalValue(value, &g); err != nil {
+				return fmt.Errorf("can't unmarshal group in bucket %q for key %v: %v", groupByIDBucketName, key, err)
+			}
+			members, err := getUsersInGroup(buckets, g.GID)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, NewGroupDB(g.Name, g.GID, members))
+			return nil
+		})
+	})
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	slices.SortFunc(users, func(a, b UserDB) int { return cmp.Compare(a.UID, b.UID) })
+	slices.SortFunc(groups, func(a, b GroupDB) int { return cmp.Compare(a.GID, b.GID) })
+
+	var passwd, shadow strings.Builder
+	for _, u := range users {
+		line, err := nssFileLine(u.Name, "x", fmt.Sprint(u.UID), fmt.Sprint(u.GID), sanitizeGecos(u.Gecos), u.Dir, u.Shell)
+		if err != nil {
+			return fmt.Errorf("invalid passwd entry for user %q: %v", u.Name, err)
+		}
+		passwd.WriteString(line)
+
+		// Like in the shadow entries returned to NSS, the password of disabled users is locked.
+		passwd := "x"
+		if u.Disabled {
+			passwd = "!x"
+		}
+		line, err = nssFileLine(u.Name, passwd, shadowDays(u.LastPwdChange), shadowDays(u.MinPwdAge), shadowDays(u.MaxPwdAge),
+			shadowDays(u.PwdWarnPeriod), shadowDays(u.PwdInactivity), shadowDays(u.ExpirationDate), "")
+		if err != nil {
+			return fmt.Errorf("invalid shadow entry for user %q: %v", u.Name, err)
+		}
+		shadow.WriteString(line)
+	}
+
+	var group strings.Builder
+	for _, g := range groups {
+		line, err := nssFileLine(g.Name, "x", fmt.Sprint(g.GID), strings.Join(g.Users, ","))
+		if err != nil {
+			return fmt.Errorf("invalid group entry for group %q: %v", g.Name, err)
+		}
+		group.WriteString(line)
+	}
+
+	if _, err := io.WriteString(passwdW, passwd.String()); err != nil {
+		return fmt.Errorf("could not write passwd entries: %v", err)
+	}
+	if _, err := io.WriteString(groupW, group.String()); err != nil {
+		return fmt.Errorf("could not write group entries: %v", err)
+	}
+	if shadowW == nil {
+		return nil
+	}
+	if _, err := io.WriteString(shadowW, shadow.String()); err != nil {
+		return fmt.Errorf("could not write shadow entries: %v", err)
+	}
+	return nil
+}
+
+// nssFileLine returns the colon-delimited line of the fields, or an error if any of them contains a colon or a newline.
+func nssFileLine(fields ...string) (string, error) {
+	for _, f := range fields {
+		if strings.ContainsAny(f, ":\n") {
+			return "", fmt.Errorf("field %q contains a colon or a newline", f)
+		}
+	}
+	return strings.Join(fields, ":") + "\n", nil
+}
+
+// sanitizeGecos replaces the characters which can't be part of a GECOS field by spaces.
+func sanitizeGecos(gecos string) string {
+	return strings.NewReplacer(":", " ", "\n", " ").Replace(gecos)
+}
+
+// shadowDays returns the shadow field of the number of days, empty if it's not set.
+func shadowDays(days int) string {
+	if days < 0 {
+		return ""
+	}
+	return strconv.Itoa(days)
+}
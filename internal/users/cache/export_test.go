@@ -1,6 +1,50 @@
 package cache
 
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+// WithNow overrides the clock used by the cache for tests.
+func WithNow(now func() time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+// WithLargeGroupThreshold overrides the number of members from which a group stores them in its own bucket for tests.
+func WithLargeGroupThreshold(threshold int) Option {
+	return func(o *options) {
+		o.largeGroupThreshold = threshold
+	}
+}
+
 // DbPath exposes the path to the database file for testing.
 func (c *Cache) DbPath() string {
 	return c.db.Path()
 }
+
+// RunMigration runs fn as part of a database migration, so that tests can read from the cache while it is running.
+func (c *Cache) RunMigration(fn func()) error {
+	return c.migrate(func(*bbolt.Tx) error {
+		fn()
+		return nil
+	})
+}
+
+// AssertConsistent fails the test with a description of each inconsistency between the buckets of the database.
+// It is meant to be called at the end of tests, to catch updates leaving the database in an inconsistent state.
+func (c *Cache) AssertConsistent(t testing.TB) {
+	t.Helper()
+
+	problems, err := c.inconsistencies()
+	if err != nil {
+		t.Fatalf("Could not check database consistency: %v", err)
+	}
+	if len(problems) > 0 {
+		t.Errorf("Database is inconsistent:\n%s", strings.Join(problems, "\n"))
+	}
+}
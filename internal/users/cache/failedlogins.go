@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// defaultFailedLoginWindow is the time after the last failed login from which the failures are not counted anymore.
+const defaultFailedLoginWindow = 15 * time.Minute
+
+// failedLoginsDB is the struct stored in json format in the failed logins bucket, for the UID of each user who failed
+// to log in since their last successful login.
+type failedLoginsDB struct {
+	UID         uint32
+	Count       int
+	LastFailure time.Time
+}
+
+type failedLoginOptions struct {
+	window time.Duration
+}
+
+// FailedLoginOption represents an optional function to override RecordFailedLogin default values.
+type FailedLoginOption func(*failedLoginOptions)
+
+// WithFailedLoginWindow sets the time after the last failed login from which the previous failures are forgotten.
+// It defaults to 15 minutes. A negative window never forgets them.
+func WithFailedLoginWindow(d time.Duration) FailedLoginOption {
+	return func(o *failedLoginOptions) {
+		o.window = d
+	}
+}
+
+// RecordFailedLogin records a failed login of the user matching username, and returns the number of failed logins
+// since their last successful login, including this one.
+// The failures are counted from 1 again if the last one is older than the window.
+// It returns an error if the user is not in the database.
+func (c *Cache) RecordFailedLogin(username string, args ...FailedLoginOption) (count int, err error) {
+	defer decorate.OnError(&err, "could not record failed login of user %q", username)
+
+	opts := failedLoginOptions{
+		window: defaultFailedLoginWindow,
+	}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.now()
+	err = c.update(func(tx *bbolt.Tx) error {
+		users, err := c.getBucket(tx, userByNameBucketName)
+		if err != nil {
+			return err
+		}
+		u, err := getFromBucket[UserDB](users, username)
+		if err != nil {
+			return err
+		}
+
+		failedLogins, err := tx.CreateBucketIfNotExists([]byte(failedLoginsBucketName))
+		if err != nil {
+			return err
+		}
+		uidKey := []byte(strconv.FormatUint(uint64(u.UID), 10))
+
+		f := failedLoginsDB{UID: u.UID}
+		if value := failedLogins.Get(uidKey); value != nil {
+			// A record we can't parse is counted from scratch.
+			_ = json.Unmarshal(value, &f)
+		}
+		if opts.window >= 0 && now.Sub(f.LastFailure) > opts.window {
+			f.Count = 0
+		}
+		f.Count++
+		f.LastFailure = now
+
+		data, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		if err := failedLogins.Put(uidKey, data); err != nil {
+			panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+		}
+		count = f.Count
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// ResetFailedLogins forgets the failed logins of the user matching username. This is meant to be called on a
+// successful authentication. It returns an error if the user is not in the database.
+func (c *Cache) ResetFailedLogins(username string) (err error) {
+	defer decorate.OnError(&err, "could not reset failed logins of user %q", username)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
+		users, err := c.getBucket(tx, userByNameBucketName)
+		if err != nil {
+			return err
+		}
+		u, err := getFromBucket[UserDB](users, username)
+		if err != nil {
+			return err
+		}
+
+		clearFailedLogins(tx, u.UID)
+		return nil
+	})
+}
+
+// clearFailedLogins forgets the failed logins of the user matching uid.
+func clearFailedLogins(tx *bbolt.Tx, uid uint32) {
+	failedLogins := tx.Bucket([]byte(failedLoginsBucketName))
+	if failedLogins == nil {
+		return
+	}
+
+	// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
+	if err := failedLogins.Delete([]byte(strconv.FormatUint(uint64(uid), 10))); err != nil {
+		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+	}
+}
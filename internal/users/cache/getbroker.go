@@ -2,6 +2,9 @@ package cache
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
 
 	"go.etcd.io/bbolt"
 )
@@ -17,8 +20,8 @@ func (c *Cache) BrokerForUser(username string) (brokerID string, err error) {
 		return "", err
 	}
 
-	err = c.db.View(func(tx *bbolt.Tx) error {
-		bucket, err := getBucket(tx, userToBrokerBucketName)
+	err = c.view(func(tx *bbolt.Tx) error {
+		bucket, err := c.getBucket(tx, userToBrokerBucketName)
 		if err != nil {
 			return err
 		}
@@ -36,3 +39,78 @@ func (c *Cache) BrokerForUser(username string) (brokerID string, err error) {
 
 	return brokerID, nil
 }
+
+// BrokerUse is a broker which was assigned to a user.
+type BrokerUse struct {
+	BrokerID string
+}
+
+// AllBrokersForUser returns the brokers the given username was assigned to, oldest first, or an error if no user was
+// found in cache. The broker history is not recorded, so only the current assignment, if any, is returned.
+func (c *Cache) AllBrokersForUser(username string) ([]BrokerUse, error) {
+	brokerID, err := c.BrokerForUser(username)
+	if err != nil {
+		return nil, err
+	}
+	if brokerID == "" {
+		return nil, nil
+	}
+
+	return []BrokerUse{{BrokerID: brokerID}}, nil
+}
+
+// UsersByBroker returns the users assigned to the broker matching brokerID, by ascending UID, or an error if the
+// database is corrupted. Users without any broker are never returned, and no user is returned for an unknown broker.
+func (c *Cache) UsersByBroker(brokerID string) (users []UserDB, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// The slice is never nil, so that an unknown broker gets an empty list.
+	users = []UserDB{}
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		var uids []uint32
+		err = buckets[userToBrokerBucketName].ForEach(func(key, value []byte) error {
+			var id string
+			if err := unmarshalValue(value, &id); err != nil {
+				return fmt.Errorf("can't unmarshal broker in bucket %q for key %s: %v", userToBrokerBucketName, key, err)
+			}
+			if id != brokerID {
+				return nil
+			}
+			uid, err := strconv.ParseUint(string(key), 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid UID %q in bucket %q: %v", key, userToBrokerBucketName, err)
+			}
+			//nolint:gosec // ParseUint checked that the value fits in an uint32.
+			uids = append(uids, uint32(uid))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		slices.Sort(uids)
+		for _, uid := range uids {
+			u, err := getFromBucket[UserDB](buckets[userByIDBucketName], uid)
+			// The broker of a deleted user may be left behind.
+			if errors.Is(err, NoDataFoundError{}) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			users = append(users, u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
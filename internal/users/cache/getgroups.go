@@ -1,8 +1,9 @@
 package cache
 
 import (
-	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
 
 	"go.etcd.io/bbolt"
 )
@@ -21,61 +22,140 @@ func NewGroupDB(name string, gid uint32, members []string) GroupDB {
 	}
 }
 
-// GroupByID returns a group matching this gid or an error if the database is corrupted or no entry was found.
+// GroupByID returns a group matching this gid or an error if the database is corrupted.
+// It returns ErrNoEntry if no entry was found.
 func (c *Cache) GroupByID(gid uint32) (GroupDB, error) {
-	return getGroup(c, groupByIDBucketName, gid)
+	return getGroup(c, groupByIDBucketName, gid, true)
 }
 
-// GroupByName returns a group matching a given name or an error if the database is corrupted or no entry was found.
+// GroupByName returns a group matching a given name or an error if the database is corrupted.
+// It returns ErrNoEntry if no entry was found.
 func (c *Cache) GroupByName(name string) (GroupDB, error) {
-	return getGroup(c, groupByNameBucketName, name)
+	return getGroup(c, groupByNameBucketName, name, true)
+}
+
+// GroupMetaByID returns the name and GID of the group matching this gid, without its members, or an error if the
+// database is corrupted. Unlike GroupByID, it doesn't resolve the names of the members, for callers only needing the
+// group itself.
+// It returns ErrNoEntry if no entry was found.
+func (c *Cache) GroupMetaByID(gid uint32) (GroupDB, error) {
+	return getGroup(c, groupByIDBucketName, gid, false)
+}
+
+// GroupMetaByName returns the name and GID of the group matching a given name, without its members, or an error if the
+// database is corrupted. Unlike GroupByName, it doesn't resolve the names of the members, for callers only needing the
+// group itself.
+// It returns ErrNoEntry if no entry was found.
+func (c *Cache) GroupMetaByName(name string) (GroupDB, error) {
+	return getGroup(c, groupByNameBucketName, name, false)
 }
 
 // AllGroups returns all groups or an error if the database is corrupted.
+//
+// Only the GroupByID bucket is iterated, so that each GID is returned once even if the GroupByName bucket drifted.
 func (c *Cache) AllGroups() (all []GroupDB, err error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	err = c.db.View(func(tx *bbolt.Tx) error {
-		buckets, err := getAllBuckets(tx)
+	err = c.view(func(tx *bbolt.Tx) error {
+		all, err = c.allGroups(tx)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// allGroups returns all groups of the GroupByID bucket in the transaction, with their members.
+func (c *Cache) allGroups(tx *bbolt.Tx) (all []GroupDB, err error) {
+	buckets, err := c.getAllBuckets(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = buckets[groupByIDBucketName].ForEach(func(key, value []byte) error {
+		var g groupDB
+		if err := unmarshalValue(value, &g); err != nil {
+			return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
+		}
+
+		// Get user names in the group.
+		users, err := getUsersInGroup(buckets, g.GID)
 		if err != nil {
 			return err
 		}
 
-		return buckets[groupByIDBucketName].ForEach(func(key, value []byte) error {
-			var g groupDB
-			if err := json.Unmarshal(value, &g); err != nil {
-				return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
-			}
+		all = append(all, NewGroupDB(g.Name, g.GID, users))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// GroupsForUser returns the groups the user matching uid belongs to, including their primary group, read from the
+// UserToGroups bucket, in ascending GID order. Groups without any record are skipped.
+// It returns an error if the database is corrupted, or ErrNoEntry if the user was not found.
+func (c *Cache) GroupsForUser(uid uint32) (groups []GroupDB, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := getFromBucket[userDB](buckets[userByIDBucketName], uid); err != nil {
+			return noEntryError(err)
+		}
+		userToGroups, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], uid)
+		if err != nil && !errors.Is(err, NoDataFoundError{}) {
+			return err
+		}
 
-			// Get user names in the group.
-			users, err := getUsersInGroup(buckets, g.GID)
+		// The GIDs are in the order the user was added to the groups, which differs between hosts.
+		slices.Sort(userToGroups.GIDs)
+		for _, gid := range userToGroups.GIDs {
+			g, err := getFromBucket[groupDB](buckets[groupByIDBucketName], gid)
+			if errors.Is(err, NoDataFoundError{}) {
+				continue
+			}
 			if err != nil {
 				return err
 			}
 
-			all = append(all, NewGroupDB(g.Name, g.GID, users))
-			return nil
-		})
+			users, err := getUsersInGroup(buckets, gid)
+			if err != nil {
+				return err
+			}
+			groups = append(groups, NewGroupDB(g.Name, g.GID, users))
+		}
+		return nil
 	})
 
 	if err != nil {
 		return nil, err
 	}
 
-	return all, nil
+	return groups, nil
 }
 
-// getGroup returns a group matching the key and its members or an error if the database is corrupted
-// or no entry was found.
-func getGroup[K uint32 | string](c *Cache, bucketName string, key K) (GroupDB, error) {
+// getGroup returns a group matching the key, with its members if withMembers is true, or an error if the database is
+// corrupted.
+// It returns ErrNoEntry if no entry was found.
+func getGroup[K uint32 | string](c *Cache, bucketName string, key K, withMembers bool) (GroupDB, error) {
 	var groupName string
 	var gid uint32
 	var users []string
 
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	err := c.db.View(func(tx *bbolt.Tx) error {
-		buckets, err := getAllBuckets(tx)
+	err := c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
 		if err != nil {
 			return err
 		}
@@ -83,11 +163,14 @@ func getGroup[K uint32 | string](c *Cache, bucketName string, key K) (GroupDB, e
 		// Get id and name of the group.
 		g, err := getFromBucket[groupDB](buckets[bucketName], key)
 		if err != nil {
-			return err
+			return noEntryError(err)
 		}
 
 		groupName = g.Name
 		gid = g.GID
+		if !withMembers {
+			return nil
+		}
 
 		// Get user names in the group.
 		users, err = getUsersInGroup(buckets, gid)
@@ -105,12 +188,96 @@ func getGroup[K uint32 | string](c *Cache, bucketName string, key K) (GroupDB, e
 	return NewGroupDB(groupName, gid, users), nil
 }
 
-// usersInGroup returns all user names in a given group. It returns an error if the database is corrupted.
+// GroupMembersByName calls fn with the names of the members of the group matching name, in ascending UID order, in
+// batches of at most batchSize names.
+//
+// The member UIDs are read first, then each batch of names is resolved in its own read transaction, so that no
+// transaction is held open while fn runs. Members deleted in the meantime are skipped. It returns an error if the
+// database is corrupted or fn returned an error, and ErrNoEntry if no group matches name.
+func (c *Cache) GroupMembersByName(name string, batchSize int, fn func(members []string) error) error {
+	if batchSize < 1 {
+		return fmt.Errorf("invalid batch size: %d", batchSize)
+	}
+
+	var uids []uint32
+	c.mu.RLock()
+	err := c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		g, err := getFromBucket[groupDB](buckets[groupByNameBucketName], name)
+		if err != nil {
+			return noEntryError(err)
+		}
+
+		usersInGroup, err := groupMembers(buckets, g.GID)
+		if err != nil {
+			return err
+		}
+		uids = usersInGroup.UIDs
+		slices.Sort(uids)
+
+		return nil
+	})
+	c.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	for batch := range slices.Chunk(uids, batchSize) {
+		members, err := c.userNames(batch)
+		if err != nil {
+			return err
+		}
+		if err := fn(members); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// userNames returns the names of the users matching the given uids, skipping the ones which are not in the database
+// anymore. It returns an error if the database is corrupted.
+func (c *Cache) userNames(uids []uint32) (names []string, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	err = c.view(func(tx *bbolt.Tx) error {
+		bucket, err := c.getBucket(tx, userByIDBucketName)
+		if err != nil {
+			return err
+		}
+
+		for _, uid := range uids {
+			u, err := getFromBucket[UserDB](bucket, uid)
+			if errors.Is(err, NoDataFoundError{}) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			names = append(names, u.Name)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// getUsersInGroup returns all user names in a given group, in ascending UID order, so that the members are listed in the
+// same order whatever the order they were added in. It returns an error if the database is corrupted.
 func getUsersInGroup(buckets map[string]bucketWithName, gid uint32) (users []string, err error) {
-	usersInGroup, err := getFromBucket[groupToUsersDB](buckets[groupToUsersBucketName], gid)
+	usersInGroup, err := groupMembers(buckets, gid)
 	if err != nil {
 		return nil, err
 	}
+	slices.Sort(usersInGroup.UIDs)
 
 	for _, uid := range usersInGroup.UIDs {
 		// we should always get an entry
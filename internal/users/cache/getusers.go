@@ -1,19 +1,24 @@
 package cache
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"time"
 
 	"go.etcd.io/bbolt"
 )
 
+const secondsPerDay = 24 * 60 * 60
+
 // userDB is the struct stored in json format in the bucket.
 //
-// It prevents leaking of lastLogin, which is only relevant to the cache.
+// It prevents leaking of lastLogin and modifiedAt, which are only relevant to the cache.
 type userDB struct {
 	UserDB
-	LastLogin time.Time
+	LastLogin  time.Time
+	ModifiedAt time.Time
+	// Version is incremented on every write of the record. Records written before it was introduced have version 0.
+	Version uint64
 }
 
 // NewUserDB creates a new UserDB.
@@ -34,34 +39,98 @@ func NewUserDB(name string, uid, gid uint32, gecos, dir, shell string) UserDB {
 	}
 }
 
-// UserByID returns a user matching this uid or an error if the database is corrupted or no entry was found.
+// IsExpired returns whether the account of the user is expired at now: either its expiration date is reached, or
+// its password is older than the maximum password age. Negative or zero expiration dates never expire.
+func (u UserDB) IsExpired(now time.Time) bool {
+	today := int(now.Unix() / secondsPerDay)
+	return (u.ExpirationDate > 0 && today >= u.ExpirationDate) || passwordExpired(u, today)
+}
+
+// UserByID returns a user matching this uid or an error if the database is corrupted.
+// It returns ErrNoEntry if no entry was found.
 func (c *Cache) UserByID(uid uint32) (UserDB, error) {
 	u, err := getUser(c, userByIDBucketName, uid)
 	return u.UserDB, err
 }
 
-// UserByName returns a user matching this name or an error if the database is corrupted or no entry was found.
+// UserByIDWithVersion returns a user matching this uid with the version of its record, to be passed to
+// UpdateUserEntryCAS. It returns an error if the database is corrupted, or ErrNoEntry if no entry was found.
+func (c *Cache) UserByIDWithVersion(uid uint32) (UserDB, uint64, error) {
+	u, err := getUser(c, userByIDBucketName, uid)
+	return u.UserDB, u.Version, err
+}
+
+// UserByName returns a user matching this name or an error if the database is corrupted.
+// It returns ErrNoEntry if no entry was found.
 func (c *Cache) UserByName(name string) (UserDB, error) {
 	u, err := getUser(c, userByNameBucketName, name)
 	return u.UserDB, err
 }
 
 // AllUsers returns all users or an error if the database is corrupted.
+//
+// Only the UserByID bucket is iterated, so that each UID is returned once even if the UserByName bucket drifted.
 func (c *Cache) AllUsers() (all []UserDB, err error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	err = c.db.View(func(tx *bbolt.Tx) error {
-		bucket, err := getBucket(tx, userByIDBucketName)
+	err = c.view(func(tx *bbolt.Tx) error {
+		all, err = c.allUsers(tx)
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// allUsers returns all users of the UserByID bucket in the transaction.
+func (c *Cache) allUsers(tx *bbolt.Tx) (all []UserDB, err error) {
+	bucket, err := c.getBucket(tx, userByIDBucketName)
+	if err != nil {
+		return nil, err
+	}
+
+	err = bucket.ForEach(func(key, value []byte) error {
+		var e userDB
+		if err := unmarshalValue(value, &e); err != nil {
+			return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
+		}
+		all = append(all, e.UserDB)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return all, nil
+}
+
+// UsersModifiedSince returns all users whose record was modified after t or an error if the database is corrupted
+// or ctx is cancelled.
+func (c *Cache) UsersModifiedSince(ctx context.Context, t time.Time) (users []UserDB, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	err = c.view(func(tx *bbolt.Tx) error {
+		bucket, err := c.getBucket(tx, userByIDBucketName)
 		if err != nil {
 			return err
 		}
 
 		return bucket.ForEach(func(key, value []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
 			var e userDB
-			if err := json.Unmarshal(value, &e); err != nil {
+			if err := unmarshalValue(value, &e); err != nil {
 				return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
 			}
-			all = append(all, e.UserDB)
+			if !e.ModifiedAt.After(t) {
+				return nil
+			}
+			users = append(users, e.UserDB)
 			return nil
 		})
 	})
@@ -70,22 +139,88 @@ func (c *Cache) AllUsers() (all []UserDB, err error) {
 		return nil, err
 	}
 
-	return all, nil
+	return users, nil
 }
 
-// getUser returns an user matching the key or an error if the database is corrupted or no entry was found.
+// LastLogin returns the time of the last login of the user matching this name, which is zero if they never logged in,
+// or an error if the database is corrupted.
+// It returns ErrNoEntry if no entry was found.
+func (c *Cache) LastLogin(username string) (time.Time, error) {
+	u, err := getUser(c, userByNameBucketName, username)
+	return u.LastLogin, err
+}
+
+// AllLastLogins returns the time of the last login of all users, by name, or an error if the database is corrupted.
+// The time is zero for users who never logged in.
+func (c *Cache) AllLastLogins() (lastLogins map[string]time.Time, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	err = c.view(func(tx *bbolt.Tx) error {
+		bucket, err := c.getBucket(tx, userByIDBucketName)
+		if err != nil {
+			return err
+		}
+
+		lastLogins = make(map[string]time.Time)
+		return bucket.ForEach(func(key, value []byte) error {
+			var e userDB
+			if err := unmarshalValue(value, &e); err != nil {
+				return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
+			}
+			lastLogins[e.Name] = e.LastLogin
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return lastLogins, nil
+}
+
+// ExpiredPasswordUsers returns all users whose password is expired or an error if the database is corrupted.
+//
+// As with shadow, a password is expired from the day LastPwdChange + MaxPwdAge on, days being counted since the epoch
+// in UTC. Users without a last change date or with a MaxPwdAge of 0 or -1 never expire.
+func (c *Cache) ExpiredPasswordUsers() ([]UserDB, error) {
+	users, err := c.AllUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	today := int(c.now().Unix() / secondsPerDay)
+
+	var expired []UserDB
+	for _, u := range users {
+		if !passwordExpired(u, today) {
+			continue
+		}
+		expired = append(expired, u)
+	}
+
+	return expired, nil
+}
+
+// passwordExpired returns whether the password of the user is expired on today, in days since the epoch.
+func passwordExpired(u UserDB, today int) bool {
+	return u.LastPwdChange >= 0 && u.MaxPwdAge > 0 && today >= u.LastPwdChange+u.MaxPwdAge
+}
+
+// getUser returns an user matching the key or an error if the database is corrupted.
+// It returns ErrNoEntry if no entry was found.
 func getUser[K uint32 | string](c *Cache, bucketName string, key K) (u userDB, err error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
-	err = c.db.View(func(tx *bbolt.Tx) error {
-		bucket, err := getBucket(tx, bucketName)
+	err = c.view(func(tx *bbolt.Tx) error {
+		bucket, err := c.getBucket(tx, bucketName)
 		if err != nil {
 			return err
 		}
 
 		u, err = getFromBucket[userDB](bucket, key)
 		if err != nil {
-			return err
+			return noEntryError(err)
 		}
 
 		return nil
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"go.etcd.io/bbolt"
+)
+
+// defaultLargeGroupThreshold is the number of members from which a group stores them in its own bucket. Below it,
+// the members are stored in the GroupToUsers record of the group, which is rewritten whenever one is added.
+const defaultLargeGroupThreshold = 1000
+
+// groupMembers returns the GroupToUsers record of the group matching gid with all its members. The members of large
+// groups are read from their bucket, in ascending order.
+// It returns a NoDataFoundError if the group has no GroupToUsers record.
+func groupMembers(buckets map[string]bucketWithName, gid uint32) (groupToUsersDB, error) {
+	g, err := getFromBucket[groupToUsersDB](buckets[groupToUsersBucketName], gid)
+	if err != nil {
+		return groupToUsersDB{}, err
+	}
+
+	members := largeGroupMembersBucket(buckets, gid)
+	if members == nil {
+		return g, nil
+	}
+	if g.UIDs, err = uidsOfLargeGroup(members); err != nil {
+		return groupToUsersDB{}, fmt.Errorf("can't read members of group %d in bucket %q: %v", gid, groupMembersBucketName, err)
+	}
+	return g, nil
+}
+
+// addGroupMember adds uid to the members of the group matching gid, creating its GroupToUsers record if needed.
+// Once the group has more than threshold members, they are moved to the bucket of the group, where adding one
+// doesn't depend on the number of the other ones.
+func addGroupMember(buckets map[string]bucketWithName, gid, uid uint32, threshold int) error {
+	if members := largeGroupMembersBucket(buckets, gid); members != nil {
+		if err := members.Put([]byte(strconv.FormatUint(uint64(uid), 10)), []byte{}); err != nil {
+			panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+		}
+		return nil
+	}
+
+	g, err := getFromBucket[groupToUsersDB](buckets[groupToUsersBucketName], gid)
+	// No data is valid and means that this is the first time we record it.
+	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		return err
+	}
+
+	if !slices.Contains(g.UIDs, uid) {
+		g.UIDs = append(g.UIDs, uid)
+	}
+	return setGroupMembers(buckets, gid, g.UIDs, len(g.UIDs) > threshold)
+}
+
+// removeGroupMember removes uid from the members of the group matching gid.
+// It returns true if the group has no member left, which is also the case if it has no GroupToUsers record.
+func removeGroupMember(buckets map[string]bucketWithName, gid, uid uint32) (empty bool, err error) {
+	if members := largeGroupMembersBucket(buckets, gid); members != nil {
+		// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
+		if err := members.Delete([]byte(strconv.FormatUint(uint64(uid), 10))); err != nil {
+			panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+		}
+		k, _ := members.Cursor().First()
+		return k == nil, nil
+	}
+
+	g, err := getFromBucket[groupToUsersDB](buckets[groupToUsersBucketName], gid)
+	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		return false, err
+	}
+
+	g.UIDs = slices.DeleteFunc(g.UIDs, func(id uint32) bool { return id == uid })
+	if len(g.UIDs) == 0 {
+		return true, nil
+	}
+	// Update the group entry with the new list of UIDs
+	updateBucket(buckets[groupToUsersBucketName], gid, g)
+	return false, nil
+}
+
+// isLargeGroupMember returns true if the group matching gid is a large group which uid is a member of.
+func isLargeGroupMember(buckets map[string]bucketWithName, gid, uid uint32) bool {
+	members := largeGroupMembersBucket(buckets, gid)
+	return members != nil && members.Get([]byte(strconv.FormatUint(uint64(uid), 10))) != nil
+}
+
+// setGroupMembers replaces the members of the group matching gid by uids. The members of large groups are stored in
+// the bucket of the group.
+func setGroupMembers(buckets map[string]bucketWithName, gid uint32, uids []uint32, large bool) error {
+	if !large {
+		updateBucket(buckets[groupToUsersBucketName], gid, groupToUsersDB{GID: gid, UIDs: uids})
+		return nil
+	}
+
+	groupMembers, err := buckets[groupToUsersBucketName].Tx().CreateBucketIfNotExists([]byte(groupMembersBucketName))
+	if err != nil {
+		return err
+	}
+	members, err := groupMembers.CreateBucketIfNotExists([]byte(strconv.FormatUint(uint64(gid), 10)))
+	if err != nil {
+		return err
+	}
+	for _, uid := range uids {
+		if err := members.Put([]byte(strconv.FormatUint(uint64(uid), 10)), []byte{}); err != nil {
+			panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+		}
+	}
+
+	// The record of the group is kept, without its members, so that all groups have one.
+	updateBucket(buckets[groupToUsersBucketName], gid, groupToUsersDB{GID: gid})
+	return nil
+}
+
+// deleteGroupMembers deletes the GroupToUsers record of the group matching gid and, for large groups, their bucket.
+func deleteGroupMembers(buckets map[string]bucketWithName, gid uint32) {
+	gidKey := []byte(strconv.FormatUint(uint64(gid), 10))
+	// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
+	if err := buckets[groupToUsersBucketName].Delete(gidKey); err != nil {
+		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+	}
+
+	if largeGroupMembersBucket(buckets, gid) == nil {
+		return
+	}
+	groupMembers := buckets[groupToUsersBucketName].Tx().Bucket([]byte(groupMembersBucketName))
+	if err := groupMembers.DeleteBucket(gidKey); err != nil {
+		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+	}
+}
+
+// largeGroupMembersBucket returns the bucket of the members of the group matching gid, or nil if it's not a large
+// group.
+func largeGroupMembersBucket(buckets map[string]bucketWithName, gid uint32) *bbolt.Bucket {
+	groupMembers := buckets[groupToUsersBucketName].Tx().Bucket([]byte(groupMembersBucketName))
+	if groupMembers == nil {
+		return nil
+	}
+	return groupMembers.Bucket([]byte(strconv.FormatUint(uint64(gid), 10)))
+}
+
+// uidsOfLargeGroup returns the UIDs, in ascending order, of the members stored in the bucket of a large group.
+func uidsOfLargeGroup(members *bbolt.Bucket) (uids []uint32, err error) {
+	err = members.ForEach(func(key, _ []byte) error {
+		uid, err := strconv.ParseUint(string(key), 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid UID %q: %v", key, err)
+		}
+		//nolint:gosec // ParseUint checked that the value fits in an uint32.
+		uids = append(uids, uint32(uid))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Keys are sorted as strings.
+	slices.Sort(uids)
+	return uids, nil
+}
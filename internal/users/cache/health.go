@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// defaultIdleThreshold is the time after their last login from which users are reported as idle.
+const defaultIdleThreshold = 90 * 24 * time.Hour
+
+// HealthReport summarizes the health of the cache.
+type HealthReport struct {
+	// Users and Groups are the number of users and groups in the cache.
+	Users  int
+	Groups int
+
+	// NeverLoggedInUsers is the number of users who never logged in.
+	NeverLoggedInUsers int
+	// IdleUsers is the number of users whose last login is older than the idle threshold.
+	IdleUsers int
+
+	// DuplicateHomedirs are the UIDs of the users, in ascending order, by homedir shared by more than one user.
+	DuplicateHomedirs map[string][]uint32
+	// Inconsistencies are the descriptions of the inconsistencies between the buckets of the database.
+	Inconsistencies []string
+	// ExpiredPasswords is the number of users whose password is expired.
+	ExpiredPasswords int
+	// Tombstones is the number of UIDs of deleted users which are currently reserved.
+	Tombstones int
+
+	// FragmentationRatio is the ratio of the database file which is allocated to free pages.
+	FragmentationRatio float64
+}
+
+type healthReportOptions struct {
+	idleThreshold time.Duration
+}
+
+// HealthReportOption represents an optional function to override HealthReport default values.
+type HealthReportOption func(*healthReportOptions)
+
+// WithIdleThreshold sets the time after their last login from which users are reported as idle. It defaults to 90 days.
+func WithIdleThreshold(d time.Duration) HealthReportOption {
+	return func(o *healthReportOptions) {
+		o.idleThreshold = d
+	}
+}
+
+// HealthReport gathers the health metrics of the cache in a single read transaction.
+// As it scans the whole database, it returns an error if ctx is cancelled before it's done.
+func (c *Cache) HealthReport(ctx context.Context, args ...HealthReportOption) (r HealthReport, err error) {
+	defer decorate.OnError(&err, "could not compute cache health report")
+
+	opts := healthReportOptions{
+		idleThreshold: defaultIdleThreshold,
+	}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	now := c.now()
+	today := int(now.Unix() / secondsPerDay)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		// The collections are never nil, so that the report lists them as empty rather than null once serialized.
+		r.DuplicateHomedirs = make(map[string][]uint32)
+		r.Inconsistencies = []string{}
+
+		homedirs := make(map[string][]uint32)
+		err = buckets[userByIDBucketName].ForEach(func(key, value []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			var u userDB
+			if err := unmarshalValue(value, &u); err != nil {
+				return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
+			}
+
+			r.Users++
+			switch {
+			case u.LastLogin.IsZero():
+				r.NeverLoggedInUsers++
+			case now.Sub(u.LastLogin) > opts.idleThreshold:
+				r.IdleUsers++
+			}
+			if passwordExpired(u.UserDB, today) {
+				r.ExpiredPasswords++
+			}
+			homedirs[u.Dir] = append(homedirs[u.Dir], u.UID)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for dir, uids := range homedirs {
+			if len(uids) < 2 {
+				continue
+			}
+			slices.Sort(uids)
+			r.DuplicateHomedirs[dir] = uids
+		}
+
+		err = buckets[groupByIDBucketName].ForEach(func(_, _ []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			r.Groups++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r.Inconsistencies = append(r.Inconsistencies, bucketsInconsistencies(buckets)...)
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.uidReuseDelay != 0 {
+			tombstones, err := c.activeTombstones(tx)
+			if err != nil {
+				return err
+			}
+			r.Tombstones = len(tombstones)
+		}
+
+		if size := tx.Size(); size > 0 {
+			r.FragmentationRatio = float64(c.db.Stats().FreeAlloc) / float64(size)
+		}
+		return nil
+	})
+	if err != nil {
+		return HealthReport{}, err
+	}
+
+	return r, nil
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/ubuntu/decorate"
+)
+
+// ImportConflict is a collision between entries of a set of user updates, which can't all be imported.
+type ImportConflict struct {
+	// Reason describes the collision.
+	Reason string
+	// Names and IDs are the names and UIDs or GIDs of the colliding users or groups, in order of appearance.
+	Names []string
+	IDs   []uint32
+}
+
+func (c ImportConflict) String() string {
+	var entries []string
+	for i, name := range c.Names {
+		entries = append(entries, fmt.Sprintf("%q (%d)", name, c.IDs[i]))
+	}
+	return fmt.Sprintf("%s: %s", c.Reason, strings.Join(entries, ", "))
+}
+
+// namedID is a user or group name with its ID.
+type namedID struct {
+	name string
+	id   uint32
+}
+
+// ValidateImportSet returns the collisions between the entries, so that a set of user updates can be rejected before
+// being imported: UIDs or user names used more than once, and GIDs or group names used by different groups.
+// An error is returned if an entry is invalid on its own.
+func ValidateImportSet(entries []UserEntryUpdate) (conflicts []ImportConflict, err error) {
+	defer decorate.OnError(&err, "invalid import set")
+
+	var users, groups []namedID
+	for _, e := range entries {
+		if e.User.Name == "" {
+			return nil, fmt.Errorf("no name for user %d", e.User.UID)
+		}
+		if len(e.Groups) == 0 {
+			return nil, fmt.Errorf("no group for user %q", e.User.Name)
+		}
+		users = append(users, namedID{name: e.User.Name, id: e.User.UID})
+
+		for _, g := range e.Groups {
+			if g.Name == "" {
+				return nil, fmt.Errorf("no name for group %d of user %q", g.GID, e.User.Name)
+			}
+			// The same group is expected to be listed by all its members.
+			if n := (namedID{name: g.Name, id: g.GID}); !slices.Contains(groups, n) {
+				groups = append(groups, n)
+			}
+		}
+	}
+
+	conflicts = append(conflicts, collisions("UID used by several users", users, func(n namedID) any { return n.id })...)
+	conflicts = append(conflicts, collisions("name used by several users", users, func(n namedID) any { return n.name })...)
+	conflicts = append(conflicts, collisions("GID used by several groups", groups, func(n namedID) any { return n.id })...)
+	conflicts = append(conflicts, collisions("name used by several groups", groups, func(n namedID) any { return n.name })...)
+	return conflicts, nil
+}
+
+// collisions returns a conflict with the reason for each key shared by several of the records.
+func collisions(reason string, records []namedID, key func(namedID) any) (conflicts []ImportConflict) {
+	var keys []any
+	byKey := make(map[any][]namedID)
+	for _, r := range records {
+		k := key(r)
+		if _, ok := byKey[k]; !ok {
+			keys = append(keys, k)
+		}
+		byKey[k] = append(byKey[k], r)
+	}
+
+	for _, k := range keys {
+		if len(byKey[k]) < 2 {
+			continue
+		}
+		c := ImportConflict{Reason: reason}
+		for _, r := range byKey[k] {
+			c.Names = append(c.Names, r.name)
+			c.IDs = append(c.IDs, r.id)
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts
+}
+
+// importConflictsError returns an error listing the conflicts, or nil if there are none.
+func importConflictsError(conflicts []ImportConflict) error {
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	var errs []error
+	for _, c := range conflicts {
+		errs = append(errs, errors.New(c.String()))
+	}
+	return fmt.Errorf("conflicting entries: %w", errors.Join(errs...))
+}
@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// IntegrityReport lists the discrepancies between the user and group buckets found by CheckIntegrity, or the ones
+// fixed by RepairIntegrity.
+type IntegrityReport struct {
+	// OrphanedUserNames are the keys, in ascending order, of the UserByName records without a matching UserByID record.
+	OrphanedUserNames []string
+	// OrphanedGroupNames are the keys, in ascending order, of the GroupByName records without a matching GroupByID
+	// record.
+	OrphanedGroupNames []string
+	// MissingGroupMembers maps the GIDs of the groups whose GroupToUsers record references missing users to their
+	// UIDs, in ascending order.
+	MissingGroupMembers map[uint32][]uint32
+	// MissingUserGroups maps the UIDs of the users whose UserToGroups record references missing groups to their GIDs,
+	// in ascending order.
+	MissingUserGroups map[uint32][]uint32
+}
+
+// IsEmpty returns true if the report doesn't list any discrepancy.
+func (r IntegrityReport) IsEmpty() bool {
+	return len(r.OrphanedUserNames) == 0 && len(r.OrphanedGroupNames) == 0 &&
+		len(r.MissingGroupMembers) == 0 && len(r.MissingUserGroups) == 0
+}
+
+// CheckIntegrity cross-validates the user and group buckets with their pivot tables, and reports the name records
+// without a matching ID record and the references to missing users or groups.
+// It returns an error if the database is corrupted.
+func (c *Cache) CheckIntegrity() (r *IntegrityReport, err error) {
+	defer decorate.OnError(&err, "could not check cache integrity")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		r, err = integrityReport(buckets)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// RepairIntegrity fixes, in a single transaction, the discrepancies reported by CheckIntegrity: orphaned name records
+// are deleted, and references to missing users or groups are dropped from the pivot tables.
+// It returns the report of the discrepancies which were fixed.
+func (c *Cache) RepairIntegrity() (r *IntegrityReport, err error) {
+	defer decorate.OnError(&err, "could not repair cache integrity")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.update(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		if r, err = integrityReport(buckets); err != nil {
+			return err
+		}
+
+		// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
+		for _, name := range r.OrphanedUserNames {
+			if err := buckets[userByNameBucketName].Delete([]byte(name)); err != nil {
+				panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+			}
+		}
+		for _, name := range r.OrphanedGroupNames {
+			if err := buckets[groupByNameBucketName].Delete([]byte(name)); err != nil {
+				panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+			}
+		}
+
+		for gid, uids := range r.MissingGroupMembers {
+			if err := dropGroupMembers(buckets, gid, uids); err != nil {
+				return err
+			}
+		}
+		for uid, gids := range r.MissingUserGroups {
+			u, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], uid)
+			if err != nil {
+				return err
+			}
+			u.GIDs = slices.DeleteFunc(u.GIDs, func(gid uint32) bool { return slices.Contains(gids, gid) })
+			updateBucket(buckets[userToGroupsBucketName], uid, u)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// integrityReport returns the discrepancies between the user and group buckets which RepairIntegrity can fix, as
+// found by checkBuckets.
+// It returns an error if a record can't be parsed.
+func integrityReport(buckets map[string]bucketWithName) (r *IntegrityReport, err error) {
+	// The collections are never nil, so that the report lists them as empty rather than null once serialized.
+	r = &IntegrityReport{
+		OrphanedUserNames:   []string{},
+		OrphanedGroupNames:  []string{},
+		MissingGroupMembers: make(map[uint32][]uint32),
+		MissingUserGroups:   make(map[uint32][]uint32),
+	}
+
+	checkBuckets(buckets, func(i inconsistency) {
+		switch i.kind {
+		case unparsableRecord:
+			if err == nil {
+				err = errors.New(i.description)
+			}
+		case orphanedName:
+			if i.bucket == userByNameBucketName {
+				r.OrphanedUserNames = append(r.OrphanedUserNames, i.key)
+				return
+			}
+			r.OrphanedGroupNames = append(r.OrphanedGroupNames, i.key)
+		case missingGroupMember:
+			r.MissingGroupMembers[i.id] = append(r.MissingGroupMembers[i.id], i.ref)
+		case missingUserGroup:
+			r.MissingUserGroups[i.id] = append(r.MissingUserGroups[i.id], i.ref)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(r.OrphanedUserNames)
+	slices.Sort(r.OrphanedGroupNames)
+	for ids := range maps.Values(r.MissingGroupMembers) {
+		slices.Sort(ids)
+	}
+	for ids := range maps.Values(r.MissingUserGroups) {
+		slices.Sort(ids)
+	}
+	return r, nil
+}
+
+// dropGroupMembers removes uids from the members of the group matching gid, keeping its GroupToUsers record even if
+// it has no member left.
+func dropGroupMembers(buckets map[string]bucketWithName, gid uint32, uids []uint32) error {
+	if members := largeGroupMembersBucket(buckets, gid); members != nil {
+		for _, uid := range uids {
+			// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
+			if err := members.Delete([]byte(strconv.FormatUint(uint64(uid), 10))); err != nil {
+				panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+			}
+		}
+		return nil
+	}
+
+	g, err := getFromBucket[groupToUsersDB](buckets[groupToUsersBucketName], gid)
+	if err != nil {
+		return err
+	}
+	g.UIDs = slices.DeleteFunc(g.UIDs, func(uid uint32) bool { return slices.Contains(uids, uid) })
+	updateBucket(buckets[groupToUsersBucketName], gid, g)
+	return nil
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// HomedirMergePolicy defines which homedir MergeUsers keeps when the two users have different ones.
+type HomedirMergePolicy int
+
+const (
+	// HomedirMergeFail fails the merge.
+	HomedirMergeFail HomedirMergePolicy = iota
+	// HomedirMergeKeepExisting keeps the homedir of the kept user.
+	HomedirMergeKeepExisting
+	// HomedirMergePreferMerged gives the kept user the homedir of the merged one.
+	HomedirMergePreferMerged
+)
+
+type mergeUsersOptions struct {
+	homedirPolicy HomedirMergePolicy
+}
+
+// MergeUsersOption represents an optional function to override MergeUsers default values.
+type MergeUsersOption func(*mergeUsersOptions)
+
+// WithHomedirMergePolicy sets which homedir MergeUsers keeps when the two users have different ones.
+func WithHomedirMergePolicy(policy HomedirMergePolicy) MergeUsersOption {
+	return func(o *mergeUsersOptions) {
+		o.homedirPolicy = policy
+	}
+}
+
+// MergeUsers merges the user matching mergeUID into the one matching keepUID, in a single transaction, like when the
+// same person got two users whose names only differ by their case.
+// The supplementary groups of the merged user are added to the kept user, and the broker of the merged user, if any,
+// becomes the one of the kept user. The merged user is then deleted, with its primary group if it's left empty.
+// It returns an error if the users have different homedirs, unless WithHomedirMergePolicy says which one to keep.
+func (c *Cache) MergeUsers(keepUID, mergeUID uint32, args ...MergeUsersOption) (err error) {
+	defer decorate.OnError(&err, "could not merge user %d into user %d", mergeUID, keepUID)
+
+	opts := mergeUsersOptions{}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	if keepUID == mergeUID {
+		return errors.New("can't merge a user into themselves")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		keep, err := getFromBucket[userDB](buckets[userByIDBucketName], keepUID)
+		if err != nil {
+			return err
+		}
+		keepGroups, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], keepUID)
+		if err != nil {
+			return err
+		}
+		merge, err := getFromBucket[userDB](buckets[userByIDBucketName], mergeUID)
+		if err != nil {
+			return err
+		}
+		mergeGroups, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], mergeUID)
+		if err != nil {
+			return err
+		}
+
+		if keep.Dir != merge.Dir {
+			switch opts.homedirPolicy {
+			case HomedirMergeKeepExisting:
+			case HomedirMergePreferMerged:
+				keep.Dir = merge.Dir
+				keep.ModifiedAt = c.now()
+				putUser(buckets, keep)
+			default:
+				return fmt.Errorf("users %q and %q have different homedirs: %q and %q", keep.Name, merge.Name, keep.Dir, merge.Dir)
+			}
+		}
+
+		// The primary group of the kept user comes first, followed by the supplementary groups of both users.
+		groups := []GroupDB{{GID: keep.GID}}
+		var gids []uint32
+		for _, gid := range slices.Concat(keepGroups.GIDs, mergeGroups.GIDs) {
+			if gid == keep.GID || gid == merge.GID || slices.Contains(gids, gid) {
+				continue
+			}
+			gids = append(gids, gid)
+			groups = append(groups, GroupDB{GID: gid})
+		}
+		if err := updateUsersAndGroups(buckets, keepUID, groups, keepGroups.GIDs, c.largeGroupThreshold); err != nil {
+			return err
+		}
+
+		brokerID, err := getFromBucket[string](buckets[userToBrokerBucketName], mergeUID)
+		if err != nil && !errors.Is(err, NoDataFoundError{}) {
+			return err
+		}
+		if err == nil {
+			updateBucket(buckets[userToBrokerBucketName], keepUID, brokerID)
+		}
+
+		log.Debugf(context.Background(), "Merging user %q into user %q", merge.Name, keep.Name)
+		return c.deleteUserWithTombstone(buckets, mergeUID)
+	})
+}
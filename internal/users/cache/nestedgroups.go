@@ -0,0 +1,211 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// groupToGroupsDB is the struct stored in json format to match a gid to the gids of the groups nested in it.
+type groupToGroupsDB struct {
+	GID  uint32
+	GIDs []uint32
+}
+
+// SetNestedGroups sets the groups nested in the group matching gid, replacing the previous ones. The members of
+// nested groups are members of the group too, as returned by FlattenedGroupMembers. Nesting can be cyclic.
+// It returns an error if any of the groups doesn't exist.
+func (c *Cache) SetNestedGroups(gid uint32, nestedGIDs []uint32) (err error) {
+	defer decorate.OnError(&err, "could not set the groups nested in group %d", gid)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range append([]uint32{gid}, nestedGIDs...) {
+			if _, err := getFromBucket[groupDB](buckets[groupByIDBucketName], id); err != nil {
+				return err
+			}
+		}
+
+		nested, ok := nestedGroupsBucket(buckets)
+		if !ok && len(nestedGIDs) == 0 {
+			return nil
+		}
+		if !ok {
+			if nested, err = createNestedGroupsBucket(tx, buckets); err != nil {
+				return err
+			}
+		}
+
+		if len(nestedGIDs) == 0 {
+			if err := nested.Delete([]byte(strconv.FormatUint(uint64(gid), 10))); err != nil {
+				panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+			}
+			return nil
+		}
+
+		gids := slices.Compact(slices.Sorted(slices.Values(nestedGIDs)))
+		updateBucket(nested, gid, groupToGroupsDB{GID: gid, GIDs: gids})
+		return nil
+	})
+}
+
+// FlattenedGroupMembers returns the UIDs, in ascending order, of the members of the group matching gid and of all the
+// groups nested in it, directly or through other nested groups. Each group is only visited once, so cyclic nesting is
+// resolved.
+// It returns an error if the database is corrupted or the group was not found.
+func (c *Cache) FlattenedGroupMembers(gid uint32) (uids []uint32, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.view(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		if _, err := getFromBucket[groupDB](buckets[groupByIDBucketName], gid); err != nil {
+			return err
+		}
+		uids, err = flattenedGroupMembers(buckets, gid)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return uids, nil
+}
+
+// flattenedGroupMembers returns the UIDs, in ascending order, of the members of the group matching gid and of all the
+// groups nested in it, like FlattenedGroupMembers, in the transaction of buckets.
+func flattenedGroupMembers(buckets map[string]bucketWithName, gid uint32) ([]uint32, error) {
+	nested, hasNested := nestedGroupsBucket(buckets)
+
+	members := make(map[uint32]struct{})
+	visited := map[uint32]struct{}{gid: {}}
+	for toVisit := []uint32{gid}; len(toVisit) > 0; {
+		id := toVisit[0]
+		toVisit = toVisit[1:]
+
+		groupToUsers, err := groupMembers(buckets, id)
+		if err != nil && !errors.Is(err, NoDataFoundError{}) {
+			return nil, err
+		}
+		for _, uid := range groupToUsers.UIDs {
+			members[uid] = struct{}{}
+		}
+
+		if !hasNested {
+			continue
+		}
+		groupToGroups, err := getFromBucket[groupToGroupsDB](nested, id)
+		if err != nil && !errors.Is(err, NoDataFoundError{}) {
+			return nil, err
+		}
+		for _, nestedGID := range groupToGroups.GIDs {
+			if _, ok := visited[nestedGID]; ok {
+				continue
+			}
+			visited[nestedGID] = struct{}{}
+			toVisit = append(toVisit, nestedGID)
+		}
+	}
+
+	return slices.Sorted(maps.Keys(members)), nil
+}
+
+// nestedGroupsBucket returns the bucket of nested groups, with the same encoding as buckets, or false if no group was
+// ever nested.
+func nestedGroupsBucket(buckets map[string]bucketWithName) (bucketWithName, bool) {
+	groups := buckets[groupByIDBucketName]
+	b := groups.Tx().Bucket([]byte(groupToGroupsBucketName))
+	if b == nil {
+		return bucketWithName{}, false
+	}
+	return bucketWithName{name: groupToGroupsBucketName, compress: groups.compress, checksum: groups.checksum, Bucket: b}, true
+}
+
+// createNestedGroupsBucket creates the bucket of nested groups, with the same encoding as buckets.
+func createNestedGroupsBucket(tx *bbolt.Tx, buckets map[string]bucketWithName) (bucketWithName, error) {
+	if _, err := tx.CreateBucketIfNotExists([]byte(groupToGroupsBucketName)); err != nil {
+		return bucketWithName{}, err
+	}
+	b, _ := nestedGroupsBucket(buckets)
+	return b, nil
+}
+
+// deleteNestedGroup removes the group matching gid from all nesting relationships, so that they don't apply to
+// another group getting its GID later.
+func deleteNestedGroup(buckets map[string]bucketWithName, gid uint32) error {
+	return rewriteNestedGroups(buckets, gid)
+}
+
+// moveNestedGroup moves the nesting relationships of the group matching oldGID to newGID.
+func moveNestedGroup(buckets map[string]bucketWithName, oldGID, newGID uint32) error {
+	return rewriteNestedGroups(buckets, oldGID, newGID)
+}
+
+// rewriteNestedGroups replaces oldGID in all nesting relationships by newGIDs, which removes it if there is none.
+// Relationships left without any nested group are removed.
+func rewriteNestedGroups(buckets map[string]bucketWithName, oldGID uint32, newGIDs ...uint32) error {
+	nested, ok := nestedGroupsBucket(buckets)
+	if !ok {
+		return nil
+	}
+
+	edges := make(map[uint32][]uint32)
+	var touched bool
+	err := nested.ForEach(func(key, value []byte) error {
+		var g groupToGroupsDB
+		if err := unmarshalValue(value, &g); err != nil {
+			return fmt.Errorf("can't unmarshal nested groups in bucket %q for key %s: %v", groupToGroupsBucketName, key, err)
+		}
+		edges[g.GID] = g.GIDs
+		touched = touched || g.GID == oldGID || slices.Contains(g.GIDs, oldGID)
+		return nil
+	})
+	if err != nil || !touched {
+		return err
+	}
+
+	replace := func(gid uint32) []uint32 {
+		if gid == oldGID {
+			return newGIDs
+		}
+		return []uint32{gid}
+	}
+	rewritten := make(map[uint32][]uint32)
+	for parent, children := range edges {
+		for _, p := range replace(parent) {
+			for _, child := range children {
+				rewritten[p] = append(rewritten[p], replace(child)...)
+			}
+		}
+	}
+
+	// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
+	for parent := range edges {
+		if err := nested.Delete([]byte(strconv.FormatUint(uint64(parent), 10))); err != nil {
+			panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+		}
+	}
+	for parent, children := range rewritten {
+		if len(children) == 0 {
+			continue
+		}
+		updateBucket(nested, parent, groupToGroupsDB{GID: parent, GIDs: slices.Compact(slices.Sorted(slices.Values(children)))})
+	}
+	return nil
+}
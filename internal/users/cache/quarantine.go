@@ -0,0 +1,317 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// quarantineDB is the struct stored in json format in the quarantine bucket. Values are kept as they were stored.
+type quarantineDB struct {
+	UID           uint32
+	Reason        string
+	QuarantinedAt time.Time
+	// Records are the values of the user in the user buckets, by bucket name.
+	Records map[string][]byte
+	// GIDs are the groups of the user, primary group first, and Groups the values of the ones found in GroupByID.
+	GIDs   []uint32
+	Groups map[uint32][]byte
+}
+
+// QuarantinedRecord is a user record moved out of the active buckets by Quarantine.
+type QuarantinedRecord struct {
+	UID           uint32
+	Reason        string
+	QuarantinedAt time.Time
+	// Records are the JSON values of the user in the user buckets, by bucket name.
+	Records map[string]string
+	// Groups are the JSON values of the groups of the user, by GID.
+	Groups map[uint32]string
+}
+
+// Quarantine moves the records of the user matching uid out of the active buckets, so that they are not served
+// anymore, into the quarantine bucket with the reason and time. The records are kept as they are, even if they can't
+// be parsed, so that they can be inspected with QuarantinedRecords and brought back with Restore.
+// Groups left without any member are removed.
+func (c *Cache) Quarantine(uid uint32, reason string) (err error) {
+	defer decorate.OnError(&err, "could not quarantine user %d", uid)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+		quarantine, err := tx.CreateBucketIfNotExists([]byte(quarantineBucketName))
+		if err != nil {
+			return err
+		}
+
+		uidKey := []byte(strconv.FormatUint(uint64(uid), 10))
+		if quarantine.Get(uidKey) != nil {
+			return fmt.Errorf("user %d is already quarantined", uid)
+		}
+
+		q := quarantineDB{
+			UID:           uid,
+			Reason:        reason,
+			QuarantinedAt: c.now(),
+			Records:       make(map[string][]byte),
+			Groups:        make(map[uint32][]byte),
+		}
+
+		userRecord := buckets[userByIDBucketName].Get(uidKey)
+		name, nameFound := userNameFromRecord(buckets, userRecord, uid)
+		if userRecord == nil && !nameFound {
+			return NoDataFoundError{key: string(uidKey), bucketName: userByIDBucketName}
+		}
+
+		for _, name := range []string{userByIDBucketName, userToGroupsBucketName, userToBrokerBucketName} {
+			if v := buckets[name].Get(uidKey); v != nil {
+				q.Records[name] = slices.Clone(v)
+			}
+		}
+		if nameFound {
+			q.Records[userByNameBucketName] = slices.Clone(buckets[userByNameBucketName].Get([]byte(name)))
+		}
+
+		if q.GIDs, err = gidsOfUser(buckets, uid); err != nil {
+			return err
+		}
+		for _, gid := range q.GIDs {
+			if v := buckets[groupByIDBucketName].Get([]byte(strconv.FormatUint(uint64(gid), 10))); v != nil {
+				q.Groups[gid] = slices.Clone(v)
+			}
+			if err := deleteUserFromGroup(buckets, uid, gid); err != nil {
+				return err
+			}
+		}
+
+		// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
+		for _, name := range []string{userByIDBucketName, userToGroupsBucketName, userToBrokerBucketName} {
+			if err := buckets[name].Delete(uidKey); err != nil {
+				panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+			}
+		}
+		if nameFound {
+			if err := buckets[userByNameBucketName].Delete([]byte(name)); err != nil {
+				panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+			}
+		}
+
+		data, err := json.Marshal(q)
+		if err != nil {
+			return err
+		}
+		if err := quarantine.Put(uidKey, data); err != nil {
+			panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+		}
+
+		log.Warningf(context.Background(), "Quarantined user %d: %s", uid, reason)
+		return nil
+	})
+}
+
+// userNameFromRecord returns the name of the user matching uid, from its UserByID record if it can be parsed, or by
+// looking for a UserByName record with the same value or UID otherwise.
+func userNameFromRecord(buckets map[string]bucketWithName, record []byte, uid uint32) (name string, found bool) {
+	var u UserDB
+	if record != nil && unmarshalValue(record, &u) == nil && u.UID == uid {
+		if key := buckets[userByNameBucketName].nameKey(u.Name); buckets[userByNameBucketName].Get(key) != nil {
+			return string(key), true
+		}
+		if buckets[userByNameBucketName].Get([]byte(u.Name)) != nil {
+			return u.Name, true
+		}
+		return "", false
+	}
+
+	// The iteration can't fail, as we never return an error.
+	_ = buckets[userByNameBucketName].ForEach(func(key, value []byte) error {
+		if found {
+			return nil
+		}
+		var u UserDB
+		if (record != nil && string(value) == string(record)) || (unmarshalValue(value, &u) == nil && u.UID == uid) {
+			name, found = string(key), true
+		}
+		return nil
+	})
+	return name, found
+}
+
+// gidsOfUser returns the groups of the user from the UserToGroups bucket or, if its record can't be parsed, from the
+// GroupToUsers one.
+func gidsOfUser(buckets map[string]bucketWithName, uid uint32) (gids []uint32, err error) {
+	userToGroups, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], uid)
+	if err == nil {
+		return userToGroups.GIDs, nil
+	}
+	if errors.Is(err, NoDataFoundError{}) {
+		return nil, nil
+	}
+
+	err = buckets[groupToUsersBucketName].ForEach(func(_, value []byte) error {
+		var g groupToUsersDB
+		if err := unmarshalValue(value, &g); err != nil {
+			// We can't know if the user is in a group we can't parse.
+			return nil
+		}
+		if slices.Contains(g.UIDs, uid) || isLargeGroupMember(buckets, g.GID, uid) {
+			gids = append(gids, g.GID)
+		}
+		return nil
+	})
+	return gids, err
+}
+
+// QuarantinedRecords returns all the quarantined user records, ordered by UID.
+func (c *Cache) QuarantinedRecords() (records []QuarantinedRecord, err error) {
+	defer decorate.OnError(&err, "could not get quarantined records")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.view(func(tx *bbolt.Tx) error {
+		quarantine := tx.Bucket([]byte(quarantineBucketName))
+		if quarantine == nil {
+			return nil
+		}
+
+		return quarantine.ForEach(func(key, value []byte) error {
+			var q quarantineDB
+			if err := json.Unmarshal(value, &q); err != nil {
+				return fmt.Errorf("can't unmarshal quarantined record %s: %v", key, err)
+			}
+
+			r := QuarantinedRecord{
+				UID:           q.UID,
+				Reason:        q.Reason,
+				QuarantinedAt: q.QuarantinedAt,
+				Records:       make(map[string]string),
+				Groups:        make(map[uint32]string),
+			}
+			for name, v := range q.Records {
+				r.Records[name] = rawValueToString(v)
+			}
+			for gid, v := range q.Groups {
+				r.Groups[gid] = rawValueToString(v)
+			}
+			records = append(records, r)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(records, func(a, b QuarantinedRecord) int { return cmp.Compare(a.UID, b.UID) })
+	return records, nil
+}
+
+// rawValueToString returns the JSON data of a stored value, or the value as is if it can't be decompressed.
+func rawValueToString(v []byte) string {
+	data, err := decodeValue(v)
+	if err != nil {
+		return string(v)
+	}
+	return string(data)
+}
+
+// Restore brings the quarantined records of the user matching uid back into the active buckets, with the groups
+// which were removed in the meantime. It fails if the user record can't be parsed, or if its UID, name or groups are
+// now used by other entries.
+func (c *Cache) Restore(uid uint32) (err error) {
+	defer decorate.OnError(&err, "could not restore user %d", uid)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		uidKey := []byte(strconv.FormatUint(uint64(uid), 10))
+		quarantine := tx.Bucket([]byte(quarantineBucketName))
+		var value []byte
+		if quarantine != nil {
+			value = quarantine.Get(uidKey)
+		}
+		if value == nil {
+			return NoDataFoundError{key: string(uidKey), bucketName: quarantineBucketName}
+		}
+		var q quarantineDB
+		if err := json.Unmarshal(value, &q); err != nil {
+			return fmt.Errorf("can't unmarshal quarantined record: %v", err)
+		}
+
+		var u userDB
+		if err := unmarshalValue(q.Records[userByIDBucketName], &u); err != nil {
+			return fmt.Errorf("can't restore invalid user record: %v", err)
+		}
+		if buckets[userByIDBucketName].Get(uidKey) != nil {
+			return fmt.Errorf("UID %d is now used by another user", uid)
+		}
+		if _, err := getFromBucket[userDB](buckets[userByNameBucketName], u.Name); err == nil {
+			return fmt.Errorf("name %q is now used by another user", u.Name)
+		}
+
+		var groups []GroupDB
+		for _, gid := range q.GIDs {
+			g, err := getFromBucket[groupDB](buckets[groupByIDBucketName], gid)
+			if err != nil && !errors.Is(err, NoDataFoundError{}) {
+				return err
+			}
+			if errors.Is(err, NoDataFoundError{}) {
+				if err := unmarshalValue(q.Groups[gid], &g); err != nil {
+					return fmt.Errorf("can't restore invalid record of group %d: %v", gid, err)
+				}
+				if buckets[groupByNameBucketName].Get([]byte(g.Name)) != nil {
+					return fmt.Errorf("name %q of group %d is now used by another group", g.Name, gid)
+				}
+				updateBucket(buckets[groupByIDBucketName], gid, g)
+				updateBucket(buckets[groupByNameBucketName], g.Name, g)
+			}
+			groups = append(groups, GroupDB{Name: g.Name, GID: gid})
+		}
+
+		if err := buckets[userByIDBucketName].Put(uidKey, q.Records[userByIDBucketName]); err != nil {
+			panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+		}
+		nameRecord := q.Records[userByNameBucketName]
+		if err := unmarshalValue(nameRecord, &userDB{}); err != nil {
+			nameRecord = q.Records[userByIDBucketName]
+		}
+		if err := buckets[userByNameBucketName].Put(buckets[userByNameBucketName].nameKey(u.Name), nameRecord); err != nil {
+			panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+		}
+		if brokerRecord := q.Records[userToBrokerBucketName]; brokerRecord != nil {
+			if err := buckets[userToBrokerBucketName].Put(uidKey, brokerRecord); err != nil {
+				panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+			}
+		}
+		if err := updateUsersAndGroups(buckets, uid, groups, nil, c.largeGroupThreshold); err != nil {
+			return err
+		}
+
+		if err := quarantine.Delete(uidKey); err != nil {
+			panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+		}
+
+		log.Infof(context.Background(), "Restored quarantined user %q (%d)", u.Name, uid)
+		return nil
+	})
+}
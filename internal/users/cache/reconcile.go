@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ubuntu/authd/internal/log"
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// UserEntryUpdate is the desired state of a user, with its groups, primary group first.
+type UserEntryUpdate struct {
+	User   UserDB
+	Groups []GroupDB
+	// BrokerID is the broker assigned to the user. It is left unchanged if empty.
+	BrokerID string
+}
+
+// ReconcileOptions controls how Reconcile handles the users which are not in the desired state.
+type ReconcileOptions struct {
+	// AllowDeletion must be set for users not in the desired state to be deleted. Otherwise, they are kept.
+	AllowDeletion bool
+	// Source restricts the deletion to the users assigned to this broker ID. All users are considered if empty.
+	Source string
+}
+
+// ReconcileReport is the summary of the changes made by Reconcile.
+type ReconcileReport struct {
+	Created int
+	Updated int
+	Deleted int
+}
+
+// Reconcile makes the cache match the desired state in a single transaction: users in desired are inserted or
+// updated, and, if opts.AllowDeletion is set, the other users (assigned to opts.Source if set) are deleted.
+// Unlike UpdateUserEntry, it does not count as a login of the users.
+// The desired state is checked with ValidateImportSet first, and rejected if it has any conflict.
+// If any change fails, none of them are applied.
+func (c *Cache) Reconcile(desired []UserEntryUpdate, opts ReconcileOptions) (report ReconcileReport, err error) {
+	defer decorate.OnError(&err, "could not reconcile database")
+
+	conflicts, err := ValidateImportSet(desired)
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+	if err := importConflictsError(conflicts); err != nil {
+		return ReconcileReport{}, err
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.update(func(tx *bbolt.Tx) error {
+		report = ReconcileReport{}
+
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		now := c.now()
+		wanted := make(map[uint32]struct{}, len(desired))
+		for _, d := range desired {
+			wanted[d.User.UID] = struct{}{}
+
+			existing, err := getFromBucket[userDB](buckets[userByIDBucketName], d.User.UID)
+			if err != nil && !errors.Is(err, NoDataFoundError{}) {
+				return err
+			}
+			created := errors.Is(err, NoDataFoundError{})
+
+			u := userDB{UserDB: d.User, LastLogin: existing.LastLogin, ModifiedAt: now}
+			u.GID = d.Groups[0].GID
+			if err := c.updateUserEntry(buckets, u, d.Groups, now); err != nil {
+				return err
+			}
+			if d.BrokerID != "" {
+				updateBucket(buckets[userToBrokerBucketName], d.User.UID, d.BrokerID)
+			}
+
+			if created {
+				report.Created++
+			} else {
+				report.Updated++
+			}
+		}
+
+		if !opts.AllowDeletion {
+			return nil
+		}
+
+		// Deleting while iterating over the bucket is not supported, so we collect the users first.
+		var toDelete []uint32
+		err = buckets[userByIDBucketName].ForEach(func(key, _ []byte) error {
+			id, err := strconv.ParseUint(string(key), 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid UID key %q: %v", key, err)
+			}
+			//nolint:gosec // ParseUint checked that the value fits in an uint32.
+			uid := uint32(id)
+			if _, ok := wanted[uid]; ok {
+				return nil
+			}
+			if opts.Source != "" {
+				brokerID, err := getFromBucket[string](buckets[userToBrokerBucketName], uid)
+				if err != nil && !errors.Is(err, NoDataFoundError{}) {
+					return err
+				}
+				if brokerID != opts.Source {
+					return nil
+				}
+			}
+			toDelete = append(toDelete, uid)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, uid := range toDelete {
+			if err := c.deleteUserWithTombstone(buckets, uid); err != nil {
+				return err
+			}
+			report.Deleted++
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return ReconcileReport{}, err
+	}
+
+	log.Debugf(context.Background(), "Reconciled database: %d created, %d updated, %d deleted",
+		report.Created, report.Updated, report.Deleted)
+	return report, nil
+}
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"errors"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// GroupsSnapshot returns all groups with, if withNestedMembers is true, the names of the members of the groups nested
+// in each of them, directly or through other nested groups, indexed by GID. Everything is read in a single transaction
+// so that the groups and their members are consistent with each other even if the database is concurrently updated.
+// Members which are not users of the database anymore are skipped.
+func (c *Cache) GroupsSnapshot(withNestedMembers bool) (groups []GroupDB, nestedMembers map[uint32][]string, err error) {
+	defer decorate.OnError(&err, "could not take a snapshot of the groups")
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	err = c.view(func(tx *bbolt.Tx) error {
+		if groups, err = c.allGroups(tx); err != nil {
+			return err
+		}
+		if !withNestedMembers {
+			return nil
+		}
+
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+		nestedMembers = make(map[uint32][]string, len(groups))
+		for _, g := range groups {
+			uids, err := flattenedGroupMembers(buckets, g.GID)
+			if err != nil {
+				return err
+			}
+			for _, uid := range uids {
+				u, err := getFromBucket[userDB](buckets[userByIDBucketName], uid)
+				if errors.Is(err, NoDataFoundError{}) {
+					continue
+				}
+				if err != nil {
+					return err
+				}
+				nestedMembers[g.GID] = append(nestedMembers[g.GID], u.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return groups, nestedMembers, nil
+}
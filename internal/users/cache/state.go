@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"errors"
+
+	"go.etcd.io/bbolt"
+)
+
+// CacheStatus is the lifecycle status of the cache.
+type CacheStatus int
+
+const (
+	// CacheHealthy means that the cache is open and its last database transaction succeeded.
+	CacheHealthy CacheStatus = iota
+	// CacheReadOnly means that the database is opened read-only.
+	CacheReadOnly
+	// CacheInError means that the last database transaction failed.
+	CacheInError
+	// CacheClosed means that the cache was closed.
+	CacheClosed
+)
+
+// String returns a human readable representation of the status.
+func (s CacheStatus) String() string {
+	switch s {
+	case CacheHealthy:
+		return "healthy"
+	case CacheReadOnly:
+		return "read-only"
+	case CacheInError:
+		return "error"
+	case CacheClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// CacheState is a snapshot of the lifecycle state of the cache.
+type CacheState struct {
+	Status CacheStatus
+	// Path is the path to the database file.
+	Path string
+	// LastError is the error of the last failed database transaction, if the ones after it did not succeed.
+	LastError error
+}
+
+// State returns the current state of the cache, without accessing the database.
+func (c *Cache) State() CacheState {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+
+	s := CacheState{
+		Status:    CacheHealthy,
+		Path:      c.db.Path(),
+		LastError: c.lastErr,
+	}
+
+	switch {
+	case c.closed:
+		s.Status = CacheClosed
+	case c.lastErr != nil:
+		s.Status = CacheInError
+	case c.db.IsReadOnly():
+		s.Status = CacheReadOnly
+	}
+
+	return s
+}
+
+// view runs fn in a read-only transaction, recording the state of the database.
+// It returns ErrMigrationInProgress without running fn if a migration is in progress.
+func (c *Cache) view(fn func(tx *bbolt.Tx) error) error {
+	if c.migrating.Load() > 0 {
+		return ErrMigrationInProgress
+	}
+
+	err := c.db.View(fn)
+	c.recordTransactionError(err)
+	return err
+}
+
+// update runs fn in a read-write transaction, recording the state of the database.
+// It returns ErrReadOnlyCache without running fn if the database is opened read-only.
+func (c *Cache) update(fn func(tx *bbolt.Tx) error) error {
+	if c.db.IsReadOnly() {
+		return ErrReadOnlyCache
+	}
+
+	err := c.db.Update(fn)
+	c.recordTransactionError(err)
+	return err
+}
+
+// dryRun runs fn in a read-write transaction which is always rolled back, recording the state of the database.
+// It returns ErrReadOnlyCache without running fn if the database is opened read-only.
+func (c *Cache) dryRun(fn func(tx *bbolt.Tx) error) error {
+	if c.db.IsReadOnly() {
+		return ErrReadOnlyCache
+	}
+
+	tx, err := c.db.Begin(true)
+	if err != nil {
+		c.recordTransactionError(err)
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	err = fn(tx)
+	c.recordTransactionError(err)
+	return err
+}
+
+// migrate runs fn in a read-write transaction, during which reads return ErrMigrationInProgress. Reads are served
+// again once the transaction is committed or rolled back.
+func (c *Cache) migrate(fn func(tx *bbolt.Tx) error) error {
+	c.migrating.Add(1)
+	defer c.migrating.Add(-1)
+
+	return c.update(fn)
+}
+
+// recordTransactionError records err as the last error, including the ones returned by the callback of the
+// transaction, like ErrCorrupted, unless no entry was found, which says nothing about the state of the database.
+// A successful transaction clears the last error.
+func (c *Cache) recordTransactionError(err error) {
+	if errors.Is(err, NoDataFoundError{}) {
+		return
+	}
+
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.lastErr = err
+}
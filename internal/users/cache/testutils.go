@@ -4,6 +4,7 @@ package cache
 // They are not exported, and guarded by testing assertions.
 
 import (
+	"encoding/json"
 	"io"
 	"os"
 	"path/filepath"
@@ -33,30 +34,32 @@ var redactedTimes = map[string]string{
 func redactTime(line string) string {
 	testsdetection.MustBeTesting()
 
-	re := regexp.MustCompile(`"LastLogin":"(.*?)"`)
-	match := re.FindSubmatch([]byte(line))
-
-	if len(match) <= 1 {
-		// Not found
-		return line
+	re := regexp.MustCompile(`"(?:LastLogin|ModifiedAt)":"(.*?)"`)
+	for _, match := range re.FindAllStringSubmatch(line, -1) {
+		line = redactOneTime(line, match[1])
 	}
+	return line
+}
 
-	lastLogin := string(match[1])
-	lastLoginTime, err := time.Parse(time.RFC3339, lastLogin)
+// redactOneTime replace the given time in line by a redacted version.
+//
+//nolint:unused // This is used for tests, with methods that are using go linking. Not part of exported API.
+func redactOneTime(line, t string) string {
+	parsedTime, err := time.Parse(time.RFC3339, t)
 	if err != nil {
 		return line
 	}
 	var isNow bool
-	if time.Since(lastLoginTime) < time.Minute*5 {
+	if time.Since(parsedTime) < time.Minute*5 {
 		isNow = true
 	}
 
 	for redacted, time := range redactedTimes {
-		if lastLogin != time && (time != "now" || !isNow) {
+		if t != time && (time != "now" || !isNow) {
 			continue
 		}
 
-		return strings.Replace(line, lastLogin, redacted, 1)
+		return strings.Replace(line, t, redacted, 1)
 	}
 	return line
 }
@@ -78,6 +81,25 @@ func (c *Cache) dumpToYaml() (string, error) {
 		return tx.ForEach(func(name []byte, bucket *bbolt.Bucket) error {
 			d[string(name)] = make(map[string]string)
 			return bucket.ForEach(func(key, value []byte) error {
+				// The members of large groups are dumped as a list of UIDs.
+				if members := bucket.Bucket(key); members != nil {
+					uids, err := uidsOfLargeGroup(members)
+					if err != nil {
+						return err
+					}
+					value, err = json.Marshal(uids)
+					if err != nil {
+						return err
+					}
+					d[string(name)][string(key)] = string(value)
+					return nil
+				}
+
+				value, err := decodeValue(value)
+				if err != nil {
+					return err
+				}
+
 				key = []byte(strings.Replace(string(key), strconv.Itoa(uid), "{{CURRENT_UID}}", 1))
 				value = []byte(strings.ReplaceAll(string(value), strconv.Itoa(uid), "{{CURRENT_UID}}"))
 
@@ -129,6 +151,12 @@ func dbfromYAML(r io.Reader, destDir string) error {
 			}
 
 			for key, val := range bucketContent {
+				if bucketName == groupMembersBucketName {
+					if err := putLargeGroupMembers(bucket, key, val); err != nil {
+						return err
+					}
+					continue
+				}
 				if bucketName == userByIDBucketName || bucketName == userByNameBucketName {
 					// Replace {{CURRENT_UID}} with the UID of the current process
 					val = strings.ReplaceAll(val, "{{CURRENT_UID}}", strconv.Itoa(uid))
@@ -139,7 +167,7 @@ func dbfromYAML(r io.Reader, destDir string) error {
 						if t == "now" {
 							t = time.Now().Format(time.RFC3339)
 						}
-						val = strings.Replace(val, redacted, t, 1)
+						val = strings.ReplaceAll(val, redacted, t)
 					}
 				}
 				if err := bucket.Put([]byte(key), []byte(val)); err != nil {
@@ -150,3 +178,24 @@ func dbfromYAML(r io.Reader, destDir string) error {
 		return nil
 	})
 }
+
+// putLargeGroupMembers creates the bucket of the members of a large group from the list of their UIDs.
+//
+//nolint:unused // This is used for tests, with methods that are using go linking. Not part of exported API.
+func putLargeGroupMembers(groupMembers *bbolt.Bucket, gid, uids string) error {
+	var members []uint32
+	if err := json.Unmarshal([]byte(uids), &members); err != nil {
+		return err
+	}
+
+	bucket, err := groupMembers.CreateBucketIfNotExists([]byte(gid))
+	if err != nil {
+		return err
+	}
+	for _, uid := range members {
+		if err := bucket.Put([]byte(strconv.FormatUint(uint64(uid), 10)), []byte{}); err != nil {
+			panic("programming error: put called in a RO transaction")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ubuntu/decorate"
+	"go.etcd.io/bbolt"
+)
+
+// ErrUIDReserved is returned when inserting a new user with a UID reserved by the tombstone of another deleted user.
+var ErrUIDReserved = errors.New("UID is reserved by the tombstone of a deleted user")
+
+// tombstoneDB is the struct stored in json format in the tombstones bucket, for the UID of each deleted user.
+type tombstoneDB struct {
+	UID       uint32
+	Name      string
+	DeletedAt time.Time
+}
+
+// WithUIDReuseDelay sets how long the UIDs of deleted users are not allocated again, so that a new user doesn't get
+// the ownership of the files left by a deleted one. Inserting a new user with a reserved UID fails with ErrUIDReserved,
+// unless it has the name of the deleted user, which gets its UID back.
+// A negative delay keeps the UIDs reserved until their tombstone is released with ReleaseTombstone. The default, 0,
+// allows reusing them right away.
+func WithUIDReuseDelay(d time.Duration) Option {
+	return func(o *options) {
+		o.uidReuseDelay = d
+	}
+}
+
+// deleteUserWithTombstone removes the user matching uid from buckets and reserves its UID, if UIDs reuse is delayed.
+func (c *Cache) deleteUserWithTombstone(buckets map[string]bucketWithName, uid uint32) error {
+	// If the user doesn't exist, deleteUser returns the error.
+	u, _ := getFromBucket[UserDB](buckets[userByIDBucketName], uid)
+	if err := deleteUser(buckets, uid); err != nil {
+		return err
+	}
+	return c.addTombstone(buckets[userByIDBucketName].Tx(), uid, u.Name)
+}
+
+// addTombstone reserves the UID of the deleted user named name, if UIDs reuse is delayed.
+func (c *Cache) addTombstone(tx *bbolt.Tx, uid uint32, name string) error {
+	if c.uidReuseDelay == 0 {
+		return nil
+	}
+
+	tombstones, err := tx.CreateBucketIfNotExists([]byte(tombstonesBucketName))
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(tombstoneDB{UID: uid, Name: name, DeletedAt: c.now()})
+	if err != nil {
+		return err
+	}
+	if err := tombstones.Put([]byte(strconv.FormatUint(uint64(uid), 10)), data); err != nil {
+		panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+	}
+	return nil
+}
+
+// isTombstoned returns whether the UID is reserved by the tombstone of a deleted user with another name than name.
+// Tombstones recorded without a name reserve their UID for any user.
+func (c *Cache) isTombstoned(tx *bbolt.Tx, uid uint32, name string) bool {
+	if c.uidReuseDelay == 0 {
+		return false
+	}
+	tombstones := tx.Bucket([]byte(tombstonesBucketName))
+	if tombstones == nil {
+		return false
+	}
+
+	value := tombstones.Get([]byte(strconv.FormatUint(uint64(uid), 10)))
+	if value == nil {
+		return false
+	}
+	var t tombstoneDB
+	if err := json.Unmarshal(value, &t); err != nil {
+		// Keep the UID reserved: we don't know when it was freed.
+		return true
+	}
+	// The name is matched like the keys of the users by name, so only case-insensitively if names are.
+	usersByName := c.newBucketWithName(userByNameBucketName, tx.Bucket([]byte(userByNameBucketName)))
+	if t.Name != "" && bytes.Equal(usersByName.nameKey(t.Name), usersByName.nameKey(name)) {
+		return false
+	}
+	return c.tombstoneActive(t)
+}
+
+// reclaimTombstone removes the tombstone of the UID of a user inserted again with the name of the deleted one, as
+// the UID is used again.
+func reclaimTombstone(tx *bbolt.Tx, uid uint32) {
+	tombstones := tx.Bucket([]byte(tombstonesBucketName))
+	if tombstones == nil {
+		return
+	}
+	if err := tombstones.Delete([]byte(strconv.FormatUint(uint64(uid), 10))); err != nil {
+		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+	}
+}
+
+// tombstoneActive returns whether the tombstone still reserves its UID.
+func (c *Cache) tombstoneActive(t tombstoneDB) bool {
+	return c.uidReuseDelay < 0 || c.now().Before(t.DeletedAt.Add(c.uidReuseDelay))
+}
+
+// Tombstones returns the UIDs of deleted users which are currently reserved, in ascending order.
+func (c *Cache) Tombstones() (uids []uint32, err error) {
+	defer decorate.OnError(&err, "could not get tombstones")
+
+	if c.uidReuseDelay == 0 {
+		return nil, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	err = c.view(func(tx *bbolt.Tx) error {
+		uids, err = c.activeTombstones(tx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortFunc(uids, cmp.Compare)
+	return uids, nil
+}
+
+// activeTombstones returns the UIDs reserved by tombstones in the transaction, in no particular order.
+func (c *Cache) activeTombstones(tx *bbolt.Tx) (uids []uint32, err error) {
+	tombstones := tx.Bucket([]byte(tombstonesBucketName))
+	if tombstones == nil {
+		return nil, nil
+	}
+
+	err = tombstones.ForEach(func(key, value []byte) error {
+		var t tombstoneDB
+		if err := json.Unmarshal(value, &t); err != nil {
+			return fmt.Errorf("can't unmarshal tombstone %s: %v", key, err)
+		}
+		if c.tombstoneActive(t) {
+			uids = append(uids, t.UID)
+		}
+		return nil
+	})
+	return uids, err
+}
+
+// ReleaseTombstone removes the tombstone of the UID, so that it can be allocated again. This is meant to be called
+// once the files of the deleted user are cleaned up.
+func (c *Cache) ReleaseTombstone(uid uint32) (err error) {
+	defer decorate.OnError(&err, "could not release tombstone of UID %d", uid)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) error {
+		uidKey := []byte(strconv.FormatUint(uint64(uid), 10))
+		tombstones := tx.Bucket([]byte(tombstonesBucketName))
+		if tombstones == nil || tombstones.Get(uidKey) == nil {
+			return NoDataFoundError{key: string(uidKey), bucketName: tombstonesBucketName}
+		}
+
+		if err := tombstones.Delete(uidKey); err != nil {
+			panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+		}
+		return nil
+	})
+}
@@ -1,10 +1,12 @@
 package cache
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"slices"
 	"strconv"
 	"time"
@@ -13,88 +15,597 @@ import (
 	"go.etcd.io/bbolt"
 )
 
-// UpdateUserEntry inserts or updates user and group buckets from the user information.
-func (c *Cache) UpdateUserEntry(usr UserDB, groupContents []GroupDB) error {
+// ErrVersionConflict is returned by UpdateUserEntryCAS when the user changed since it was read.
+var ErrVersionConflict = errors.New("user was modified concurrently")
+
+type updateUserEntryOptions struct {
+	uidRange         *uidRange
+	deterministicUID func(name string) uint32
+}
+
+// uidRange is an inclusive range of UIDs.
+type uidRange struct {
+	min, max uint32
+}
+
+// UpdateUserEntryOption represents an optional function to override UpdateUserEntry default values.
+type UpdateUserEntryOption func(*updateUserEntryOptions)
+
+// WithAuthoritativeUIDRange makes UpdateUserEntry reject users whose UID is outside of [minUID, maxUID], like when a
+// broker tries to write a user outside of the UID range it is authoritative for.
+func WithAuthoritativeUIDRange(minUID, maxUID uint32) UpdateUserEntryOption {
+	return func(o *updateUserEntryOptions) {
+		o.uidRange = &uidRange{min: minUID, max: maxUID}
+	}
+}
+
+// WithDeterministicUID makes UpdateUserEntry compute the UID of users passed without any, so that stateless hosts give
+// the same UID to the same user without coordination.
+// A user already in the database keeps its UID. Otherwise, the UID is fn(name) if it's in the authoritative UID range,
+// or in 1-4294967295 if none is set, and the start of the range plus fn(name) modulo the size of the range if not.
+// If that UID is used by another user or reserved by a tombstone, the next UIDs are probed in ascending order, wrapping
+// around to the start of the range, and the first free one is taken. Colliding users thus only get the same UIDs on
+// different hosts if they are added in the same order.
+func WithDeterministicUID(fn func(name string) uint32) UpdateUserEntryOption {
+	return func(o *updateUserEntryOptions) {
+		o.deterministicUID = fn
+	}
+}
+
+// UpdateUserEntry inserts or updates user and group buckets from the user information. As it records a login of the
+// user, their failed logins are forgotten.
+func (c *Cache) UpdateUserEntry(usr UserDB, groupContents []GroupDB, args ...UpdateUserEntryOption) error {
+	opts := updateUserEntryOptions{}
+	for _, arg := range args {
+		arg(&opts)
+	}
+	computeUID := usr.UID == 0 && opts.deterministicUID != nil
+	if r := opts.uidRange; r != nil && !computeUID && (usr.UID < r.min || usr.UID > r.max) {
+		return fmt.Errorf("UID %d of user %q is outside of the authoritative range %d-%d", usr.UID, usr.Name, r.min, r.max)
+	}
+
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
+	now := c.now()
 	userDB := userDB{
-		UserDB:    usr,
-		LastLogin: time.Now(),
+		UserDB:     usr,
+		LastLogin:  now,
+		ModifiedAt: now,
 	}
 
-	err := c.db.Update(func(tx *bbolt.Tx) error {
-		buckets, err := getAllBuckets(tx)
+	err := c.updateWithEvents(func(tx *bbolt.Tx, t *changeTracker) error {
+		buckets, err := c.getAllBuckets(tx)
 		if err != nil {
 			return err
 		}
 
-		previousGroupsForCurrentUser, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], userDB.UID)
-		// No data is valid and means this is the first insertion.
-		if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		if computeUID {
+			userDB.UID, err = c.deterministicUID(tx, buckets, usr.Name, opts)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := t.trackUser(buckets, userDB.UID, groupContents); err != nil {
 			return err
 		}
+		if err := c.updateUserEntry(buckets, userDB, groupContents, now); err != nil {
+			return err
+		}
+		// The login of the user forgets their failed ones.
+		clearFailedLogins(tx, userDB.UID)
+		return nil
+	})
+
+	return err
+}
+
+// deterministicUID returns the UID of the user name as documented in WithDeterministicUID.
+func (c *Cache) deterministicUID(tx *bbolt.Tx, buckets map[string]bucketWithName, name string, opts updateUserEntryOptions) (uint32, error) {
+	existing, err := getFromBucket[userDB](buckets[userByNameBucketName], name)
+	if err == nil {
+		return existing.UID, nil
+	}
+	if !errors.Is(err, NoDataFoundError{}) {
+		return 0, err
+	}
+
+	r := uidRange{min: 1, max: math.MaxUint32}
+	if opts.uidRange != nil {
+		r = *opts.uidRange
+	}
+
+	start := opts.deterministicUID(name)
+	if start < r.min || start > r.max {
+		// Computed in 64 bits, as the size of the full range doesn't fit in 32.
+		size := uint64(r.max) - uint64(r.min) + 1
+		start = r.min + uint32(uint64(start)%size)
+	}
+
+	for uid := start; ; {
+		key := []byte(strconv.FormatUint(uint64(uid), 10))
+		if buckets[userByIDBucketName].Get(key) == nil && !c.isTombstoned(tx, uid, name) {
+			return uid, nil
+		}
+
+		if uid == r.max {
+			uid = r.min
+		} else {
+			uid++
+		}
+		if uid == start {
+			return 0, fmt.Errorf("no free UID in range %d-%d for user %q", r.min, r.max, name)
+		}
+	}
+}
+
+// UpdateUserEntryCAS inserts or updates user and group buckets from the user information like UpdateUserEntry, but
+// only if the version of the stored user is still expectedVersion, as returned by UserByIDWithVersion. The version
+// of a user not in the database is 0.
+// It returns an error matching ErrVersionConflict if the user was written since it was read.
+func (c *Cache) UpdateUserEntryCAS(usr UserDB, groupContents []GroupDB, expectedVersion uint64) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.now()
+	newUser := userDB{
+		UserDB:     usr,
+		LastLogin:  now,
+		ModifiedAt: now,
+	}
 
-		/* 1. Handle user update */
-		if err := updateUser(buckets, userDB); err != nil {
+	return c.updateWithEvents(func(tx *bbolt.Tx, t *changeTracker) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
 			return err
 		}
 
-		/* 2. Handle groups update */
-		if err := updateGroups(buckets, groupContents); err != nil {
+		existing, err := getFromBucket[userDB](buckets[userByIDBucketName], usr.UID)
+		if err != nil && !errors.Is(err, NoDataFoundError{}) {
 			return err
 		}
+		if existing.Version != expectedVersion {
+			return fmt.Errorf("%w: user %q is at version %d, expected %d", ErrVersionConflict, usr.Name, existing.Version, expectedVersion)
+		}
 
-		/* 3. Users and groups mapping buckets */
-		if err := updateUsersAndGroups(buckets, userDB.UID, groupContents, previousGroupsForCurrentUser.GIDs); err != nil {
+		if err := t.trackUser(buckets, newUser.UID, groupContents); err != nil {
 			return err
 		}
+		if err := c.updateUserEntry(buckets, newUser, groupContents, now); err != nil {
+			return err
+		}
+		// The login of the user forgets their failed ones.
+		clearFailedLogins(tx, newUser.UID)
+		return nil
+	})
+}
+
+// UpdateUserEntries inserts or updates the users and their groups, like UpdateUserEntry, in a single transaction.
+// The broker of the users with a BrokerID is updated too.
+// If any update fails, like when a UID or GID is already used by a different user or group, none of them are applied.
+func (c *Cache) UpdateUserEntries(entries []UserEntryUpdate) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
+	now := c.now()
+	return c.updateWithEvents(func(tx *bbolt.Tx, t *changeTracker) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if err := t.trackUser(buckets, e.User.UID, e.Groups); err != nil {
+				return err
+			}
+		}
+		for _, e := range entries {
+			u := userDB{
+				UserDB:     e.User,
+				LastLogin:  now,
+				ModifiedAt: now,
+			}
+			if err := c.updateUserEntry(buckets, u, e.Groups, now); err != nil {
+				return fmt.Errorf("user %q: %w", e.User.Name, err)
+			}
+			clearFailedLogins(tx, e.User.UID)
+			if e.BrokerID != "" {
+				updateBucket(buckets[userToBrokerBucketName], e.User.UID, e.BrokerID)
+			}
+		}
 		return nil
 	})
+}
 
-	return err
+// updateUserEntry inserts or updates the user and its groups in buckets.
+func (c *Cache) updateUserEntry(buckets map[string]bucketWithName, userDB userDB, groupContents []GroupDB, now time.Time) error {
+	/* 0. Handle groups whose name is already used with a different GID */
+	resolvedGroups, err := resolveGroupConflicts(buckets, groupContents, c.groupConflictPolicy, now)
+	if err != nil {
+		return err
+	}
+	// The primary group of the user may have been kept with its existing GID.
+	if i := slices.IndexFunc(groupContents, func(g GroupDB) bool { return g.GID == userDB.GID }); i >= 0 {
+		userDB.GID = resolvedGroups[i].GID
+	}
+	groupContents = resolvedGroups
+
+	previousGroupsForCurrentUser, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], userDB.UID)
+	// No data is valid and means this is the first insertion.
+	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		return err
+	}
+
+	/* 1. Handle user update */
+	// A new user must not get the UID of a deleted user, and with it the ownership of their files, while it's reserved.
+	// The deleted user itself can log in again and get its UID back.
+	users := buckets[userByIDBucketName]
+	isNew := users.Get([]byte(strconv.FormatUint(uint64(userDB.UID), 10))) == nil
+	if isNew && c.isTombstoned(users.Tx(), userDB.UID, userDB.Name) {
+		return fmt.Errorf("%w: can't insert user %q with UID %d", ErrUIDReserved, userDB.Name, userDB.UID)
+	}
+	if isNew {
+		reclaimTombstone(users.Tx(), userDB.UID)
+	}
+	if err := updateUser(buckets, userDB, c.uniqueHomedirs, c.keepExistingShell); err != nil {
+		return err
+	}
+
+	/* 2. Handle groups update */
+	if err := updateGroups(buckets, groupContents); err != nil {
+		return err
+	}
+
+	/* 3. Users and groups mapping buckets */
+	return updateUsersAndGroups(buckets, userDB.UID, groupContents, previousGroupsForCurrentUser.GIDs, c.largeGroupThreshold)
+}
+
+type copyGroupMembershipOptions struct {
+	primaryGroup bool
+}
+
+// CopyGroupMembershipOption represents an optional function to override CopyGroupMembership default values.
+type CopyGroupMembershipOption func(*copyGroupMembershipOptions)
+
+// WithPrimaryGroup makes CopyGroupMembership copy the primary group of the source user too.
+func WithPrimaryGroup() CopyGroupMembershipOption {
+	return func(o *copyGroupMembershipOptions) {
+		o.primaryGroup = true
+	}
+}
+
+// CopyGroupMembership sets the supplementary groups of the user matching toUID to exactly the ones of the user
+// matching fromUID, in a single transaction. The destination user keeps their primary group, unless
+// WithPrimaryGroup is passed. Groups left without any member are removed.
+// It returns an error if any of the users does not exist or if the database is corrupted.
+func (c *Cache) CopyGroupMembership(fromUID, toUID uint32, args ...CopyGroupMembershipOption) error {
+	opts := copyGroupMembershipOptions{}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.update(func(tx *bbolt.Tx) (err error) {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		from, err := getFromBucket[userDB](buckets[userByIDBucketName], fromUID)
+		if err != nil {
+			return err
+		}
+		fromGroups, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], fromUID)
+		if err != nil {
+			return err
+		}
+		to, err := getFromBucket[userDB](buckets[userByIDBucketName], toUID)
+		if err != nil {
+			return err
+		}
+		toGroups, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], toUID)
+		if err != nil {
+			return err
+		}
+
+		if opts.primaryGroup && to.GID != from.GID {
+			to.GID = from.GID
+			to.ModifiedAt = c.now()
+			putUser(buckets, to)
+		}
+
+		// The primary group of the destination user comes first, followed by the supplementary groups of the source.
+		groups := []GroupDB{{GID: to.GID}}
+		for _, gid := range fromGroups.GIDs {
+			if gid == from.GID || gid == to.GID {
+				continue
+			}
+			groups = append(groups, GroupDB{GID: gid})
+		}
+
+		log.Debugf(context.Background(), "Copying group membership of user %q to user %q", from.Name, to.Name)
+		return updateUsersAndGroups(buckets, toUID, groups, toGroups.GIDs, c.largeGroupThreshold)
+	})
 }
 
 // updateUser updates both user buckets with userContent.
-func updateUser(buckets map[string]bucketWithName, userContent userDB) error {
-	existingUser, err := getFromBucket[userDB](buckets[userByIDBucketName], userContent.UID)
-	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+// The existing shell and gecos are kept if userContent doesn't have any, and the existing shell is always kept if
+// keepExistingShell is set. The existing disabled state is always kept.
+// If uniqueHomedirs is set, it fails if the homedir of a new user already belongs to a user with a different UID.
+func updateUser(buckets map[string]bucketWithName, userContent userDB, uniqueHomedirs, keepExistingShell bool) error {
+	existingUser, err := checkUser(buckets, userContent.UserDB, uniqueHomedirs)
+	if err != nil {
 		return err
 	}
 
-	// If a user with the same UID exists, we need to ensure that it's the same user or fail the update otherwise.
-	if existingUser.Name != "" && existingUser.Name != userContent.Name {
-		log.Errorf(context.TODO(), "UID for user %q already in use by user %q", userContent.Name, existingUser.Name)
-		return errors.New("UID already in use by a different user")
+	// The user may have been renamed by case only, which changes the key of records written before keys were folded.
+	if names := buckets[userByNameBucketName]; names.foldKeys && existingUser.Name != "" {
+		deleteUnfoldedName(names, existingUser.Name)
 	}
 
 	// Ensure that we use the same homedir as the one we have in cache.
 	if existingUser.Dir != "" && existingUser.Dir != userContent.Dir {
-		log.Warningf(context.TODO(), "User %q already has a homedir. The existing %q one will be kept instead of %q", userContent.Name, existingUser.Dir, userContent.Dir)
+		log.RateLimitedWarningf(context.TODO(), "User %q already has a homedir. The existing %q one will be kept instead of %q", userContent.Name, existingUser.Dir, userContent.Dir)
 		userContent.Dir = existingUser.Dir
 	}
 
+	// Ensure that we don't clear the shell and gecos if the update doesn't provide any.
+	if existingUser.Shell != "" && existingUser.Shell != userContent.Shell && (userContent.Shell == "" || keepExistingShell) {
+		log.Debugf(context.TODO(), "User %q already has a shell. The existing %q one will be kept instead of %q", userContent.Name, existingUser.Shell, userContent.Shell)
+		userContent.Shell = existingUser.Shell
+	}
+	if existingUser.Gecos != "" && userContent.Gecos == "" {
+		log.Debugf(context.TODO(), "No gecos provided for user %q. The existing %q one will be kept", userContent.Name, existingUser.Gecos)
+		userContent.Gecos = existingUser.Gecos
+	}
+
+	// Brokers don't know whether the user is disabled, so an update must not enable them again.
+	userContent.Disabled = existingUser.Disabled
+
 	// Update user buckets
 	log.Debug(context.Background(), fmt.Sprintf("Updating entry of user %q (UID: %d)", userContent.Name, userContent.UID))
-	updateBucket(buckets[userByIDBucketName], userContent.UID, userContent)
-	updateBucket(buckets[userByNameBucketName], userContent.Name, userContent)
+	userContent.Version = existingUser.Version
+	putUser(buckets, userContent)
 
 	return nil
 }
 
-// updateUser updates both group buckets with groupContent.
-func updateGroups(buckets map[string]bucketWithName, groupContents []GroupDB) error {
-	for _, groupContent := range groupContents {
-		existingGroup, err := getFromBucket[groupDB](buckets[groupByIDBucketName], groupContent.GID)
+// checkUser returns an error if usr can't be written, because its UID or name is already used by a different user,
+// or its homedir is if uniqueHomedirs is set. It returns the user with the same UID, which is empty if there is none.
+func checkUser(buckets map[string]bucketWithName, usr UserDB, uniqueHomedirs bool) (existingUser userDB, err error) {
+	existingUser, err = getFromBucket[userDB](buckets[userByIDBucketName], usr.UID)
+	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		return userDB{}, err
+	}
+
+	// If a user with the same UID exists, we need to ensure that it's the same user or fail the update otherwise.
+	names := buckets[userByNameBucketName]
+	if existingUser.Name != "" && !bytes.Equal(names.nameKey(existingUser.Name), names.nameKey(usr.Name)) {
+		log.Errorf(context.TODO(), "UID for user %q already in use by user %q", usr.Name, existingUser.Name)
+		return userDB{}, errors.New("UID already in use by a different user")
+	}
+
+	// Case-insensitive names are lossy, so we need to ensure that a name differing only by case is the same user.
+	if names.foldKeys {
+		sameName, err := getFromBucket[userDB](names, usr.Name)
 		if err != nil && !errors.Is(err, NoDataFoundError{}) {
+			return userDB{}, err
+		}
+		if sameName.Name != "" && sameName.UID != usr.UID {
+			log.Errorf(context.TODO(), "Name of user %q (UID: %d) already in use by user %q (UID: %d)", usr.Name, usr.UID, sameName.Name, sameName.UID)
+			return userDB{}, errors.New("name already in use by a different user")
+		}
+	}
+
+	// Users keeping their existing homedir are always allowed to.
+	if uniqueHomedirs && existingUser.Dir == "" {
+		owner, err := homedirOwner(buckets, usr.Dir)
+		if err != nil {
+			return userDB{}, err
+		}
+		if owner != nil && owner.UID != usr.UID {
+			log.Errorf(context.TODO(), "Homedir %q for user %q already in use by user %q", usr.Dir, usr.Name, owner.Name)
+			return userDB{}, fmt.Errorf("homedir %q already in use by UID %d", usr.Dir, owner.UID)
+		}
+	}
+
+	return existingUser, nil
+}
+
+// putUser writes the user in both user buckets, incrementing its version. It panics if we call it in RO transaction.
+func putUser(buckets map[string]bucketWithName, u userDB) {
+	u.Version++
+	updateBucket(buckets[userByIDBucketName], u.UID, u)
+	updateBucket(buckets[userByNameBucketName], u.Name, u)
+	deleteUnfoldedName(buckets[userByNameBucketName], u.Name)
+}
+
+// deleteUnfoldedName deletes the record keyed by the exact name, if the bucket folds its keys and it differs from the
+// folded one, as such records were written before keys were folded. It panics if we call it in RO transaction.
+func deleteUnfoldedName(bucket bucketWithName, name string) {
+	if string(bucket.nameKey(name)) == name {
+		return
+	}
+	if err := bucket.Delete([]byte(name)); err != nil {
+		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+	}
+}
+
+// foldUserNames rekeys the users written before names were case-insensitive by their lower-cased name. Users whose
+// lower-cased name is already used by another user are left as is, as only one of them can be found by name.
+func (c *Cache) foldUserNames() error {
+	var folded int
+	err := c.migrate(func(tx *bbolt.Tx) error {
+		names, err := c.getBucket(tx, userByNameBucketName)
+		if err != nil {
 			return err
 		}
 
-		// If a group with the same GID exists, we need to ensure that it's the same group or fail the update otherwise.
-		if existingGroup.Name != "" && existingGroup.Name != groupContent.Name {
-			log.Errorf(context.TODO(), "GID %d for group %q already in use by group %q", groupContent.GID, groupContent.Name, existingGroup.Name)
-			return fmt.Errorf("GID for group %q already in use by a different group", groupContent.Name)
+		// The bucket can't be modified while iterating over it, so we collect the records first.
+		records := make(map[string][]byte)
+		// The iteration can't fail, as we never return an error.
+		_ = names.ForEach(func(key, value []byte) error {
+			if !bytes.Equal(names.nameKey(string(key)), key) {
+				records[string(key)] = slices.Clone(value)
+			}
+			return nil
+		})
+
+		for name, value := range records {
+			key := names.nameKey(name)
+			if names.Get(key) != nil {
+				log.Warningf(context.TODO(), "User %q can't be looked up case-insensitively, as another user is named %q", name, key)
+				continue
+			}
+			if err := names.Put(key, value); err != nil {
+				panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
+			}
+			deleteUnfoldedName(names, name)
+			folded++
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("could not fold user names: %v", err)
+	}
+
+	if folded > 0 {
+		log.Infof(context.TODO(), "Rekeyed %d users by their lower-cased name", folded)
+	}
+	return nil
+}
+
+// homedirOwner returns the user whose homedir is dir, or nil if there is none.
+func homedirOwner(buckets map[string]bucketWithName, dir string) (owner *UserDB, err error) {
+	err = buckets[userByIDBucketName].ForEach(func(key, value []byte) error {
+		var u UserDB
+		if err := unmarshalValue(value, &u); err != nil {
+			return fmt.Errorf("can't unmarshal user in bucket %q for key %v: %v", userByIDBucketName, key, err)
+		}
+		if u.Dir == dir {
+			owner = &u
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return owner, nil
+}
+
+// resolveGroupConflicts applies policy to the groups whose name is already used by a group with a different GID.
+// It returns the groups to store, in the same order as groupContents. Any user record modified is stamped with now.
+func resolveGroupConflicts(buckets map[string]bucketWithName, groupContents []GroupDB, policy GroupConflictPolicy, now time.Time) ([]GroupDB, error) {
+	resolved := slices.Clone(groupContents)
+	for i, groupContent := range groupContents {
+		existingGroup, err := getFromBucket[groupDB](buckets[groupByNameBucketName], groupContent.Name)
+		// An invalid entry is not a conflict: it will be recreated by the update.
+		if err != nil {
+			continue
+		}
+		if existingGroup.GID == groupContent.GID {
+			continue
+		}
+
+		switch policy {
+		case GroupConflictPreferExisting:
+			log.Warningf(context.TODO(), "Group %q already exists with GID %d, ignoring new GID %d", groupContent.Name, existingGroup.GID, groupContent.GID)
+			resolved[i].GID = existingGroup.GID
+		case GroupConflictPreferNew:
+			log.Warningf(context.TODO(), "Group %q already exists with GID %d, moving it to new GID %d", groupContent.Name, existingGroup.GID, groupContent.GID)
+			if err := migrateGroupGID(buckets, groupContent.Name, existingGroup.GID, groupContent.GID, now); err != nil {
+				return nil, err
+			}
+		default:
+			log.Errorf(context.TODO(), "Group %q already exists with GID %d, can't use GID %d", groupContent.Name, existingGroup.GID, groupContent.GID)
+			return nil, fmt.Errorf("group %q already exists with a different GID", groupContent.Name)
+		}
+	}
+
+	return resolved, nil
+}
+
+// migrateGroupGID moves the group name from oldGID to newGID, along with its members and their references to it.
+// Members whose primary group is moved are stamped as modified at now.
+func migrateGroupGID(buckets map[string]bucketWithName, name string, oldGID, newGID uint32, now time.Time) error {
+	// The new GID must not be used by another group.
+	if err := checkGroupGID(buckets, name, newGID); err != nil {
+		return err
+	}
+
+	oldGroupToUsers, err := groupMembers(buckets, oldGID)
+	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		return err
+	}
+	newGroupToUsers, err := groupMembers(buckets, newGID)
+	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		return err
+	}
+	// The group stays large if any of them is.
+	large := largeGroupMembersBucket(buckets, oldGID) != nil || largeGroupMembersBucket(buckets, newGID) != nil
+
+	members := make(map[uint32]struct{}, len(newGroupToUsers.UIDs))
+	for _, uid := range newGroupToUsers.UIDs {
+		members[uid] = struct{}{}
+	}
+	for _, uid := range oldGroupToUsers.UIDs {
+		if _, ok := members[uid]; !ok {
+			members[uid] = struct{}{}
+			newGroupToUsers.UIDs = append(newGroupToUsers.UIDs, uid)
+		}
+
+		userToGroups, err := getFromBucket[userToGroupsDB](buckets[userToGroupsBucketName], uid)
+		if err != nil {
+			return err
+		}
+		var gids []uint32
+		for _, gid := range userToGroups.GIDs {
+			if gid == oldGID {
+				gid = newGID
+			}
+			if !slices.Contains(gids, gid) {
+				gids = append(gids, gid)
+			}
+		}
+		userToGroups.GIDs = gids
+		updateBucket(buckets[userToGroupsBucketName], uid, userToGroups)
+
+		u, err := getFromBucket[userDB](buckets[userByIDBucketName], uid)
+		if err != nil {
+			return err
+		}
+		if u.GID == oldGID {
+			u.GID = newGID
+			u.ModifiedAt = now
+			putUser(buckets, u)
+		}
+	}
+
+	deleteGroupMembers(buckets, oldGID)
+	// Delete calls fail if the transaction is read only, so we should panic if this function is called in that context.
+	oldGIDKey := []byte(strconv.FormatUint(uint64(oldGID), 10))
+	if err = buckets[groupByIDBucketName].Delete(oldGIDKey); err != nil {
+		panic(fmt.Sprintf("programming error: delete is not allowed in a RO transaction: %v", err))
+	}
+
+	if err := setGroupMembers(buckets, newGID, newGroupToUsers.UIDs, large); err != nil {
+		return err
+	}
+	updateBucket(buckets[groupByIDBucketName], newGID, groupDB{Name: name, GID: newGID})
+	updateBucket(buckets[groupByNameBucketName], name, groupDB{Name: name, GID: newGID})
+
+	return moveNestedGroup(buckets, oldGID, newGID)
+}
+
+// updateUser updates both group buckets with groupContent.
+func updateGroups(buckets map[string]bucketWithName, groupContents []GroupDB) error {
+	for _, groupContent := range groupContents {
+		if err := checkGroupGID(buckets, groupContent.Name, groupContent.GID); err != nil {
+			return err
 		}
 
 		// Update group buckets
@@ -105,23 +616,31 @@ func updateGroups(buckets map[string]bucketWithName, groupContents []GroupDB) er
 	return nil
 }
 
+// checkGroupGID returns an error if gid is already used by a group with a different name than name.
+func checkGroupGID(buckets map[string]bucketWithName, name string, gid uint32) error {
+	existingGroup, err := getFromBucket[groupDB](buckets[groupByIDBucketName], gid)
+	if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		return err
+	}
+
+	// If a group with the same GID exists, we need to ensure that it's the same group or fail the update otherwise.
+	if existingGroup.Name != "" && existingGroup.Name != name {
+		log.Errorf(context.TODO(), "GID %d for group %q already in use by group %q", gid, name, existingGroup.Name)
+		return fmt.Errorf("GID for group %q already in use by a different group", name)
+	}
+	return nil
+}
+
 // updateUserAndGroups updates the pivot table for user to groups and group to users. It handles any update
-// to groups uid is not part of anymore.
-func updateUsersAndGroups(buckets map[string]bucketWithName, uid uint32, groupContents []GroupDB, previousGIDs []uint32) error {
+// to groups uid is not part of anymore. Groups with more than largeGroupThreshold members store them in their own
+// bucket, so that adding one doesn't rewrite all the others.
+func updateUsersAndGroups(buckets map[string]bucketWithName, uid uint32, groupContents []GroupDB, previousGIDs []uint32, largeGroupThreshold int) error {
 	var currentGIDs []uint32
 	for _, groupContent := range groupContents {
 		currentGIDs = append(currentGIDs, groupContent.GID)
-		grpToUsers, err := getFromBucket[groupToUsersDB](buckets[groupToUsersBucketName], groupContent.GID)
-		// No data is valid and means that this is the first time we record it.
-		if err != nil && !errors.Is(err, NoDataFoundError{}) {
+		if err := addGroupMember(buckets, groupContent.GID, uid, largeGroupThreshold); err != nil {
 			return err
 		}
-
-		grpToUsers.GID = groupContent.GID
-		if !slices.Contains(grpToUsers.UIDs, uid) {
-			grpToUsers.UIDs = append(grpToUsers.UIDs, uid)
-		}
-		updateBucket(buckets[groupToUsersBucketName], groupContent.GID, grpToUsers)
 	}
 	updateBucket(buckets[userToGroupsBucketName], uid, userToGroupsDB{UID: uid, GIDs: currentGIDs})
 
@@ -151,17 +670,18 @@ func updateBucket[K uint32 | string](bucket bucketWithName, key K, value any) {
 	case uint32:
 		k = []byte(strconv.FormatUint(uint64(v), 10))
 	case string:
-		k = []byte(v)
+		k = bucket.nameKey(v)
 	default:
 		panic(fmt.Sprintf("unhandled type: %T", key))
 	}
 
-	if err = bucket.Put(k, data); err != nil {
+	if err = bucket.Put(k, encodeValue(data, bucket.compress, bucket.checksum)); err != nil {
 		panic(fmt.Sprintf("programming error: Put is not executed in a RW transaction: %v", err))
 	}
 }
 
 // UpdateBrokerForUser updates the last broker the user successfully authenticated with.
+// It returns a NoDataFoundError if the user is not in the database.
 func (c *Cache) UpdateBrokerForUser(username, brokerID string) error {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -171,12 +691,19 @@ func (c *Cache) UpdateBrokerForUser(username, brokerID string) error {
 		return err
 	}
 
-	err = c.db.Update(func(tx *bbolt.Tx) error {
-		bucket, err := getBucket(tx, userToBrokerBucketName)
+	err = c.update(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
 		if err != nil {
 			return err
 		}
-		updateBucket(bucket, u.UID, brokerID)
+
+		// The user may have been deleted since it was looked up, in which case its broker must not be recorded. It's
+		// checked by UID, as another user may have been given its name meanwhile.
+		if _, err := getFromBucket[userDB](buckets[userByIDBucketName], u.UID); err != nil {
+			return err
+		}
+
+		updateBucket(buckets[userToBrokerBucketName], u.UID, brokerID)
 		return nil
 	})
 
@@ -0,0 +1,55 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+
+	"go.etcd.io/bbolt"
+)
+
+// ValidateUserEntry runs UpdateUserEntry in a read-write transaction which is always rolled back, so that nothing is
+// written, and returns the error the update would fail with, or nil if it would succeed.
+// The warnings are the changes the update would make to the entry instead of failing, like keeping the existing
+// homedir of the user or the existing GID of a group with the GroupConflictPreferExisting policy.
+func (c *Cache) ValidateUserEntry(usr UserDB, groupContents []GroupDB) (warnings []string, err error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	now := c.now()
+	err = c.dryRun(func(tx *bbolt.Tx) error {
+		buckets, err := c.getAllBuckets(tx)
+		if err != nil {
+			return err
+		}
+
+		// The warnings are never nil, so that they are listed as empty rather than null once serialized.
+		warnings = []string{}
+		existingUser, err := getFromBucket[userDB](buckets[userByIDBucketName], usr.UID)
+		if err != nil && !errors.Is(err, NoDataFoundError{}) {
+			return err
+		}
+		if existingUser.Dir != "" && existingUser.Dir != usr.Dir {
+			warnings = append(warnings, fmt.Sprintf("user %q already has homedir %q, which would be kept instead of %q", usr.Name, existingUser.Dir, usr.Dir))
+		}
+		for _, g := range groupContents {
+			existingGroup, err := getFromBucket[groupDB](buckets[groupByNameBucketName], g.Name)
+			if err != nil || existingGroup.GID == g.GID {
+				continue
+			}
+			switch c.groupConflictPolicy {
+			case GroupConflictPreferExisting:
+				warnings = append(warnings, fmt.Sprintf("group %q already exists with GID %d, which would be kept instead of %d", g.Name, existingGroup.GID, g.GID))
+			case GroupConflictPreferNew:
+				warnings = append(warnings, fmt.Sprintf("group %q already exists with GID %d, which would be moved to %d", g.Name, existingGroup.GID, g.GID))
+			}
+		}
+
+		// The update itself runs the checks, so that the validation can't drift from them.
+		return c.updateUserEntry(buckets, userDB{UserDB: usr, LastLogin: now, ModifiedAt: now}, groupContents, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return warnings, nil
+}
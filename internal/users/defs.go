@@ -1,6 +1,8 @@
 package users
 
 import (
+	"errors"
+
 	"github.com/ubuntu/authd/internal/users/cache"
 )
 
@@ -41,6 +43,8 @@ type ShadowEntry struct {
 	PwdInactivity  int
 	MinPwdAge      int
 	ExpirationDate int
+	// Locked is set for disabled users, whose password is reported as locked.
+	Locked bool
 }
 
 // GroupEntry is the group information sent to the NSS service.
@@ -72,6 +76,7 @@ func shadowEntryFromUserDB(u cache.UserDB) ShadowEntry {
 		PwdInactivity:  u.PwdInactivity,
 		MinPwdAge:      u.MinPwdAge,
 		ExpirationDate: u.ExpirationDate,
+		Locked:         u.Disabled,
 	}
 }
 
@@ -86,3 +91,15 @@ func groupEntryFromGroupDB(g cache.GroupDB) GroupEntry {
 
 // ErrNoDataFound is the error returned when no entry is found in the cache.
 type ErrNoDataFound = cache.NoDataFoundError
+
+// ErrNoEntry is the error returned when no user or group matches a lookup.
+var ErrNoEntry = cache.ErrNoEntry
+
+// ErrAccountExpired is the error returned when the account of a user is expired.
+var ErrAccountExpired = errors.New("account expired")
+
+// ErrAccountDisabled is the error returned when the account of a user is disabled.
+var ErrAccountDisabled = errors.New("account disabled")
+
+// ErrMigrationInProgress is the error returned when reading the cache while a database migration is in progress.
+var ErrMigrationInProgress = cache.ErrMigrationInProgress
@@ -2,6 +2,8 @@ package users
 
 import (
 	"fmt"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -38,3 +40,30 @@ func TestGenerateID(t *testing.T) {
 		})
 	}
 }
+
+func TestGenerateIDInRange(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input string
+		idMin uint32
+		idMax uint32
+	}{
+		"Generated ID is within the defined range":           {input: "test", idMin: 100000, idMax: 199999},
+		"Generated ID is within a range far from 0":          {input: "test", idMin: 4000000000, idMax: 4000000010},
+		"Generated ID is within the full range":              {input: "test", idMin: 0, idMax: math.MaxUint32},
+		"Generate ID with minimum ID equal to maximum ID":    {input: "test", idMin: 1000, idMax: 1000},
+		"Generate same ID from input with upper case letter": {input: "TeSt", idMin: 100000, idMax: 199999},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got := generateIDInRange(tc.input, tc.idMin, tc.idMax)
+			require.GreaterOrEqual(t, got, tc.idMin, "generateIDInRange should return an ID in the range")
+			require.LessOrEqual(t, got, tc.idMax, "generateIDInRange should return an ID in the range")
+			require.Equal(t, generateIDInRange(strings.ToLower(tc.input), tc.idMin, tc.idMax), got,
+				"generateIDInRange should return the same ID for the same input, ignoring case")
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package users
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ubuntu/authd/internal/log"
+)
+
+// ExpireStaleUsers removes the users who haven't logged in for maxAge from the cache and returns their names.
+// A maxAge of 0 or less never expires any user.
+// Like the other maintenance methods, it waits for the migrations of the cache to complete first.
+func (m *Manager) ExpireStaleUsers(maxAge time.Duration) ([]string, error) {
+	m.cache.WaitForMigrations()
+	removed, err := m.cache.CleanupStaleUsers(maxAge)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range removed {
+		log.Infof(context.TODO(), "Expired user %q, who didn't log in for %v", name, maxAge)
+	}
+	return removed, nil
+}
+
+// CompactCache reclaims the space freed by the deleted records of the cache.
+func (m *Manager) CompactCache() error {
+	m.cache.WaitForMigrations()
+	before, after, err := m.cache.Compact()
+	if err != nil {
+		return err
+	}
+	log.Debugf(context.TODO(), "Compacted cache from %d to %d bytes", before, after)
+	return nil
+}
+
+// VerifyCache checks the integrity of the cache and returns an error listing the discrepancies found, if any.
+func (m *Manager) VerifyCache() error {
+	m.cache.WaitForMigrations()
+	r, err := m.cache.CheckIntegrity()
+	if err != nil {
+		return err
+	}
+	if !r.IsEmpty() {
+		return fmt.Errorf("cache integrity check found discrepancies: %+v", *r)
+	}
+	return nil
+}
+
+// PruneCache removes the dangling records of the cache reported by VerifyCache.
+func (m *Manager) PruneCache() error {
+	m.cache.WaitForMigrations()
+	r, err := m.cache.RepairIntegrity()
+	if err != nil {
+		return err
+	}
+	if !r.IsEmpty() {
+		log.Warningf(context.TODO(), "Pruned dangling cache records: %+v", *r)
+	}
+	return nil
+}
@@ -8,8 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ubuntu/authd/internal/log"
 	"github.com/ubuntu/authd/internal/users/cache"
@@ -35,14 +37,52 @@ var DefaultConfig = Config{
 
 // Manager is the manager for any user related operation.
 type Manager struct {
-	cache  *cache.Cache
-	config Config
+	cache            *cache.Cache
+	config           Config
+	defaultUserGroup *uint32
+}
+
+type options struct {
+	defaultUserGroup     *uint32
+	backgroundMigrations bool
+	cacheOptions         []cache.Option
+}
+
+// Option is the function signature used to tweak the manager creation.
+type Option func(*options)
+
+// WithDefaultUserGroup sets the GID of the group returned by GroupsForUser for the users without any group, so that
+// every user belongs to at least one group. The group must exist in the database.
+func WithDefaultUserGroup(gid uint32) Option {
+	return func(o *options) {
+		o.defaultUserGroup = &gid
+	}
+}
+
+// WithBackgroundMigrations makes NewManager return without waiting for the migrations of the cache, which are run in
+// the background. Lookups fail with ErrMigrationInProgress until they are done.
+func WithBackgroundMigrations() Option {
+	return func(o *options) {
+		o.backgroundMigrations = true
+	}
+}
+
+// WithCacheOptions passes opts to the cache.
+func WithCacheOptions(opts ...cache.Option) Option {
+	return func(o *options) {
+		o.cacheOptions = append(o.cacheOptions, opts...)
+	}
 }
 
 // NewManager creates a new user manager.
-func NewManager(config Config, cacheDir string) (m *Manager, err error) {
+func NewManager(config Config, cacheDir string, args ...Option) (m *Manager, err error) {
 	log.Debugf(context.TODO(), "Creating user manager with config: %+v", config)
 
+	opts := options{}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
 	// Check that the ID ranges are valid.
 	if config.UIDMin >= config.UIDMax {
 		return nil, errors.New("UID_MIN must be less than UID_MAX")
@@ -52,15 +92,28 @@ func NewManager(config Config, cacheDir string) (m *Manager, err error) {
 	}
 
 	m = &Manager{
-		config: config,
+		config:           config,
+		defaultUserGroup: opts.defaultUserGroup,
 	}
 
-	c, err := cache.New(cacheDir)
+	cacheOpts := slices.Clone(opts.cacheOptions)
+	if opts.backgroundMigrations {
+		cacheOpts = append(cacheOpts, cache.WithBackgroundMigrations())
+	}
+	c, err := cache.New(cacheDir, cacheOpts...)
 	if err != nil {
 		return nil, err
 	}
 	m.cache = c
 
+	if gid := m.defaultUserGroup; gid != nil {
+		c.WaitForMigrations()
+		if _, err := c.GroupMetaByID(*gid); err != nil {
+			_ = c.Close()
+			return nil, fmt.Errorf("invalid default user group %d: %w", *gid, err)
+		}
+	}
+
 	return m, nil
 }
 
@@ -69,14 +122,44 @@ func (m *Manager) Stop() error {
 	return m.cache.Close()
 }
 
+type updateUserOptions struct {
+	uidRange  *[2]uint32
+	cacheOpts []cache.UpdateUserEntryOption
+}
+
+// UpdateUserOption represents an optional function to override UpdateUser default values.
+type UpdateUserOption func(*updateUserOptions)
+
+// WithUIDRange makes UpdateUser generate the UID of new users in [minUID, maxUID] instead of the configured range,
+// like for the users of a broker authoritative for this range. Existing users keep their UID.
+func WithUIDRange(minUID, maxUID uint32) UpdateUserOption {
+	return func(o *updateUserOptions) {
+		o.uidRange = &[2]uint32{minUID, maxUID}
+	}
+}
+
+// WithAuthoritativeUIDRange makes UpdateUser generate the UID of new users in [minUID, maxUID], like WithUIDRange,
+// and reject users whose UID is outside of it, including existing ones.
+func WithAuthoritativeUIDRange(minUID, maxUID uint32) UpdateUserOption {
+	return func(o *updateUserOptions) {
+		o.uidRange = &[2]uint32{minUID, maxUID}
+		o.cacheOpts = append(o.cacheOpts, cache.WithAuthoritativeUIDRange(minUID, maxUID))
+	}
+}
+
 // UpdateUser updates the user information in the cache.
-func (m *Manager) UpdateUser(u UserInfo) (err error) {
+func (m *Manager) UpdateUser(u UserInfo, args ...UpdateUserOption) (err error) {
 	defer decorate.OnError(&err, "failed to update user %q", u.Name)
 
 	if u.Name == "" {
 		return errors.New("empty username")
 	}
 
+	opts := updateUserOptions{}
+	for _, arg := range args {
+		arg(&opts)
+	}
+
 	// Check if the user already exists in the database
 	oldUser, err := m.cache.UserByName(u.Name)
 	if err != nil && !errors.Is(err, cache.NoDataFoundError{}) {
@@ -89,7 +172,14 @@ func (m *Manager) UpdateUser(u UserInfo) (err error) {
 	}
 
 	// Generate the UID of the user unless a UID is already set.
-	if u.UID == 0 {
+	minUID, maxUID := m.config.UIDMin, m.config.UIDMax
+	if opts.uidRange != nil {
+		minUID, maxUID = opts.uidRange[0], opts.uidRange[1]
+	}
+	generatedUID := u.UID == 0
+	if u.UID == 0 && opts.uidRange != nil {
+		u.UID = generateIDInRange(u.Name, minUID, maxUID)
+	} else if u.UID == 0 {
 		u.UID = m.GenerateUID(u.Name)
 	}
 
@@ -108,7 +198,7 @@ func (m *Manager) UpdateUser(u UserInfo) (err error) {
 		}
 
 		// Check if the group already exists in the database
-		oldGroup, err := m.cache.GroupByName(g.Name)
+		oldGroup, err := m.cache.GroupMetaByName(g.Name)
 		if err != nil && !errors.Is(err, cache.NoDataFoundError{}) {
 			return err
 		}
@@ -135,10 +225,20 @@ func (m *Manager) UpdateUser(u UserInfo) (err error) {
 		groupContents = append(groupContents, cache.NewGroupDB(g.Name, *g.GID, nil))
 	}
 
-	// Update user information in the cache.
-	userDB := cache.NewUserDB(u.Name, u.UID, *u.Groups[0].GID, u.Gecos, u.Dir, u.Shell)
-	if err := m.cache.UpdateUserEntry(userDB, groupContents); err != nil {
-		return err
+	// Update user information in the cache. The UID generated for a new user may be reserved by the tombstone of
+	// another deleted user, in which case the next free UID of the range is used instead.
+	for firstUID := u.UID; ; {
+		userDB := cache.NewUserDB(u.Name, u.UID, *u.Groups[0].GID, u.Gecos, u.Dir, u.Shell)
+		err := m.cache.UpdateUserEntry(userDB, groupContents, opts.cacheOpts...)
+		if err == nil {
+			break
+		}
+		if !generatedUID || !errors.Is(err, cache.ErrUIDReserved) {
+			return err
+		}
+		if u.UID, err = m.nextFreeUID(u.UID, firstUID, minUID, maxUID); err != nil {
+			return err
+		}
 	}
 
 	// Update local groups.
@@ -237,6 +337,17 @@ func (m *Manager) AllUsers() ([]UserEntry, error) {
 	return usrEntries, err
 }
 
+// UsersInBatches calls fn with all users, in batches of at most batchSize users read one after the other.
+func (m *Manager) UsersInBatches(ctx context.Context, batchSize int, fn func(users []UserEntry) error) error {
+	return m.cache.UsersInBatches(ctx, batchSize, func(usrs []cache.UserDB) error {
+		usrEntries := make([]UserEntry, 0, len(usrs))
+		for _, usr := range usrs {
+			usrEntries = append(usrEntries, userEntryFromUserDB(usr))
+		}
+		return fn(usrEntries)
+	})
+}
+
 // GroupByName returns the group information for the given group name.
 func (m *Manager) GroupByName(groupname string) (GroupEntry, error) {
 	grp, err := m.cache.GroupByName(groupname)
@@ -255,6 +366,52 @@ func (m *Manager) GroupByID(gid uint32) (GroupEntry, error) {
 	return groupEntryFromGroupDB(grp), nil
 }
 
+// mergeGroupMembers returns the members, sorted, followed by the other members which are not part of them, sorted too,
+// without any duplicate, so that the result is stable.
+func mergeGroupMembers(members, otherMembers []string) []string {
+	merged := slices.Compact(slices.Sorted(slices.Values(members)))
+
+	var othersOnly []string
+	for _, u := range otherMembers {
+		if _, found := slices.BinarySearch(merged, u); found {
+			continue
+		}
+		othersOnly = append(othersOnly, u)
+	}
+	slices.Sort(othersOnly)
+
+	return append(merged, slices.Compact(othersOnly)...)
+}
+
+// GroupWithNestedMembers returns the group with the members of the groups nested in it added to its members, directly
+// or through other nested groups. Members are sorted, without any duplicate.
+func (m *Manager) GroupWithNestedMembers(g GroupEntry) (GroupEntry, error) {
+	uids, err := m.cache.FlattenedGroupMembers(g.GID)
+	if err != nil {
+		return GroupEntry{}, err
+	}
+
+	var members []string
+	for _, uid := range uids {
+		u, err := m.cache.UserByID(uid)
+		if errors.Is(err, cache.NoDataFoundError{}) {
+			continue
+		}
+		if err != nil {
+			return GroupEntry{}, err
+		}
+		members = append(members, u.Name)
+	}
+
+	g.Users = mergeGroupMembers(members, g.Users)
+	return g, nil
+}
+
+// GroupMembersByName calls fn with the member names of the given group, in batches of at most batchSize names.
+func (m *Manager) GroupMembersByName(groupname string, batchSize int, fn func(members []string) error) error {
+	return m.cache.GroupMembersByName(groupname, batchSize, fn)
+}
+
 // AllGroups returns all groups.
 func (m *Manager) AllGroups() ([]GroupEntry, error) {
 	grps, err := m.cache.AllGroups()
@@ -269,6 +426,40 @@ func (m *Manager) AllGroups() ([]GroupEntry, error) {
 	return grpEntries, nil
 }
 
+// GroupsInBatches calls fn with all groups, in batches of at most batchSize groups read one after the other.
+func (m *Manager) GroupsInBatches(ctx context.Context, batchSize int, fn func(groups []GroupEntry) error) error {
+	return m.cache.GroupsInBatches(ctx, batchSize, func(grps []cache.GroupDB) error {
+		grpEntries := make([]GroupEntry, 0, len(grps))
+		for _, grp := range grps {
+			grpEntries = append(grpEntries, groupEntryFromGroupDB(grp))
+		}
+		return fn(grpEntries)
+	})
+}
+
+// GroupsForUser returns the groups the user matching uid belongs to, including their primary group.
+// Users without any group get the default user group, if one is set.
+func (m *Manager) GroupsForUser(uid uint32) ([]GroupEntry, error) {
+	grps, err := m.cache.GroupsForUser(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(grps) == 0 && m.defaultUserGroup != nil {
+		grp, err := m.cache.GroupByID(*m.defaultUserGroup)
+		if err != nil {
+			return nil, fmt.Errorf("could not get default user group: %w", err)
+		}
+		grps = append(grps, grp)
+	}
+
+	var grpEntries []GroupEntry
+	for _, grp := range grps {
+		grpEntries = append(grpEntries, groupEntryFromGroupDB(grp))
+	}
+	return grpEntries, nil
+}
+
 // ShadowByName returns the shadow information for the given user name.
 func (m *Manager) ShadowByName(username string) (ShadowEntry, error) {
 	usr, err := m.cache.UserByName(username)
@@ -278,6 +469,39 @@ func (m *Manager) ShadowByName(username string) (ShadowEntry, error) {
 	return shadowEntryFromUserDB(usr), nil
 }
 
+// CheckAccountExpiration returns an error matching ErrAccountExpired if the account of the user matching username is
+// expired, as defined by its shadow information, so that they can't log in. Users not in the database are not
+// expired.
+func (m *Manager) CheckAccountExpiration(username string) error {
+	usr, err := m.cache.UserByName(username)
+	if errors.Is(err, ErrNoEntry) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if usr.IsExpired(time.Now()) {
+		return fmt.Errorf("%w: user %q", ErrAccountExpired, username)
+	}
+	return nil
+}
+
+// CheckAccountEnabled returns an error matching ErrAccountDisabled if the user matching username is disabled, so that
+// they can't log in. Users not in the database are not disabled.
+func (m *Manager) CheckAccountEnabled(username string) error {
+	usr, err := m.cache.UserByName(username)
+	if errors.Is(err, ErrNoEntry) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if usr.Disabled {
+		return fmt.Errorf("%w: user %q", ErrAccountDisabled, username)
+	}
+	return nil
+}
+
 // AllShadows returns all shadow entries.
 func (m *Manager) AllShadows() ([]ShadowEntry, error) {
 	usrs, err := m.cache.AllUsers()
@@ -292,6 +516,60 @@ func (m *Manager) AllShadows() ([]ShadowEntry, error) {
 	return shadowEntries, err
 }
 
+// ShadowsInBatches calls fn with all shadow entries, in batches of at most batchSize entries read one after the other.
+func (m *Manager) ShadowsInBatches(ctx context.Context, batchSize int, fn func(shadows []ShadowEntry) error) error {
+	return m.cache.UsersInBatches(ctx, batchSize, func(usrs []cache.UserDB) error {
+		shadowEntries := make([]ShadowEntry, 0, len(usrs))
+		for _, usr := range usrs {
+			shadowEntries = append(shadowEntries, shadowEntryFromUserDB(usr))
+		}
+		return fn(shadowEntries)
+	})
+}
+
+// GroupsSnapshot returns all groups, read at a single point in time. If withNestedMembers is true, the members of
+// the groups nested in each group, directly or through other nested groups, are added to its members, like
+// GroupWithNestedMembers, from the same point in time.
+func (m *Manager) GroupsSnapshot(withNestedMembers bool) ([]GroupEntry, error) {
+	grps, nestedMembers, err := m.cache.GroupsSnapshot(withNestedMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	var grpEntries []GroupEntry
+	for _, grp := range grps {
+		g := groupEntryFromGroupDB(grp)
+		if withNestedMembers {
+			g.Users = mergeGroupMembers(nestedMembers[g.GID], g.Users)
+		}
+		grpEntries = append(grpEntries, g)
+	}
+	return grpEntries, nil
+}
+
+// nextFreeUID returns the first UID after uid in [minUID, maxUID], wrapping around, which is not used by any user.
+// It returns an error if it gets back to firstUID without finding any.
+func (m *Manager) nextFreeUID(uid, firstUID, minUID, maxUID uint32) (uint32, error) {
+	for {
+		if uid >= maxUID || uid < minUID {
+			uid = minUID
+		} else {
+			uid++
+		}
+		if uid == firstUID {
+			return 0, fmt.Errorf("no free UID in range [%d, %d]", minUID, maxUID)
+		}
+
+		_, err := m.cache.UserByID(uid)
+		if errors.Is(err, cache.NoDataFoundError{}) {
+			return uid, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
 // GenerateUID deterministically generates an ID between from the given string, ignoring case,
 // in the range [UIDMin, UIDMax]. The generated ID is *not* guaranteed to be unique.
 func (m *Manager) GenerateUID(str string) uint32 {
@@ -322,3 +600,15 @@ func generateID(str string, minID, maxID uint32) uint32 {
 
 	return number
 }
+
+// generateIDInRange deterministically generates an ID in [minID, maxID] from the given string, ignoring case. Unlike
+// generateID, it maps the hash into the range, so that it terminates quickly for ranges far from 0, like the ones
+// brokers are authoritative for.
+func generateIDInRange(str string, minID, maxID uint32) uint32 {
+	hash := sha256.Sum256([]byte(strings.ToLower(str)))
+
+	// Computed in 64 bits, as the size of the full range doesn't fit in 32.
+	size := uint64(maxID) - uint64(minID) + 1
+	//nolint:gosec // The result is lower than size, which fits in an uint32 once added to minID.
+	return minID + uint32(uint64(binary.BigEndian.Uint32(hash[:4]))%size)
+}
@@ -23,18 +23,21 @@ func TestNewManager(t *testing.T) {
 		uidMax          uint32
 		gidMin          uint32
 		gidMax          uint32
+		defaultGroup    uint32
 
 		wantErr bool
 	}{
-		"Successfully create a new manager": {},
+		"Successfully create a new manager":                      {},
+		"Successfully create a new manager with a default group": {defaultGroup: 99999},
 
 		// Corrupted databases
 		"New recreates any missing buckets and delete unknowns": {dbFile: "database_with_unknown_bucket"},
 
-		"Error when database is corrupted":     {corruptedDbFile: true, wantErr: true},
-		"Error if cacheDir does not exist":     {dbFile: "-", wantErr: true},
-		"Error if UID_MIN is equal to UID_MAX": {uidMin: 1000, uidMax: 1000, wantErr: true},
-		"Error if GID_MIN is equal to GID_MAX": {gidMin: 1000, gidMax: 1000, wantErr: true},
+		"Error when database is corrupted":      {corruptedDbFile: true, wantErr: true},
+		"Error if cacheDir does not exist":      {dbFile: "-", wantErr: true},
+		"Error if UID_MIN is equal to UID_MAX":  {uidMin: 1000, uidMax: 1000, wantErr: true},
+		"Error if GID_MIN is equal to GID_MAX":  {gidMin: 1000, gidMax: 1000, wantErr: true},
+		"Error if default group does not exist": {defaultGroup: 12345, wantErr: true},
 	}
 	for name, tc := range tests {
 		t.Run(name, func(t *testing.T) {
@@ -69,7 +72,12 @@ func TestNewManager(t *testing.T) {
 				config.GIDMax = tc.gidMax
 			}
 
-			m, err := users.NewManager(config, cacheDir)
+			var args []users.Option
+			if tc.defaultGroup != 0 {
+				args = append(args, users.WithDefaultUserGroup(tc.defaultGroup))
+			}
+
+			m, err := users.NewManager(config, cacheDir, args...)
 			if tc.wantErr {
 				require.Error(t, err, "NewManager should return an error, but did not")
 				return
@@ -242,6 +250,58 @@ func TestUpdateUser(t *testing.T) {
 	}
 }
 
+func TestUpdateUserAfterDeletion(t *testing.T) {
+	// user1 and user3 get the same UID generated in this range, and user2 the other one.
+	const minUID, maxUID = 5000, 5001
+
+	tests := map[string]struct {
+		username      string
+		existingUsers []string
+
+		wantUID        uint32
+		wantTombstones []uint32
+		wantErr        bool
+	}{
+		"Deleted user gets its UID back":                         {username: "user1", wantUID: 5000},
+		"New user gets the next free UID if its UID is reserved": {username: "user3", wantUID: 5001, wantTombstones: []uint32{5000}},
+		"New user gets its UID if it is not reserved":            {username: "user2", wantUID: 5001, wantTombstones: []uint32{5000}},
+
+		"Error if all the free UIDs of the range are reserved": {username: "user3", existingUsers: []string{"user2"}, wantTombstones: []uint32{5000}, wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			m := newManagerForTests(t, t.TempDir(), users.WithCacheOptions(cache.WithUIDReuseDelay(-1)))
+			c := userstestutils.GetManagerCache(m)
+
+			err := m.UpdateUser(users.UserInfo{Name: "user1", Dir: "/home/user1"}, users.WithUIDRange(minUID, maxUID))
+			require.NoError(t, err, "Setup: UpdateUser should not return an error")
+			deleted, err := m.UserByName("user1")
+			require.NoError(t, err, "Setup: UserByName should not return an error")
+			require.NoError(t, c.DeleteUser(deleted.UID), "Setup: DeleteUser should not return an error")
+			for _, name := range tc.existingUsers {
+				err := m.UpdateUser(users.UserInfo{Name: name, Dir: "/home/" + name}, users.WithUIDRange(minUID, maxUID))
+				require.NoError(t, err, "Setup: UpdateUser should not return an error")
+			}
+
+			err = m.UpdateUser(users.UserInfo{Name: tc.username, Dir: "/home/" + tc.username}, users.WithUIDRange(minUID, maxUID))
+			requireErrorAssertions(t, err, nil, tc.wantErr)
+
+			tombstones, err := c.Tombstones()
+			require.NoError(t, err, "Tombstones should not return an error")
+			require.Equal(t, tc.wantTombstones, tombstones, "Tombstones should return the reserved UIDs")
+			if tc.wantErr {
+				return
+			}
+
+			got, err := m.UserByName(tc.username)
+			require.NoError(t, err, "UserByName should not return an error")
+			require.Equal(t, tc.wantUID, got.UID, "UpdateUser should give the expected UID to the user")
+		})
+	}
+}
+
 func TestBrokerForUser(t *testing.T) {
 	tests := map[string]struct {
 		username string
@@ -327,13 +387,17 @@ func TestUpdateBrokerForUser(t *testing.T) {
 //nolint:dupl // This is not a duplicate test
 func TestUserByName(t *testing.T) {
 	tests := map[string]struct {
-		username string
-		dbFile   string
+		username     string
+		dbFile       string
+		cacheOptions []cache.Option
 
 		wantErr     bool
 		wantErrType error
 	}{
 		"Successfully get user by name": {username: "user1", dbFile: "multiple_users_and_groups"},
+		"Successfully get user by name with another case when names are case-insensitive": {
+			username: "USER1", dbFile: "multiple_users_and_groups", cacheOptions: []cache.Option{cache.WithCaseInsensitiveNames(true)},
+		},
 
 		"Error if user does not exist":  {username: "doesnotexist", dbFile: "multiple_users_and_groups", wantErrType: cache.NoDataFoundError{}},
 		"Error if db has invalid entry": {username: "user1", dbFile: "invalid_entry_in_userByName", wantErr: true},
@@ -345,7 +409,7 @@ func TestUserByName(t *testing.T) {
 
 			cacheDir := t.TempDir()
 			cachetestutils.CreateDBFromYAML(t, filepath.Join("testdata", "db", tc.dbFile+".db.yaml"), cacheDir)
-			m := newManagerForTests(t, cacheDir)
+			m := newManagerForTests(t, cacheDir, users.WithCacheOptions(tc.cacheOptions...))
 
 			got, err := m.UserByName(tc.username)
 
@@ -536,6 +600,54 @@ func TestAllGroups(t *testing.T) {
 	}
 }
 
+func TestGroupsForUser(t *testing.T) {
+	tests := map[string]struct {
+		uid          uint32
+		dbFile       string
+		defaultGroup uint32
+
+		wantNoGroups bool
+		wantErr      bool
+		wantErrType  error
+	}{
+		"Successfully get groups of user":                       {uid: 2222, dbFile: "multiple_users_and_groups"},
+		"Successfully get groups of user with a default group":  {uid: 2222, dbFile: "multiple_users_and_groups", defaultGroup: 99999},
+		"Successfully get default group of user without groups": {uid: 1111, dbFile: "user_without_groups", defaultGroup: 99999},
+		"Get no groups for user without groups":                 {uid: 1111, dbFile: "user_without_groups", wantNoGroups: true},
+
+		"Error if user does not exist":  {uid: 5555, dbFile: "multiple_users_and_groups", wantErrType: cache.NoDataFoundError{}},
+		"Error if db has invalid entry": {uid: 1111, dbFile: "invalid_entry_in_userToGroups", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about the output of gpasswd in this test, but we still need to mock it.
+			_ = localgroupstestutils.SetupGPasswdMock(t, "empty.group")
+
+			cacheDir := t.TempDir()
+			cachetestutils.CreateDBFromYAML(t, filepath.Join("testdata", "db", tc.dbFile+".db.yaml"), cacheDir)
+			var args []users.Option
+			if tc.defaultGroup != 0 {
+				args = append(args, users.WithDefaultUserGroup(tc.defaultGroup))
+			}
+			m := newManagerForTests(t, cacheDir, args...)
+
+			got, err := m.GroupsForUser(tc.uid)
+
+			requireErrorAssertions(t, err, tc.wantErrType, tc.wantErr)
+			if tc.wantErrType != nil || tc.wantErr {
+				return
+			}
+			if tc.wantNoGroups {
+				require.Empty(t, got, "GroupsForUser should not return any group")
+				return
+			}
+
+			want := testutils.LoadWithUpdateFromGoldenYAML(t, got)
+			require.Equal(t, want, got, "GroupsForUser should return the expected groups, but did not")
+		})
+	}
+}
+
 //nolint:dupl // This is not a duplicate test
 func TestShadowByName(t *testing.T) {
 	tests := map[string]struct {
@@ -606,6 +718,49 @@ func TestAllShadows(t *testing.T) {
 	}
 }
 
+func TestGroupsSnapshot(t *testing.T) {
+	tests := map[string]struct {
+		dbFile            string
+		withNestedMembers bool
+
+		wantErr bool
+	}{
+		"Successfully get all groups":                     {dbFile: "multiple_users_and_groups"},
+		"Successfully get all groups with nested members": {dbFile: "nested_groups", withNestedMembers: true},
+
+		"Error if db has invalid entry": {dbFile: "invalid_entry_in_groupByID", wantErr: true},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			// We don't care about the output of gpasswd in this test, but we still need to mock it.
+			_ = localgroupstestutils.SetupGPasswdMock(t, "empty.group")
+
+			cacheDir := t.TempDir()
+			cachetestutils.CreateDBFromYAML(t, filepath.Join("testdata", "db", tc.dbFile+".db.yaml"), cacheDir)
+
+			m := newManagerForTests(t, cacheDir)
+
+			got, err := m.GroupsSnapshot(tc.withNestedMembers)
+
+			requireErrorAssertions(t, err, nil, tc.wantErr)
+			if tc.wantErr {
+				return
+			}
+
+			want, err := m.AllGroups()
+			require.NoError(t, err, "Setup: AllGroups should not return an error")
+			if tc.withNestedMembers {
+				for i, g := range want {
+					want[i], err = m.GroupWithNestedMembers(g)
+					require.NoError(t, err, "Setup: GroupWithNestedMembers should not return an error")
+				}
+			}
+
+			require.Equal(t, want, got, "GroupsSnapshot should return the same groups as AllGroups and GroupWithNestedMembers")
+		})
+	}
+}
+
 func TestMockgpasswd(t *testing.T) {
 	localgroupstestutils.Mockgpasswd(t)
 }
@@ -624,10 +779,10 @@ func requireErrorAssertions(t *testing.T, gotErr, wantErrType error, wantErr boo
 	require.NoError(t, gotErr, "Error should not be returned")
 }
 
-func newManagerForTests(t *testing.T, cacheDir string) *users.Manager {
+func newManagerForTests(t *testing.T, cacheDir string, args ...users.Option) *users.Manager {
 	t.Helper()
 
-	m, err := users.NewManager(users.DefaultConfig, cacheDir)
+	m, err := users.NewManager(users.DefaultConfig, cacheDir, args...)
 	require.NoError(t, err, "NewManager should not return an error, but did")
 
 	return m
@@ -3,9 +3,11 @@ use libc::gid_t;
 use libnss::group::{Group, GroupHooks};
 use libnss::interop::Response;
 use tokio::runtime::Builder;
-use tonic::Request;
+use tonic::transport::Channel;
+use tonic::{Request, Status};
 
 use crate::client::{self, authd};
+use authd::nss_client::NssClient;
 use authd::GroupEntry;
 
 pub struct AuthdGroup;
@@ -45,15 +47,43 @@ fn get_all_entries() -> Response<Vec<Group>> {
             }
         };
 
-        let mut req = Request::new(authd::Empty {});
+        // The entries are streamed in pages, so that large directories don't exceed the maximum message size. A page
+        // size of 0 lets authd choose it.
+        let mut req = Request::new(authd::PageRequest { page_size: 0 });
         req.set_timeout(REQUEST_TIMEOUT);
-        match client.get_group_entries(req).await {
-            Ok(r) => Response::Success(group_entries_to_groups(r.into_inner().entries)),
+        let mut stream = match client.get_group_entries_stream(req).await {
+            Ok(r) => r.into_inner(),
             Err(e) => {
                 info!("error when listing groups: {}", e.code());
-                super::grpc_status_to_nss_response(e)
+                return super::grpc_status_to_nss_response(e);
+            }
+        };
+
+        let mut entries = Vec::new();
+        loop {
+            match stream.message().await {
+                Ok(Some(page)) => entries.extend(page.entries),
+                Ok(None) => break,
+                Err(e) => {
+                    info!("error when listing groups: {}", e.code());
+                    return super::grpc_status_to_nss_response(e);
+                }
             }
         }
+
+        // The members of the truncated entries are only requested once the stream is over, so that a single stream is
+        // open at a time.
+        let mut groups = Vec::with_capacity(entries.len());
+        for entry in entries {
+            match with_all_members(&mut client, entry).await {
+                Ok(entry) => groups.push(group_entry_to_group(entry)),
+                Err(e) => {
+                    info!("error when listing groups: {}", e.code());
+                    return super::grpc_status_to_nss_response(e);
+                }
+            }
+        }
+        Response::Success(groups)
     })
 }
 
@@ -78,10 +108,22 @@ fn get_entry_by_gid(gid: gid_t) -> Response<Group> {
 
         let mut req = Request::new(authd::GetByIdRequest { id: gid });
         req.set_timeout(REQUEST_TIMEOUT);
-        match client.get_group_by_gid(req).await {
-            Ok(r) => Response::Success(group_entry_to_group(r.into_inner())),
+        let entry = match client.get_group_by_gid(req).await {
+            Ok(r) => r.into_inner(),
             Err(e) => {
                 info!("error when getting group by gid '{}': {}", gid, e.code());
+                return super::grpc_status_to_nss_response(e);
+            }
+        };
+
+        match with_all_members(&mut client, entry).await {
+            Ok(entry) => Response::Success(group_entry_to_group(entry)),
+            Err(e) => {
+                info!(
+                    "error when getting members of group with gid '{}': {}",
+                    gid,
+                    e.code()
+                );
                 super::grpc_status_to_nss_response(e)
             }
         }
@@ -109,20 +151,55 @@ fn get_entry_by_name(name: String) -> Response<Group> {
 
         let mut req = Request::new(authd::GetGroupByNameRequest { name: name.clone() });
         req.set_timeout(REQUEST_TIMEOUT);
-        match client.get_group_by_name(req).await {
-            Ok(r) => Response::Success(group_entry_to_group(r.into_inner())),
+        let entry = match client.get_group_by_name(req).await {
+            Ok(r) => r.into_inner(),
             Err(e) => {
                 info!(
                     "error when getting group by name '{}': {}",
                     name,
                     e.code().description()
                 );
+                return super::grpc_status_to_nss_response(e);
+            }
+        };
+
+        match with_all_members(&mut client, entry).await {
+            Ok(entry) => Response::Success(group_entry_to_group(entry)),
+            Err(e) => {
+                info!(
+                    "error when getting members of group '{}': {}",
+                    name,
+                    e.code().description()
+                );
                 super::grpc_status_to_nss_response(e)
             }
         }
     })
 }
 
+/// with_all_members returns the entry with all the members of its group. The entries of groups with too many members
+/// to be returned inline are truncated: their members are then read from StreamGroupMembers.
+async fn with_all_members(
+    client: &mut NssClient<Channel>,
+    mut entry: GroupEntry,
+) -> Result<GroupEntry, Status> {
+    if !entry.truncated {
+        return Ok(entry);
+    }
+
+    let mut req = Request::new(authd::GetGroupByNameRequest {
+        name: entry.name.clone(),
+    });
+    req.set_timeout(REQUEST_TIMEOUT);
+    let mut stream = client.stream_group_members(req).await?.into_inner();
+
+    entry.members.clear();
+    while let Some(batch) = stream.message().await? {
+        entry.members.extend(batch.members);
+    }
+    Ok(entry)
+}
+
 /// group_entry_to_group converts a GroupEntry to a libnss::Group.
 fn group_entry_to_group(entry: GroupEntry) -> Group {
     Group {
@@ -132,8 +209,3 @@ fn group_entry_to_group(entry: GroupEntry) -> Group {
         members: entry.members,
     }
 }
-
-/// group_entries_to_groups converts a Vec<GroupEntry> to a Vec<libnss::Group>.
-fn group_entries_to_groups(entries: Vec<GroupEntry>) -> Vec<Group> {
-    entries.into_iter().map(group_entry_to_group).collect()
-}
@@ -45,6 +45,12 @@ fn socket_path() -> String {
 fn grpc_status_to_nss_response<T>(status: Status) -> Response<T> {
     match status.code() {
         Code::NotFound => Response::NotFound,
+        // The ID is not managed by authd: let the next NSS source answer.
+        Code::OutOfRange => Response::NotFound,
+        // The daemon only returns Aborted for transient errors, like the cache being migrated: let the caller retry
+        // later. Unavailable is returned when the database is broken or on transport failures, like when the daemon
+        // is not running, so it's not one to retry.
+        Code::Aborted => Response::TryAgain,
         _ => Response::Unavail,
     }
 }
@@ -46,13 +46,27 @@ fn get_all_entries() -> Response<Vec<Passwd>> {
             }
         };
 
-        let mut req = Request::new(authd::Empty {});
+        // The entries are streamed in pages, so that large directories don't exceed the maximum message size. A page
+        // size of 0 lets authd choose it.
+        let mut req = Request::new(authd::PageRequest { page_size: 0 });
         req.set_timeout(REQUEST_TIMEOUT);
-        match client.get_passwd_entries(req).await {
-            Ok(r) => Response::Success(passwd_entries_to_passwds(r.into_inner().entries)),
+        let mut stream = match client.get_passwd_entries_stream(req).await {
+            Ok(r) => r.into_inner(),
             Err(e) => {
                 info!("error when listing passwd: {}", e.code());
-                super::grpc_status_to_nss_response(e)
+                return super::grpc_status_to_nss_response(e);
+            }
+        };
+
+        let mut passwds = Vec::new();
+        loop {
+            match stream.message().await {
+                Ok(Some(page)) => passwds.extend(passwd_entries_to_passwds(page.entries)),
+                Ok(None) => return Response::Success(passwds),
+                Err(e) => {
+                    info!("error when listing passwd: {}", e.code());
+                    return super::grpc_status_to_nss_response(e);
+                }
             }
         }
     })
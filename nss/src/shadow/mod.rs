@@ -40,13 +40,27 @@ fn get_all_entries() -> Response<Vec<Shadow>> {
             }
         };
 
-        let mut req = Request::new(authd::Empty {});
+        // The entries are streamed in pages, so that large directories don't exceed the maximum message size. A page
+        // size of 0 lets authd choose it.
+        let mut req = Request::new(authd::PageRequest { page_size: 0 });
         req.set_timeout(REQUEST_TIMEOUT);
-        match client.get_shadow_entries(req).await {
-            Ok(r) => Response::Success(shadow_entries_to_shadows(r.into_inner().entries)),
+        let mut stream = match client.get_shadow_entries_stream(req).await {
+            Ok(r) => r.into_inner(),
             Err(e) => {
                 info!("error when listing shadow: {}", e.code());
-                super::grpc_status_to_nss_response(e)
+                return super::grpc_status_to_nss_response(e);
+            }
+        };
+
+        let mut shadows = Vec::new();
+        loop {
+            match stream.message().await {
+                Ok(Some(page)) => shadows.extend(shadow_entries_to_shadows(page.entries)),
+                Ok(None) => return Response::Success(shadows),
+                Err(e) => {
+                    info!("error when listing shadow: {}", e.code());
+                    return super::grpc_status_to_nss_response(e);
+                }
             }
         }
     })
@@ -67,6 +67,7 @@ func sendIsAuthenticated(ctx context.Context, client authd.PAMClient, sessionID
 			access:    res.Access,
 			msg:       res.Msg,
 			challenge: challenge,
+			username:  res.GetUsername(),
 		}
 	}
 }
@@ -90,6 +91,7 @@ type isAuthenticatedResultReceived struct {
 	access    string
 	challenge *string
 	msg       string
+	username  string
 }
 
 // isAuthenticatedCancelled is the event to cancel the auth request.
@@ -295,7 +297,7 @@ func (m *authenticationModel) Update(msg tea.Msg) (authModel authenticationModel
 			if err != nil {
 				return *m, sendEvent(pamError{status: pam.ErrSystem, msg: err.Error()})
 			}
-			return *m, sendEvent(PamSuccess{BrokerID: m.currentBrokerID, msg: infoMsg})
+			return *m, sendEvent(PamSuccess{BrokerID: m.currentBrokerID, Username: msg.username, msg: infoMsg})
 
 		case brokers.AuthRetry:
 			errorMsg, err := dataToMsg(msg.msg)
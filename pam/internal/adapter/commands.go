@@ -20,8 +20,9 @@ func sendEvent(msg tea.Msg) tea.Cmd {
 	}
 }
 
-// startBrokerSession returns the sessionID after marking a broker as current.
-func startBrokerSession(client authd.PAMClient, brokerID, username string, mode authd.SessionMode) tea.Cmd {
+// startBrokerSession returns the sessionID after marking a broker as current. The tty scopes the broker selection
+// remembered by authd as the default one, and the session context is passed to the broker.
+func startBrokerSession(client authd.PAMClient, brokerID, username, tty string, sessionContext map[string]string, mode authd.SessionMode) tea.Cmd {
 	return func() tea.Msg {
 		if brokerID == brokers.LocalBrokerName {
 			return PamIgnore{LocalBrokerID: brokerID}
@@ -39,10 +40,12 @@ func startBrokerSession(client authd.PAMClient, brokerID, username string, mode
 		lang = strings.TrimSuffix(lang, ".UTF-8")
 
 		sbReq := &authd.SBRequest{
-			BrokerId: brokerID,
-			Username: username,
-			Lang:     lang,
-			Mode:     mode,
+			BrokerId:       brokerID,
+			Username:       username,
+			Lang:           lang,
+			Mode:           mode,
+			Tty:            tty,
+			SessionContext: sessionContext,
 		}
 
 		sbResp, err := client.SelectBroker(context.TODO(), sbReq)
@@ -50,6 +53,14 @@ func startBrokerSession(client authd.PAMClient, brokerID, username string, mode
 			return pamError{status: pam.ErrSystem, msg: fmt.Sprintf("can't select broker: %v", err)}
 		}
 
+		// authd may route the user to another broker than the selected one, like break-glass users to the local one.
+		if id := sbResp.GetBrokerId(); id != "" {
+			brokerID = id
+		}
+		if brokerID == brokers.LocalBrokerName {
+			return PamIgnore{LocalBrokerID: brokerID}
+		}
+
 		sessionID := sbResp.GetSessionId()
 		if sessionID == "" {
 			return pamError{status: pam.ErrSystem, msg: "no session ID returned by broker"}
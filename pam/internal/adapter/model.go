@@ -193,7 +193,7 @@ func (m *UIModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		log.Debugf(context.TODO(), "%#v", msg)
 		if m.sessionStartingForBroker == "" {
 			m.sessionStartingForBroker = msg.BrokerID
-			return m, startBrokerSession(m.Client, msg.BrokerID, m.username(), m.SessionMode)
+			return m, startBrokerSession(m.Client, msg.BrokerID, m.username(), m.tty(), m.sessionContext(), m.SessionMode)
 		}
 		if m.sessionStartingForBroker != msg.BrokerID {
 			return m, tea.Sequence(endSession(m.Client, m.currentSession), sendEvent(msg))
@@ -441,6 +441,33 @@ func (m UIModel) username() string {
 	return m.userSelectionModel.Username()
 }
 
+// tty returns the terminal the user is logging in from, as set in PAM_TTY, if any.
+func (m UIModel) tty() string {
+	tty, err := m.PamMTx.GetItem(pam.Tty)
+	if err != nil {
+		log.Debugf(context.TODO(), "Could not get PAM_TTY: %v", err)
+		return ""
+	}
+	return tty
+}
+
+// sessionContext returns the context of the session passed to the broker: the remote host and user the user is
+// logging in from, as set in PAM_RHOST and PAM_RUSER, if any.
+func (m UIModel) sessionContext() map[string]string {
+	sessionContext := make(map[string]string)
+	for key, item := range map[string]pam.Item{"rhost": pam.Rhost, "ruser": pam.Ruser} {
+		v, err := m.PamMTx.GetItem(item)
+		if err != nil {
+			log.Debugf(context.TODO(), "Could not get the %s of the session: %v", key, err)
+			continue
+		}
+		if v != "" {
+			sessionContext[key] = v
+		}
+	}
+	return sessionContext
+}
+
 // availableBrokers returns currently available brokers.
 func (m UIModel) availableBrokers() []*authd.ABResponse_BrokerInfo {
 	return m.brokerSelectionModel.availableBrokers
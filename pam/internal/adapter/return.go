@@ -20,6 +20,8 @@ type PamReturnError interface {
 // PamSuccess signals PAM module to return with provided pam.Success and Quit tea.Model.
 type PamSuccess struct {
 	BrokerID string
+	// Username is the name of the user as canonicalized by the broker, if authd reported it.
+	Username string
 	msg      string
 }
 
@@ -509,6 +509,16 @@ func (dc *DummyClient) EndSession(ctx context.Context, in *authd.ESRequest, opts
 	return &authd.Empty{}, nil
 }
 
+// ResumeSession simulates ResumeSession. The dummy client doesn't issue resumption tokens, so there is no session to
+// resume.
+func (dc *DummyClient) ResumeSession(ctx context.Context, in *authd.RSRequest, opts ...grpc.CallOption) (*authd.RSResponse, error) {
+	log.Debugf(ctx, "ResumeSession Called: %#v", in)
+	if in == nil {
+		return nil, errors.New("no input values provided")
+	}
+	return nil, fmt.Errorf("impossible to resume session with token %q, not found", in.ResumptionToken)
+}
+
 // SetDefaultBrokerForUser simulates SetDefaultBrokerForUser using the provided parameters.
 func (dc *DummyClient) SetDefaultBrokerForUser(ctx context.Context, in *authd.SDBFURequest, opts ...grpc.CallOption) (*authd.Empty, error) {
 	log.Debugf(ctx, "SetDefaultBrokerForUser Called: %#v", in)
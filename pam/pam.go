@@ -342,6 +342,11 @@ func (h *pamModule) handleAuthRequest(mode authd.SessionMode, mTx pam.ModuleTran
 		if err := mTx.SetData(authenticationBrokerIDKey, exitStatus.BrokerID); err != nil {
 			return err
 		}
+		// The next modules, and AcctMgmt setting the default broker of the user, must use the name the broker
+		// canonicalized, which is the one of the user entry authd provisioned.
+		if err := setCanonicalUsername(mTx, exitStatus.Username); err != nil {
+			return err
+		}
 		return nil
 
 	case adapter.PamIgnore:
@@ -358,6 +363,23 @@ func (h *pamModule) handleAuthRequest(mode authd.SessionMode, mTx pam.ModuleTran
 	return fmt.Errorf("%w: unknown exit code", pam.ErrSystem)
 }
 
+// setCanonicalUsername sets PAM_USER to the username canonicalized by the broker, if it differs from the one the
+// user typed.
+func setCanonicalUsername(mTx pam.ModuleTransaction, username string) error {
+	if username == "" {
+		return nil
+	}
+	user, err := mTx.GetItem(pam.User)
+	if err != nil {
+		return err
+	}
+	if user == username {
+		return nil
+	}
+	log.Infof(context.TODO(), "Broker canonicalized user %q as %q", user, username)
+	return mTx.SetItem(pam.User, username)
+}
+
 // AcctMgmt sets any used brokerID as default for the user.
 func (h *pamModule) AcctMgmt(mTx pam.ModuleTransaction, flags pam.Flags, args []string) (err error) {
 	parsedArgs, logArgsIssues := parseArgs(args)